@@ -0,0 +1,117 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// Admission admits a percentage of traffic regardless of its rate, which is typically
+// used for canary rollouts and load shedding. Admission instances are thread-safe.
+type Admission struct {
+	target atomic.Pointer[target] // current percentage, with an optional transition
+	clock  Clock                  // optional source of time
+}
+
+// target represents the admitted percentage, optionally ramped from a previous one
+type target struct {
+	percent     float64
+	from        float64
+	start, ramp int64 // optional transition from a previous percentage, in ns
+}
+
+// at returns the effective percentage at the specified time
+func (t *target) at(now int64) float64 {
+	if t.ramp == 0 || now >= t.start+t.ramp {
+		return t.percent
+	}
+
+	var progress float64
+	if now > t.start {
+		progress = float64(now-t.start) / float64(t.ramp)
+	}
+
+	return t.from + (t.percent-t.from)*progress
+}
+
+// AdmissionOption represents an option which can be applied to an admission on creation.
+type AdmissionOption func(*Admission)
+
+// WithAdmissionClock sets the clock used by the admission instead of the system time.
+func WithAdmissionClock(clock Clock) AdmissionOption {
+	return func(a *Admission) {
+		a.clock = clock
+	}
+}
+
+// NewAdmission creates a new admission which admits the specified percentage of calls,
+// between 0 and 100.
+func NewAdmission(percent float64, options ...AdmissionOption) *Admission {
+	a := new(Admission)
+	for _, opt := range options {
+		opt(a)
+	}
+
+	a.target.Store(&target{percent: clampPercent(percent)})
+	return a
+}
+
+// Limit returns true if the call was not selected for admission.
+func (a *Admission) Limit() bool {
+	switch percent := a.Percent(); {
+	case percent >= 100:
+		return false
+	case percent <= 0:
+		return true
+	default:
+		return rand.Float64()*100 >= percent
+	}
+}
+
+// Percent returns the percentage of calls currently admitted.
+func (a *Admission) Percent() float64 {
+	return a.target.Load().at(a.now())
+}
+
+// Update changes the admitted percentage immediately.
+func (a *Admission) Update(percent float64) {
+	a.target.Store(&target{percent: clampPercent(percent)})
+}
+
+// Ramp changes the admitted percentage linearly from the current one over the specified
+// duration, so a rollout or a brownout can progress without further calls.
+func (a *Admission) Ramp(percent float64, over time.Duration) {
+	now := a.now()
+	a.target.Store(&target{
+		percent: clampPercent(percent),
+		from:    a.target.Load().at(now),
+		start:   now,
+		ramp:    int64(over),
+	})
+}
+
+// now returns the current time of the admission's clock as unix nanoseconds
+func (a *Admission) now() int64 {
+	if a.clock == nil {
+		return time.Now().UnixNano()
+	}
+
+	return a.clock.Now()
+}
+
+// clampPercent ensures the percentage is between 0 and 100
+func clampPercent(percent float64) float64 {
+	switch {
+	case percent < 0:
+		return 0
+	case percent > 100:
+		return 100
+	default:
+		return percent
+	}
+}
@@ -0,0 +1,58 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Admission", func() {
+
+	admitted := func(a *Admission, n int) (count int) {
+		for i := 0; i < n; i++ {
+			if !a.Limit() {
+				count++
+			}
+		}
+		return
+	}
+
+	It("should admit everything or nothing", func() {
+		Expect(admitted(NewAdmission(100), 1000)).To(Equal(1000))
+		Expect(admitted(NewAdmission(0), 1000)).To(Equal(0))
+		Expect(NewAdmission(150).Percent()).To(Equal(100.0))
+		Expect(NewAdmission(-5).Percent()).To(Equal(0.0))
+	})
+
+	It("should admit a percentage of calls", func() {
+		a := NewAdmission(25)
+		Expect(admitted(a, 10000)).To(BeNumerically("~", 2500, 300))
+
+		a.Update(75)
+		Expect(admitted(a, 10000)).To(BeNumerically("~", 7500, 300))
+	})
+
+	It("should ramp the percentage over time", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		a := NewAdmission(0, WithAdmissionClock(clock))
+		a.Ramp(100, 10*time.Second)
+		Expect(a.Percent()).To(Equal(0.0))
+
+		clock.Advance(5 * time.Second)
+		Expect(a.Percent()).To(Equal(50.0))
+
+		a.Ramp(0, 5*time.Second)
+		clock.Advance(time.Second)
+		Expect(a.Percent()).To(BeNumerically("~", 40, 0.001))
+
+		clock.Advance(time.Minute)
+		Expect(a.Percent()).To(Equal(0.0))
+	})
+
+})
@@ -0,0 +1,39 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// APIKey returns a key function using the API key found in the header, such as
+// "X-API-Key" or "Authorization", in which case the scheme is stripped. The API key is
+// hashed with HMAC-SHA256 under the secret, so that raw secrets are never stored in the
+// limiters or exported in stats. Requests without an API key are keyed by their remote
+// address instead.
+func APIKey(header string, secret []byte) KeyFunc {
+	fallback := RemoteAddr()
+	return func(r *http.Request) string {
+		value := strings.TrimSpace(r.Header.Get(header))
+		if strings.EqualFold(header, "Authorization") {
+			if _, credentials, ok := strings.Cut(value, " "); ok {
+				value = strings.TrimSpace(credentials)
+			}
+		}
+
+		if value == "" {
+			return fallback(r)
+		}
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(value))
+		return "key:" + hex.EncodeToString(mac.Sum(nil)[:16])
+	}
+}
@@ -0,0 +1,49 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("APIKey", func() {
+	request := func(header, value string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "1.2.3.4:1000"
+		if value != "" {
+			r.Header.Set(header, value)
+		}
+		return r
+	}
+
+	It("should hash the API key", func() {
+		key := APIKey("X-API-Key", []byte("secret"))
+		k1 := key(request("X-API-Key", "my-secret-key"))
+		Expect(k1).To(HavePrefix("key:"))
+		Expect(k1).To(HaveLen(36))
+		Expect(k1).NotTo(ContainSubstring("my-secret-key"))
+		Expect(key(request("X-API-Key", "my-secret-key"))).To(Equal(k1))
+		Expect(key(request("X-API-Key", "other-key"))).NotTo(Equal(k1))
+		Expect(APIKey("X-API-Key", []byte("other"))(request("X-API-Key", "my-secret-key"))).NotTo(Equal(k1))
+	})
+
+	It("should strip the authorization scheme", func() {
+		key := APIKey("Authorization", nil)
+		Expect(key(request("Authorization", "Bearer abc"))).To(Equal(key(request("Authorization", "Token abc"))))
+		Expect(key(request("Authorization", "abc"))).To(Equal(key(request("Authorization", "Bearer abc"))))
+	})
+
+	It("should fall back on the remote address", func() {
+		key := APIKey("X-API-Key", nil)
+		Expect(key(request("X-API-Key", ""))).To(Equal("1.2.3.4"))
+		Expect(key(request("X-API-Key", "   "))).To(Equal("1.2.3.4"))
+	})
+
+})
@@ -0,0 +1,134 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+)
+
+// arrivals represents the number of calls which arrived within each of the recent
+// windows of the limiter's period, whether they were allowed or not
+type arrivals struct {
+	lock    sync.Mutex
+	start   uint64   // start of the current window, in unix ns
+	current uint64   // calls arrived within the current window
+	windows []uint64 // ring of the calls arrived within the completed windows
+	next    int      // position of the next completed window in the ring
+	filled  int      // number of completed windows in the ring
+	started bool     // whether the first window has started
+}
+
+// WithArrivalHistogram keeps the number of calls which arrived within each of the last
+// windows of the limiter's period, allowed or not, so that SuggestRate() can recommend a
+// limit from the observed traffic instead of a guess.
+func WithArrivalHistogram(windows int) Option {
+	if windows < 1 {
+		windows = 1
+	}
+
+	return func(rl *Limiter) {
+		rl.extend().arrivals = &arrivals{
+			windows: make([]uint64, windows),
+		}
+	}
+}
+
+// Arrivals returns the number of calls which arrived within each of the last completed
+// windows of the limiter's period, oldest first, or nil without an arrival histogram.
+func (rl *Limiter) Arrivals() []int {
+	a := rl.ext().arrivals
+	if a == nil {
+		return nil
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.advance(rl.now(), rl.unit)
+
+	out := make([]int, 0, a.filled)
+	for i := a.filled; i > 0; i-- {
+		out = append(out, int(a.windows[(a.next-i+len(a.windows))%len(a.windows)]))
+	}
+	return out
+}
+
+// SuggestRate returns the lowest rate which would have denied at most the percentage of
+// the calls observed by the arrival histogram, between 0 and 100, so 0 recommends a rate
+// which would have allowed every call. It returns zero without any completed window.
+func (rl *Limiter) SuggestRate(targetDenialPct float64) int {
+	windows := rl.Arrivals()
+	if len(windows) == 0 {
+		return 0
+	}
+
+	var total, peak int
+	for _, n := range windows {
+		total += n
+		if n > peak {
+			peak = n
+		}
+	}
+
+	// The denials only decrease as the rate grows, find the lowest rate within the target
+	target := clampPercent(targetDenialPct) / 100 * float64(total)
+	lo, hi := 0, peak
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if float64(denials(windows, mid)) <= target {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	if lo < 1 {
+		lo = 1
+	}
+	return lo
+}
+
+// denials returns the number of calls the rate would have denied within the windows
+func denials(windows []int, rate int) (denied int) {
+	for _, n := range windows {
+		if n > rate {
+			denied += n - rate
+		}
+	}
+	return
+}
+
+// observe records n calls which arrived at the specified time
+func (a *arrivals) observe(now, n, unit uint64) {
+	a.lock.Lock()
+	a.advance(now, unit)
+	a.current += n
+	a.lock.Unlock()
+}
+
+// advance completes the windows which elapsed by the specified time, must be called while
+// holding the lock
+func (a *arrivals) advance(now, unit uint64) {
+	if !a.started {
+		a.start, a.started = now, true
+		return
+	}
+
+	for i := 0; now-a.start >= unit && i < len(a.windows); i++ {
+		a.windows[a.next] = a.current
+		a.next = (a.next + 1) % len(a.windows)
+		if a.filled < len(a.windows) {
+			a.filled++
+		}
+
+		a.current = 0
+		a.start += unit
+	}
+
+	// Skip the idle windows which no longer fit in the ring
+	if now-a.start >= unit {
+		a.start = now - (now-a.start)%unit
+	}
+}
@@ -0,0 +1,57 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Arrivals", func() {
+	var clock *ManualClock
+	BeforeEach(func() {
+		clock = NewManualClock(time.Unix(0, 0))
+	})
+
+	It("should count the arrivals per window", func() {
+		rl := New(5, time.Second, WithClock(clock), WithArrivalHistogram(3))
+		Expect(rl.Arrivals()).To(BeEmpty())
+
+		for _, n := range []int{10, 20, 30, 40} {
+			for i := 0; i < n; i++ {
+				rl.Limit()
+			}
+			clock.Advance(time.Second)
+		}
+
+		Expect(rl.Arrivals()).To(Equal([]int{20, 30, 40}))
+		clock.Advance(10 * time.Second)
+		Expect(rl.Arrivals()).To(Equal([]int{0, 0, 0}))
+	})
+
+	It("should suggest a rate for the target denials", func() {
+		rl := New(1000, time.Second, WithClock(clock), WithArrivalHistogram(10))
+		Expect(rl.SuggestRate(0)).To(Equal(0))
+
+		for _, n := range []int{10, 20, 30, 40} {
+			rl.LimitN(n)
+			clock.Advance(time.Second)
+		}
+
+		Expect(rl.SuggestRate(0)).To(Equal(40))
+		Expect(rl.SuggestRate(10)).To(Equal(30))
+		Expect(rl.SuggestRate(100)).To(Equal(1))
+	})
+
+	It("should not collect arrivals by default", func() {
+		rl := New(10, time.Second)
+		rl.Limit()
+		Expect(rl.Arrivals()).To(BeNil())
+		Expect(rl.SuggestRate(5)).To(Equal(0))
+	})
+})
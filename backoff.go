@@ -0,0 +1,103 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Backoff computes retry delays from the actual state of a limiter rather than blind
+// exponential guesses. Each delay starts from the time until a token becomes available
+// and is spread by the recent density of denials, so that callers competing for the
+// same tokens retry at different times instead of in a storm. Backoff instances are
+// thread-safe.
+type Backoff struct {
+	lock    sync.Mutex
+	limiter *Limiter
+	min     time.Duration // minimum delay
+	max     time.Duration // maximum delay, unbounded if zero
+	last    uint64        // time of the last sample, in ns
+	denied  uint64        // denial counter of the limiter at the last sample
+	density float64       // moving average of the denials per token
+}
+
+// NewBackoff creates a new backoff for retries of calls denied by the limiter, with
+// delays between min and max. A zero max means the delays are unbounded.
+func NewBackoff(rl *Limiter, min, max time.Duration) *Backoff {
+	return &Backoff{
+		limiter: rl,
+		min:     min,
+		max:     max,
+		last:    rl.now(),
+		denied:  rl.stats.denied.Load(),
+	}
+}
+
+// Next returns the delay before retrying a call which failed with the error. If the
+// error is a *LimitedError, its RetryAfter is used as the base delay, otherwise the time
+// until the limiter has a token available.
+func (b *Backoff) Next(err error) time.Duration {
+	var limited *LimitedError
+	delay := b.limiter.estimate(0)
+	if errors.As(err, &limited) {
+		delay = limited.RetryAfter
+	}
+
+	if delay < b.min {
+		delay = b.min
+	}
+
+	// Spread the retries over as many token intervals as there are competing denials
+	if density := b.sample(); density > 0 {
+		rate, _ := b.limiter.limits(b.limiter.now())
+		if rate > 0 {
+			interval := float64(b.limiter.unit / rate)
+			delay += time.Duration(rand.Float64() * density * interval)
+		}
+	}
+
+	if b.max > 0 && delay > b.max {
+		delay = b.max
+	}
+	return delay
+}
+
+// Density returns the moving average of the number of denials per token of the
+// limiter, as of the last call to Next().
+func (b *Backoff) Density() float64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.density
+}
+
+// sample updates the moving average of the denial density and returns it
+func (b *Backoff) sample() float64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	rl := b.limiter
+	now, denied := rl.now(), rl.stats.denied.Load()
+	rate, _ := rl.limits(now)
+	if now <= b.last || rate == 0 {
+		return b.density
+	}
+
+	// Denials observed per token refilled over the elapsed time, weighted by how much of
+	// the limiter's period has elapsed since the last sample
+	elapsed := float64(now - b.last)
+	observed := float64(denied-b.denied) / (elapsed * float64(rate) / float64(rl.unit))
+	alpha := elapsed / float64(rl.unit)
+	if alpha > 1 {
+		alpha = 1
+	}
+
+	b.density += alpha * (observed - b.density)
+	b.last, b.denied = now, denied
+	return b.density
+}
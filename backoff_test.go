@@ -0,0 +1,61 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backoff", func() {
+
+	It("should start from the retry after of the error", func() {
+		b := NewBackoff(New(10, time.Second), time.Millisecond, 0)
+		err := &LimitedError{Err: ErrLimited, RetryAfter: 300 * time.Millisecond}
+		Expect(b.Next(err)).To(Equal(300 * time.Millisecond))
+	})
+
+	It("should fall back to the state of the limiter", func() {
+		rl := New(10, time.Second, WithTicks())
+		b := NewBackoff(rl, 0, 0)
+		for i := 0; i < 10; i++ {
+			rl.Limit()
+		}
+
+		Expect(b.Next(errors.New("boom"))).To(Equal(100 * time.Millisecond))
+	})
+
+	It("should respect the bounds", func() {
+		b := NewBackoff(New(10, time.Second), 50*time.Millisecond, 200*time.Millisecond)
+		Expect(b.Next(nil)).To(Equal(50 * time.Millisecond))
+		Expect(b.Next(&LimitedError{Err: ErrLimited, RetryAfter: time.Hour})).To(Equal(200 * time.Millisecond))
+	})
+
+	It("should spread retries by the denial density", func() {
+		rl := New(10, time.Second, WithTicks())
+		b := NewBackoff(rl, 0, 0)
+		for i := 0; i < 50; i++ {
+			rl.Limit()
+		}
+
+		rl.Tick(time.Second)
+		for i := 0; i < 50; i++ {
+			rl.Limit()
+		}
+
+		Expect(b.Next(nil)).To(BeNumerically(">=", 100*time.Millisecond))
+		Expect(b.Density()).To(BeNumerically("~", 8, 0.01))
+
+		var spread bool
+		for i := 0; i < 20 && !spread; i++ {
+			spread = b.Next(nil) > 200*time.Millisecond
+		}
+		Expect(spread).To(BeTrue())
+	})
+})
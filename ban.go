@@ -0,0 +1,94 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrBanned is returned by Wait() when the key is banned.
+var ErrBanned = errors.New("rate: key is banned")
+
+// autoban represents the configuration of automatic bans
+type autoban struct {
+	violations int           // number of denials which trigger a ban
+	window     time.Duration // window within which denials are counted
+	duration   time.Duration // duration of the ban
+}
+
+// strikes represents the violations of a single key
+type strikes struct {
+	start int64 // start of the counting window, in unix ns
+	count int   // denials within the counting window
+}
+
+// WithAutoBan automatically bans a key for the specified duration once it has been
+// denied a number of times within the window.
+func WithAutoBan(violations int, window, duration time.Duration) KeyedOption {
+	return func(k *Keyed) {
+		k.autoban = &autoban{
+			violations: violations,
+			window:     window,
+			duration:   duration,
+		}
+	}
+}
+
+// Ban bans the key for the specified duration, during which every call is denied.
+func (k *Keyed) Ban(key string, duration time.Duration) {
+	s := k.shard(key)
+	s.Lock()
+	s.bans[key] = time.Now().Add(duration).UnixNano()
+	delete(s.strikes, key)
+	s.Unlock()
+}
+
+// Unban lifts the ban of the key, if any.
+func (k *Keyed) Unban(key string) {
+	s := k.shard(key)
+	s.Lock()
+	delete(s.bans, key)
+	s.Unlock()
+}
+
+// Banned returns the keys which are currently banned, along with the expiry of their ban.
+func (k *Keyed) Banned() map[string]time.Time {
+	now := time.Now().UnixNano()
+	out := make(map[string]time.Time)
+	for i := range k.shards {
+		s := &k.shards[i]
+		s.Lock()
+		for key, until := range s.bans {
+			if until <= now {
+				delete(s.bans, key)
+				continue
+			}
+			out[key] = time.Unix(0, until)
+		}
+		s.Unlock()
+	}
+	return out
+}
+
+// strike records a violation of the key and bans it once the threshold is reached
+func (k *Keyed) strike(key string) {
+	now := time.Now()
+	s := k.shard(key)
+	s.Lock()
+	defer s.Unlock()
+
+	v, ok := s.strikes[key]
+	if !ok || now.UnixNano()-v.start >= int64(k.autoban.window) {
+		v = &strikes{start: now.UnixNano()}
+		s.strikes[key] = v
+	}
+
+	if v.count++; v.count >= k.autoban.violations {
+		s.bans[key] = now.Add(k.autoban.duration).UnixNano()
+		delete(s.strikes, key)
+	}
+}
@@ -0,0 +1,67 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ban", func() {
+
+	It("should ban a key", func() {
+		k := NewKeyed(10, time.Second)
+		k.Ban("a", time.Minute)
+		Expect(k.Limit("a")).To(BeTrue())
+		Expect(k.Wait(context.Background(), "a")).To(Equal(ErrBanned))
+		Expect(k.Limit("b")).To(BeFalse())
+		Expect(k.Banned()).To(HaveKey("a"))
+
+		k.Unban("a")
+		Expect(k.Limit("a")).To(BeFalse())
+		Expect(k.Banned()).To(BeEmpty())
+	})
+
+	It("should expire bans", func() {
+		k := NewKeyed(10, time.Second)
+		k.Ban("a", 10*time.Millisecond)
+		Expect(k.Limit("a")).To(BeTrue())
+		Eventually(func() bool { return k.Limit("a") }).Should(BeFalse())
+		Expect(k.Banned()).To(BeEmpty())
+	})
+
+	It("should ban automatically on repeated violations", func() {
+		k := NewKeyed(1, time.Minute, WithAutoBan(3, time.Minute, time.Hour))
+		Expect(k.Limit("a")).To(BeFalse())
+		Expect(k.Limit("a")).To(BeTrue())
+		Expect(k.Limit("a")).To(BeTrue())
+		Expect(k.Banned()).To(BeEmpty())
+
+		Expect(k.Limit("a")).To(BeTrue())
+		banned := k.Banned()
+		Expect(banned).To(HaveKey("a"))
+		Expect(banned["a"]).To(BeTemporally("~", time.Now().Add(time.Hour), time.Second))
+	})
+
+	It("should drop the strikes and lapsed bans of the removed keys", func() {
+		k := NewKeyed(1, time.Minute, WithAutoBan(3, time.Minute, time.Hour))
+		Expect(k.Limit("a")).To(BeFalse())
+		Expect(k.Limit("a")).To(BeTrue())
+		Expect(k.Limit("b")).To(BeFalse())
+		Expect(k.Limit("b")).To(BeTrue())
+		k.Ban("c", time.Nanosecond)
+
+		k.Remove("a")
+		Expect(k.shard("a").strikes).NotTo(HaveKey("a"))
+		Expect(k.Expire(0)).To(Equal(1))
+		Expect(k.shard("b").strikes).NotTo(HaveKey("b"))
+		Expect(k.shard("c").bans).NotTo(HaveKey("c"))
+	})
+
+})
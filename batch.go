@@ -0,0 +1,64 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// Batch represents a set of tokens acquired up front from a limiter. It allows tight
+// loops to consume tokens without touching the shared limiter on every iteration. A
+// batch is not thread-safe and should be owned by a single goroutine.
+type Batch struct {
+	limiter *Limiter
+	left    uint64
+}
+
+// Batch acquires up to n tokens at once and returns them as a batch. If fewer than n
+// tokens are currently available, the batch only holds what could be acquired.
+func (rl *Limiter) Batch(n int) *Batch {
+	if n < 1 {
+		return &Batch{limiter: rl}
+	}
+
+	return &Batch{
+		limiter: rl,
+		left:    rl.acquire(uint64(n)),
+	}
+}
+
+// Limit returns true if the batch has been exhausted, otherwise consumes a token.
+func (b *Batch) Limit() bool {
+	if b.left == 0 {
+		return true
+	}
+
+	b.left--
+	return false
+}
+
+// Len returns the number of tokens remaining in the batch.
+func (b *Batch) Len() int {
+	return int(b.left)
+}
+
+// Close returns the unused remainder of the batch back to the limiter.
+func (b *Batch) Close() error {
+	b.limiter.relinquish(b.left)
+	b.left = 0
+	return nil
+}
+
+// acquire takes up to n tokens at once and counts the ones taken as allowed
+func (rl *Limiter) acquire(n uint64) uint64 {
+	taken := rl.take(n)
+	rl.stats.allowed.Add(taken)
+	return taken
+}
+
+// relinquish returns n tokens acquired but never used, as if they were never acquired
+func (rl *Limiter) relinquish(n uint64) {
+	if n > 0 {
+		rl.stats.allowed.Add(-n)
+		rl.refund(n)
+	}
+}
@@ -0,0 +1,75 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Batch", func() {
+
+	It("should acquire tokens up front", func() {
+		rl := New(10, time.Minute)
+		b := rl.Batch(4)
+		Expect(b.Len()).To(Equal(4))
+
+		for i := 0; i < 4; i++ {
+			Expect(b.Limit()).To(BeFalse(), "on cycle %d", i)
+		}
+		Expect(b.Limit()).To(BeTrue())
+
+		var count int
+		for !rl.Limit() {
+			count++
+		}
+		Expect(count).To(Equal(6))
+	})
+
+	It("should acquire only what is available", func() {
+		rl := New(5, time.Minute)
+		Expect(rl.Batch(3).Len()).To(Equal(3))
+		Expect(rl.Batch(3).Len()).To(Equal(2))
+		Expect(rl.Batch(3).Len()).To(Equal(0))
+		Expect(rl.Batch(0).Len()).To(Equal(0))
+	})
+
+	It("should return the unused remainder", func() {
+		rl := New(5, time.Minute)
+		b := rl.Batch(5)
+		Expect(b.Limit()).To(BeFalse())
+		Expect(b.Limit()).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+
+		b.Close()
+		Expect(b.Len()).To(Equal(0))
+		Expect(b.Limit()).To(BeTrue())
+
+		var count int
+		for !rl.Limit() {
+			count++
+		}
+		Expect(count).To(Equal(3))
+	})
+
+})
+
+// --------------------------------------------------------------------
+
+func BenchmarkBatch(b *testing.B) {
+	rl := New(1000000000, time.Second)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 100 {
+		batch := rl.Batch(100)
+		for !batch.Limit() {
+		}
+		batch.Close()
+	}
+}
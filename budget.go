@@ -0,0 +1,128 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Budget represents tokens reserved for a batch job, spread evenly over a window. While
+// the budget is held, the rate left to the other callers of the limiter is lowered
+// accordingly, so bulk work coexists predictably with interactive traffic. Budget
+// instances are thread-safe.
+type Budget struct {
+	limiter  *Limiter
+	lock     sync.Mutex
+	rate     uint64        // rate reserved on the limiter
+	n        int           // number of tokens reserved
+	taken    int           // number of tokens taken so far
+	start    time.Time     // start of the window
+	interval time.Duration // interval between two tokens
+	timer    *time.Timer   // releases the budget at the end of the window
+	once     sync.Once
+}
+
+// AcquireBudget reserves n tokens spread over the coming window for a batch job, which
+// pulls them with Next(). The reserved rate is rounded up to a whole rate of the limiter
+// and returned to it when the window ends or on Release(). It fails with ErrBudget if
+// the limiter does not have enough rate left.
+func (rl *Limiter) AcquireBudget(n int, window time.Duration) (*Budget, error) {
+	if n < 1 || window <= 0 {
+		return nil, ErrBudget
+	}
+
+	// Convert the budget into a rate per period of the limiter, rounded up
+	units := rl.units(uint64(n))
+	rate := units / uint64(window)
+	if units%uint64(window) != 0 {
+		rate++
+	}
+	for {
+		current, _ := rl.limits(rl.now())
+		reserved := rl.reserved.Load()
+		if reserved+rate > current {
+			return nil, ErrBudget
+		}
+
+		if rl.reserved.CompareAndSwap(reserved, reserved+rate) {
+			break
+		}
+	}
+
+	b := &Budget{
+		limiter:  rl,
+		rate:     rate,
+		n:        n,
+		start:    time.Now(),
+		interval: window / time.Duration(n),
+	}
+	b.lock.Lock()
+	b.timer = time.AfterFunc(window, b.Release)
+	b.lock.Unlock()
+	return b, nil
+}
+
+// Next blocks until the next token of the budget is due or the context is done. It fails
+// with ErrExhausted once every token was taken or the budget was released.
+func (b *Budget) Next(ctx context.Context) error {
+	b.lock.Lock()
+	if b.taken >= b.n {
+		b.lock.Unlock()
+		return ErrExhausted
+	}
+
+	due := b.start.Add(time.Duration(b.taken) * b.interval)
+	b.taken++
+	b.lock.Unlock()
+
+	delay := time.Until(due)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.lock.Lock()
+		b.taken--
+		b.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Remaining returns the number of tokens of the budget not taken yet.
+func (b *Budget) Remaining() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.n - b.taken
+}
+
+// Release returns the reserved rate to the limiter, the tokens not taken yet are lost.
+func (b *Budget) Release() {
+	b.once.Do(func() {
+		b.limiter.reserved.Add(-b.rate)
+		b.limiter.wake()
+
+		b.lock.Lock()
+		b.timer.Stop()
+		b.taken = b.n
+		b.lock.Unlock()
+	})
+}
+
+// minUint64 returns the smaller of two integers
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,76 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Budget", func() {
+
+	It("should pace the tokens over the window", func() {
+		rl := New(1000, time.Second)
+		b, err := rl.AcquireBudget(4, 40*time.Millisecond)
+		Expect(err).NotTo(HaveOccurred())
+		defer b.Release()
+
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			Expect(b.Next(context.Background())).To(Succeed())
+		}
+
+		Expect(time.Since(start)).To(BeNumerically(">=", 30*time.Millisecond))
+		Expect(b.Remaining()).To(BeZero())
+		Expect(b.Next(context.Background())).To(Equal(ErrExhausted))
+	})
+
+	It("should lower the rate of other callers while held", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock))
+		b, err := rl.AcquireBudget(60, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rl.reserved.Load()).To(Equal(uint64(1)))
+
+		Expect(rl.LimitN(10)).To(BeFalse())
+		clock.Advance(time.Second)
+		Expect(rl.Remaining()).To(Equal(9))
+
+		b.Release()
+		b.Release()
+		Expect(rl.reserved.Load()).To(BeZero())
+		Expect(b.Next(context.Background())).To(Equal(ErrExhausted))
+
+		clock.Advance(100 * time.Millisecond)
+		Expect(rl.Remaining()).To(Equal(10))
+	})
+
+	It("should refuse budgets exceeding the rate", func() {
+		rl := New(10, time.Second)
+		_, err := rl.AcquireBudget(20, time.Second)
+		Expect(err).To(Equal(ErrBudget))
+
+		b, err := rl.AcquireBudget(10, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		defer b.Release()
+
+		_, err = rl.AcquireBudget(1, time.Second)
+		Expect(err).To(Equal(ErrBudget))
+		_, err = rl.AcquireBudget(0, time.Second)
+		Expect(err).To(Equal(ErrBudget))
+	})
+
+	It("should release the budget at the end of the window", func() {
+		rl := New(1000, time.Second)
+		_, err := rl.AcquireBudget(5, 10*time.Millisecond)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(rl.reserved.Load).Should(BeZero())
+	})
+
+})
@@ -0,0 +1,118 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"math"
+	"time"
+)
+
+// LimitMany returns, for every key, whether its rate was exceeded or it is banned, as
+// Limit() does. The shard locks are taken once per shard rather than once per key, for
+// gateways which evaluate several keys per request, such as the user, the organization,
+// the route and a global one.
+func (k *Keyed) LimitMany(keys []string) []bool {
+	limiters, banned := k.lookupMany(keys)
+	limited := make([]bool, len(keys))
+	for i, key := range keys {
+		limited[i] = banned[i] || limiters[i].Limit()
+		if k.activity != nil {
+			k.observe(key, limited[i])
+		}
+		if limited[i] && !banned[i] && k.autoban != nil {
+			k.strike(key)
+		}
+	}
+	return limited
+}
+
+// UpdateMany updates the rate of every key, creating the limiters of the keys which do
+// not exist yet, along with the options of the patterns they match, and takes the shard
+// locks once per shard. Rates are converted to the period of the limiter of the key, with
+// at least one token per period. Invalid rates, which were not created with NewRate(),
+// are ignored.
+func (k *Keyed) UpdateMany(rates map[string]Rate) {
+	var groups [shards][]string
+	for key, rate := range rates {
+		if rate.n > 0 && rate.per > 0 {
+			i := k.index(key)
+			groups[i] = append(groups[i], key)
+		}
+	}
+
+	for i, keys := range groups {
+		if len(keys) == 0 {
+			continue
+		}
+
+		s := &k.shards[i]
+		s.Lock()
+		for _, key := range keys {
+			if rl, ok := s.limiters[key]; ok {
+				rl.UpdateRate(rates[key].in(time.Duration(rl.unit)))
+			} else {
+				_, per, options := k.template(key)
+				s.limiters[key] = New(rates[key].in(per), per, options...)
+			}
+		}
+		s.Unlock()
+	}
+}
+
+// lookupMany returns the limiters for the keys, creating those which do not exist yet,
+// along with whether each key is currently banned
+func (k *Keyed) lookupMany(keys []string) ([]*Limiter, []bool) {
+	var groups [shards][]int
+	for i, key := range keys {
+		shard := k.index(key)
+		groups[shard] = append(groups[shard], i)
+	}
+
+	limiters := make([]*Limiter, len(keys))
+	banned := make([]bool, len(keys))
+	now := time.Now().UnixNano()
+	for shard, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+
+		s, missing := &k.shards[shard], false
+		s.RLock()
+		for _, i := range group {
+			limiters[i] = s.limiters[keys[i]]
+			until, ok := s.bans[keys[i]]
+			banned[i] = ok && now < until
+			missing = missing || limiters[i] == nil
+		}
+		s.RUnlock()
+
+		if !missing {
+			continue
+		}
+
+		s.Lock()
+		for _, i := range group {
+			if limiters[i] != nil {
+				continue
+			}
+
+			if limiters[i] = s.limiters[keys[i]]; limiters[i] == nil {
+				limiters[i] = k.spawn(keys[i])
+				s.limiters[keys[i]] = limiters[i]
+			}
+		}
+		s.Unlock()
+	}
+	return limiters, banned
+}
+
+// in returns the number of tokens of the rate over the specified period, at least one
+func (r Rate) in(per time.Duration) int {
+	if n := int(math.Round(float64(r.n) * float64(per) / float64(r.per))); n > 1 {
+		return n
+	}
+	return 1
+}
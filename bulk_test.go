@@ -0,0 +1,80 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bulk", func() {
+
+	It("should limit many keys at once", func() {
+		k := NewKeyed(1, time.Hour)
+		k.Limit("org")
+		k.Ban("banned", time.Minute)
+
+		Expect(k.LimitMany([]string{"user", "org", "banned", "global"})).To(Equal([]bool{false, true, true, false}))
+		Expect(k.LimitMany([]string{"new", "new"})).To(Equal([]bool{false, true}))
+		Expect(k.Len()).To(Equal(5))
+	})
+
+	It("should strike the limited keys", func() {
+		k := NewKeyed(1, time.Hour, WithAutoBan(2, time.Minute, time.Minute))
+		k.LimitMany([]string{"user", "user", "user"})
+
+		_, banned := k.lookup("user")
+		Expect(banned).To(BeTrue())
+	})
+
+	It("should update many keys at once", func() {
+		k := NewKeyed(1, time.Second)
+		k.Get("user")
+
+		k.UpdateMany(map[string]Rate{
+			"user":    MustRate(10, time.Second),
+			"org":     MustRate(120, time.Minute),
+			"slow":    MustRate(1, time.Hour),
+			"invalid": {},
+		})
+
+		Expect(k.Len()).To(Equal(3))
+		Expect(k.Get("user").View().Rate()).To(Equal(10.0))
+		Expect(k.Get("org").View().Rate()).To(Equal(2.0))
+		Expect(k.Get("slow").View().Rate()).To(Equal(1.0))
+	})
+
+	It("should create the keys with the options of their pattern", func() {
+		k := NewKeyed(1, time.Second, WithPattern("internal-*", 100, time.Minute, WithName("internal")))
+		k.UpdateMany(map[string]Rate{
+			"internal-a": MustRate(10, time.Second),
+		})
+
+		rl := k.Get("internal-a")
+		Expect(rl.Name()).To(Equal("internal"))
+		Expect(rl.View().Rate()).To(Equal(10.0))
+		Expect(rl.unit).To(Equal(uint64(time.Minute)))
+	})
+})
+
+// --------------------------------------------------------------------
+
+func BenchmarkLimitMany(b *testing.B) {
+	k := NewKeyed(1000000000, time.Second)
+	keys := make([]string, 16)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k.LimitMany(keys)
+	}
+}
@@ -0,0 +1,70 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+// burst detects arrival rates sustained above a multiple of the configured rate
+type burst struct {
+	lock     sync.Mutex
+	multiple float64             // multiple of the rate considered as a burst
+	sustain  uint64              // duration a burst must last before alerting, in ns
+	alert    func(ratio float64) // callback fired once per burst
+	start    uint64              // start of the current window, in unix ns
+	count    uint64              // arrivals within the current window
+	since    uint64              // start of the ongoing burst, in unix ns
+	bursting bool                // whether a burst is ongoing
+	fired    bool                // whether the ongoing burst was alerted
+}
+
+// WithBurstAlert calls the function when the arrival rate, whether the calls are allowed
+// or denied, exceeds a multiple of the configured rate for longer than the duration. The
+// arrival rate is measured over consecutive windows of the limiter's period and the
+// function receives the ratio of the last window. It is called once per burst.
+func WithBurstAlert(multiple float64, sustain time.Duration, alert func(ratio float64)) Option {
+	return func(rl *Limiter) {
+		rl.extend().burst = &burst{
+			multiple: multiple,
+			sustain:  uint64(sustain),
+			alert:    alert,
+		}
+	}
+}
+
+// observe records n arrivals at the specified time
+func (b *burst) observe(now, n, rate, unit uint64) {
+	var ratio float64
+	var fire bool
+
+	b.lock.Lock()
+	if now-b.start >= unit {
+		ratio = float64(b.count) / float64(rate)
+		switch {
+		case ratio <= b.multiple || now-b.start >= 2*unit:
+			b.bursting, b.fired = false, false
+		case !b.bursting:
+			b.bursting, b.since = true, b.start
+			fallthrough
+		default:
+			if end := b.start + unit; !b.fired && end-b.since >= b.sustain {
+				b.fired, fire = true, true
+			}
+		}
+
+		b.start = now
+		b.count = 0
+	}
+
+	b.count += n
+	b.lock.Unlock()
+
+	if fire {
+		b.alert(ratio)
+	}
+}
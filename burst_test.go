@@ -0,0 +1,80 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Burst", func() {
+
+	var alerts []float64
+	alert := func(ratio float64) {
+		alerts = append(alerts, ratio)
+	}
+
+	BeforeEach(func() {
+		alerts = nil
+	})
+
+	It("should alert on sustained bursts", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock), WithBurstAlert(2, 3*time.Second, alert))
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 30; j++ {
+				rl.Limit()
+			}
+
+			Expect(alerts).To(BeEmpty())
+			clock.Advance(time.Second)
+		}
+
+		rl.Limit()
+		Expect(alerts).To(Equal([]float64{3}))
+
+		// Only once per burst
+		for j := 0; j < 30; j++ {
+			rl.Limit()
+		}
+		clock.Advance(time.Second)
+		rl.Limit()
+		Expect(alerts).To(HaveLen(1))
+	})
+
+	It("should not alert on short bursts", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock), WithBurstAlert(2, 3*time.Second, alert))
+		for i := 0; i < 5; i++ {
+			n := 30
+			if i == 2 {
+				n = 5
+			}
+
+			for j := 0; j < n; j++ {
+				rl.Limit()
+			}
+			clock.Advance(time.Second)
+		}
+
+		rl.Limit()
+		Expect(alerts).To(BeEmpty())
+	})
+
+	It("should reset after a quiet period", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock), WithBurstAlert(2, 2*time.Second, alert))
+		rl.LimitN(30)
+		clock.Advance(time.Second)
+		rl.LimitN(30)
+		clock.Advance(5 * time.Second)
+		rl.Limit()
+		Expect(alerts).To(BeEmpty())
+	})
+
+})
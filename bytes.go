@@ -0,0 +1,167 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ByteLimiter limits a throughput in bytes per second, with a burst in bytes. Unlike a
+// Limiter, it is tuned for large token counts and never overflows, even at rates of
+// many gigabytes per second. ByteLimiter instances are thread-safe.
+type ByteLimiter struct {
+	lock   sync.Mutex
+	rate   float64 // bytes per second
+	burst  float64 // maximum number of bytes available at once
+	tokens float64 // bytes currently available, negative when in debt
+	last   int64   // time of the last refill, in unix ns
+	clock  Clock   // optional source of time
+}
+
+// ByteOption represents an option which can be applied to a byte limiter on creation.
+type ByteOption func(*ByteLimiter)
+
+// WithByteClock sets the clock used by the byte limiter instead of the system time.
+func WithByteClock(clock Clock) ByteOption {
+	return func(b *ByteLimiter) {
+		b.clock = clock
+	}
+}
+
+// NewByteLimiter creates a new byte limiter allowing the rate in bytes per second, with
+// the burst in bytes. A burst lower than the rate defaults to one second of throughput.
+func NewByteLimiter(bytesPerSecond, burst int64, options ...ByteOption) *ByteLimiter {
+	if bytesPerSecond < 1 {
+		bytesPerSecond = 1
+	}
+	if burst < bytesPerSecond {
+		burst = bytesPerSecond
+	}
+
+	b := &ByteLimiter{
+		rate:   float64(bytesPerSecond),
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+
+	for _, opt := range options {
+		opt(b)
+	}
+
+	b.last = b.now()
+	return b
+}
+
+// LimitBytes returns true if transferring n bytes would exceed the rate, otherwise it
+// consumes them.
+func (b *ByteLimiter) LimitBytes(n int64) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.refill(); b.tokens < float64(n) {
+		return true
+	}
+
+	b.tokens -= float64(n)
+	return false
+}
+
+// WaitBytes blocks until n bytes can be transferred or the context is done. The bytes
+// are reserved right away, so transfers larger than the burst are allowed and simply
+// wait for longer.
+func (b *ByteLimiter) WaitBytes(ctx context.Context, n int64) error {
+	b.lock.Lock()
+	b.refill()
+	b.tokens -= float64(n)
+	delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.lock.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.ReturnBytes(n)
+		return ctx.Err()
+	}
+}
+
+// ReturnBytes returns n bytes which were consumed but not transferred.
+func (b *ByteLimiter) ReturnBytes(n int64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refill()
+	if b.tokens += float64(n); b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// Available returns the number of bytes which can currently be transferred at once.
+func (b *ByteLimiter) Available() int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.refill(); b.tokens > 0 {
+		return int64(b.tokens)
+	}
+	return 0
+}
+
+// Rate returns the rate in bytes per second.
+func (b *ByteLimiter) Rate() int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return int64(b.rate)
+}
+
+// UpdateRate changes the rate in bytes per second, keeping the burst unless it is lower
+// than the new rate.
+func (b *ByteLimiter) UpdateRate(bytesPerSecond int64) {
+	if bytesPerSecond < 1 {
+		bytesPerSecond = 1
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refill()
+	b.rate = float64(bytesPerSecond)
+	if b.burst < b.rate {
+		b.burst = b.rate
+	}
+}
+
+// refill adds the bytes accrued since the last refill, must be called while holding the lock
+func (b *ByteLimiter) refill() {
+	now := b.now()
+	if now <= b.last {
+		return
+	}
+
+	b.tokens += float64(now-b.last) / float64(time.Second) * b.rate
+	b.last = now
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// now returns the current time of the byte limiter's clock as unix nanoseconds
+func (b *ByteLimiter) now() int64 {
+	if b.clock == nil {
+		return time.Now().UnixNano()
+	}
+
+	return b.clock.Now()
+}
@@ -0,0 +1,87 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ByteLimiter", func() {
+
+	It("should limit the throughput", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		b := NewByteLimiter(1000, 2000, WithByteClock(clock))
+		Expect(b.Rate()).To(Equal(int64(1000)))
+		Expect(b.Available()).To(Equal(int64(2000)))
+		Expect(b.LimitBytes(1500)).To(BeFalse())
+		Expect(b.LimitBytes(1000)).To(BeTrue())
+
+		clock.Advance(500 * time.Millisecond)
+		Expect(b.Available()).To(Equal(int64(1000)))
+		Expect(b.LimitBytes(1000)).To(BeFalse())
+
+		clock.Advance(time.Hour)
+		Expect(b.Available()).To(Equal(int64(2000)))
+	})
+
+	It("should handle very large rates", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		b := NewByteLimiter(100<<30, 0, WithByteClock(clock))
+		Expect(b.LimitBytes(100 << 30)).To(BeFalse())
+		Expect(b.LimitBytes(1)).To(BeTrue())
+
+		clock.Advance(time.Second)
+		Expect(b.Available()).To(Equal(int64(100 << 30)))
+	})
+
+	It("should wait for transfers larger than the burst", func() {
+		b := NewByteLimiter(10000, 0)
+		start := time.Now()
+		Expect(b.WaitBytes(context.Background(), 10000)).To(Succeed())
+		Expect(b.WaitBytes(context.Background(), 200)).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 15*time.Millisecond))
+	})
+
+	It("should return the bytes when the context is done", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		b := NewByteLimiter(1000, 0, WithByteClock(clock))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+
+		Expect(b.WaitBytes(ctx, 1500)).To(Equal(context.DeadlineExceeded))
+		Expect(b.Available()).To(Equal(int64(1000)))
+	})
+
+	It("should update the rate", func() {
+		b := NewByteLimiter(1000, 1000)
+		b.UpdateRate(5000)
+		Expect(b.Rate()).To(Equal(int64(5000)))
+		Expect(b.burst).To(Equal(5000.0))
+	})
+
+	It("should update the rate while waiting", func() {
+		b := NewByteLimiter(10000000, 0)
+		Expect(b.WaitBytes(context.Background(), 10000000)).To(Succeed())
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 1000; i++ {
+				b.UpdateRate(int64(10000000 + i))
+			}
+		}()
+
+		for i := 0; i < 50; i++ {
+			Expect(b.WaitBytes(context.Background(), 5000)).To(Succeed())
+		}
+		<-done
+	})
+
+})
@@ -0,0 +1,59 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Carried represents the token of an admitted call which failed with a retryable error,
+// carried over to its retry attempt so that the retry is not charged a second token.
+// Carried instances are thread-safe, the token is only redeemed once.
+type Carried struct {
+	limiter  *Limiter
+	expires  uint64      // time after which the token is no longer carried, in unix ns
+	redeemed atomic.Bool // whether the token was already redeemed
+}
+
+// CarryToken carries the token of a call which was admitted but failed with a retryable
+// error over to its retry, provided the retry happens within the specified duration. Past
+// that bound, or once redeemed, the retry is charged as usual.
+func (rl *Limiter) CarryToken(within time.Duration) *Carried {
+	return &Carried{
+		limiter: rl,
+		expires: rl.now() + uint64(within),
+	}
+}
+
+// Limit admits the retry with the carried token if it is still valid, otherwise returns
+// true if the rate of the limiter was exceeded, as Limit() does.
+func (c *Carried) Limit() bool {
+	if c.redeem() {
+		return false
+	}
+	return c.limiter.Limit()
+}
+
+// Wait admits the retry with the carried token if it is still valid, otherwise blocks
+// until a token is available or the context is done, as Wait() does.
+func (c *Carried) Wait(ctx context.Context) error {
+	if c.redeem() {
+		return nil
+	}
+	return c.limiter.Wait(ctx)
+}
+
+// Valid returns whether the carried token can still be redeemed.
+func (c *Carried) Valid() bool {
+	return !c.redeemed.Load() && c.limiter.now() <= c.expires
+}
+
+// redeem redeems the carried token and returns whether it was still valid
+func (c *Carried) redeem() bool {
+	return c.limiter.now() <= c.expires && c.redeemed.CompareAndSwap(false, true)
+}
@@ -0,0 +1,55 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Carried", func() {
+	var clock *ManualClock
+	BeforeEach(func() {
+		clock = NewManualClock(time.Unix(0, 0))
+	})
+
+	It("should admit the retry with the carried token", func() {
+		rl := New(1, time.Hour, WithClock(clock))
+		Expect(rl.Limit()).To(BeFalse())
+
+		carried := rl.CarryToken(time.Second)
+		Expect(carried.Valid()).To(BeTrue())
+		Expect(carried.Limit()).To(BeFalse())
+		Expect(carried.Valid()).To(BeFalse())
+		Expect(carried.Limit()).To(BeTrue())
+	})
+
+	It("should charge a retry past the bound", func() {
+		rl := New(2, time.Hour, WithClock(clock))
+		Expect(rl.Limit()).To(BeFalse())
+
+		carried := rl.CarryToken(time.Second)
+		clock.Advance(2 * time.Second)
+		Expect(carried.Valid()).To(BeFalse())
+		Expect(carried.Limit()).To(BeFalse())
+		Expect(rl.Remaining()).To(Equal(0))
+	})
+
+	It("should wait with the carried token", func() {
+		rl := New(1, time.Hour, WithClock(clock))
+		Expect(rl.Limit()).To(BeFalse())
+
+		carried := rl.CarryToken(time.Second)
+		Expect(carried.Wait(context.Background())).To(Succeed())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(carried.Wait(ctx)).To(HaveOccurred())
+	})
+})
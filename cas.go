@@ -0,0 +1,39 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// UpdateRateCAS updates the rate from one value to another, as UpdateRate() does, only if
+// the current rate is still the expected one, and returns whether it was applied. Several
+// controllers adjusting the same limiter, such as an autoscaler and an operator, thus
+// detect that another one changed the rate in the meantime instead of silently
+// overwriting it.
+func (rl *Limiter) UpdateRateCAS(from, to int) bool {
+	return rl.configure(to, func(current *config) (uint64, bool) {
+		return current.version, current.rate == uint64(from)
+	})
+}
+
+// UpdateRateIfUnchanged updates the rate, as UpdateRate() does, only if no other update
+// was applied since the sequence was read with Sequence(), and returns whether it was
+// applied. Unlike UpdateRateCAS(), it also detects updates which set the rate back to the
+// value it had.
+func (rl *Limiter) UpdateRateIfUnchanged(sequence uint64, rate int) bool {
+	return rl.configure(rate, func(current *config) (uint64, bool) {
+		return current.version, current.sequence == sequence
+	})
+}
+
+// Sequence returns the change-sequence number of the rate, incremented by every update
+// applied, whichever method applied it.
+func (rl *Limiter) Sequence() uint64 {
+	return rl.load().sequence
+}
+
+// CurrentRate returns the rate currently configured, the target one if a transition of
+// WithRamp() is in progress.
+func (rl *Limiter) CurrentRate() int {
+	return int(rl.load().rate)
+}
@@ -0,0 +1,70 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UpdateRateCAS", func() {
+
+	It("should only update from the expected rate", func() {
+		rl := New(10, time.Second)
+		Expect(rl.UpdateRateCAS(10, 20)).To(BeTrue())
+		Expect(rl.CurrentRate()).To(Equal(20))
+		Expect(rl.UpdateRateCAS(10, 30)).To(BeFalse())
+		Expect(rl.CurrentRate()).To(Equal(20))
+	})
+
+	It("should count every update applied", func() {
+		rl := New(10, time.Second)
+		Expect(rl.Sequence()).To(BeZero())
+
+		rl.UpdateRate(20)
+		rl.ApplyIfNewer(5, 30)
+		rl.UpdateRateCAS(30, 40)
+		rl.UpdateRateCAS(30, 50)
+		Expect(rl.Sequence()).To(Equal(uint64(3)))
+		Expect(rl.Version()).To(Equal(uint64(5)))
+	})
+
+	It("should detect updates since the sequence was read", func() {
+		rl := New(10, time.Second)
+		seq := rl.Sequence()
+
+		rl.UpdateRate(20)
+		rl.UpdateRate(10) // back to the same rate
+		Expect(rl.UpdateRateIfUnchanged(seq, 50)).To(BeFalse())
+		Expect(rl.UpdateRateIfUnchanged(rl.Sequence(), 50)).To(BeTrue())
+		Expect(rl.CurrentRate()).To(Equal(50))
+	})
+
+	It("should let a single controller win a conflict", func() {
+		rl := New(10, time.Second)
+		seq := rl.Sequence()
+
+		var wins atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if rl.UpdateRateIfUnchanged(seq, 100+i) {
+					wins.Add(1)
+				}
+			}(i)
+		}
+
+		wg.Wait()
+		Expect(wins.Load()).To(Equal(int32(1)))
+		Expect(rl.Sequence()).To(Equal(seq + 1))
+	})
+})
@@ -0,0 +1,78 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// Interface represents any limiter which decides whether calls are admitted, such as a
+// Limiter, a Windowed limiter or a Quota.
+type Interface interface {
+	Limit() bool
+	LimitN(n int) bool
+}
+
+// Decorator wraps a limiter with a cross-cutting behavior, such as metrics or logging.
+type Decorator func(Interface) Interface
+
+// Chain wraps the limiter with the decorators, the first decorator being the outermost.
+func Chain(l Interface, decorators ...Decorator) Interface {
+	for i := len(decorators) - 1; i >= 0; i-- {
+		l = decorators[i](l)
+	}
+	return l
+}
+
+// Use wraps the limiter with the decorators, the first decorator being the outermost.
+func (rl *Limiter) Use(decorators ...Decorator) Interface {
+	return Chain(rl, decorators...)
+}
+
+// LimitFunc is an adapter which allows a function to be used as a limiter, it receives
+// the number of calls and returns true if they are limited.
+type LimitFunc func(n int) bool
+
+// Limit returns true if the call is limited.
+func (f LimitFunc) Limit() bool {
+	return f(1)
+}
+
+// LimitN returns true if the n calls are limited.
+func (f LimitFunc) LimitN(n int) bool {
+	return f(n)
+}
+
+// Observe returns a decorator which calls the function with every decision, typically to
+// record metrics or log denials.
+func Observe(fn func(n int, limited bool)) Decorator {
+	return func(next Interface) Interface {
+		return LimitFunc(func(n int) bool {
+			limited := next.LimitN(n)
+			fn(n, limited)
+			return limited
+		})
+	}
+}
+
+// Shadowed returns a decorator which never limits, but calls the function with the calls
+// which would have been denied.
+func Shadowed(fn func(n int)) Decorator {
+	return func(next Interface) Interface {
+		return LimitFunc(func(n int) bool {
+			if next.LimitN(n) {
+				fn(n)
+			}
+			return false
+		})
+	}
+}
+
+// Fallback returns a decorator which gives the calls limited by the wrapped limiter a
+// second chance on another one, such as a shared overflow pool.
+func Fallback(other Interface) Decorator {
+	return func(next Interface) Interface {
+		return LimitFunc(func(n int) bool {
+			return next.LimitN(n) && other.LimitN(n)
+		})
+	}
+}
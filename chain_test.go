@@ -0,0 +1,73 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chain", func() {
+
+	It("should implement the interface", func() {
+		var _ Interface = New(1, time.Second)
+		var _ Interface = NewWindowed(nil)
+		var _ Interface = NewQuota(1, time.Second)
+		var _ Interface = LimitFunc(nil)
+	})
+
+	It("should apply decorators in order", func() {
+		var order []string
+		trace := func(name string) Decorator {
+			return func(next Interface) Interface {
+				return LimitFunc(func(n int) bool {
+					order = append(order, name)
+					return next.LimitN(n)
+				})
+			}
+		}
+
+		l := New(10, time.Second).Use(trace("a"), trace("b"))
+		Expect(l.Limit()).To(BeFalse())
+		Expect(order).To(Equal([]string{"a", "b"}))
+	})
+
+	It("should observe decisions", func() {
+		var allowed, denied int
+		l := New(2, time.Minute).Use(Observe(func(n int, limited bool) {
+			if limited {
+				denied += n
+			} else {
+				allowed += n
+			}
+		}))
+
+		Expect(l.Limit()).To(BeFalse())
+		Expect(l.LimitN(2)).To(BeTrue())
+		Expect(allowed).To(Equal(1))
+		Expect(denied).To(Equal(2))
+	})
+
+	It("should shadow denials", func() {
+		var shadowed int
+		l := Chain(New(1, time.Minute), Shadowed(func(n int) { shadowed += n }))
+		for i := 0; i < 3; i++ {
+			Expect(l.Limit()).To(BeFalse())
+		}
+		Expect(shadowed).To(Equal(2))
+	})
+
+	It("should fall back on another limiter", func() {
+		overflow := New(1, time.Minute)
+		l := New(1, time.Minute).Use(Fallback(overflow))
+		Expect(l.Limit()).To(BeFalse())
+		Expect(l.Limit()).To(BeFalse())
+		Expect(l.Limit()).To(BeTrue())
+	})
+
+})
@@ -0,0 +1,116 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+)
+
+// children represents the child limiters scoped under a limiter, by name
+type children struct {
+	sync.Mutex
+	scoped map[string]*child
+}
+
+// child represents a child limiter entitled to a share of the rate of its parent
+type child struct {
+	share   float64  // fraction of the rate of the parent
+	limiter *Limiter // limiter of the child
+}
+
+// Child returns the child limiter scoped under the name, entitled to a percentage of the
+// rate, between 0 and 100, and creates it on the first call. The rate of the child is
+// recomputed whenever the rate changes, so a service budget can be subdivided between
+// teams declaratively. The calls admitted by the child are consumed from the parent too,
+// so the children never admit more than the parent would. Calling it again with the
+// same name updates the percentage of the existing child, the options only apply on
+// creation.
+func (rl *Limiter) Child(name string, percent float64, options ...Option) *Limiter {
+	share := clampPercent(percent) / 100
+	rate := portion(int(rl.load().rate), share)
+
+	e := rl.extend()
+	e.children.Lock()
+	defer e.children.Unlock()
+	if c, ok := e.children.scoped[name]; ok {
+		if c.share != share {
+			c.share = share
+			c.limiter.UpdateRate(rate)
+		}
+		return c.limiter
+	}
+
+	if e.children.scoped == nil {
+		e.children.scoped = make(map[string]*child)
+	}
+
+	c := &child{share: share, limiter: rl.derive(rate, options...)}
+	c.limiter.extend().parent = rl
+	e.children.scoped[name] = c
+	return c.limiter
+}
+
+// Children returns the child limiters scoped under the limiter, by name.
+func (rl *Limiter) Children() map[string]*Limiter {
+	e := rl.optional.Load()
+	if e == nil {
+		return map[string]*Limiter{}
+	}
+
+	e.children.Lock()
+	defer e.children.Unlock()
+
+	out := make(map[string]*Limiter, len(e.children.scoped))
+	for name, c := range e.children.scoped {
+		out[name] = c.limiter
+	}
+	return out
+}
+
+// rescope recomputes the rate of the child limiters for the rate of the limiter
+func (rl *Limiter) rescope(rate int) {
+	e := rl.optional.Load()
+	if e == nil {
+		return // no children without any extension
+	}
+
+	e.children.Lock()
+	defer e.children.Unlock()
+	for _, c := range e.children.scoped {
+		c.limiter.UpdateRate(portion(rate, c.share))
+	}
+}
+
+// inherit consumes the n calls admitted by a child from its parent too, and gives them
+// back to the child if the parent would exceed its rate
+func (rl *Limiter) inherit(n uint64) bool {
+	e := rl.optional.Load()
+	if e == nil || e.parent == nil {
+		return false
+	}
+
+	if e.parent.LimitN(int(n)) {
+		rl.refund(n)
+		return true
+	}
+	return false
+}
+
+// inheritUpTo takes up to the n tokens taken by a child from its parent too, gives the
+// others back to the child and returns the number of tokens taken from both
+func (rl *Limiter) inheritUpTo(n uint64) uint64 {
+	e := rl.optional.Load()
+	if e == nil || e.parent == nil || n == 0 {
+		return n
+	}
+
+	taken := e.parent.take(n)
+	e.parent.stats.allowed.Add(taken)
+	if taken < n {
+		rl.refund(n - taken)
+	}
+	return taken
+}
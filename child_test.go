@@ -0,0 +1,107 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Child", func() {
+
+	It("should be entitled to a share of the rate", func() {
+		parent := New(100, time.Hour)
+		analytics := parent.Child("analytics", 20)
+		Expect(analytics.Remaining()).To(Equal(20))
+		Expect(analytics.LimitN(20)).To(BeFalse())
+		Expect(analytics.Limit()).To(BeTrue())
+		Expect(parent.Remaining()).To(Equal(80))
+
+		analytics.Undo()
+		Expect(analytics.Remaining()).To(Equal(1))
+		Expect(parent.Remaining()).To(Equal(81))
+	})
+
+	It("should never admit more than the parent", func() {
+		parent := New(100, time.Hour)
+		child := parent.Child("analytics", 20)
+
+		var admitted int
+		for i := 0; i < 200; i++ {
+			if !child.Limit() {
+				admitted++
+			}
+			if !parent.Limit() {
+				admitted++
+			}
+		}
+		Expect(admitted).To(Equal(100))
+	})
+
+	It("should give the tokens back when the parent is exhausted", func() {
+		parent := New(10, time.Hour)
+		child := parent.Child("analytics", 50)
+		Expect(parent.LimitN(8)).To(BeFalse())
+
+		Expect(child.LimitN(3)).To(BeTrue())
+		Expect(child.Remaining()).To(Equal(5))
+		Expect(child.Batch(5).Len()).To(Equal(2))
+		Expect(child.Remaining()).To(Equal(3))
+		Expect(parent.Remaining()).To(BeZero())
+		Expect(child.Limit()).To(BeTrue())
+		Expect(child.Remaining()).To(Equal(3))
+	})
+
+	It("should wait for the parent", func() {
+		parent := New(10, 100*time.Millisecond)
+		child := parent.Child("analytics", 50)
+		Expect(parent.LimitN(10)).To(BeFalse())
+
+		start := time.Now()
+		Expect(child.Wait(context.Background())).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 5*time.Millisecond))
+		Expect(child.Remaining()).To(Equal(4))
+	})
+
+	It("should return the existing child", func() {
+		parent := New(100, time.Hour)
+		child := parent.Child("analytics", 20)
+		Expect(parent.Child("analytics", 20)).To(BeIdenticalTo(child))
+		Expect(parent.Children()).To(Equal(map[string]*Limiter{"analytics": child}))
+
+		parent.Child("analytics", 50)
+		Expect(child.Remaining()).To(Equal(20))
+		Expect(child.load().rate).To(Equal(uint64(50)))
+	})
+
+	It("should follow the rate of the parent", func() {
+		parent := New(100, time.Hour)
+		child := parent.Child("analytics", 20)
+		nested := child.Child("reports", 50)
+
+		parent.UpdateRate(1000)
+		Expect(child.load().rate).To(Equal(uint64(200)))
+		Expect(nested.load().rate).To(Equal(uint64(100)))
+
+		parent.UpdateRate(10)
+		Expect(child.load().rate).To(Equal(uint64(2)))
+		Expect(nested.load().rate).To(Equal(uint64(1)))
+	})
+
+	It("should share the clock of the parent", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		parent := New(10, time.Second, WithClock(clock))
+		child := parent.Child("analytics", 50)
+		Expect(child.LimitN(5)).To(BeFalse())
+		Expect(child.Limit()).To(BeTrue())
+
+		clock.Advance(time.Second)
+		Expect(child.Remaining()).To(Equal(5))
+	})
+})
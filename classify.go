@@ -0,0 +1,41 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net/http"
+	"time"
+)
+
+// Classifier classifies an HTTP request using application-specific signals, such as
+// whether it comes from a bot or a logged-in user. It returns the key of the request,
+// or an empty key to use the key function of the middleware, the name of the tier whose
+// limit applies, or an empty tier to apply the routes, and whether the request should
+// bypass the limits entirely.
+type Classifier func(r *http.Request) (key, tier string, skip bool)
+
+// tier represents a limit applied to a class of requests
+type tier struct {
+	rate    int
+	per     time.Duration
+	limiter *Keyed
+}
+
+// WithClassifier sets a classifier which is called before limiting every request, in
+// order to route it to a tier or to let it bypass the limits.
+func WithClassifier(fn Classifier) MiddlewareOption {
+	return func(m *Middleware) {
+		m.classify = fn
+	}
+}
+
+// WithTier defines the limit applied per key to the requests classified in the tier.
+// Requests classified in an unknown tier are limited according to the routes.
+func WithTier(name string, rate int, per time.Duration) MiddlewareOption {
+	return func(m *Middleware) {
+		m.tiers[name] = &tier{rate: rate, per: per}
+	}
+}
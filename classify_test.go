@@ -0,0 +1,69 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Classifier", func() {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	classify := func(r *http.Request) (string, string, bool) {
+		switch {
+		case r.URL.Path == "/health":
+			return "", "", true
+		case r.Header.Get("X-User") != "":
+			return r.Header.Get("X-User"), "user", false
+		case r.Header.Get("User-Agent") == "bot":
+			return "", "bot", false
+		default:
+			return "", "", false
+		}
+	}
+
+	It("should route requests to tiers", func() {
+		h := NewMiddleware(2, time.Minute,
+			WithClassifier(classify),
+			WithTier("user", 3, time.Minute),
+			WithTier("bot", 1, time.Minute),
+		).Handler(ok)
+
+		serve := func(path string, headers ...string) int {
+			r := httptest.NewRequest("GET", path, nil)
+			r.RemoteAddr = "1.2.3.4:1000"
+			for i := 0; i < len(headers); i += 2 {
+				r.Header.Set(headers[i], headers[i+1])
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			return w.Code
+		}
+
+		for i := 0; i < 5; i++ {
+			Expect(serve("/health")).To(Equal(http.StatusOK))
+		}
+
+		Expect(serve("/", "User-Agent", "bot")).To(Equal(http.StatusOK))
+		Expect(serve("/", "User-Agent", "bot")).To(Equal(http.StatusTooManyRequests))
+
+		for i := 0; i < 3; i++ {
+			Expect(serve("/", "X-User", "alice")).To(Equal(http.StatusOK))
+		}
+		Expect(serve("/", "X-User", "alice")).To(Equal(http.StatusTooManyRequests))
+		Expect(serve("/", "X-User", "bob")).To(Equal(http.StatusOK))
+
+		Expect(serve("/")).To(Equal(http.StatusOK))
+		Expect(serve("/")).To(Equal(http.StatusOK))
+		Expect(serve("/")).To(Equal(http.StatusTooManyRequests))
+	})
+
+})
@@ -0,0 +1,166 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RemoteAddr returns a key function using the IP address of the client connection. It is
+// the default and the only safe choice when clients connect directly, since any header
+// can be forged by the client.
+func RemoteAddr() KeyFunc {
+	return func(r *http.Request) string {
+		if ip, ok := peer(r); ok {
+			return ip.String()
+		}
+		return r.RemoteAddr
+	}
+}
+
+// XForwardedFor returns a key function using the rightmost address of the X-Forwarded-For
+// header which is not a trusted proxy. The header is only considered when the connection
+// comes from a trusted proxy, otherwise the remote address is used, so that clients
+// can not pick their own key. Leftmost addresses are never used as they can be forged.
+func XForwardedFor(trusted ...netip.Prefix) KeyFunc {
+	return forwarded(trusted, func(r *http.Request) (hops []string) {
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(header, ",")...)
+		}
+		return
+	})
+}
+
+// Forwarded returns a key function using the rightmost "for" parameter of the RFC 7239
+// Forwarded header which is not a trusted proxy, with the same precautions as
+// XForwardedFor().
+func Forwarded(trusted ...netip.Prefix) KeyFunc {
+	return forwarded(trusted, func(r *http.Request) (hops []string) {
+		for _, header := range r.Header.Values("Forwarded") {
+			for _, element := range strings.Split(header, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					if k, v, ok := strings.Cut(strings.TrimSpace(pair), "="); ok && strings.EqualFold(k, "for") {
+						hops = append(hops, v)
+					}
+				}
+			}
+		}
+		return
+	})
+}
+
+// HeaderIP returns a key function using the address set in a header by a trusted proxy,
+// such as X-Real-IP. The header is only considered when the connection comes from a
+// trusted proxy, otherwise the remote address is used.
+func HeaderIP(name string, trusted ...netip.Prefix) KeyFunc {
+	return forwarded(trusted, func(r *http.Request) []string {
+		if v := r.Header.Get(name); v != "" {
+			return []string{v}
+		}
+		return nil
+	})
+}
+
+// CloudflareIP returns a key function using the CF-Connecting-IP header set by Cloudflare,
+// which must be listed among the trusted proxies.
+func CloudflareIP(trusted ...netip.Prefix) KeyFunc {
+	return HeaderIP("CF-Connecting-IP", trusted...)
+}
+
+// WithPrefixGrouping groups the IP keys of the middleware by network prefix, such as /24
+// for IPv4 and /64 for IPv6, so clients rotating addresses within a subnet share the
+// same limiter. A zero length leaves the addresses of that family ungrouped.
+func WithPrefixGrouping(v4, v6 int) MiddlewareOption {
+	return func(m *Middleware) {
+		m.v4, m.v6 = v4, v6
+	}
+}
+
+// GroupIP returns the network prefix of the IP address in the key, such as "1.2.3.0/24",
+// with the prefix length for its family. Keys which are not IP addresses and families
+// with a zero length are returned unchanged.
+func GroupIP(key string, v4, v6 int) string {
+	ip, err := netip.ParseAddr(key)
+	if err != nil {
+		return key
+	}
+
+	bits := v6
+	if ip = ip.Unmap(); ip.Is4() {
+		bits = v4
+	}
+
+	if bits <= 0 {
+		return key
+	}
+
+	prefix, err := ip.WithZone("").Prefix(bits)
+	if err != nil {
+		return key
+	}
+	return prefix.String()
+}
+
+// forwarded returns a key function using the rightmost untrusted hop of a request
+func forwarded(trusted []netip.Prefix, hops func(*http.Request) []string) KeyFunc {
+	isTrusted := func(ip netip.Addr) bool {
+		for _, p := range trusted {
+			if p.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(r *http.Request) string {
+		ip, ok := peer(r)
+		if !ok {
+			return r.RemoteAddr
+		}
+
+		if !isTrusted(ip) {
+			return ip.String()
+		}
+
+		list := hops(r)
+		for i := len(list) - 1; i >= 0; i-- {
+			hop, ok := parseHop(list[i])
+			if !ok {
+				break // do not look past a malformed hop
+			}
+
+			if ip = hop; !isTrusted(hop) {
+				break
+			}
+		}
+		return ip.String()
+	}
+}
+
+// peer returns the IP address of the client connection
+func peer(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip, err := netip.ParseAddr(host)
+	return ip.Unmap(), err == nil
+}
+
+// parseHop parses an address of a forwarding header, with an optional port
+func parseHop(v string) (netip.Addr, bool) {
+	v = strings.Trim(strings.TrimSpace(v), `"`)
+	if ap, err := netip.ParseAddrPort(v); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+
+	ip, err := netip.ParseAddr(strings.Trim(v, "[]"))
+	return ip.Unmap(), err == nil
+}
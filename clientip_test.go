@@ -0,0 +1,93 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client IP", func() {
+	proxies := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+
+	request := func(addr string, headers ...string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = addr
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Add(headers[i], headers[i+1])
+		}
+		return r
+	}
+
+	It("should use the remote address", func() {
+		key := RemoteAddr()
+		Expect(key(request("1.2.3.4:1000"))).To(Equal("1.2.3.4"))
+		Expect(key(request("[::ffff:1.2.3.4]:1000"))).To(Equal("1.2.3.4"))
+		Expect(key(request("[2001:db8::1]:1000"))).To(Equal("2001:db8::1"))
+		Expect(key(request("invalid"))).To(Equal("invalid"))
+		Expect(key(request("1.2.3.4:1000", "X-Forwarded-For", "5.6.7.8"))).To(Equal("1.2.3.4"))
+	})
+
+	It("should use the rightmost untrusted X-Forwarded-For address", func() {
+		key := XForwardedFor(proxies...)
+		Expect(key(request("10.0.0.1:1000", "X-Forwarded-For", "6.6.6.6, 1.2.3.4, 10.0.0.2"))).To(Equal("1.2.3.4"))
+		Expect(key(request("10.0.0.1:1000", "X-Forwarded-For", "6.6.6.6", "X-Forwarded-For", "1.2.3.4"))).To(Equal("1.2.3.4"))
+		Expect(key(request("10.0.0.1:1000", "X-Forwarded-For", "10.0.0.3"))).To(Equal("10.0.0.3"))
+		Expect(key(request("10.0.0.1:1000"))).To(Equal("10.0.0.1"))
+		Expect(key(request("10.0.0.1:1000", "X-Forwarded-For", "1.2.3.4, garbage"))).To(Equal("10.0.0.1"))
+	})
+
+	It("should ignore headers from untrusted peers", func() {
+		Expect(XForwardedFor(proxies...)(request("1.2.3.4:1000", "X-Forwarded-For", "6.6.6.6"))).To(Equal("1.2.3.4"))
+		Expect(XForwardedFor()(request("10.0.0.1:1000", "X-Forwarded-For", "6.6.6.6"))).To(Equal("10.0.0.1"))
+		Expect(CloudflareIP(proxies...)(request("1.2.3.4:1000", "CF-Connecting-IP", "6.6.6.6"))).To(Equal("1.2.3.4"))
+	})
+
+	It("should use the rightmost untrusted Forwarded address", func() {
+		key := Forwarded(proxies...)
+		Expect(key(request("10.0.0.1:1000", "Forwarded", `for=6.6.6.6, for="[2001:db9::1]:4711";proto=https, for=10.0.0.2`))).To(Equal("2001:db9::1"))
+		Expect(key(request("10.0.0.1:1000", "Forwarded", "proto=https;For=1.2.3.4"))).To(Equal("1.2.3.4"))
+		Expect(key(request("10.0.0.1:1000", "Forwarded", "for=unknown"))).To(Equal("10.0.0.1"))
+	})
+
+	It("should use a header set by a trusted proxy", func() {
+		Expect(CloudflareIP(proxies...)(request("10.0.0.1:1000", "CF-Connecting-IP", "1.2.3.4"))).To(Equal("1.2.3.4"))
+		Expect(HeaderIP("X-Real-IP", proxies...)(request("[2001:db8::5]:1000", "X-Real-IP", "1.2.3.4"))).To(Equal("1.2.3.4"))
+		Expect(HeaderIP("X-Real-IP", proxies...)(request("10.0.0.1:1000"))).To(Equal("10.0.0.1"))
+	})
+
+	It("should group addresses by prefix", func() {
+		Expect(GroupIP("1.2.3.4", 24, 64)).To(Equal("1.2.3.0/24"))
+		Expect(GroupIP("::ffff:1.2.3.4", 24, 64)).To(Equal("1.2.3.0/24"))
+		Expect(GroupIP("2001:db8:1:2:3:4:5:6", 24, 64)).To(Equal("2001:db8:1:2::/64"))
+		Expect(GroupIP("1.2.3.4", 0, 64)).To(Equal("1.2.3.4"))
+		Expect(GroupIP("1.2.3.4", 40, 64)).To(Equal("1.2.3.4"))
+		Expect(GroupIP("user", 24, 64)).To(Equal("user"))
+	})
+
+	It("should share limiters within a subnet", func() {
+		ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+		h := NewMiddleware(1, time.Minute, WithPrefixGrouping(24, 64)).Handler(ok)
+		serve := func(addr string) int {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request(addr))
+			return w.Code
+		}
+
+		Expect(serve("1.2.3.4:1000")).To(Equal(http.StatusOK))
+		Expect(serve("1.2.3.5:1000")).To(Equal(http.StatusTooManyRequests))
+		Expect(serve("1.2.4.5:1000")).To(Equal(http.StatusOK))
+	})
+
+})
@@ -0,0 +1,133 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock represents a source of time for the limiter.
+type Clock interface {
+	// Now returns the current time as unix nanoseconds.
+	Now() int64
+}
+
+// Resolution returns the resolution of the system time used by the limiters without a
+// clock on this platform, such as a millisecond on WASM. Rates whose interval between two
+// tokens is shorter can not be paced accurately, see Rate.Representable().
+func Resolution() time.Duration {
+	return resolution
+}
+
+// WithClock sets the clock used by the limiter instead of the system time.
+func WithClock(clock Clock) Option {
+	return func(rl *Limiter) {
+		rl.clock = clock
+	}
+}
+
+// WithTicks makes the limiter accrue allowance only when Tick() is called, instead of
+// following the wall clock. This keeps the decisions deterministic relative to a fixed
+// step simulation, such as a game loop.
+func WithTicks() Option {
+	return func(rl *Limiter) {
+		rl.clock = new(ManualClock)
+	}
+}
+
+// Tick advances the time of a limiter created with WithTicks() or with a ManualClock by
+// the elapsed duration. It has no effect on limiters which follow the wall clock.
+func (rl *Limiter) Tick(elapsed time.Duration) {
+	if clock, ok := rl.clock.(*ManualClock); ok {
+		clock.Advance(elapsed)
+	}
+}
+
+// ---------------------------------- Manual Clock ----------------------------------
+
+// ManualClock is a clock which only advances when explicitly told to, making the
+// decisions of a limiter fully deterministic. This is useful for simulations and tests.
+type ManualClock struct {
+	now atomic.Int64
+}
+
+// NewManualClock creates a new manual clock, starting at the specified time.
+func NewManualClock(start time.Time) *ManualClock {
+	c := new(ManualClock)
+	c.now.Store(start.UnixNano())
+	return c
+}
+
+// Now returns the current time as unix nanoseconds.
+func (c *ManualClock) Now() int64 {
+	return c.now.Load()
+}
+
+// Advance moves the clock forward by the specified duration.
+func (c *ManualClock) Advance(d time.Duration) {
+	if d > 0 {
+		c.now.Add(int64(d))
+	}
+}
+
+// ---------------------------------- Coarse Clock ----------------------------------
+
+// CoarseClock is a clock which caches the current time and refreshes it periodically,
+// trading a little precision for far fewer calls to the system time. This is useful on
+// embedded devices where reading the time on every call is relatively expensive.
+type CoarseClock struct {
+	now  atomic.Int64
+	done chan struct{}
+	once sync.Once
+}
+
+// NewCoarseClock creates a new coarse clock refreshed by a background ticker at the
+// specified resolution. If the resolution is zero, no ticker is started and the clock
+// only advances when Refresh() is called.
+func NewCoarseClock(resolution time.Duration) *CoarseClock {
+	c := &CoarseClock{done: make(chan struct{})}
+	c.Refresh()
+
+	if resolution > 0 {
+		go c.run(resolution)
+	}
+	return c
+}
+
+// Now returns the cached time as unix nanoseconds.
+func (c *CoarseClock) Now() int64 {
+	return c.now.Load()
+}
+
+// Refresh updates the cached time from the system time.
+func (c *CoarseClock) Refresh() {
+	c.now.Store(time.Now().UnixNano())
+}
+
+// Close stops the background ticker, if any.
+func (c *CoarseClock) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+	})
+	return nil
+}
+
+// run refreshes the cached time periodically, until the clock is closed
+func (c *CoarseClock) run(resolution time.Duration) {
+	ticker := time.NewTicker(resolution)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Refresh()
+		case <-c.done:
+			return
+		}
+	}
+}
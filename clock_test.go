@@ -0,0 +1,114 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CoarseClock", func() {
+
+	It("should refresh periodically", func() {
+		clock := NewCoarseClock(time.Millisecond)
+		defer clock.Close()
+
+		start := clock.Now()
+		Expect(start).To(BeNumerically("~", time.Now().UnixNano(), int64(time.Second)))
+		Eventually(clock.Now).Should(BeNumerically(">", start))
+		Expect(clock.Close()).To(Succeed())
+	})
+
+	It("should refresh on demand", func() {
+		clock := NewCoarseClock(0)
+		start := clock.Now()
+		time.Sleep(time.Millisecond)
+		Expect(clock.Now()).To(Equal(start))
+
+		clock.Refresh()
+		Expect(clock.Now()).To(BeNumerically(">", start))
+	})
+
+	It("should drive the limiter", func() {
+		clock := NewCoarseClock(0)
+		rl := New(1, time.Millisecond, WithClock(clock))
+		Expect(rl.Limit()).To(BeFalse())
+
+		time.Sleep(2 * time.Millisecond)
+		Expect(rl.Limit()).To(BeTrue())
+
+		clock.Refresh()
+		Expect(rl.Limit()).To(BeFalse())
+	})
+
+})
+
+var _ = Describe("ManualClock", func() {
+
+	It("should only advance when told to", func() {
+		clock := NewManualClock(time.Unix(10, 0))
+		Expect(clock.Now()).To(Equal(int64(10 * time.Second)))
+
+		clock.Advance(time.Second)
+		clock.Advance(-time.Hour)
+		Expect(clock.Now()).To(Equal(int64(11 * time.Second)))
+	})
+
+	It("should drive the limiter", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(2, time.Second, WithClock(clock))
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+
+		clock.Advance(499 * time.Millisecond)
+		Expect(rl.Limit()).To(BeTrue())
+
+		clock.Advance(time.Millisecond)
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+	})
+
+	It("should advance on ticks", func() {
+		rl := New(10, time.Second, WithTicks())
+		for i := 0; i < 10; i++ {
+			Expect(rl.Limit()).To(BeFalse())
+		}
+		Expect(rl.Limit()).To(BeTrue())
+
+		time.Sleep(time.Millisecond)
+		Expect(rl.Limit()).To(BeTrue())
+
+		for i := 0; i < 5; i++ {
+			rl.Tick(50 * time.Millisecond)
+			Expect(rl.Limit()).To(Equal(i%2 == 0), "on tick %d", i)
+		}
+	})
+
+	It("should ignore ticks when following the wall clock", func() {
+		rl := New(1, time.Hour)
+		Expect(rl.Limit()).To(BeFalse())
+		rl.Tick(time.Hour)
+		Expect(rl.Limit()).To(BeTrue())
+	})
+
+})
+
+var _ = Describe("Resolution", func() {
+
+	It("should expose the resolution of the system time", func() {
+		Expect(Resolution()).To(BeNumerically(">", 0))
+		Expect(Resolution()).To(BeNumerically("<=", time.Millisecond))
+	})
+
+	It("should tell whether a rate is representable", func() {
+		Expect(MustRate(1000, time.Second).Representable()).To(BeTrue())
+		Expect(MustRate(1000000000, time.Second).Representable()).To(Equal(Resolution() <= time.Nanosecond))
+		Expect(Rate{}.Representable()).To(BeFalse())
+	})
+})
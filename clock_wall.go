@@ -0,0 +1,19 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+//go:build !windows && !js && !wasip1
+
+package rate
+
+import "time"
+
+// resolution is the resolution of the system time, which is read through the vDSO with a
+// nanosecond precision on most platforms
+const resolution = time.Nanosecond
+
+// unixNano returns the system time as unix nanoseconds
+func unixNano() uint64 {
+	return uint64(time.Now().UnixNano())
+}
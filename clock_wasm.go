@@ -0,0 +1,23 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+//go:build js || wasip1
+
+package rate
+
+import "time"
+
+// resolution is the resolution of the system time, which browsers and most WASM runtimes
+// coarsen to a millisecond
+const resolution = time.Millisecond
+
+// epoch is the time at which the module started, from which the time is measured
+var epoch = time.Now()
+
+// unixNano returns the system time as unix nanoseconds, measured on the monotonic clock
+// since the module started so that it never goes backwards
+func unixNano() uint64 {
+	return uint64(epoch.UnixNano()) + uint64(time.Since(epoch))
+}
@@ -0,0 +1,24 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+//go:build windows
+
+package rate
+
+import "time"
+
+// resolution is the resolution of the system time, the wall clock of Windows is counted in
+// intervals of 100ns but only advances at every scheduler tick, so the monotonic clock is
+// used instead
+const resolution = 100 * time.Nanosecond
+
+// epoch is the time at which the process started, from which the time is measured
+var epoch = time.Now()
+
+// unixNano returns the system time as unix nanoseconds, measured on the monotonic clock
+// since the process started
+func unixNano() uint64 {
+	return uint64(epoch.UnixNano()) + uint64(time.Since(epoch))
+}
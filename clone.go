@@ -0,0 +1,33 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import "time"
+
+// CloneMode represents how the allowance of a cloned limiter is initialized.
+type CloneMode uint8
+
+// Various clone modes
+const (
+	CloneFresh        CloneMode = iota // starts with a full allowance, as a new limiter
+	CloneProportional                  // starts as full as the original currently is
+)
+
+// Clone creates a new limiter with the current rate of the limiter and the options it
+// was created with, so that worker pools can derive per-worker limiters from a template.
+// The state of the limiter, such as its statistics, waiters and attached background
+// components, is not copied.
+func (rl *Limiter) Clone(mode CloneMode) *Limiter {
+	clone := New(int(rl.load().rate), time.Duration(rl.unit), rl.ext().options...)
+	if mode == CloneProportional {
+		_, max := rl.limits(rl.now())
+		fill := float64(rl.refill()) / float64(max)
+
+		_, max = clone.limits(clone.now())
+		clone.allowance.Store(uint64(fill * float64(max)))
+	}
+	return clone
+}
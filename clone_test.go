@@ -0,0 +1,45 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Clone", func() {
+
+	It("should copy the configuration", func() {
+		rl := New(10, time.Minute, WithName("worker"))
+		rl.UpdateRate(4)
+		rl.LimitN(3)
+
+		clone := rl.Clone(CloneFresh)
+		Expect(clone.Name()).To(Equal("worker"))
+		Expect(clone.Remaining()).To(Equal(4))
+		Expect(clone.Stats().Allowed).To(BeZero())
+		Expect(rl.Remaining()).To(Equal(1))
+	})
+
+	It("should copy the fill ratio of the allowance", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(10, time.Minute, WithClock(clock))
+		rl.LimitN(6)
+
+		clone := rl.Clone(CloneProportional)
+		Expect(clone.Remaining()).To(Equal(4))
+	})
+
+	It("should not share the state of the options", func() {
+		rl := New(1, time.Hour, WithPenalty(1, time.Minute, time.Minute, 0))
+		rl.Limit()
+		rl.Limit()
+		Expect(rl.Penalized()).To(BeTrue())
+		Expect(rl.Clone(CloneFresh).Penalized()).To(BeFalse())
+	})
+})
@@ -0,0 +1,121 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Every type holding background resources or tokens implements io.Closer the same way:
+// Close is idempotent, only the first call releasing anything, and the calls which need
+// those resources fail with ErrClosed afterwards.
+var (
+	_ io.Closer = (*Limiter)(nil)
+	_ io.Closer = (*Batch)(nil)
+	_ io.Closer = (*Lease)(nil)
+	_ io.Closer = (*CoarseClock)(nil)
+	_ io.Closer = (*Coordinator)(nil)
+	_ io.Closer = (*Distributed)(nil)
+	_ io.Closer = (*Leaky[struct{}])(nil)
+	_ io.Closer = (*Local)(nil)
+	_ io.Closer = (*Middleware)(nil)
+	_ io.Closer = (*Persister)(nil)
+	_ io.Closer = (*Provider)(nil)
+	_ io.Closer = (*Remote)(nil)
+	_ io.Closer = (*Scheduler)(nil)
+	_ io.Closer = (*Shared)(nil)
+	_ io.Closer = (*Subscription[struct{}])(nil)
+)
+
+// attached represents the background components bound to the lifecycle of a limiter
+type attached struct {
+	sync.Mutex
+	closers []io.Closer
+}
+
+// attach binds a background component, such as a scheduler, to the lifecycle of the
+// limiter so it is closed along with it
+func (rl *Limiter) attach(c io.Closer) {
+	e := rl.extend()
+	e.attached.Lock()
+	defer e.attached.Unlock()
+	e.attached.closers = append(e.attached.closers, c)
+}
+
+// Close stops accepting new Wait() calls, fails the queued waiters with ErrClosed and
+// stops the background components bound to the limiter, such as schedulers and rate
+// providers. Limit() keeps working on a closed limiter.
+func (rl *Limiter) Close() error {
+	q := &rl.extend().waiters
+	q.Lock()
+	q.closed = true
+	for q.list.Len() > 0 {
+		q.evict(q.list.Front(), ErrClosed)
+	}
+	q.Unlock()
+
+	return rl.release()
+}
+
+// Shutdown stops accepting new Wait() calls and blocks until the queued waiters have been
+// admitted, then stops the background components bound to the limiter. If the context is
+// done first, the remaining waiters fail with ErrClosed and the context error is returned.
+func (rl *Limiter) Shutdown(ctx context.Context) error {
+	q := &rl.extend().waiters
+	q.Lock()
+	q.closed = true
+	if q.list.Len() > 0 && q.drained == nil {
+		q.drained = make(chan struct{})
+	}
+	drained := q.drained
+	q.Unlock()
+
+	if drained != nil {
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			rl.Close()
+			return ctx.Err()
+		}
+	}
+
+	return rl.release()
+}
+
+// Closed returns whether the limiter was closed or is shutting down.
+func (rl *Limiter) Closed() bool {
+	e := rl.optional.Load()
+	if e == nil {
+		return false // closing always allocates the extensions
+	}
+
+	q := &e.waiters
+	q.Lock()
+	defer q.Unlock()
+	return q.closed
+}
+
+// release closes the background components bound to the limiter
+func (rl *Limiter) release() (err error) {
+	e := rl.optional.Load()
+	if e == nil {
+		return nil // nothing was attached
+	}
+
+	e.attached.Lock()
+	closers := e.attached.closers
+	e.attached.closers = nil
+	e.attached.Unlock()
+
+	for _, c := range closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
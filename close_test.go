@@ -0,0 +1,143 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Close", func() {
+
+	It("should fail queued and new waiters", func() {
+		rl := New(1, time.Hour)
+		Expect(rl.Limit()).To(BeFalse())
+
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() { errs <- rl.Wait(context.Background()) }()
+			Eventually(func() int { return waiting(rl) }).Should(Equal(i + 1))
+		}
+
+		Expect(rl.Close()).To(Succeed())
+		Expect(rl.Closed()).To(BeTrue())
+		Eventually(errs).Should(Receive(Equal(ErrClosed)))
+		Eventually(errs).Should(Receive(Equal(ErrClosed)))
+		Expect(rl.Wait(context.Background())).To(Equal(ErrClosed))
+		Expect(rl.Limit()).To(BeTrue())
+	})
+
+	It("should admit queued waiters on shutdown", func() {
+		rl := New(20, time.Second)
+		Expect(rl.LimitN(20)).To(BeFalse())
+
+		errs := make(chan error, 3)
+		for i := 0; i < 3; i++ {
+			go func() { errs <- rl.Wait(context.Background()) }()
+			Eventually(func() int { return waiting(rl) }).Should(Equal(i + 1))
+		}
+
+		Expect(rl.Shutdown(context.Background())).To(Succeed())
+		Expect(waiting(rl)).To(BeZero())
+		for i := 0; i < 3; i++ {
+			Expect(<-errs).To(Succeed())
+		}
+		Expect(rl.Wait(context.Background())).To(Equal(ErrClosed))
+	})
+
+	It("should fail the remaining waiters when shutdown times out", func() {
+		rl := New(1, time.Hour)
+		Expect(rl.Limit()).To(BeFalse())
+
+		errs := make(chan error, 1)
+		go func() { errs <- rl.Wait(context.Background()) }()
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		Expect(rl.Shutdown(ctx)).To(Equal(context.DeadlineExceeded))
+		Eventually(errs).Should(Receive(Equal(ErrClosed)))
+	})
+
+	It("should stop attached components", func() {
+		rates := make(chan int, 1)
+		rl := New(10, time.Second)
+		s := NewScheduler(rl)
+		p := FollowRate(rl, rates)
+		Expect(rl.Shutdown(context.Background())).To(Succeed())
+
+		Eventually(s.done).Should(BeClosed())
+		Eventually(p.done).Should(BeClosed())
+	})
+
+	It("should close every component idempotently", func() {
+		dir, err := os.MkdirTemp("", "close")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		rl := New(10, time.Hour)
+		shared, err := NewShared(filepath.Join(dir, "shared"), 10, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		persister, err := Persist(New(10, time.Hour), filepath.Join(dir, "state"), time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		lease, err := rl.Lease(2)
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, c := range []io.Closer{
+			New(10, time.Hour),
+			rl.Batch(2),
+			lease,
+			NewCoarseClock(time.Millisecond),
+			NewDistributed(NewMemoryStore(), "api", 10, time.Second, time.Hour),
+			NewLeaky[int](rl, 1),
+			persister,
+			NewScheduler(New(10, time.Hour)),
+			shared,
+			NewSubscription(rl, OverflowBuffer, 1, func(int) {}),
+		} {
+			Expect(c.Close()).To(Succeed())
+			Expect(c.Close()).To(Succeed())
+		}
+	})
+
+	It("should fail the calls needing closed resources", func() {
+		dir, err := os.MkdirTemp("", "close")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		p, err := Persist(New(10, time.Hour), filepath.Join(dir, "state"), time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.Close()).To(Succeed())
+		Expect(p.Save()).To(Equal(ErrClosed))
+
+		s := NewScheduler(New(10, time.Hour))
+		Expect(s.Close()).To(Succeed())
+		Expect(s.Cron("0 9 * * *", 100)).To(Equal(ErrClosed))
+
+		d := NewDistributed(NewMemoryStore(), "api", 10, time.Second, time.Hour)
+		Expect(d.Close()).To(Succeed())
+		Expect(d.Sync()).To(Equal(ErrClosed))
+
+		l := NewLeaky[int](New(10, time.Hour), 1)
+		Expect(l.Close()).To(Succeed())
+		Expect(l.Offer(1)).To(BeFalse())
+		_, err = l.Next(context.Background())
+		Expect(err).To(Equal(ErrClosed))
+
+		delivered := 0
+		sub := NewSubscription(New(10, time.Hour), OverflowDrop, 0, func(int) { delivered++ })
+		Expect(sub.Close()).To(Succeed())
+		sub.Handle(1)
+		Expect(delivered).To(Equal(0))
+		Expect(sub.Dropped()).To(Equal(uint64(1)))
+	})
+})
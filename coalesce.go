@@ -0,0 +1,75 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"sync"
+)
+
+// Coalescer cuts duplicate load during throttling. When a call is denied, it is parked
+// until it is admitted and any identical call made in the meantime waits for it and
+// shares its result instead of consuming another token. Coalescer instances are
+// thread-safe.
+type Coalescer struct {
+	limiter *Limiter
+	lock    sync.Mutex
+	calls   map[string]*call
+}
+
+// call represents a parked or running call, shared by identical requests
+type call struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// NewCoalescer creates a new coalescer on top of the limiter.
+func NewCoalescer(rl *Limiter) *Coalescer {
+	return &Coalescer{
+		limiter: rl,
+		calls:   make(map[string]*call),
+	}
+}
+
+// Do executes the function once admitted by the limiter and returns its result. If an
+// identical call, represented by the same key, is already parked or running, Do waits
+// for it and returns its result instead, in which case shared is true. If the context
+// of the first call is done before it was admitted, the calls sharing it also fail.
+func (c *Coalescer) Do(ctx context.Context, key string, fn func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	c.lock.Lock()
+	if pending, ok := c.calls[key]; ok {
+		c.lock.Unlock()
+		select {
+		case <-pending.done:
+			return pending.value, pending.err, true
+		case <-ctx.Done():
+			return nil, ctx.Err(), true
+		}
+	}
+
+	// If we are admitted right away, there is nothing to coalesce
+	if !c.limiter.Limit() {
+		c.lock.Unlock()
+		value, err = fn()
+		return
+	}
+
+	// We have been denied, park the call so identical ones can share it
+	pending := &call{done: make(chan struct{})}
+	c.calls[key] = pending
+	c.lock.Unlock()
+
+	if pending.err = c.limiter.Wait(ctx); pending.err == nil {
+		pending.value, pending.err = fn()
+	}
+
+	c.lock.Lock()
+	delete(c.calls, key)
+	c.lock.Unlock()
+	close(pending.done)
+	return pending.value, pending.err, false
+}
@@ -0,0 +1,90 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Coalescer", func() {
+
+	It("should run calls directly when not limited", func() {
+		c := NewCoalescer(New(10, time.Second))
+		v, err, shared := c.Do(context.Background(), "a", func() (interface{}, error) {
+			return 42, nil
+		})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v).To(Equal(42))
+		Expect(shared).To(BeFalse())
+	})
+
+	It("should share the result of a parked call", func() {
+		rl := New(1, 50*time.Millisecond)
+		Expect(rl.Limit()).To(BeFalse())
+		c := NewCoalescer(rl)
+
+		var calls atomic.Int32
+		var sharedCount atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				v, err, shared := c.Do(context.Background(), "a", func() (interface{}, error) {
+					calls.Add(1)
+					return "hello", nil
+				})
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(v).To(Equal("hello"))
+				if shared {
+					sharedCount.Add(1)
+				}
+			}()
+
+			if i == 0 {
+				Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+			}
+		}
+
+		wg.Wait()
+		Expect(calls.Load()).To(Equal(int32(1)))
+		Expect(sharedCount.Load()).To(Equal(int32(9)))
+	})
+
+	It("should fail shared calls when the first one fails", func() {
+		rl := New(1, time.Minute)
+		Expect(rl.Limit()).To(BeFalse())
+		c := NewCoalescer(rl)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		go func() {
+			_, err, _ := c.Do(ctx, "a", func() (interface{}, error) { return nil, nil })
+			errs <- err
+		}()
+
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+		go func() {
+			_, err, _ := c.Do(context.Background(), "a", func() (interface{}, error) { return nil, nil })
+			errs <- err
+		}()
+
+		time.Sleep(5 * time.Millisecond)
+		cancel()
+		Eventually(errs).Should(Receive(Equal(context.Canceled)))
+		Eventually(errs).Should(Receive(Equal(context.Canceled)))
+	})
+
+})
@@ -0,0 +1,41 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+)
+
+// Handler represents a function processing a single message of a consumer, such as a
+// Kafka record or an SQS message.
+type Handler[M any] func(ctx context.Context, msg M) error
+
+// Consume wraps a message handler so that messages are processed at the rate of the
+// limiter, with at most concurrency handlers running at once, or without a cap if the
+// concurrency is zero. The wrapped handler blocks until the message may be processed
+// and returns the context error if it is done first, leaving the message unprocessed so
+// it can be redelivered. This way the consumer is paced without pausing and resuming
+// fetches by hand.
+func Consume[M any](rl *Limiter, concurrency int, handler Handler[M]) Handler[M] {
+	var slots *Resource
+	if concurrency > 0 {
+		slots = NewResource(concurrency)
+	}
+
+	return func(ctx context.Context, msg M) error {
+		if slots != nil {
+			if err := slots.Wait(ctx); err != nil {
+				return err
+			}
+			defer slots.Release(1)
+		}
+
+		if err := rl.Wait(ctx); err != nil {
+			return err
+		}
+		return handler(ctx, msg)
+	}
+}
@@ -0,0 +1,91 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Consume", func() {
+
+	It("should pace the messages", func() {
+		var processed []string
+		handle := Consume(New(1, 10*time.Millisecond), 0, func(_ context.Context, msg string) error {
+			processed = append(processed, msg)
+			return nil
+		})
+
+		start := time.Now()
+		for _, msg := range []string{"a", "b", "c"} {
+			Expect(handle(context.Background(), msg)).To(Succeed())
+		}
+
+		Expect(processed).To(Equal([]string{"a", "b", "c"}))
+		Expect(time.Since(start)).To(BeNumerically(">=", 15*time.Millisecond))
+	})
+
+	It("should return the handler error", func() {
+		failure := errors.New("failed")
+		handle := Consume(New(10, time.Second), 1, func(context.Context, int) error {
+			return failure
+		})
+
+		Expect(handle(context.Background(), 1)).To(Equal(failure))
+		Expect(handle(context.Background(), 2)).To(Equal(failure))
+	})
+
+	It("should cap the concurrency", func() {
+		var running, peak int32
+		handle := Consume(New(1000, time.Second), 2, func(context.Context, int) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				handle(context.Background(), i)
+			}(i)
+		}
+
+		wg.Wait()
+		Expect(atomic.LoadInt32(&peak)).To(Equal(int32(2)))
+	})
+
+	It("should leave the message unprocessed when the context is done", func() {
+		rl := New(1, time.Hour)
+		rl.Limit()
+
+		called := false
+		handle := Consume(rl, 0, func(context.Context, int) error {
+			called = true
+			return nil
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		Expect(handle(ctx, 1)).To(HaveOccurred())
+		Expect(called).To(BeFalse())
+	})
+})
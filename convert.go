@@ -0,0 +1,97 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"math"
+	"time"
+)
+
+// PerSecond returns the rate of n tokens per duration, in tokens per second.
+func PerSecond(n int, per time.Duration) float64 {
+	if per <= 0 {
+		return 0
+	}
+	return float64(n) * float64(time.Second) / float64(per)
+}
+
+// Interval returns the time between two tokens at the rate of n tokens per duration.
+func Interval(n int, per time.Duration) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return per / time.Duration(n)
+}
+
+// FromInterval returns the count and duration of a rate of one token per interval.
+func FromInterval(interval time.Duration) (int, time.Duration) {
+	return 1, interval
+}
+
+// FromPerSecond returns the count and duration of a rate in tokens per second, such as
+// 3 per 2 seconds for a rate of 1.5. Fractional rates are approximated by the closest
+// fraction of at most an hour, so that they can be passed to New().
+func FromPerSecond(rate float64) (int, time.Duration) {
+	if rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return 0, time.Second
+	}
+
+	// Find the best rational approximation with continued fractions
+	const maxSeconds = 3600
+	p0, q0, p1, q1 := 0.0, 1.0, 1.0, 0.0
+	for x := rate; ; {
+		a := math.Floor(x)
+		p2, q2 := a*p1+p0, a*q1+q0
+		if q2 > maxSeconds {
+			break
+		}
+
+		p0, q0, p1, q1 = p1, q1, p2, q2
+		frac := x - a
+		if frac < 1e-9 || math.Abs(p1/q1-rate) < 1e-12*rate {
+			break
+		}
+		x = 1 / frac
+	}
+
+	if p1 < 1 {
+		return 1, time.Duration(math.Round(float64(time.Second) / rate))
+	}
+	return int(p1), time.Duration(q1) * time.Second
+}
+
+// Normalize rewrites an awkward rate of n tokens per duration, such as 7 per 350ms, into
+// an equivalent one per second, minute or hour, such as 20 per second, or else reduces it
+// to lowest terms. Rates already per millisecond, second, minute or hour are returned as
+// is. Since the count is also the burst of a limiter, a normalized rate may allow larger
+// bursts than the original one.
+func Normalize(n int, per time.Duration) (int, time.Duration) {
+	if n <= 0 || per <= 0 {
+		return n, per
+	}
+
+	switch per {
+	case time.Millisecond, time.Second, time.Minute, time.Hour:
+		return n, per
+	}
+
+	for _, unit := range []time.Duration{time.Second, time.Minute, time.Hour} {
+		if scaled := int64(n) * int64(unit); scaled%int64(per) == 0 {
+			return int(scaled / int64(per)), unit
+		}
+	}
+
+	d := gcd(int64(n), int64(per))
+	return int(int64(n) / d), time.Duration(int64(per) / d)
+}
+
+// gcd returns the greatest common divisor of a and b
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
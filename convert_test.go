@@ -0,0 +1,72 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Convert", func() {
+
+	It("should convert to tokens per second", func() {
+		Expect(PerSecond(7, 350*time.Millisecond)).To(BeNumerically("~", 20, 1e-9))
+		Expect(PerSecond(1, time.Minute)).To(BeNumerically("~", 1.0/60, 1e-9))
+		Expect(PerSecond(1, 0)).To(BeZero())
+	})
+
+	It("should convert to an interval", func() {
+		Expect(Interval(10, time.Second)).To(Equal(100 * time.Millisecond))
+		Expect(Interval(0, time.Second)).To(BeZero())
+
+		n, per := FromInterval(250 * time.Millisecond)
+		Expect(n).To(Equal(1))
+		Expect(per).To(Equal(250 * time.Millisecond))
+	})
+
+	It("should convert from tokens per second", func() {
+		for _, tc := range []struct {
+			rate float64
+			n    int
+			per  time.Duration
+		}{
+			{20, 20, time.Second},
+			{1.5, 3, 2 * time.Second},
+			{0.5, 1, 2 * time.Second},
+			{1.0 / 60, 1, time.Minute},
+			{1.0 / 3, 1, 3 * time.Second},
+			{0.0001, 1, 10000 * time.Second},
+		} {
+			n, per := FromPerSecond(tc.rate)
+			Expect(n).To(Equal(tc.n), "%v", tc.rate)
+			Expect(per).To(Equal(tc.per), "%v", tc.rate)
+		}
+
+		n, per := FromPerSecond(-1)
+		Expect(n).To(BeZero())
+		Expect(per).To(Equal(time.Second))
+	})
+
+	It("should normalize awkward rates", func() {
+		for _, tc := range []struct {
+			n, expectN     int
+			per, expectPer time.Duration
+		}{
+			{7, 20, 350 * time.Millisecond, time.Second},
+			{10, 10, time.Second, time.Second},
+			{1000, 1000, time.Millisecond, time.Millisecond},
+			{1, 12, 5 * time.Minute, time.Hour},
+			{3, 3, 700 * time.Millisecond, 700 * time.Millisecond},
+			{6, 3, 1400 * time.Millisecond, 700 * time.Millisecond},
+		} {
+			n, per := Normalize(tc.n, tc.per)
+			Expect(n).To(Equal(tc.expectN), "%d per %v", tc.n, tc.per)
+			Expect(per).To(Equal(tc.expectPer), "%d per %v", tc.n, tc.per)
+		}
+	})
+})
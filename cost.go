@@ -0,0 +1,77 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import "math"
+
+// ReportCost reconciles an operation which was charged a number of tokens with its actual
+// cost, once known, such as when an upstream API reports what a call cost. Extra tokens
+// are consumed even if not available, in which case the difference is owed and repaid
+// from future refills, while the tokens overcharged are refunded.
+func (rl *Limiter) ReportCost(charged, actual int) {
+	switch diff := actual - charged; {
+	case diff > 0:
+		rl.stats.allowed.Add(uint64(diff))
+		rl.charge(rl.units(uint64(diff)))
+	case diff < 0:
+		rl.stats.allowed.Add(-uint64(-diff))
+		rl.refund(uint64(-diff))
+	}
+}
+
+// Debt returns the number of tokens owed, which will be repaid from future refills.
+func (rl *Limiter) Debt() int {
+	rl.refill()
+	debt := rl.debt.Load()
+	tokens := debt / rl.unit
+	if debt%rl.unit != 0 {
+		tokens++ // rounded up, as a partial token is still owed
+	}
+	return int(tokens)
+}
+
+// charge consumes n units of allowance, owing what is not currently available
+func (rl *Limiter) charge(n uint64) {
+	for {
+		current := rl.allowance.Load()
+		paid := minUint64(current, n)
+		if rl.allowance.CompareAndSwap(current, current-paid) {
+			if n > paid {
+				rl.owe(n - paid)
+			}
+			return
+		}
+	}
+}
+
+// owe adds n units to the debt, saturating instead of overflowing
+func (rl *Limiter) owe(n uint64) {
+	for {
+		debt := rl.debt.Load()
+		next := debt + n
+		if next < debt {
+			next = math.MaxUint64
+		}
+		if rl.debt.CompareAndSwap(debt, next) {
+			return
+		}
+	}
+}
+
+// repay pays back the debt from refilled units of allowance and returns what is left
+func (rl *Limiter) repay(refilled uint64) uint64 {
+	for {
+		debt := rl.debt.Load()
+		if debt == 0 {
+			return refilled
+		}
+
+		paid := minUint64(debt, refilled)
+		if rl.debt.CompareAndSwap(debt, debt-paid) {
+			return refilled - paid
+		}
+	}
+}
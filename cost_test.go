@@ -0,0 +1,54 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cost", func() {
+
+	It("should refund overcharged tokens", func() {
+		rl := New(10, time.Minute)
+		Expect(rl.LimitN(5)).To(BeFalse())
+		rl.ReportCost(5, 2)
+		Expect(rl.Remaining()).To(Equal(8))
+		Expect(rl.Stats().Allowed).To(Equal(uint64(2)))
+	})
+
+	It("should charge extra tokens", func() {
+		rl := New(10, time.Minute)
+		Expect(rl.Limit()).To(BeFalse())
+		rl.ReportCost(1, 4)
+		Expect(rl.Remaining()).To(Equal(6))
+		Expect(rl.Debt()).To(BeZero())
+		Expect(rl.Stats().Allowed).To(Equal(uint64(4)))
+
+		rl.ReportCost(1, 1)
+		Expect(rl.Remaining()).To(Equal(6))
+	})
+
+	It("should repay the debt from future refills", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock))
+		Expect(rl.LimitN(8)).To(BeFalse())
+		rl.ReportCost(1, 6)
+		Expect(rl.Remaining()).To(BeZero())
+		Expect(rl.Debt()).To(Equal(3))
+
+		clock.Advance(200 * time.Millisecond)
+		Expect(rl.Remaining()).To(BeZero())
+		Expect(rl.Debt()).To(Equal(1))
+
+		clock.Advance(200 * time.Millisecond)
+		Expect(rl.Debt()).To(BeZero())
+		Expect(rl.Remaining()).To(Equal(1))
+	})
+
+})
@@ -0,0 +1,103 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// costSmoothing is the weight of each new sample in the moving average of the costs
+const costSmoothing = 0.2
+
+// CostModel learns the average downstream latency of the operations of each category
+// from the results reported by the callers, and charges every operation of a category
+// as many tokens of the limiter as its latency is worth, so that cost tables do not have
+// to be hand-tuned as the workloads shift. CostModel instances are thread-safe.
+type CostModel struct {
+	lock     sync.Mutex
+	limiter  *Limiter
+	baseline time.Duration      // latency worth a single token
+	latency  map[string]float64 // moving average of the latency per category, in ns
+}
+
+// LearnCosts creates a new cost model charging the limiter a token for every baseline
+// of average latency observed for a category. Categories without any result reported
+// yet cost a single token.
+func LearnCosts(rl *Limiter, baseline time.Duration) *CostModel {
+	if baseline <= 0 {
+		baseline = time.Millisecond
+	}
+
+	return &CostModel{
+		limiter:  rl,
+		baseline: baseline,
+		latency:  make(map[string]float64),
+	}
+}
+
+// Limit charges the limiter the learned cost of an operation of the category and
+// returns the cost along with true if the rate was exceeded.
+func (m *CostModel) Limit(category string) (cost int, limited bool) {
+	cost = m.Cost(category)
+	return cost, m.limiter.LimitN(cost)
+}
+
+// ReportResult reports the latency and the outcome of an operation of the category. The
+// latency is folded into the learned cost of the category, while the outcome is reported
+// to the limiter as with ReportFailure().
+func (m *CostModel) ReportResult(category string, latency time.Duration, err error) {
+	if latency >= 0 {
+		m.lock.Lock()
+		if avg, ok := m.latency[category]; ok {
+			m.latency[category] = avg + costSmoothing*(float64(latency)-avg)
+		} else {
+			m.latency[category] = float64(latency)
+		}
+		m.lock.Unlock()
+	}
+
+	m.limiter.report(err)
+}
+
+// Cost returns the number of tokens currently charged for an operation of the category,
+// at least one and at most the burst of the limiter.
+func (m *CostModel) Cost(category string) int {
+	m.lock.Lock()
+	avg, ok := m.latency[category]
+	m.lock.Unlock()
+	if !ok {
+		return 1
+	}
+
+	return m.tokens(avg)
+}
+
+// Costs returns the number of tokens currently charged for each category with results.
+func (m *CostModel) Costs() map[string]int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	costs := make(map[string]int, len(m.latency))
+	for category, avg := range m.latency {
+		costs[category] = m.tokens(avg)
+	}
+	return costs
+}
+
+// tokens converts an average latency to a number of tokens within the burst
+func (m *CostModel) tokens(latency float64) int {
+	cost := int(math.Round(latency / float64(m.baseline)))
+	_, max := m.limiter.limits(m.limiter.now())
+	if burst := int(max / m.limiter.unit); cost > burst {
+		cost = burst
+	}
+	if cost < 1 {
+		cost = 1
+	}
+	return cost
+}
@@ -0,0 +1,64 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CostModel", func() {
+
+	It("should charge a single token for unknown categories", func() {
+		m := LearnCosts(New(10, time.Hour), 10*time.Millisecond)
+		cost, limited := m.Limit("search")
+		Expect(cost).To(Equal(1))
+		Expect(limited).To(BeFalse())
+		Expect(m.Costs()).To(BeEmpty())
+	})
+
+	It("should learn the cost from the reported latency", func() {
+		rl := New(100, time.Hour)
+		m := LearnCosts(rl, 10*time.Millisecond)
+		m.ReportResult("search", 50*time.Millisecond, nil)
+		m.ReportResult("lookup", 5*time.Millisecond, nil)
+		Expect(m.Costs()).To(Equal(map[string]int{"search": 5, "lookup": 1}))
+
+		cost, limited := m.Limit("search")
+		Expect(cost).To(Equal(5))
+		Expect(limited).To(BeFalse())
+		Expect(rl.Remaining()).To(Equal(95))
+	})
+
+	It("should adjust the cost as the workload shifts", func() {
+		m := LearnCosts(New(100, time.Hour), 10*time.Millisecond)
+		m.ReportResult("search", 10*time.Millisecond, nil)
+		for i := 0; i < 30; i++ {
+			m.ReportResult("search", 100*time.Millisecond, nil)
+		}
+		Expect(m.Cost("search")).To(Equal(10))
+	})
+
+	It("should cap the cost to the burst", func() {
+		m := LearnCosts(New(5, time.Hour), time.Millisecond)
+		m.ReportResult("export", time.Second, nil)
+		Expect(m.Cost("export")).To(Equal(5))
+	})
+
+	It("should report the outcome to the limiter", func() {
+		rl := New(10, time.Hour)
+		m := LearnCosts(rl, time.Millisecond)
+		m.ReportResult("search", time.Millisecond, nil)
+		m.ReportResult("search", time.Millisecond, errors.New("failed"))
+
+		stats := rl.Stats()
+		Expect(stats.Success).To(Equal(uint64(1)))
+		Expect(stats.Failure).To(Equal(uint64(1)))
+	})
+})
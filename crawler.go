@@ -0,0 +1,128 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Crawler is a politeness limiter for web crawlers which spaces out the requests made
+// to each host. Each host may specify its own delay, for example through the Crawl-Delay
+// directive of its robots.txt, and the delay is automatically increased whenever a host
+// responds with 429 or 503 status codes. Crawler instances are thread-safe.
+type Crawler struct {
+	hosts *Keyed
+	lock  sync.Mutex
+	state map[string]*politeness
+	delay time.Duration // default delay between requests to a host
+	max   time.Duration // maximum delay after slowdowns
+}
+
+// politeness represents the delay state of a single host
+type politeness struct {
+	base    time.Duration // delay requested by the host
+	current time.Duration // delay currently applied
+}
+
+// NewCrawler creates a new politeness limiter, allowing one request per delay to any
+// given host. The delay of a host never grows beyond the maximum after slowdowns.
+func NewCrawler(delay, max time.Duration) *Crawler {
+	if max < delay {
+		max = delay
+	}
+
+	return &Crawler{
+		hosts: NewKeyed(1, delay),
+		state: make(map[string]*politeness),
+		delay: delay,
+		max:   max,
+	}
+}
+
+// Wait blocks until a request to the host is allowed or the context is done.
+func (c *Crawler) Wait(ctx context.Context, host string) error {
+	return c.hosts.Wait(ctx, host)
+}
+
+// Limit returns true if a request to the host would be impolite right now.
+func (c *Crawler) Limit(host string) bool {
+	return c.hosts.Limit(host)
+}
+
+// Delay returns the delay currently applied between requests to the host.
+func (c *Crawler) Delay(host string) time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if p, ok := c.state[host]; ok {
+		return p.current
+	}
+	return c.delay
+}
+
+// SetCrawlDelay sets the delay requested by the host, typically the Crawl-Delay value
+// of its robots.txt.
+func (c *Crawler) SetCrawlDelay(host string, delay time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.apply(host, &politeness{base: delay, current: delay})
+}
+
+// Observe adjusts the delay for the host given the status code of its response. The delay
+// is doubled on 429 and 503 responses, and gradually restored on successful ones.
+func (c *Crawler) Observe(host string, status int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	p, ok := c.state[host]
+	if !ok {
+		p = &politeness{base: c.delay, current: c.delay}
+	}
+
+	switch {
+	case status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable:
+		next := 2 * p.current
+		if next > c.max {
+			next = c.max
+		}
+		c.apply(host, &politeness{base: p.base, current: next})
+	case status < 400 && p.current > p.base:
+		next := p.current / 2
+		if next < p.base {
+			next = p.base
+		}
+		c.apply(host, &politeness{base: p.base, current: next})
+	}
+}
+
+// apply stores the delay state of the host and replaces its limiter if the delay has
+// changed, must be called while holding the lock
+func (c *Crawler) apply(host string, p *politeness) {
+	if prev, ok := c.state[host]; ok && prev.current == p.current {
+		c.state[host] = p
+		return
+	}
+
+	// Start the replacement limiter empty, since the host was presumably just visited
+	rl := New(1, p.current)
+	rl.limit()
+	c.state[host] = p
+	c.hosts.Set(host, rl)
+}
+
+// ParseCrawlDelay parses the value of a Crawl-Delay directive, expressed in seconds.
+func ParseCrawlDelay(value string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || seconds < 0 {
+		return 0, &strconv.NumError{Func: "ParseCrawlDelay", Num: value, Err: strconv.ErrSyntax}
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
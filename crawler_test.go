@@ -0,0 +1,67 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Crawler", func() {
+
+	It("should space out requests per host", func() {
+		c := NewCrawler(time.Minute, time.Hour)
+		Expect(c.Wait(context.Background(), "a.com")).To(Succeed())
+		Expect(c.Limit("a.com")).To(BeTrue())
+		Expect(c.Limit("b.com")).To(BeFalse())
+	})
+
+	It("should apply the crawl delay", func() {
+		c := NewCrawler(time.Minute, time.Hour)
+		c.SetCrawlDelay("a.com", 10*time.Millisecond)
+		Expect(c.Delay("a.com")).To(Equal(10 * time.Millisecond))
+		Expect(c.Delay("b.com")).To(Equal(time.Minute))
+
+		start := time.Now()
+		Expect(c.Wait(context.Background(), "a.com")).To(Succeed())
+		Expect(c.Wait(context.Background(), "a.com")).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 10*time.Millisecond))
+	})
+
+	It("should slow down when the host is overloaded", func() {
+		c := NewCrawler(time.Second, 3*time.Second)
+		c.Observe("a.com", http.StatusTooManyRequests)
+		Expect(c.Delay("a.com")).To(Equal(2 * time.Second))
+		Expect(c.Limit("a.com")).To(BeTrue())
+
+		c.Observe("a.com", http.StatusServiceUnavailable)
+		Expect(c.Delay("a.com")).To(Equal(3 * time.Second))
+
+		c.Observe("a.com", http.StatusNotFound)
+		Expect(c.Delay("a.com")).To(Equal(3 * time.Second))
+
+		c.Observe("a.com", http.StatusOK)
+		Expect(c.Delay("a.com")).To(Equal(1500 * time.Millisecond))
+		c.Observe("a.com", http.StatusOK)
+		Expect(c.Delay("a.com")).To(Equal(time.Second))
+	})
+
+	It("should parse crawl delays", func() {
+		d, err := ParseCrawlDelay(" 2.5 ")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d).To(Equal(2500 * time.Millisecond))
+
+		_, err = ParseCrawlDelay("soon")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseCrawlDelay("-1")
+		Expect(err).To(HaveOccurred())
+	})
+
+})
@@ -0,0 +1,52 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cron", func() {
+	at := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04", s)
+		Expect(err).NotTo(HaveOccurred())
+		return t
+	}
+
+	next := func(expr, from string) time.Time {
+		c, err := ParseCron(expr)
+		Expect(err).NotTo(HaveOccurred())
+		return c.Next(at(from))
+	}
+
+	It("should compute the next time", func() {
+		Expect(next("* * * * *", "2020-01-01 10:00")).To(Equal(at("2020-01-01 10:01")))
+		Expect(next("30 8 * * *", "2020-01-01 10:00")).To(Equal(at("2020-01-02 08:30")))
+		Expect(next("*/15 * * * *", "2020-01-01 10:16")).To(Equal(at("2020-01-01 10:30")))
+		Expect(next("0 0 1 * *", "2020-01-15 10:00")).To(Equal(at("2020-02-01 00:00")))
+		Expect(next("0 9 * * 1-5", "2020-01-03 10:00")).To(Equal(at("2020-01-06 09:00")))
+		Expect(next("0 0 * * 7", "2020-01-01 00:00")).To(Equal(at("2020-01-05 00:00")))
+		Expect(next("0 0 29 2 *", "2020-03-01 00:00")).To(Equal(at("2024-02-29 00:00")))
+		Expect(next("0 12 13 * 5", "2020-01-01 00:00")).To(Equal(at("2020-01-03 12:00")))
+		Expect(next("0,30 8-9 * 6 *", "2020-01-01 00:00")).To(Equal(at("2020-06-01 08:00")))
+	})
+
+	It("should not find impossible times", func() {
+		Expect(next("0 0 31 2 *", "2020-01-01 00:00").IsZero()).To(BeTrue())
+	})
+
+	It("should reject invalid expressions", func() {
+		for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+			"* * * 13 *", "* * * * 8", "*/0 * * * *", "a * * * *", "5-1 * * * *", "1-a * * * *"} {
+			_, err := ParseCron(expr)
+			Expect(err).To(HaveOccurred(), expr)
+		}
+	})
+
+})
@@ -0,0 +1,265 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"net"
+	"os"
+	"sync"
+)
+
+// The protocol between the coordinator and its clients is a tiny binary one. Once
+// connected, a client sends its key prefixed by its length as a big-endian uint16, then
+// each request is the number of tokens to take as a big-endian uint32, zero only querying
+// the allowance. Each response is a byte which is 1 if the request was limited, followed
+// by the remaining tokens as a big-endian uint32.
+const responseSize = 5
+
+// Coordinator serves the budgets of a keyed limiter over a unix socket, so that unrelated
+// processes on a host can share them without an external store such as Redis. Coordinator
+// instances are thread-safe.
+type Coordinator struct {
+	listener net.Listener
+	limiter  *Keyed
+	lock     sync.Mutex
+	conns    map[net.Conn]struct{} // connections being served
+	stop     sync.WaitGroup
+	once     sync.Once
+}
+
+// Coordinate starts serving the keyed limiter on the unix socket at the path, replacing
+// a stale socket left by a previous coordinator. It starts a background goroutine which
+// is stopped by Close().
+func Coordinate(path string, k *Keyed) (*Coordinator, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Coordinator{
+		listener: listener,
+		limiter:  k,
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	c.stop.Add(1)
+	go c.accept()
+	return c, nil
+}
+
+// Close stops serving, closing the socket and every connection of the clients.
+func (c *Coordinator) Close() (err error) {
+	c.once.Do(func() {
+		err = c.listener.Close()
+		c.lock.Lock()
+		for conn := range c.conns {
+			conn.Close()
+		}
+		c.conns = nil
+		c.lock.Unlock()
+		c.stop.Wait()
+	})
+	return
+}
+
+// accept accepts the connections of the clients until the listener is closed
+func (c *Coordinator) accept() {
+	defer c.stop.Done()
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c.lock.Lock()
+		if c.conns == nil {
+			c.lock.Unlock()
+			conn.Close()
+			return
+		}
+
+		c.conns[conn] = struct{}{}
+		c.stop.Add(1)
+		c.lock.Unlock()
+		go c.serve(conn)
+	}
+}
+
+// serve answers the requests of a client until its connection is closed
+func (c *Coordinator) serve(conn net.Conn) {
+	defer c.stop.Done()
+	defer func() {
+		c.lock.Lock()
+		delete(c.conns, conn)
+		c.lock.Unlock()
+		conn.Close()
+	}()
+
+	var buf [responseSize]byte
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+
+	key := make([]byte, binary.BigEndian.Uint16(buf[:2]))
+	if _, err := io.ReadFull(conn, key); err != nil {
+		return
+	}
+
+	rl := c.limiter.Get(string(key))
+	for {
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+			return
+		}
+
+		// Counts which do not fit an int, on 32-bit platforms, are over any burst
+		buf[0] = 0
+		if n := binary.BigEndian.Uint32(buf[:4]); uint64(n) > math.MaxInt || rl.LimitN(int(n)) {
+			buf[0] = 1
+		}
+
+		binary.BigEndian.PutUint32(buf[1:], uint32(rl.Remaining()))
+		if _, err := conn.Write(buf[:]); err != nil {
+			return
+		}
+	}
+}
+
+// ------------------------------------ Client ------------------------------------
+
+// Remote is a limiter of a single key whose budget is held by a coordinator on the
+// same host. The connection is re-established on the next call after a failure, and
+// while the coordinator is unreachable calls are limited, so that processes never
+// exceed the shared budget. Remote instances are thread-safe.
+type Remote struct {
+	lock   sync.Mutex
+	path   string
+	key    string
+	conn   net.Conn // connection to the coordinator, nil when disconnected
+	err    error    // error of the last call
+	closed bool
+}
+
+// DialCoordinator connects to the coordinator listening on the unix socket at the path
+// and returns a limiter drawing from the budget of the key.
+func DialCoordinator(path, key string) (*Remote, error) {
+	if len(key) > math.MaxUint16 {
+		return nil, ErrInvalidKey
+	}
+
+	r := &Remote{path: path, key: key}
+	if err := r.connect(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Limit returns true if the shared rate was exceeded or the coordinator is unreachable.
+func (r *Remote) Limit() bool {
+	return r.LimitN(1)
+}
+
+// LimitN returns true if the shared rate would be exceeded by n calls at once or the
+// coordinator is unreachable, otherwise it consumes n tokens.
+func (r *Remote) LimitN(n int) bool {
+	if n < 1 {
+		return false
+	}
+
+	if uint64(n) > math.MaxUint32 {
+		return true
+	}
+
+	limited, _ := r.call(uint32(n))
+	return limited
+}
+
+// Remaining returns the number of tokens left in the shared budget, or zero if the
+// coordinator is unreachable.
+func (r *Remote) Remaining() int {
+	_, remaining := r.call(0)
+	return remaining
+}
+
+// Err returns the error of the last call, if it failed.
+func (r *Remote) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+// Close closes the connection to the coordinator, after which every call is limited
+// with ErrClosed.
+func (r *Remote) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.closed = true
+	if r.conn == nil {
+		return nil
+	}
+
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// call sends a request for n tokens and returns whether it was limited and the tokens
+// remaining, reconnecting first if needed
+func (r *Remote) call(n uint32) (bool, int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	switch {
+	case r.closed:
+		r.err = ErrClosed
+		return true, 0
+	case r.conn == nil:
+		if r.err = r.connect(); r.err != nil {
+			return true, 0
+		}
+	}
+
+	var buf [responseSize]byte
+	binary.BigEndian.PutUint32(buf[:4], n)
+	if _, r.err = r.conn.Write(buf[:4]); r.err == nil {
+		_, r.err = io.ReadFull(r.conn, buf[:])
+	}
+
+	if r.err != nil {
+		r.conn.Close()
+		r.conn = nil
+		return true, 0
+	}
+	return buf[0] == 1, int(binary.BigEndian.Uint32(buf[1:]))
+}
+
+// connect connects to the coordinator and sends the key
+func (r *Remote) connect() error {
+	conn, err := net.Dial("unix", r.path)
+	if err != nil {
+		return err
+	}
+
+	hello := make([]byte, 2+len(r.key))
+	binary.BigEndian.PutUint16(hello, uint16(len(r.key)))
+	copy(hello[2:], r.key)
+	if _, err := conn.Write(hello); err != nil {
+		conn.Close()
+		return err
+	}
+
+	r.conn = conn
+	return nil
+}
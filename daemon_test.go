@@ -0,0 +1,130 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Coordinator", func() {
+	var dir, path string
+	var coordinator *Coordinator
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "rate")
+		Expect(err).NotTo(HaveOccurred())
+
+		path = filepath.Join(dir, "rate.sock")
+		coordinator, err = Coordinate(path, NewKeyed(10, time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(coordinator.Close()).To(Succeed())
+		os.RemoveAll(dir)
+	})
+
+	It("should share the budget between clients", func() {
+		a, err := DialCoordinator(path, "uploads")
+		Expect(err).NotTo(HaveOccurred())
+		defer a.Close()
+		b, err := DialCoordinator(path, "uploads")
+		Expect(err).NotTo(HaveOccurred())
+		defer b.Close()
+
+		Expect(a.LimitN(6)).To(BeFalse())
+		Expect(b.LimitN(6)).To(BeTrue())
+		Expect(b.LimitN(4)).To(BeFalse())
+		Expect(a.Limit()).To(BeTrue())
+		Expect(a.Remaining()).To(BeZero())
+		Expect(a.Err()).NotTo(HaveOccurred())
+	})
+
+	It("should deny requests over the burst", func() {
+		r, err := DialCoordinator(path, "uploads")
+		Expect(err).NotTo(HaveOccurred())
+		defer r.Close()
+
+		Expect(r.LimitN(math.MaxInt32)).To(BeTrue())
+		limited, _ := r.call(math.MaxUint32)
+		Expect(limited).To(BeTrue())
+		Expect(r.Remaining()).To(Equal(10))
+	})
+
+	It("should keep the keys apart", func() {
+		a, err := DialCoordinator(path, "uploads")
+		Expect(err).NotTo(HaveOccurred())
+		defer a.Close()
+		b, err := DialCoordinator(path, "downloads")
+		Expect(err).NotTo(HaveOccurred())
+		defer b.Close()
+
+		Expect(a.LimitN(10)).To(BeFalse())
+		Expect(b.Remaining()).To(Equal(10))
+	})
+
+	It("should stay within the budget under concurrency", func() {
+		var admitted atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			r, err := DialCoordinator(path, "uploads")
+			Expect(err).NotTo(HaveOccurred())
+			defer r.Close()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					if !r.Limit() {
+						admitted.Add(1)
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+		Expect(admitted.Load()).To(Equal(int32(10)))
+	})
+
+	It("should limit while the coordinator is unreachable", func() {
+		r, err := DialCoordinator(path, "uploads")
+		Expect(err).NotTo(HaveOccurred())
+		defer r.Close()
+
+		Expect(coordinator.Close()).To(Succeed())
+		Expect(r.Limit()).To(BeTrue())
+		Expect(r.Err()).To(HaveOccurred())
+
+		coordinator, err = Coordinate(path, NewKeyed(10, time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Limit()).To(BeFalse())
+		Expect(r.Err()).NotTo(HaveOccurred())
+	})
+
+	It("should limit once closed", func() {
+		r, err := DialCoordinator(path, "uploads")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Close()).To(Succeed())
+		Expect(r.Close()).To(Succeed())
+		Expect(r.Limit()).To(BeTrue())
+		Expect(errors.Is(r.Err(), ErrClosed)).To(BeTrue())
+	})
+
+	It("should reject a key which is too long", func() {
+		_, err := DialCoordinator(path, string(make([]byte, 1<<16)))
+		Expect(err).To(Equal(ErrInvalidKey))
+	})
+})
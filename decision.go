@@ -0,0 +1,137 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"time"
+)
+
+// Reason represents why a call was rejected.
+type Reason uint8
+
+// Various reasons of a rejection
+const (
+	ReasonNone      Reason = iota // the call was allowed
+	ReasonRate                    // the rate was exceeded
+	ReasonBurst                   // the call exceeds the maximum burst and can never be allowed
+	ReasonBan                     // the key is banned
+	ReasonQueueFull               // too many calls are waiting, or they would wait for too long
+	ReasonPaused                  // every call is blocked, during a penalty or once closed
+)
+
+// String returns the name of the reason, as used in logs.
+func (r Reason) String() string {
+	switch r {
+	case ReasonNone:
+		return "none"
+	case ReasonRate:
+		return "rate"
+	case ReasonBurst:
+		return "burst"
+	case ReasonBan:
+		return "ban"
+	case ReasonQueueFull:
+		return "queue-full"
+	case ReasonPaused:
+		return "paused"
+	default:
+		return "unknown"
+	}
+}
+
+// ReasonOf returns the reason of the rejection of a call which failed with the error, such
+// as an error returned by Wait(), or ReasonNone if the error is not a rejection.
+func ReasonOf(err error) Reason {
+	switch {
+	case err == nil:
+		return ReasonNone
+	case errors.Is(err, ErrQueueFull), errors.Is(err, ErrDeadline):
+		return ReasonQueueFull
+	case errors.Is(err, ErrBanned):
+		return ReasonBan
+	case errors.Is(err, ErrClosed):
+		return ReasonPaused
+	case errors.Is(err, ErrBudget):
+		return ReasonBurst
+	case errors.Is(err, ErrLimited):
+		return ReasonRate
+	default:
+		return ReasonNone
+	}
+}
+
+// Decision represents the outcome of an admission, explaining why a call was rejected
+// rather than only that it was.
+type Decision struct {
+	Allowed    bool          // Whether the call was allowed
+	Reason     Reason        // Why the call was rejected, if it was
+	Remaining  int           // Number of calls which would currently be allowed
+	RetryAfter time.Duration // Expected time until a retry could succeed, if rejected
+}
+
+// Decide consumes n tokens if they are available, as LimitN() does, and returns the
+// decision along with its reason.
+func (rl *Limiter) Decide(n int) Decision {
+	if n < 1 {
+		return Decision{Allowed: true, Remaining: rl.Remaining()}
+	}
+
+	now := rl.now()
+	_, max, blocked := rl.effective(now)
+	switch {
+	case uint64(n) > max/rl.unit && !rl.ext().shadow:
+		rl.record(true, uint64(n))
+		return Decision{Reason: ReasonBurst}
+	case blocked && !rl.ext().shadow:
+		rl.record(true, uint64(n))
+		return Decision{Reason: ReasonPaused, RetryAfter: rl.delay()}
+	}
+
+	if !rl.LimitN(n) {
+		return Decision{Allowed: true, Remaining: rl.Remaining()}
+	}
+
+	retry, _ := rl.until(now, rl.units(uint64(n))+rl.headroom())
+	return Decision{
+		Reason:     ReasonRate,
+		Remaining:  rl.Remaining(),
+		RetryAfter: retry,
+	}
+}
+
+// Decide consumes n tokens of the key if they are available and the key is not banned,
+// and returns the decision along with its reason.
+func (k *Keyed) Decide(key string, n int) Decision {
+	rl, banned := k.lookup(key)
+	var d Decision
+	if banned {
+		d = Decision{Reason: ReasonBan, RetryAfter: k.banned(key)}
+	} else {
+		d = rl.Decide(n)
+	}
+
+	if k.activity != nil {
+		k.observe(key, !d.Allowed)
+	}
+	if !d.Allowed && !banned && k.autoban != nil {
+		k.strike(key)
+	}
+	return d
+}
+
+// banned returns the time left until the ban of the key expires
+func (k *Keyed) banned(key string) time.Duration {
+	s := k.shard(key)
+	s.RLock()
+	until := s.bans[key]
+	s.RUnlock()
+
+	if left := time.Duration(until - time.Now().UnixNano()); left > 0 {
+		return left
+	}
+	return 0
+}
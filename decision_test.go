@@ -0,0 +1,81 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Decision", func() {
+
+	It("should explain rate rejections", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(2, time.Second, WithClock(clock))
+		Expect(rl.Decide(1)).To(Equal(Decision{Allowed: true, Remaining: 1}))
+		Expect(rl.Decide(1)).To(Equal(Decision{Allowed: true}))
+
+		d := rl.Decide(1)
+		Expect(d.Allowed).To(BeFalse())
+		Expect(d.Reason).To(Equal(ReasonRate))
+		Expect(d.RetryAfter).To(Equal(500 * time.Millisecond))
+		Expect(rl.Stats().Denied).To(BeEquivalentTo(1))
+	})
+
+	It("should explain burst rejections", func() {
+		rl := New(2, time.Second)
+		d := rl.Decide(3)
+		Expect(d.Reason).To(Equal(ReasonBurst))
+		Expect(rl.Remaining()).To(Equal(2))
+	})
+
+	It("should explain paused limiters", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(1, time.Second, WithClock(clock), WithPenalty(1, time.Minute, time.Minute, 0))
+		rl.Limit()
+		rl.Limit()
+
+		d := rl.Decide(1)
+		Expect(d.Reason).To(Equal(ReasonPaused))
+		Expect(d.RetryAfter).To(Equal(time.Minute))
+	})
+
+	It("should explain bans", func() {
+		k := NewKeyed(1, time.Hour)
+		Expect(k.Decide("alice", 1).Allowed).To(BeTrue())
+		Expect(k.Decide("alice", 1).Reason).To(Equal(ReasonRate))
+
+		k.Ban("alice", time.Minute)
+		d := k.Decide("alice", 1)
+		Expect(d.Reason).To(Equal(ReasonBan))
+		Expect(d.RetryAfter).To(BeNumerically("~", time.Minute, time.Second))
+	})
+
+	It("should explain errors", func() {
+		rl := New(1, time.Hour, WithMaxWaiters(1))
+		rl.Limit()
+		go rl.Wait(context.Background())
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+
+		Expect(ReasonOf(rl.Wait(context.Background()))).To(Equal(ReasonQueueFull))
+		Expect(ReasonOf(rl.Try())).To(Equal(ReasonRate))
+		Expect(ReasonOf(fmt.Errorf("wait: %w", ErrBanned))).To(Equal(ReasonBan))
+		Expect(ReasonOf(ErrClosed)).To(Equal(ReasonPaused))
+		Expect(ReasonOf(errors.New("boom"))).To(Equal(ReasonNone))
+		Expect(ReasonOf(nil)).To(Equal(ReasonNone))
+		rl.Close()
+	})
+
+	It("should name the reasons", func() {
+		Expect(ReasonQueueFull.String()).To(Equal("queue-full"))
+		Expect(Reason(42).String()).To(Equal("unknown"))
+	})
+})
@@ -0,0 +1,75 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+// dedup represents the identifiers of the calls admitted recently, so their retries are
+// not charged again
+type dedup struct {
+	sync.Mutex
+	window  uint64            // duration an identifier is remembered for, in ns
+	seen    map[string]uint64 // expiry of the identifiers admitted, in ns
+	pruneAt int               // number of identifiers beyond which expired ones are pruned
+}
+
+// WithDedup remembers the identifiers of the calls admitted by AcquireOnce() for the
+// specified window, so that redeliveries of the same message by an at-least-once
+// pipeline are not charged twice.
+func WithDedup(window time.Duration) Option {
+	return func(rl *Limiter) {
+		rl.extend().dedup = &dedup{
+			window:  uint64(window),
+			seen:    make(map[string]uint64),
+			pruneAt: 1024,
+		}
+	}
+}
+
+// AcquireOnce returns true if the call with the identifier is admitted, consuming a token
+// unless a call with the same identifier was already admitted within the dedup window.
+// Denied calls are not remembered, so their retries are charged. Without WithDedup(),
+// every call is charged.
+func (rl *Limiter) AcquireOnce(id string) bool {
+	d := rl.ext().dedup
+	if d == nil {
+		return !rl.Limit()
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	now := rl.now()
+	if expiry, ok := d.seen[id]; ok && expiry > now {
+		return true
+	}
+
+	if rl.Limit() {
+		return false
+	}
+
+	d.seen[id] = now + d.window
+	if len(d.seen) > d.pruneAt {
+		d.prune(now)
+	}
+	return true
+}
+
+// prune removes the expired identifiers, must be called while holding the lock
+func (d *dedup) prune(now uint64) {
+	for id, expiry := range d.seen {
+		if expiry <= now {
+			delete(d.seen, id)
+		}
+	}
+
+	if d.pruneAt = 2 * len(d.seen); d.pruneAt < 1024 {
+		d.pruneAt = 1024
+	}
+}
@@ -0,0 +1,59 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dedup", func() {
+
+	It("should not charge redeliveries twice", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(2, time.Hour, WithClock(clock), WithDedup(time.Minute))
+
+		Expect(rl.AcquireOnce("msg-1")).To(BeTrue())
+		Expect(rl.AcquireOnce("msg-1")).To(BeTrue())
+		Expect(rl.Remaining()).To(Equal(1))
+
+		Expect(rl.AcquireOnce("msg-2")).To(BeTrue())
+		Expect(rl.AcquireOnce("msg-3")).To(BeFalse())
+		Expect(rl.AcquireOnce("msg-1")).To(BeTrue())
+	})
+
+	It("should charge again once the window is over", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(1, time.Hour, WithClock(clock), WithDedup(time.Minute))
+
+		Expect(rl.AcquireOnce("msg-1")).To(BeTrue())
+		clock.Advance(time.Minute)
+		Expect(rl.AcquireOnce("msg-1")).To(BeFalse())
+	})
+
+	It("should charge every call without a window", func() {
+		rl := New(1, time.Hour)
+		Expect(rl.AcquireOnce("msg-1")).To(BeTrue())
+		Expect(rl.AcquireOnce("msg-1")).To(BeFalse())
+	})
+
+	It("should prune the expired identifiers", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(1000000, time.Second, WithClock(clock), WithDedup(time.Second))
+		for i := 0; i < 1000; i++ {
+			rl.AcquireOnce(strconv.Itoa(i))
+		}
+
+		clock.Advance(time.Second)
+		for i := 0; i < 100; i++ {
+			rl.AcquireOnce("new" + strconv.Itoa(i))
+		}
+		Expect(len(rl.ext().dedup.seen)).To(Equal(100))
+	})
+})
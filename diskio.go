@@ -0,0 +1,167 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// IOThrottle paces I/O operations on both the number of operations per second and the
+// throughput in bytes per second, so that background jobs such as compactions or backups
+// do not starve foreground workloads on the same disk. Either limiter may be nil.
+type IOThrottle struct {
+	iops  *Limiter
+	bytes *ByteLimiter
+}
+
+// NewIOThrottle creates a new throttle limiting operations on the iops limiter and the
+// throughput on the bytes limiter.
+func NewIOThrottle(iops *Limiter, bytes *ByteLimiter) *IOThrottle {
+	return &IOThrottle{
+		iops:  iops,
+		bytes: bytes,
+	}
+}
+
+// wait blocks until an operation transferring n bytes is allowed
+func (t *IOThrottle) wait(n int) error {
+	ctx := context.Background()
+	if t.iops != nil {
+		if err := t.iops.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if t.bytes != nil && n > 0 {
+		return t.bytes.WaitBytes(ctx, int64(n))
+	}
+	return nil
+}
+
+// done returns the bytes which were reserved for an operation but not transferred
+func (t *IOThrottle) done(reserved, n int) {
+	if t.bytes != nil && n < reserved {
+		if n < 0 {
+			n = 0
+		}
+		t.bytes.ReturnBytes(int64(reserved - n))
+	}
+}
+
+// Reader wraps the reader so that every read is throttled.
+func (t *IOThrottle) Reader(r io.Reader) io.Reader {
+	return &throttledReader{t, r}
+}
+
+// Writer wraps the writer so that every write is throttled.
+func (t *IOThrottle) Writer(w io.Writer) io.Writer {
+	return &throttledWriter{t, w}
+}
+
+// ReaderAt wraps the reader so that every read is throttled.
+func (t *IOThrottle) ReaderAt(r io.ReaderAt) io.ReaderAt {
+	return &throttledReaderAt{t, r}
+}
+
+// WriterAt wraps the writer so that every write is throttled.
+func (t *IOThrottle) WriterAt(w io.WriterAt) io.WriterAt {
+	return &throttledWriterAt{t, w}
+}
+
+// File wraps the file so that its reads and writes are throttled.
+func (t *IOThrottle) File(f *os.File) *File {
+	return &File{File: f, throttle: t}
+}
+
+// ---------------------------------- Throttled I/O ----------------------------------
+
+// File represents an *os.File whose Read, ReadAt, Write and WriteAt calls are throttled.
+// Other methods are forwarded to the underlying file untouched.
+type File struct {
+	*os.File
+	throttle *IOThrottle
+}
+
+// Read reads from the file once the throttle allows it.
+func (f *File) Read(p []byte) (int, error) {
+	return (&throttledReader{f.throttle, f.File}).Read(p)
+}
+
+// ReadAt reads from the file at an offset once the throttle allows it.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	return (&throttledReaderAt{f.throttle, f.File}).ReadAt(p, off)
+}
+
+// Write writes to the file once the throttle allows it.
+func (f *File) Write(p []byte) (int, error) {
+	return (&throttledWriter{f.throttle, f.File}).Write(p)
+}
+
+// WriteAt writes to the file at an offset once the throttle allows it.
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	return (&throttledWriterAt{f.throttle, f.File}).WriteAt(p, off)
+}
+
+type throttledReader struct {
+	throttle *IOThrottle
+	r        io.Reader
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if err := t.throttle.wait(len(p)); err != nil {
+		return 0, err
+	}
+
+	n, err := t.r.Read(p)
+	t.throttle.done(len(p), n)
+	return n, err
+}
+
+type throttledWriter struct {
+	throttle *IOThrottle
+	w        io.Writer
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	if err := t.throttle.wait(len(p)); err != nil {
+		return 0, err
+	}
+
+	n, err := t.w.Write(p)
+	t.throttle.done(len(p), n)
+	return n, err
+}
+
+type throttledReaderAt struct {
+	throttle *IOThrottle
+	r        io.ReaderAt
+}
+
+func (t *throttledReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if err := t.throttle.wait(len(p)); err != nil {
+		return 0, err
+	}
+
+	n, err := t.r.ReadAt(p, off)
+	t.throttle.done(len(p), n)
+	return n, err
+}
+
+type throttledWriterAt struct {
+	throttle *IOThrottle
+	w        io.WriterAt
+}
+
+func (t *throttledWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	if err := t.throttle.wait(len(p)); err != nil {
+		return 0, err
+	}
+
+	n, err := t.w.WriteAt(p, off)
+	t.throttle.done(len(p), n)
+	return n, err
+}
@@ -0,0 +1,79 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IOThrottle", func() {
+
+	It("should count operations and return unread bytes", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		iops := New(100, time.Second)
+		b := NewByteLimiter(1000, 0, WithByteClock(clock))
+		t := NewIOThrottle(iops, b)
+
+		buf := make([]byte, 100)
+		n, err := t.Reader(strings.NewReader("hello")).Read(buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(5))
+		Expect(iops.Stats().Allowed).To(Equal(uint64(1)))
+		Expect(b.Available()).To(Equal(int64(995)))
+	})
+
+	It("should throttle the throughput", func() {
+		t := NewIOThrottle(nil, NewByteLimiter(10000, 0))
+		var out bytes.Buffer
+		w := t.Writer(&out)
+
+		start := time.Now()
+		_, err := w.Write(make([]byte, 10000))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = w.Write(make([]byte, 200))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.Len()).To(Equal(10200))
+		Expect(time.Since(start)).To(BeNumerically(">=", 15*time.Millisecond))
+	})
+
+	It("should fail once the limiter is closed", func() {
+		iops := New(1, time.Hour)
+		iops.Close()
+
+		_, err := NewIOThrottle(iops, nil).Writer(io.Discard).Write([]byte("x"))
+		Expect(err).To(Equal(ErrClosed))
+	})
+
+	It("should throttle a file", func() {
+		f, err := os.CreateTemp("", "diskio")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Remove(f.Name())
+		defer f.Close()
+
+		iops := New(100, time.Second)
+		file := NewIOThrottle(iops, nil).File(f)
+		_, err = file.Write([]byte("hello world"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.WriteAt([]byte("W"), 6)
+		Expect(err).NotTo(HaveOccurred())
+
+		buf := make([]byte, 5)
+		_, err = file.ReadAt(buf, 6)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(buf)).To(Equal("World"))
+		Expect(iops.Stats().Allowed).To(Equal(uint64(3)))
+
+		var _ io.ReaderAt = NewIOThrottle(iops, nil).ReaderAt(file)
+		var _ io.WriterAt = NewIOThrottle(iops, nil).WriterAt(file)
+	})
+})
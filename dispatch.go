@@ -0,0 +1,52 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+)
+
+// Dispatcher paces outbound calls, such as the calls of an API client, on a limiter.
+// Calls are queued in order and dispatched as tokens become available, unless they would
+// wait for longer than their budget. Dispatcher instances are thread-safe.
+type Dispatcher struct {
+	limiter *Limiter
+	budget  time.Duration // default wait budget, zero if unbounded
+}
+
+// NewDispatcher creates a new dispatcher on top of the limiter, where calls wait at most
+// for the budget before being dispatched. A zero budget means calls wait for as long as
+// their context allows.
+func NewDispatcher(rl *Limiter, budget time.Duration) *Dispatcher {
+	return &Dispatcher{
+		limiter: rl,
+		budget:  budget,
+	}
+}
+
+// Do queues the call with the default budget, see DoWithin().
+func (d *Dispatcher) Do(ctx context.Context, fn func(context.Context) error) error {
+	return d.DoWithin(ctx, d.budget, fn)
+}
+
+// DoWithin queues the call and runs it once dispatched. If the projected dispatch time
+// exceeds the budget, the call fails right away with ErrDeadline and is never run. The
+// budget only applies to the time spent queued, the call itself runs with the context.
+func (d *Dispatcher) DoWithin(ctx context.Context, budget time.Duration, fn func(context.Context) error) error {
+	wait := ctx
+	if budget > 0 {
+		var cancel context.CancelFunc
+		wait, cancel = context.WithTimeout(ctx, budget)
+		defer cancel()
+	}
+
+	if err := d.limiter.Wait(wait); err != nil {
+		return err
+	}
+
+	return fn(ctx)
+}
@@ -0,0 +1,55 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dispatcher", func() {
+
+	It("should dispatch calls at the pace of the limiter", func() {
+		d := NewDispatcher(New(1, 20*time.Millisecond), time.Second)
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			Expect(d.Do(context.Background(), func(ctx context.Context) error {
+				_, timed := ctx.Deadline()
+				Expect(timed).To(BeFalse())
+				return nil
+			})).To(Succeed())
+		}
+		Expect(time.Since(start)).To(BeNumerically(">=", 40*time.Millisecond))
+	})
+
+	It("should fail calls exceeding their budget", func() {
+		rl := New(1, time.Minute)
+		Expect(rl.Limit()).To(BeFalse())
+
+		d := NewDispatcher(rl, time.Second)
+		err := d.Do(context.Background(), func(context.Context) error {
+			Fail("should not be called")
+			return nil
+		})
+
+		Expect(errors.Is(err, ErrDeadline)).To(BeTrue())
+		Expect(errors.Is(err, ErrLimited)).To(BeTrue())
+	})
+
+	It("should return the error of the call", func() {
+		d := NewDispatcher(New(1, time.Minute), 0)
+		boom := errors.New("boom")
+		Expect(d.DoWithin(context.Background(), 0, func(context.Context) error {
+			return boom
+		})).To(Equal(boom))
+	})
+
+})
@@ -0,0 +1,240 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Distributed is a limiter enforcing a global rate across several instances sharing a
+// quota store, such as one backed by Redis. It serves its decisions from a slice of the
+// global budget leased locally and only syncs with the store at every interval, or early
+// once the slice runs out, so that no decision waits for a network hop. The global
+// budget is counted in windows aligned on multiples of the period since the unix epoch.
+// While the store is unreachable, calls are admitted until the leased slice is used up.
+// Distributed instances are thread-safe.
+type Distributed struct {
+	store  QuotaStore
+	key    string
+	limit  int           // operations allowed globally per window
+	window int64         // window duration, in ns
+	lease  int64         // tokens leased at every sync
+	clock  Clock         // optional source of time
+	tokens atomic.Int64  // tokens leased and not used yet
+	full   atomic.Int64  // end of the window the store reported used up, in ns
+	closed atomic.Bool   // whether the limiter was closed
+	refill chan struct{} // signals the sync loop that the slice ran out
+	done   chan struct{} // closed when the limiter is closed
+	lock   sync.Mutex
+	err    error // error of the last sync
+	stop   sync.WaitGroup
+	once   sync.Once
+}
+
+// DistributedOption represents an option which can be applied to a distributed limiter
+// on creation.
+type DistributedOption func(*Distributed)
+
+// WithDistributedClock sets the clock used by the distributed limiter instead of the
+// system time.
+func WithDistributedClock(clock Clock) DistributedOption {
+	return func(d *Distributed) {
+		d.clock = clock
+	}
+}
+
+// NewDistributed creates a new limiter enforcing the global rate of the key in the store
+// and leasing, at every interval, the share of the global rate refilled over an interval
+// or at most the budget of a window.
+// It leases a first slice right away and starts a background goroutine which is stopped
+// by Close(). A period or interval which is not positive is replaced by a second.
+func NewDistributed(store QuotaStore, key string, rate int, per, every time.Duration, options ...DistributedOption) *Distributed {
+	if per <= 0 {
+		per = time.Second
+	}
+	if every <= 0 {
+		every = time.Second
+	}
+
+	d := &Distributed{
+		store:  store,
+		key:    key,
+		limit:  rate,
+		window: int64(per),
+		lease:  int64(float64(rate) * float64(every) / float64(per)),
+		refill: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	switch {
+	case d.lease > int64(rate):
+		d.lease = int64(rate)
+	case d.lease < 1:
+		d.lease = 1
+	}
+
+	for _, opt := range options {
+		opt(d)
+	}
+
+	d.topUp()
+	d.stop.Add(1)
+	go d.run(every)
+	return d
+}
+
+// Limit returns true if the leased slice of the global rate was used up.
+func (d *Distributed) Limit() bool {
+	return d.LimitN(1)
+}
+
+// LimitN returns true if n tokens are not left in the leased slice, otherwise they are
+// consumed. Running out of tokens triggers an early sync in the background, unless the
+// global rate of the window was used up. Every call is limited once the limiter was
+// closed.
+func (d *Distributed) LimitN(n int) bool {
+	switch {
+	case d.closed.Load():
+		return true
+	case n < 1:
+		return false
+	}
+
+	for {
+		left := d.tokens.Load()
+		if left < int64(n) {
+			d.wake()
+			return true
+		}
+
+		if d.tokens.CompareAndSwap(left, left-int64(n)) {
+			if left == int64(n) {
+				d.wake()
+			}
+			return false
+		}
+	}
+}
+
+// Remaining returns the number of tokens left in the leased slice.
+func (d *Distributed) Remaining() int {
+	return int(d.tokens.Load())
+}
+
+// Sync tops up the leased slice from the store right away. Tokens leased beyond the
+// global rate of the window are given back to the store. It fails with ErrClosed once
+// the limiter was closed.
+func (d *Distributed) Sync() error {
+	select {
+	case <-d.done:
+		return ErrClosed
+	default:
+		return d.topUp()
+	}
+}
+
+// topUp tops up the leased slice from the store and records the error, if any
+func (d *Distributed) topUp() error {
+	want := int(d.lease - d.tokens.Load())
+	if want <= 0 {
+		return nil
+	}
+
+	taken, err := d.take(want)
+	if taken > 0 {
+		d.tokens.Add(int64(taken))
+	}
+
+	d.lock.Lock()
+	d.err = err
+	d.lock.Unlock()
+	return err
+}
+
+// Err returns the error of the last sync, if it failed.
+func (d *Distributed) Err() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.err
+}
+
+// Close stops syncing with the store and limits every call from then on. The tokens left
+// in the slice are not given back, they are only lost for the current window.
+func (d *Distributed) Close() error {
+	d.once.Do(func() {
+		d.closed.Store(true)
+		close(d.done)
+		d.stop.Wait()
+	})
+	return nil
+}
+
+// take leases up to n tokens of the current window from the store
+func (d *Distributed) take(n int) (int, error) {
+	start := d.now() / d.window * d.window
+	window := time.Unix(0, start)
+	count, err := d.store.Incr(d.key, window, n)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only sync at every interval until the next window once it was used up
+	if count >= d.limit {
+		d.full.Store(start + d.window)
+	}
+
+	over := count - d.limit
+	switch {
+	case over <= 0:
+		return n, nil
+	case over > n:
+		over = n
+	}
+
+	if _, err := d.store.Incr(d.key, window, -over); err != nil {
+		return n - over, err
+	}
+	return n - over, nil
+}
+
+// wake signals the sync loop to top up the slice without waiting for the next interval,
+// unless the store reported the current window used up
+func (d *Distributed) wake() {
+	if d.now() < d.full.Load() {
+		return
+	}
+
+	select {
+	case d.refill <- struct{}{}:
+	default:
+	}
+}
+
+// run syncs with the store at every interval or whenever the slice runs out
+func (d *Distributed) run(every time.Duration) {
+	defer d.stop.Done()
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.refill:
+		case <-d.done:
+			return
+		}
+		d.topUp()
+	}
+}
+
+// now returns the current time as unix nanoseconds
+func (d *Distributed) now() int64 {
+	if d.clock == nil {
+		return time.Now().UnixNano()
+	}
+	return d.clock.Now()
+}
@@ -0,0 +1,119 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// countingStore counts the calls to the underlying store and fails them on demand
+type countingStore struct {
+	store QuotaStore
+	calls atomic.Int32
+	fail  atomic.Bool
+}
+
+func (s *countingStore) Incr(key string, window time.Time, n int) (int, error) {
+	s.calls.Add(1)
+	if s.fail.Load() {
+		return 0, errors.New("unreachable")
+	}
+	return s.store.Incr(key, window, n)
+}
+
+var _ = Describe("Distributed", func() {
+	var clock *ManualClock
+	BeforeEach(func() {
+		clock = NewManualClock(time.Unix(0, 0))
+	})
+
+	It("should serve decisions from the leased slice", func() {
+		store := &countingStore{store: NewMemoryStore()}
+		d := NewDistributed(store, "api", 100, time.Second, time.Hour, WithDistributedClock(clock))
+		defer d.Close()
+
+		Expect(d.Remaining()).To(Equal(100))
+		for i := 0; i < 50; i++ {
+			Expect(d.Limit()).To(BeFalse())
+		}
+		Expect(store.calls.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("should default an interval which is not positive", func() {
+		d := NewDistributed(NewMemoryStore(), "api", 10, time.Second, 0, WithDistributedClock(clock))
+		defer d.Close()
+		Expect(d.Remaining()).To(Equal(10))
+	})
+
+	It("should share the global rate", func() {
+		store := NewMemoryStore()
+		a := NewDistributed(store, "api", 10, time.Hour, time.Hour, WithDistributedClock(clock))
+		b := NewDistributed(store, "api", 10, time.Hour, time.Hour, WithDistributedClock(clock))
+		defer a.Close()
+		defer b.Close()
+
+		Expect(a.Remaining()).To(Equal(10))
+		Expect(b.Remaining()).To(BeZero())
+		Expect(b.Limit()).To(BeTrue())
+
+		n, _ := store.Incr("api", time.Unix(0, 0), 0)
+		Expect(n).To(Equal(10))
+	})
+
+	It("should sync early once the slice runs out", func() {
+		store := &countingStore{store: NewMemoryStore()}
+		d := NewDistributed(store, "api", 10, time.Hour, 10*time.Minute, WithDistributedClock(clock))
+		defer d.Close()
+
+		Expect(d.Remaining()).To(Equal(1))
+		Expect(d.Limit()).To(BeFalse())
+		Eventually(d.Remaining).Should(Equal(1))
+		Expect(store.calls.Load()).To(BeNumerically(">=", 2))
+	})
+
+	It("should not sync early while the window is used up", func() {
+		store := &countingStore{store: NewMemoryStore()}
+		a := NewDistributed(store, "api", 10, time.Hour, time.Hour, WithDistributedClock(clock))
+		b := NewDistributed(store, "api", 10, time.Hour, time.Hour, WithDistributedClock(clock))
+		defer a.Close()
+		defer b.Close()
+
+		Expect(a.LimitN(10)).To(BeFalse())
+		for i := 0; i < 100; i++ {
+			Expect(b.Limit()).To(BeTrue())
+		}
+		Consistently(store.calls.Load, "50ms").Should(BeEquivalentTo(3))
+
+		clock.Advance(time.Hour)
+		Expect(b.Limit()).To(BeTrue())
+		Eventually(b.Remaining).Should(Equal(10))
+	})
+
+	It("should limit every call once closed", func() {
+		d := NewDistributed(NewMemoryStore(), "api", 10, time.Second, time.Second, WithDistributedClock(clock))
+		Expect(d.Close()).To(Succeed())
+		Expect(d.Limit()).To(BeTrue())
+		Expect(d.Remaining()).To(Equal(10))
+	})
+
+	It("should keep serving while the store is unreachable", func() {
+		store := &countingStore{store: NewMemoryStore()}
+		d := NewDistributed(store, "api", 10, time.Second, time.Second, WithDistributedClock(clock))
+		defer d.Close()
+
+		store.fail.Store(true)
+		Expect(d.LimitN(5)).To(BeFalse())
+		Expect(d.Sync()).To(HaveOccurred())
+		Expect(d.Err()).To(HaveOccurred())
+		Expect(d.LimitN(5)).To(BeFalse())
+		Expect(d.Limit()).To(BeTrue())
+	})
+})
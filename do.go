@@ -0,0 +1,54 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"errors"
+)
+
+// Do waits for a token, then runs the function. If the function reports that the work
+// was not performed by returning an error marked with NotPerformed(), such as when a
+// circuit breaker short-circuited or the connection was refused before anything was
+// sent, the token is refunded and the underlying error is returned.
+func (rl *Limiter) Do(ctx context.Context, fn func(context.Context) error) error {
+	if err := rl.Wait(ctx); err != nil {
+		return err
+	}
+
+	err := fn(ctx)
+	var skipped *notPerformed
+	if errors.As(err, &skipped) {
+		rl.Undo()
+		return skipped.err
+	}
+	return err
+}
+
+// NotPerformed marks the error as one of work which was not performed, so that Do()
+// refunds the token. A nil error can be marked as well, in which case Do() refunds the
+// token and returns nil.
+func NotPerformed(err error) error {
+	return &notPerformed{err: err}
+}
+
+// notPerformed represents the error of work which was not performed
+type notPerformed struct {
+	err error
+}
+
+// Error returns the error message.
+func (e *notPerformed) Error() string {
+	if e.err == nil {
+		return "rate: work was not performed"
+	}
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *notPerformed) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,65 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Do", func() {
+
+	It("should consume a token for performed work", func() {
+		rl := New(2, time.Hour)
+		failure := errors.New("failed")
+		Expect(rl.Do(context.Background(), func(context.Context) error { return nil })).To(Succeed())
+		Expect(rl.Do(context.Background(), func(context.Context) error { return failure })).To(Equal(failure))
+		Expect(rl.Remaining()).To(BeZero())
+	})
+
+	It("should refund the token of notPerformed work", func() {
+		rl := New(1, time.Hour)
+		refused := errors.New("connection refused")
+		Expect(rl.Do(context.Background(), func(context.Context) error {
+			return NotPerformed(refused)
+		})).To(Equal(refused))
+		Expect(rl.Do(context.Background(), func(context.Context) error {
+			return NotPerformed(nil)
+		})).To(Succeed())
+
+		Expect(rl.Remaining()).To(Equal(1))
+		Expect(rl.Stats().Undone).To(BeEquivalentTo(2))
+	})
+
+	It("should find notPerformed work in wrapped errors", func() {
+		rl := New(1, time.Hour)
+		err := rl.Do(context.Background(), func(context.Context) error {
+			return fmt.Errorf("send: %w", NotPerformed(errors.New("refused")))
+		})
+
+		Expect(err).To(MatchError("refused"))
+		Expect(rl.Remaining()).To(Equal(1))
+	})
+
+	It("should not run when the context is done", func() {
+		rl := New(1, time.Hour)
+		rl.Limit()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		called := false
+		Expect(rl.Do(ctx, func(context.Context) error {
+			called = true
+			return nil
+		})).To(HaveOccurred())
+		Expect(called).To(BeFalse())
+	})
+})
@@ -0,0 +1,89 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+)
+
+// drift detects admitted rates persistently deviating from the configured rate
+type drift struct {
+	lock      sync.Mutex
+	tolerance float64             // fraction of the rate tolerated before drifting
+	sustain   int                 // consecutive windows of drift before flagging it
+	alert     func(ratio float64) // optional callback fired once per drift
+	start     uint64              // start of the current window, in unix ns
+	allowed   uint64              // operations allowed within the current window
+	denied    uint64              // calls denied within the current window
+	windows   int                 // consecutive windows which drifted
+	ratio     float64             // ratio of the flagged drift, zero if none
+}
+
+// WithDriftDetection flags a drift when the admitted rate deviates from the configured
+// rate by more than the tolerance, for example 0.1 for 10%, over the specified number of
+// consecutive windows of the limiter's period. Admitting more than the rate points to
+// misuse such as refunds of calls which were made, while admitting less despite denials
+// points to clock issues. The drift is reported by Stats() and, if the function is not
+// nil, it is called with the ratio of the admitted to the configured rate once per drift.
+func WithDriftDetection(tolerance float64, sustain int, alert func(ratio float64)) Option {
+	if sustain < 1 {
+		sustain = 1
+	}
+
+	return func(rl *Limiter) {
+		rl.extend().drift = &drift{
+			tolerance: tolerance,
+			sustain:   sustain,
+			alert:     alert,
+		}
+	}
+}
+
+// observe records a decision for n operations at the specified time
+func (d *drift) observe(now, n uint64, limited bool, rate, unit uint64) {
+	var fire bool
+
+	d.lock.Lock()
+	if elapsed := now - d.start; elapsed >= unit {
+		ratio := float64(d.allowed) * float64(unit) / (float64(rate) * float64(elapsed))
+		switch {
+		case ratio > 1+d.tolerance, d.denied > 0 && ratio < 1-d.tolerance:
+			if d.windows++; d.windows >= d.sustain {
+				fire = d.ratio == 0 && d.alert != nil
+				d.ratio = ratio
+			}
+		default:
+			d.windows, d.ratio = 0, 0
+		}
+
+		d.start = now
+		d.allowed, d.denied = 0, 0
+	}
+
+	if limited {
+		d.denied++
+	} else {
+		d.allowed += n
+	}
+
+	ratio := d.ratio
+	d.lock.Unlock()
+
+	if fire {
+		d.alert(ratio)
+	}
+}
+
+// current returns the ratio of the flagged drift, zero if none
+func (d *drift) current() float64 {
+	if d == nil {
+		return 0
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.ratio
+}
@@ -0,0 +1,79 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Drift", func() {
+
+	var alerts []float64
+	alert := func(ratio float64) {
+		alerts = append(alerts, ratio)
+	}
+
+	BeforeEach(func() {
+		alerts = nil
+	})
+
+	It("should flag persistent over-admission", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock), WithDriftDetection(0.1, 2, alert))
+		for i := 0; i < 3; i++ {
+			Expect(alerts).To(BeEmpty())
+			for j := 0; j < 20; j++ {
+				rl.Limit()
+				rl.Undo() // misuse, refunding calls which were made
+			}
+			clock.Advance(time.Second)
+		}
+
+		Expect(alerts).To(Equal([]float64{2}))
+		Expect(rl.Stats().Drift).To(Equal(2.0))
+
+		// Recovers once the admitted rate is back within the tolerance
+		for i := 0; i < 2; i++ {
+			for j := 0; j < 10; j++ {
+				rl.Limit()
+			}
+			clock.Advance(time.Second)
+		}
+
+		Expect(rl.Stats().Drift).To(BeZero())
+		Expect(alerts).To(HaveLen(1))
+	})
+
+	It("should not flag a saturated limiter", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock), WithDriftDetection(0.1, 2, alert))
+		rl.LimitN(10) // drain the initial burst
+
+		for i := 0; i < 5; i++ {
+			clock.Advance(time.Second)
+			for j := 0; j < 30; j++ {
+				rl.Limit()
+			}
+		}
+
+		Expect(alerts).To(BeEmpty())
+		Expect(rl.Stats().Drift).To(BeZero())
+	})
+
+	It("should not flag a limiter with little traffic", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock), WithDriftDetection(0.1, 1, nil))
+		for i := 0; i < 5; i++ {
+			rl.Limit()
+			clock.Advance(time.Second)
+		}
+
+		Expect(rl.ResetStats().Drift).To(BeZero())
+	})
+})
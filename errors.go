@@ -0,0 +1,66 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"time"
+)
+
+// Various errors returned by the limiters
+var (
+	ErrLimited   = errors.New("rate: limit exceeded")
+	ErrQueueFull = errors.New("rate: wait queue is full")
+	ErrDeadline  = errors.New("rate: wait would exceed context deadline")
+	ErrClosed    = errors.New("rate: limiter is closed")
+	ErrBudget    = errors.New("rate: budget exceeds the available rate")
+	ErrExhausted = errors.New("rate: budget is exhausted")
+
+	ErrInvalidRate  = errors.New("rate: invalid rate")
+	ErrInvalidBurst = errors.New("rate: invalid burst")
+	ErrInvalidKey   = errors.New("rate: invalid key")
+)
+
+// LimitedError is returned when a call was rejected because of the rate, along with the
+// expected time until it could succeed. It matches ErrLimited as well as the underlying
+// error, such as ErrQueueFull, with errors.Is().
+type LimitedError struct {
+	Err        error         // The reason of the rejection
+	RetryAfter time.Duration // Expected time until a retry could succeed
+}
+
+// Error returns the error message.
+func (e *LimitedError) Error() string {
+	return e.Err.Error() + ", retry after " + e.RetryAfter.String()
+}
+
+// Unwrap returns the reason of the rejection.
+func (e *LimitedError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns whether the target is ErrLimited.
+func (e *LimitedError) Is(target error) bool {
+	return target == ErrLimited
+}
+
+// Try returns nil if the call is allowed, otherwise a *LimitedError with the time until
+// a token becomes available.
+func (rl *Limiter) Try() error {
+	if !rl.Limit() {
+		return nil
+	}
+
+	return rl.limited(ErrLimited, 0)
+}
+
+// limited returns an error for a call rejected while n others are queued
+func (rl *Limiter) limited(err error, n int) error {
+	return &LimitedError{
+		Err:        err,
+		RetryAfter: rl.estimate(n),
+	}
+}
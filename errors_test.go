@@ -0,0 +1,54 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Errors", func() {
+
+	It("should return when to retry", func() {
+		rl := New(10, time.Second)
+		Expect(rl.LimitN(10)).To(BeFalse())
+
+		err := rl.Try()
+		Expect(errors.Is(err, ErrLimited)).To(BeTrue())
+
+		var limited *LimitedError
+		Expect(errors.As(err, &limited)).To(BeTrue())
+		Expect(limited.RetryAfter).To(BeNumerically("~", 100*time.Millisecond, 5*time.Millisecond))
+		Expect(limited.Error()).To(HavePrefix("rate: limit exceeded, retry after"))
+	})
+
+	It("should allow calls", func() {
+		rl := New(1, time.Second)
+		Expect(rl.Try()).To(Succeed())
+		Expect(rl.Try()).NotTo(Succeed())
+	})
+
+	It("should match the reason of rejected waits", func() {
+		rl := New(1, time.Minute, WithMaxWaiters(1))
+		Expect(rl.Limit()).To(BeFalse())
+		go rl.Wait(context.Background())
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+
+		err := rl.Wait(context.Background())
+		Expect(errors.Is(err, ErrQueueFull)).To(BeTrue())
+		Expect(errors.Is(err, ErrLimited)).To(BeTrue())
+
+		var limited *LimitedError
+		Expect(errors.As(err, &limited)).To(BeTrue())
+		Expect(limited.RetryAfter).To(BeNumerically(">", time.Minute))
+		Expect(rl.Close()).To(Succeed())
+	})
+
+})
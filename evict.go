@@ -0,0 +1,63 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+)
+
+// WithEviction registers a function called with the final stats of a key whenever its
+// limiter is removed, either by Remove() or once it expires with Expire(), so that usage
+// accounting can persist the consumption of every client without polling the key space.
+// The function is called without holding any lock.
+func WithEviction(fn func(key string, final Stats)) KeyedOption {
+	return func(k *Keyed) {
+		k.evicted = fn
+	}
+}
+
+// Expire removes the keys which were not used for at least the idle duration and returns
+// how many were removed. It is typically called periodically to bound the memory of
+// keyed limiters whose keys churn, such as client addresses. The bans which lapsed are
+// dropped as well.
+func (k *Keyed) Expire(idle time.Duration) (n int) {
+	type eviction struct {
+		key   string
+		final Stats
+	}
+
+	var evicted []eviction
+	lapsed := time.Now().UnixNano() // bans expire on the system time
+	for i := range k.shards {
+		s := &k.shards[i]
+		s.Lock()
+		for key, until := range s.bans {
+			if until <= lapsed {
+				delete(s.bans, key)
+			}
+		}
+
+		for key, rl := range s.limiters {
+			if now, last := rl.now(), rl.lastCheck.Load(); now < last+uint64(idle) {
+				continue
+			}
+
+			delete(s.limiters, key)
+			delete(s.heat, key)
+			delete(s.strikes, key)
+			if k.evicted != nil {
+				evicted = append(evicted, eviction{key, rl.Stats()})
+			}
+			n++
+		}
+		s.Unlock()
+	}
+
+	for _, e := range evicted {
+		k.evicted(e.key, e.final)
+	}
+	return
+}
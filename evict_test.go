@@ -0,0 +1,54 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Eviction", func() {
+
+	It("should expire the idle keys", func() {
+		clock := NewManualClock(time.Now())
+		final := map[string]Stats{}
+		k := NewKeyed(1, time.Second, WithLimiterOptions(WithClock(clock)), WithEviction(func(key string, stats Stats) {
+			final[key] = stats
+		}))
+
+		k.Limit("alice")
+		k.Limit("alice")
+		clock.Advance(time.Minute)
+		k.Limit("bob")
+
+		Expect(k.Expire(time.Minute)).To(Equal(1))
+		Expect(k.Len()).To(Equal(1))
+		Expect(final).To(HaveLen(1))
+		Expect(final["alice"].Allowed).To(BeEquivalentTo(1))
+		Expect(final["alice"].Denied).To(BeEquivalentTo(1))
+	})
+
+	It("should report the removed keys", func() {
+		var evicted []string
+		k := NewKeyed(1, time.Second, WithEviction(func(key string, _ Stats) {
+			evicted = append(evicted, key)
+		}))
+
+		k.Limit("alice")
+		k.Remove("alice")
+		k.Remove("bob")
+		Expect(evicted).To(Equal([]string{"alice"}))
+	})
+
+	It("should expire without a callback", func() {
+		k := NewKeyed(1, time.Second)
+		k.Limit("alice")
+		Expect(k.Expire(0)).To(Equal(1))
+		Expect(k.Len()).To(BeZero())
+	})
+})
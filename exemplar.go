@@ -0,0 +1,100 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exemplar represents a single denied call, so that engineers can go from a spike of
+// denials to concrete requests which were affected.
+type Exemplar struct {
+	Key     string    // Key which was denied
+	Time    time.Time // Time of the denial
+	TraceID string    // Trace identifier of the call, if any
+}
+
+// Exemplars keeps a small reservoir of the most recent denials, sampling them to keep
+// the overhead low during denial storms. Exemplars instances are thread-safe.
+type Exemplars struct {
+	lock   sync.Mutex
+	ring   []Exemplar
+	next   int     // index at which the next exemplar is written
+	count  int     // number of exemplars in the ring
+	sample float64 // fraction of the denials recorded
+}
+
+// NewExemplars creates a new reservoir keeping up to size exemplars, recording the
+// specified fraction of the denials, where 1 records all of them.
+func NewExemplars(size int, sample float64) *Exemplars {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Exemplars{
+		ring:   make([]Exemplar, size),
+		sample: sample,
+	}
+}
+
+// Record samples a denial of the key, along with its trace identifier if known.
+func (e *Exemplars) Record(key, traceID string) {
+	if e.sample < 1 && rand.Float64() >= e.sample {
+		return
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.ring[e.next] = Exemplar{Key: key, Time: time.Now(), TraceID: traceID}
+	e.next = (e.next + 1) % len(e.ring)
+	if e.count < len(e.ring) {
+		e.count++
+	}
+}
+
+// List returns the exemplars in the reservoir, most recent first.
+func (e *Exemplars) List() []Exemplar {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	out := make([]Exemplar, 0, e.count)
+	for i := 1; i <= e.count; i++ {
+		out = append(out, e.ring[(e.next-i+len(e.ring))%len(e.ring)])
+	}
+	return out
+}
+
+// WithExemplars keeps a reservoir of the requests denied by the middleware, along with
+// the trace identifier of their W3C "traceparent" header. See Exemplars().
+func WithExemplars(size int, sample float64) MiddlewareOption {
+	return func(m *Middleware) {
+		m.exemplars = NewExemplars(size, sample)
+	}
+}
+
+// Exemplars returns the most recent requests denied by the middleware, most recent first,
+// or nil if the middleware was not created with WithExemplars().
+func (m *Middleware) Exemplars() []Exemplar {
+	if m.exemplars == nil {
+		return nil
+	}
+	return m.exemplars.List()
+}
+
+// traceID returns the trace identifier of the W3C "traceparent" header of the request,
+// formatted as "version-traceid-parentid-flags"
+func traceID(r *http.Request) string {
+	parts := strings.Split(r.Header.Get("traceparent"), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,66 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Exemplars", func() {
+
+	It("should keep the most recent denials", func() {
+		e := NewExemplars(2, 1)
+		Expect(e.List()).To(BeEmpty())
+
+		e.Record("a", "")
+		e.Record("b", "t1")
+		e.Record("c", "t2")
+
+		list := e.List()
+		Expect(list).To(HaveLen(2))
+		Expect(list[0].Key).To(Equal("c"))
+		Expect(list[0].TraceID).To(Equal("t2"))
+		Expect(list[1].Key).To(Equal("b"))
+		Expect(list[0].Time).To(BeTemporally("~", time.Now(), time.Second))
+	})
+
+	It("should sample the denials", func() {
+		e := NewExemplars(1000, 0.1)
+		for i := 0; i < 1000; i++ {
+			e.Record("a", "")
+		}
+		Expect(len(e.List())).To(BeNumerically("~", 100, 50))
+		Expect(NewExemplars(10, 0).List()).To(BeEmpty())
+	})
+
+	It("should record the requests denied by the middleware", func() {
+		m := NewMiddleware(1, time.Minute, WithExemplars(10, 1))
+		handler := m.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		for i := 0; i < 3; i++ {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+		}
+
+		list := m.Exemplars()
+		Expect(list).To(HaveLen(2))
+		Expect(list[0].Key).To(Equal("192.0.2.1"))
+		Expect(list[0].TraceID).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		Expect(NewMiddleware(1, time.Minute).Exemplars()).To(BeNil())
+	})
+
+	It("should ignore malformed trace headers", func() {
+		r := httptest.NewRequest("GET", "/", nil)
+		Expect(traceID(r)).To(BeEmpty())
+		r.Header.Set("traceparent", "00-abc-01")
+		Expect(traceID(r)).To(BeEmpty())
+	})
+})
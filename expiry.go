@@ -0,0 +1,50 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// expiry represents the expiry of the allowance accumulated while the limiter was idle
+type expiry struct {
+	idle     uint64        // idle time after which the allowance expires, in ns
+	baseline uint64        // number of tokens kept once expired
+	last     atomic.Uint64 // time of the last call, in unix ns
+}
+
+// WithCreditExpiry makes the allowance accumulated above a baseline of tokens expire once
+// the limiter was idle for the specified duration, so a client which was silent for an
+// hour can not instantly fire its entire burst. The first call after the idle period
+// finds at most the baseline, and the allowance then refills at the rate as usual. Reads
+// of the allowance, such as Peek() or Remaining(), do not count as activity.
+func WithCreditExpiry(idle time.Duration, baseline int) Option {
+	return func(rl *Limiter) {
+		if baseline < 0 {
+			baseline = 0
+		}
+
+		rl.extend().expiry = &expiry{
+			idle:     uint64(idle),
+			baseline: uint64(baseline),
+		}
+	}
+}
+
+// expire drops the allowance above the baseline if the limiter was idle long enough, and
+// returns the allowance left
+func (e *expiry) expire(rl *Limiter, now, current uint64) uint64 {
+	if now < e.last.Load()+e.idle {
+		return current
+	}
+
+	if baseline := e.baseline * rl.unit; current > baseline {
+		rl.allowance.Add(baseline - current)
+		return baseline
+	}
+	return current
+}
@@ -0,0 +1,58 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Expiry", func() {
+	var clock *ManualClock
+	BeforeEach(func() {
+		clock = NewManualClock(time.Unix(0, 0))
+	})
+
+	It("should expire the allowance of an idle limiter", func() {
+		rl := New(10, time.Minute, WithClock(clock), WithCreditExpiry(time.Hour, 2))
+		Expect(rl.Remaining()).To(Equal(10))
+
+		clock.Advance(time.Hour)
+		Expect(rl.Remaining()).To(Equal(2))
+		Expect(rl.LimitN(3)).To(BeTrue())
+
+		clock.Advance(time.Minute)
+		Expect(rl.Remaining()).To(Equal(10))
+	})
+
+	It("should keep the allowance of an active limiter", func() {
+		rl := New(10, time.Minute, WithClock(clock), WithCreditExpiry(time.Hour, 2))
+		for i := 0; i < 6; i++ {
+			clock.Advance(30 * time.Minute)
+			Expect(rl.Remaining()).To(Equal(10))
+			Expect(rl.Limit()).To(BeFalse())
+		}
+	})
+
+	It("should expire the allowance of a limiter which is only read", func() {
+		rl := New(10, time.Minute, WithClock(clock), WithCreditExpiry(time.Hour, 2))
+		for i := 0; i < 6; i++ {
+			clock.Advance(15 * time.Minute)
+			Expect(rl.Peek()).To(BeFalse())
+		}
+		Expect(rl.Remaining()).To(Equal(2))
+	})
+
+	It("should not expire the allowance below the baseline", func() {
+		rl := New(10, time.Minute, WithClock(clock), WithCreditExpiry(time.Nanosecond, 5))
+		Expect(rl.LimitN(8)).To(BeFalse())
+
+		clock.Advance(time.Second)
+		Expect(rl.Remaining()).To(Equal(2))
+	})
+})
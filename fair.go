@@ -0,0 +1,229 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fair divides a global rate among the tenants which are currently active, according to
+// their weights. Shares are recomputed as tenants appear and once they go idle, so the
+// capacity is never statically over-partitioned. Fair instances are thread-safe.
+type Fair struct {
+	lock     sync.RWMutex
+	rate     int                      // global rate shared by the active tenants
+	per      time.Duration            // period of the rate
+	idle     int64                    // inactivity after which a tenant is idle, in ns
+	options  []Option                 // options of the limiters created
+	clock    Clock                    // clock of the limiters created, if any
+	weights  map[string]float64       // weight of the tenants, if not the default
+	active   map[string]*tenant       // tenants currently active
+	division atomic.Pointer[division] // current division of the rate among the tenants
+	swept    atomic.Int64             // time of the last sweep of idle tenants, in unix ns
+}
+
+// division represents how the rate is divided among the active tenants. It is replaced
+// whenever they change and every tenant updates its own share on its next call, so that
+// a new tenant never updates all of the others.
+type division struct {
+	total   float64 // total weight of the active tenants
+	tenants int     // number of active tenants
+}
+
+// tenant represents an active tenant of a fair limiter
+type tenant struct {
+	lock     sync.Mutex               // held while updating the share
+	limiter  *Limiter                 // limiter enforcing the share of the tenant
+	weight   atomic.Uint64            // weight of the tenant, as float64 bits
+	seen     atomic.Int64             // time of the last call, in unix ns
+	division atomic.Pointer[division] // division the share was last updated for
+}
+
+// NewFair creates a new fair limiter which divides the rate among the tenants seen
+// within the idle duration. The options are applied to the limiter of every tenant and
+// the clock of the limiters, if any, also measures the idleness of the tenants.
+func NewFair(rate int, per, idle time.Duration, options ...Option) *Fair {
+	f := &Fair{
+		rate:    rate,
+		per:     per,
+		idle:    int64(idle),
+		options: options,
+		clock:   New(rate, per, options...).clock,
+		weights: make(map[string]float64),
+		active:  make(map[string]*tenant),
+	}
+
+	f.division.Store(&division{})
+	f.swept.Store(f.now())
+	return f
+}
+
+// Limit returns true if the tenant exceeded its share of the rate.
+func (f *Fair) Limit(key string) bool {
+	return f.Get(key).Limit()
+}
+
+// Get returns the limiter of the tenant and marks it as active.
+func (f *Fair) Get(key string) *Limiter {
+	now := f.now()
+	f.sweep(now)
+
+	f.lock.RLock()
+	t, ok := f.active[key]
+	f.lock.RUnlock()
+	if !ok {
+		t = f.activate(key, now)
+	}
+
+	t.seen.Store(now)
+	f.refresh(t)
+	return t.limiter
+}
+
+// SetWeight sets the weight of the tenant, which receives a share of the rate in
+// proportion to it. Tenants have a weight of 1 by default.
+func (f *Fair) SetWeight(key string, weight float64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.weights[key] = weight
+	if t, ok := f.active[key]; ok {
+		prev := math.Float64frombits(t.weight.Swap(math.Float64bits(weight)))
+		d := f.division.Load()
+		f.division.Store(&division{total: d.total - prev + weight, tenants: d.tenants})
+	}
+}
+
+// Share returns the rate currently allotted to the tenant, or the whole rate if the
+// tenant is not active.
+func (f *Fair) Share(key string) int {
+	f.lock.RLock()
+	t, ok := f.active[key]
+	f.lock.RUnlock()
+	if !ok {
+		return f.rate
+	}
+
+	f.refresh(t)
+	return int(t.limiter.load().rate)
+}
+
+// Active returns the number of tenants currently active.
+func (f *Fair) Active() int {
+	f.sweep(f.now())
+
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return len(f.active)
+}
+
+// activate returns the tenant, adding it to the active ones if it is not yet
+func (f *Fair) activate(key string, now int64) *tenant {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if t, ok := f.active[key]; ok {
+		return t
+	}
+
+	t := &tenant{limiter: New(f.rate, f.per, f.options...)}
+	t.weight.Store(math.Float64bits(f.weight(key)))
+	t.seen.Store(now)
+	f.active[key] = t
+
+	d := f.division.Load()
+	f.division.Store(&division{total: d.total + f.weight(key), tenants: d.tenants + 1})
+	return t
+}
+
+// refresh updates the share of the tenant if the division of the rate changed since it
+// was last updated
+func (f *Fair) refresh(t *tenant) {
+	if t.division.Load() == f.division.Load() {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	d := f.division.Load()
+	if t.division.Load() == d {
+		return
+	}
+
+	share := d.share(f.rate, math.Float64frombits(t.weight.Load()))
+	if share != int(t.limiter.load().rate) {
+		t.limiter.UpdateRate(share)
+	}
+	t.division.Store(d)
+}
+
+// sweep removes the idle tenants once per idle duration
+func (f *Fair) sweep(now int64) {
+	if now-f.swept.Load() < f.idle {
+		return
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if now-f.swept.Load() < f.idle {
+		return
+	}
+
+	// Sum the weights again rather than subtracting, so rounding errors never add up
+	var total float64
+	f.swept.Store(now)
+	removed := false
+	for key, t := range f.active {
+		if now-t.seen.Load() >= f.idle {
+			delete(f.active, key)
+			removed = true
+			continue
+		}
+		total += math.Float64frombits(t.weight.Load())
+	}
+
+	if removed {
+		f.division.Store(&division{total: total, tenants: len(f.active)})
+	}
+}
+
+// share returns the rate allotted to a tenant of the specified weight, divided evenly
+// if none of the tenants has a positive weight
+func (d *division) share(rate int, weight float64) (share int) {
+	switch {
+	case d.total > 0:
+		share = int(float64(rate) * weight / d.total)
+	case d.tenants > 0:
+		share = rate / d.tenants
+	default:
+		share = rate
+	}
+
+	if share < 1 {
+		share = 1
+	}
+	return
+}
+
+// weight returns the weight of the tenant
+func (f *Fair) weight(key string) float64 {
+	if w, ok := f.weights[key]; ok {
+		return w
+	}
+	return 1
+}
+
+// now returns the current time as unix nanoseconds
+func (f *Fair) now() int64 {
+	if f.clock == nil {
+		return time.Now().UnixNano()
+	}
+	return f.clock.Now()
+}
@@ -0,0 +1,112 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fair", func() {
+
+	It("should divide the rate among active tenants", func() {
+		f := NewFair(100, time.Second, time.Minute)
+		Expect(f.Share("a")).To(Equal(100))
+		f.Get("a")
+		Expect(f.Share("a")).To(Equal(100))
+
+		f.Get("b")
+		f.Get("c")
+		f.Get("d")
+		Expect(f.Active()).To(Equal(4))
+		Expect(f.Share("a")).To(Equal(25))
+		Expect(f.Share("d")).To(Equal(25))
+	})
+
+	It("should divide the rate according to weights", func() {
+		f := NewFair(100, time.Second, time.Minute)
+		f.SetWeight("a", 3)
+		f.Get("a")
+		f.Get("b")
+		Expect(f.Share("a")).To(Equal(75))
+		Expect(f.Share("b")).To(Equal(25))
+
+		f.SetWeight("b", 2)
+		Expect(f.Share("a")).To(Equal(60))
+		Expect(f.Share("b")).To(Equal(40))
+	})
+
+	It("should enforce the share", func() {
+		f := NewFair(10, time.Minute, time.Minute)
+		f.Get("a")
+		f.Get("b")
+		for i := 0; i < 5; i++ {
+			Expect(f.Limit("a")).To(BeFalse())
+		}
+		Expect(f.Limit("a")).To(BeTrue())
+	})
+
+	It("should give the share of idle tenants back", func() {
+		f := NewFair(100, time.Second, 20*time.Millisecond)
+		f.Get("a")
+		f.Get("b")
+		Expect(f.Share("a")).To(Equal(50))
+
+		time.Sleep(10 * time.Millisecond)
+		f.Get("a")
+		time.Sleep(15 * time.Millisecond)
+		f.Get("a")
+		Expect(f.Active()).To(Equal(1))
+		Expect(f.Share("a")).To(Equal(100))
+	})
+
+	It("should use the clock of the limiters", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		f := NewFair(100, time.Second, time.Minute, WithClock(clock))
+		f.Get("a")
+		f.Get("b")
+		Expect(f.Share("a")).To(Equal(50))
+
+		clock.Advance(time.Minute)
+		f.Get("a")
+		Expect(f.Active()).To(Equal(1))
+		Expect(f.Share("a")).To(Equal(100))
+	})
+
+	It("should divide the rate evenly without any positive weight", func() {
+		f := NewFair(100, time.Second, time.Minute)
+		f.SetWeight("a", 0)
+		f.SetWeight("b", 0)
+		f.Get("a")
+		f.Get("b")
+		Expect(f.Share("a")).To(Equal(50))
+		Expect(f.Share("b")).To(Equal(50))
+	})
+
+	It("should divide the rate among concurrent tenants", func() {
+		f := NewFair(1000, time.Second, time.Minute)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					f.Limit(key)
+				}
+			}(string(rune('a' + i)))
+		}
+		wg.Wait()
+
+		Expect(f.Active()).To(Equal(8))
+		for i := 0; i < 8; i++ {
+			Expect(f.Share(string(rune('a' + i)))).To(Equal(125))
+		}
+	})
+
+})
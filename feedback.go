@@ -0,0 +1,44 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// WithFeedback registers a consumer of the outcomes reported with ReportSuccess() and
+// ReportFailure(), such as an adaptive rate or a circuit breaker, so that every feature
+// relies on the same feedback from the callers. The function is called with a nil error
+// for successes.
+func WithFeedback(fn func(err error)) Option {
+	return func(rl *Limiter) {
+		e := rl.extend()
+		e.feedback = append(e.feedback, fn)
+	}
+}
+
+// ReportSuccess reports that an operation admitted by the limiter succeeded.
+func (rl *Limiter) ReportSuccess() {
+	rl.report(nil)
+}
+
+// ReportFailure reports that an operation admitted by the limiter failed with the error,
+// which counts as a violation towards the penalty, if any. A nil error reports a success.
+func (rl *Limiter) ReportFailure(err error) {
+	rl.report(err)
+}
+
+// report counts the outcome of an operation and hands it over to the consumers
+func (rl *Limiter) report(err error) {
+	if err == nil {
+		rl.stats.success.Add(1)
+	} else {
+		rl.stats.failure.Add(1)
+		if p := rl.ext().penalty; p != nil {
+			p.violate(rl.now())
+		}
+	}
+
+	for _, fn := range rl.ext().feedback {
+		fn(err)
+	}
+}
@@ -0,0 +1,48 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Feedback", func() {
+
+	It("should count the outcomes", func() {
+		rl := New(10, time.Second)
+		rl.ReportSuccess()
+		rl.ReportFailure(errors.New("boom"))
+		rl.ReportFailure(nil)
+
+		stats := rl.Stats()
+		Expect(stats.Success).To(BeEquivalentTo(2))
+		Expect(stats.Failure).To(BeEquivalentTo(1))
+	})
+
+	It("should hand the outcomes over to the consumers", func() {
+		var outcomes []error
+		failure := errors.New("boom")
+		rl := New(10, time.Second, WithFeedback(func(err error) {
+			outcomes = append(outcomes, err)
+		}))
+
+		rl.ReportSuccess()
+		rl.ReportFailure(failure)
+		Expect(outcomes).To(Equal([]error{nil, failure}))
+	})
+
+	It("should count the failures towards the penalty", func() {
+		rl := New(10, time.Second, WithPenalty(2, time.Minute, time.Minute, 0))
+		rl.ReportFailure(errors.New("boom"))
+		Expect(rl.Penalized()).To(BeFalse())
+		rl.ReportFailure(errors.New("boom"))
+		Expect(rl.Penalized()).To(BeTrue())
+	})
+})
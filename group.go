@@ -0,0 +1,101 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"sync"
+)
+
+// Group is a barrier where a fixed number of goroutines rendezvous and proceed together
+// once the limiter admits all of them at once, so that fan-out operations are admitted
+// all-or-nothing against the budget. A group is reusable, every complete set of
+// goroutines being admitted in turn. Group instances are thread-safe.
+type Group struct {
+	lock    sync.Mutex
+	limiter *Limiter
+	size    int         // number of goroutines admitted together
+	arrived int         // number of goroutines waiting in the current round
+	round   *groupRound // current round of the group
+}
+
+// groupRound represents a single round of a group
+type groupRound struct {
+	done chan struct{} // closed once the round is admitted or failed
+	err  error         // error of the round, set before done is closed
+}
+
+// NewGroup creates a new group admitting n goroutines at once on the limiter.
+func NewGroup(rl *Limiter, n int) *Group {
+	if n < 1 {
+		n = 1
+	}
+
+	return &Group{
+		limiter: rl,
+		size:    n,
+		round:   &groupRound{done: make(chan struct{})},
+	}
+}
+
+// Wait blocks until the group is complete and the limiter admits all of its goroutines,
+// or until the context is done. A goroutine whose context is done before the group is
+// complete leaves it and returns the context error. Once complete, the tokens are
+// waited for on the context of the last goroutine to arrive and every goroutine of the
+// round returns the same error, ErrBudget being returned if the limiter can never admit
+// the whole group at once.
+func (g *Group) Wait(ctx context.Context) error {
+	g.lock.Lock()
+	round := g.round
+	if g.arrived++; g.arrived == g.size {
+		g.arrived = 0
+		g.round = &groupRound{done: make(chan struct{})}
+		g.lock.Unlock()
+
+		round.err = g.acquire(ctx)
+		close(round.done)
+		return round.err
+	}
+	g.lock.Unlock()
+
+	select {
+	case <-round.done:
+		return round.err
+	case <-ctx.Done():
+		g.lock.Lock()
+		if round == g.round {
+			g.arrived--
+			g.lock.Unlock()
+			return ctx.Err()
+		}
+		g.lock.Unlock()
+
+		<-round.done // the round is complete and its tokens are being acquired
+		return round.err
+	}
+}
+
+// acquire waits until the limiter admits every goroutine of the group at once
+func (g *Group) acquire(ctx context.Context) error {
+	rl, n := g.limiter, uint64(g.size)
+	for {
+		if !rl.limitN(n) {
+			rl.record(false, n)
+			return nil
+		}
+
+		next := rl.NextAllowed(g.size)
+		if next.IsZero() {
+			rl.record(true, n)
+			return ErrBudget
+		}
+
+		if err := sleepUntil(ctx, next); err != nil {
+			rl.record(true, n)
+			return err
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Group", func() {
+
+	run := func(g *Group, n int) []error {
+		var wg sync.WaitGroup
+		errs := make([]error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = g.Wait(context.Background())
+			}(i)
+		}
+
+		wg.Wait()
+		return errs
+	}
+
+	It("should admit the group all at once", func() {
+		rl := New(3, time.Minute)
+		errs := run(NewGroup(rl, 3), 3)
+		Expect(errs).To(Equal([]error{nil, nil, nil}))
+		Expect(rl.Stats()).To(Equal(Stats{Allowed: 3}))
+	})
+
+	It("should wait for the tokens of the whole group", func() {
+		rl := New(2, 20*time.Millisecond)
+		rl.Limit()
+
+		start := time.Now()
+		errs := run(NewGroup(rl, 2), 4)
+		Expect(errs).To(Equal([]error{nil, nil, nil, nil}))
+		Expect(time.Since(start)).To(BeNumerically(">=", 25*time.Millisecond))
+	})
+
+	It("should fail a group larger than the burst", func() {
+		errs := run(NewGroup(New(2, time.Minute), 3), 3)
+		Expect(errs).To(Equal([]error{ErrBudget, ErrBudget, ErrBudget}))
+	})
+
+	It("should let goroutines leave an incomplete group", func() {
+		rl := New(10, time.Minute)
+		g := NewGroup(rl, 2)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		Expect(g.Wait(ctx)).To(Equal(context.DeadlineExceeded))
+
+		errs := run(g, 2)
+		Expect(errs).To(Equal([]error{nil, nil}))
+		Expect(rl.Remaining()).To(Equal(8))
+	})
+})
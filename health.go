@@ -0,0 +1,122 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Health represents the health of a limiter, as reported by a health check.
+type Health uint8
+
+// Various health levels
+const (
+	Healthy   Health = iota // the limiter is not saturated
+	Degraded                // the limiter is saturated, but still serving most calls
+	Unhealthy               // the limiter is denying or queueing most calls
+)
+
+// String returns the name of the health level.
+func (h Health) String() string {
+	switch h {
+	case Degraded:
+		return "degraded"
+	case Unhealthy:
+		return "unhealthy"
+	default:
+		return "healthy"
+	}
+}
+
+// Errors returned by the health checks
+var (
+	ErrDegraded  = errors.New("rate: limiter is degraded")
+	ErrUnhealthy = errors.New("rate: limiter is unhealthy")
+)
+
+// HealthCheck reports whether a limiter is saturated, based on its Pressure(). A level is
+// only reported once the pressure has stayed above its threshold for a sustained period,
+// so short bursts do not flap the health of a service. HealthCheck instances are
+// thread-safe and can be served as an HTTP handler.
+type HealthCheck struct {
+	lock      sync.Mutex
+	limiter   *Limiter
+	threshold [3]float64 // pressure thresholds of the degraded and unhealthy levels
+	exceeded  [3]bool    // whether the thresholds are currently exceeded
+	since     [3]uint64  // time since which the thresholds are exceeded, in unix ns
+	sustain   uint64     // duration a threshold must be exceeded, in ns
+}
+
+// NewHealthCheck creates a new health check reporting the limiter as degraded or
+// unhealthy once its pressure exceeds the respective threshold, between 0 and 1, for
+// longer than the sustain duration.
+func NewHealthCheck(rl *Limiter, degraded, unhealthy float64, sustain time.Duration) *HealthCheck {
+	return &HealthCheck{
+		limiter:   rl,
+		threshold: [3]float64{0, degraded, unhealthy},
+		sustain:   uint64(sustain),
+	}
+}
+
+// Check returns the current health of the limiter.
+func (c *HealthCheck) Check() Health {
+	pressure := c.limiter.Pressure()
+	now := c.limiter.now()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	health := Healthy
+	for level := Degraded; level <= Unhealthy; level++ {
+		switch {
+		case pressure < c.threshold[level]:
+			c.exceeded[level] = false
+		case !c.exceeded[level]:
+			c.exceeded[level], c.since[level] = true, now
+			fallthrough
+		default:
+			if now-c.since[level] >= c.sustain {
+				health = level
+			}
+		}
+	}
+	return health
+}
+
+// Err returns nil if the limiter is healthy, or ErrDegraded or ErrUnhealthy otherwise,
+// matching the checker functions of most health-check frameworks.
+func (c *HealthCheck) Err() error {
+	switch c.Check() {
+	case Degraded:
+		return ErrDegraded
+	case Unhealthy:
+		return ErrUnhealthy
+	default:
+		return nil
+	}
+}
+
+// ServeHTTP responds with the health of the limiter and its pressure as JSON, with a 503
+// status code once it is unhealthy so that it can be used as a readiness probe.
+func (c *HealthCheck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	health := c.Check()
+	w.Header().Set("Content-Type", "application/json")
+	if health == Unhealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		Status   string  `json:"status"`
+		Pressure float64 `json:"pressure"`
+	}{
+		Status:   health.String(),
+		Pressure: c.limiter.Pressure(),
+	})
+}
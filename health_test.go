@@ -0,0 +1,79 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthCheck", func() {
+
+	It("should report sustained saturation", func() {
+		rl := New(10, time.Minute, WithTicks())
+		c := NewHealthCheck(rl, 0.5, 0.9, 10*time.Second)
+		Expect(c.Check()).To(Equal(Healthy))
+
+		for i := 0; i < 30; i++ {
+			rl.Limit()
+		}
+		Expect(c.Check()).To(Equal(Healthy))
+		Expect(c.Err()).NotTo(HaveOccurred())
+
+		rl.Tick(10 * time.Second)
+		Expect(c.Check()).To(Equal(Degraded))
+		Expect(c.Err()).To(Equal(ErrDegraded))
+
+		for i := 0; i < 300; i++ {
+			rl.Limit()
+		}
+		Expect(c.Check()).To(Equal(Degraded))
+		rl.Tick(10 * time.Second)
+		Expect(c.Check()).To(Equal(Unhealthy))
+		Expect(c.Err()).To(Equal(ErrUnhealthy))
+	})
+
+	It("should recover once the pressure drops", func() {
+		rl := New(10, time.Second, WithTicks())
+		c := NewHealthCheck(rl, 0.5, 0.9, 0)
+		for i := 0; i < 100; i++ {
+			rl.Limit()
+		}
+		Expect(c.Check()).To(Equal(Unhealthy))
+
+		rl.Tick(3 * time.Second)
+		Expect(c.Check()).To(Equal(Healthy))
+	})
+
+	It("should serve the health over HTTP", func() {
+		rl := New(1, time.Second, WithTicks())
+		c := NewHealthCheck(rl, 0.5, 0.9, 0)
+
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(MatchJSON(`{"status":"healthy","pressure":0}`))
+
+		for i := 0; i < 20; i++ {
+			rl.Limit()
+		}
+
+		w = httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+		Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(w.Body.String()).To(MatchJSON(`{"status":"unhealthy","pressure":0.95}`))
+	})
+
+	It("should name the levels", func() {
+		Expect(Healthy.String()).To(Equal("healthy"))
+		Expect(Degraded.String()).To(Equal("degraded"))
+		Expect(Unhealthy.String()).To(Equal("unhealthy"))
+	})
+})
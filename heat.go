@@ -0,0 +1,182 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// activity represents the configuration of the per-key activity tracking
+type activity struct {
+	buckets int   // number of buckets kept per key
+	width   int64 // duration of a bucket, in ns
+}
+
+// heat represents the recent activity of a single key, as a ring of bucket tallies
+type heat []tally
+
+// tally represents the decisions made within a bucket, each packed with the index of
+// the bucket since the epoch in its upper 32 bits, so that the first call of a new bucket
+// resets the stale one without a lock
+type tally struct {
+	allowed atomic.Uint64
+	denied  atomic.Uint64
+}
+
+// Bucket represents the decisions made for a key within a period of time.
+type Bucket struct {
+	Start   time.Time // Start of the bucket
+	Allowed uint64    // Number of calls allowed within the bucket
+	Denied  uint64    // Number of calls denied within the bucket
+}
+
+// WithActivity keeps the activity of every key over the last buckets periods of the
+// specified width, for example 60 buckets of a minute for the last hour. This lets
+// dashboards show which keys were hot during an incident without external metrics, at
+// the cost of a small ring buffer per key.
+func WithActivity(buckets int, width time.Duration) KeyedOption {
+	return func(k *Keyed) {
+		if buckets > 0 && width > 0 {
+			k.activity = &activity{
+				buckets: buckets,
+				width:   int64(width),
+			}
+		}
+	}
+}
+
+// observe records a decision for the key in its current bucket
+func (k *Keyed) observe(key string, limited bool) {
+	now := time.Now()
+	s := k.shard(key)
+	s.RLock()
+	h, ok := s.heat[key]
+	if ok {
+		k.activity.record(h, now, limited)
+		s.RUnlock()
+		return
+	}
+
+	// The ring of a new key is only created once, under the write lock
+	s.RUnlock()
+	s.Lock()
+	if h, ok = s.heat[key]; !ok {
+		h = make(heat, k.activity.buckets)
+		s.heat[key] = h
+	}
+	k.activity.record(h, now, limited)
+	s.Unlock()
+}
+
+// Activity returns the activity of the key over the tracked period, oldest bucket first,
+// or nil if activity tracking is not enabled.
+func (k *Keyed) Activity(key string) []Bucket {
+	if k.activity == nil {
+		return nil
+	}
+
+	s := k.shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	return k.activity.read(s.heat[key], time.Now())
+}
+
+// Heat returns the activity of every key which had any within the tracked period, oldest
+// bucket first, or nil if activity tracking is not enabled.
+func (k *Keyed) Heat() map[string][]Bucket {
+	if k.activity == nil {
+		return nil
+	}
+
+	now := time.Now()
+	out := make(map[string][]Bucket)
+	for i := range k.shards {
+		s := &k.shards[i]
+		s.Lock()
+		for key, h := range s.heat {
+			buckets := k.activity.read(h, now)
+			if active(buckets) {
+				out[key] = buckets
+			} else {
+				delete(s.heat, key)
+			}
+		}
+		s.Unlock()
+	}
+	return out
+}
+
+// index returns the index since the epoch of the bucket of the specified time
+func (a *activity) index(t time.Time) int64 {
+	return t.UnixNano() / a.width
+}
+
+// record counts a decision made at the specified time in its bucket of the ring
+func (a *activity) record(h heat, now time.Time, limited bool) {
+	index := a.index(now)
+	c := &h[index%int64(a.buckets)]
+	if limited {
+		increment(&c.denied, uint32(index))
+	} else {
+		increment(&c.allowed, uint32(index))
+	}
+}
+
+// read returns the buckets of the ring up to the specified time, oldest first
+func (a *activity) read(h heat, now time.Time) []Bucket {
+	out := make([]Bucket, a.buckets)
+	current := a.index(now)
+	for i := range out {
+		index := current - int64(a.buckets-1-i)
+		out[i].Start = time.Unix(0, index*a.width)
+		if h != nil {
+			c := &h[index%int64(a.buckets)]
+			out[i].Allowed = count(&c.allowed, uint32(index))
+			out[i].Denied = count(&c.denied, uint32(index))
+		}
+	}
+	return out
+}
+
+// increment adds one to the counter of the bucket, resetting it if it was counting for
+// another bucket and saturating instead of overflowing into the index
+func increment(counter *atomic.Uint64, index uint32) {
+	for {
+		v, next := counter.Load(), uint64(index)<<32|1
+		switch {
+		case uint32(v>>32) != index:
+		case uint32(v) == math.MaxUint32:
+			return
+		default:
+			next = v + 1
+		}
+
+		if counter.CompareAndSwap(v, next) {
+			return
+		}
+	}
+}
+
+// count returns the value of the counter of the bucket, or zero if it was counting for
+// another bucket
+func count(counter *atomic.Uint64, index uint32) uint64 {
+	if v := counter.Load(); uint32(v>>32) == index {
+		return uint64(uint32(v))
+	}
+	return 0
+}
+
+// active returns whether any of the buckets had activity
+func active(buckets []Bucket) bool {
+	for _, b := range buckets {
+		if b.Allowed > 0 || b.Denied > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+	"unsafe"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Activity", func() {
+
+	It("should track the activity per key", func() {
+		k := NewKeyed(2, time.Minute, WithActivity(3, time.Hour))
+		for i := 0; i < 3; i++ {
+			k.Limit("a")
+		}
+		k.Limit("b")
+
+		buckets := k.Activity("a")
+		Expect(buckets).To(HaveLen(3))
+		Expect(buckets[2].Allowed).To(Equal(uint64(2)))
+		Expect(buckets[2].Denied).To(Equal(uint64(1)))
+		Expect(buckets[1].Start).To(Equal(buckets[2].Start.Add(-time.Hour)))
+		Expect(buckets[0].Allowed + buckets[0].Denied).To(BeZero())
+
+		heat := k.Heat()
+		Expect(heat).To(HaveLen(2))
+		Expect(heat["b"][2].Allowed).To(Equal(uint64(1)))
+		Expect(active(k.Activity("c"))).To(BeFalse())
+
+		k.Remove("a")
+		Expect(k.Heat()).To(HaveLen(1))
+	})
+
+	It("should count banned calls as denied", func() {
+		k := NewKeyed(10, time.Minute, WithActivity(1, time.Hour))
+		k.Ban("a", time.Hour)
+		k.Limit("a")
+		Expect(k.Activity("a")[0].Denied).To(Equal(uint64(1)))
+	})
+
+	It("should not track activity by default", func() {
+		k := NewKeyed(10, time.Minute)
+		k.Limit("a")
+		Expect(k.Activity("a")).To(BeNil())
+		Expect(k.Heat()).To(BeNil())
+	})
+
+	It("should track the activity concurrently in compact buckets", func() {
+		k := NewKeyed(4000, time.Hour, WithActivity(2, time.Hour))
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					k.Limit("a")
+				}
+			}()
+		}
+		wg.Wait()
+
+		var allowed, denied uint64
+		for _, b := range k.Activity("a") {
+			allowed += b.Allowed
+			denied += b.Denied
+		}
+		Expect(allowed).To(Equal(uint64(4000)))
+		Expect(denied).To(Equal(uint64(4000)))
+		Expect(int(unsafe.Sizeof(tally{}))).To(Equal(16))
+	})
+
+	It("should expire old buckets", func() {
+		a := &activity{buckets: 3, width: int64(time.Minute)}
+		h := make(heat, 3)
+		t0 := time.Unix(600, 0)
+		for i, n := range []int{1, 2, 3, 4} {
+			for j := 0; j < n; j++ {
+				a.record(h, t0.Add(time.Duration(i)*time.Minute), false)
+			}
+		}
+
+		buckets := a.read(h, t0.Add(3*time.Minute))
+		Expect([]uint64{buckets[0].Allowed, buckets[1].Allowed, buckets[2].Allowed}).To(Equal([]uint64{2, 3, 4}))
+
+		buckets = a.read(h, t0.Add(5*time.Minute))
+		Expect([]uint64{buckets[0].Allowed, buckets[1].Allowed, buckets[2].Allowed}).To(Equal([]uint64{4, 0, 0}))
+		Expect(active(a.read(h, t0.Add(time.Hour)))).To(BeFalse())
+	})
+})
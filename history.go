@@ -0,0 +1,99 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+// Event represents a single decision recorded by the history of a limiter, so that the
+// throttling of a customer at a given time can be explained after the fact.
+type Event struct {
+	Time      time.Time // Time of the decision
+	Tokens    int       // Number of tokens requested
+	Allowed   bool      // Whether the call was allowed
+	Allowance float64   // Tokens left in the bucket right after the decision
+}
+
+// events represents a ring of the most recent decisions of a limiter
+type events struct {
+	lock   sync.Mutex
+	events []Event // ring of the recorded decisions
+	next   int     // position of the next decision in the ring
+	filled int     // number of decisions in the ring
+}
+
+// observe records a decision
+func (h *events) observe(rl *Limiter, limited bool, n uint64) {
+	e := Event{
+		Time:      time.Unix(0, int64(rl.now())),
+		Tokens:    int(n),
+		Allowed:   !limited,
+		Allowance: float64(rl.allowance.Load()) / float64(rl.unit),
+	}
+
+	h.lock.Lock()
+	h.events[h.next] = e
+	h.next = (h.next + 1) % len(h.events)
+	if h.filled < len(h.events) {
+		h.filled++
+	}
+	h.lock.Unlock()
+}
+
+// RecordDecisions starts recording the last n decisions of the limiter, which History()
+// returns on demand, discarding those recorded so far. It is meant to be turned on at
+// runtime while debugging, as a zero or negative n stops the recording.
+func (rl *Limiter) RecordDecisions(n int) {
+	if n < 1 {
+		if e := rl.optional.Load(); e != nil {
+			e.history.Store(nil)
+		}
+		return
+	}
+
+	rl.extend().history.Store(&events{
+		events: make([]Event, n),
+	})
+}
+
+// History returns the decisions recorded since RecordDecisions() was called, oldest
+// first, or nil if they are not being recorded.
+func (rl *Limiter) History() []Event {
+	h := rl.ext().history.Load()
+	if h == nil {
+		return nil
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	out := make([]Event, 0, h.filled)
+	for i := h.filled; i > 0; i-- {
+		out = append(out, h.events[(h.next-i+len(h.events))%len(h.events)])
+	}
+	return out
+}
+
+// RecordDecisions starts recording the last n decisions of the key, creating its limiter
+// if needed, so that support engineers can find out why a customer was throttled.
+func (k *Keyed) RecordDecisions(key string, n int) {
+	k.Get(key).RecordDecisions(n)
+}
+
+// History returns the decisions recorded for the key, oldest first, or nil if the key is
+// not tracked or its decisions are not being recorded.
+func (k *Keyed) History(key string) []Event {
+	s := k.shard(key)
+	s.RLock()
+	rl, ok := s.limiters[key]
+	s.RUnlock()
+
+	if !ok {
+		return nil
+	}
+	return rl.History()
+}
@@ -0,0 +1,67 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("History", func() {
+
+	It("should not record decisions by default", func() {
+		rl := New(10, time.Second)
+		rl.Limit()
+		Expect(rl.History()).To(BeNil())
+	})
+
+	It("should record the last decisions", func() {
+		clock := NewManualClock(time.Unix(100, 0))
+		rl := New(2, time.Second, WithClock(clock))
+		rl.RecordDecisions(2)
+
+		rl.Limit()
+		clock.Advance(time.Millisecond)
+		rl.LimitN(1)
+		clock.Advance(time.Millisecond)
+		rl.Limit()
+
+		history := rl.History()
+		Expect(history).To(HaveLen(2))
+		Expect(history[0].Time).To(Equal(time.Unix(100, int64(time.Millisecond))))
+		Expect(history[0].Allowed).To(BeTrue())
+		Expect(history[0].Tokens).To(Equal(1))
+		Expect(history[0].Allowance).To(BeNumerically("~", 0.002, 0.001))
+		Expect(history[1].Allowed).To(BeFalse())
+	})
+
+	It("should stop recording", func() {
+		rl := New(10, time.Second)
+		rl.RecordDecisions(10)
+		rl.Limit()
+		Expect(rl.History()).To(HaveLen(1))
+
+		rl.RecordDecisions(0)
+		Expect(rl.History()).To(BeNil())
+	})
+
+	It("should record the decisions of a single key", func() {
+		k := NewKeyed(1, time.Hour)
+		k.RecordDecisions("alice", 10)
+		k.Limit("alice")
+		k.Limit("alice")
+		k.Limit("bob")
+
+		history := k.History("alice")
+		Expect(history).To(HaveLen(2))
+		Expect(history[0].Allowed).To(BeTrue())
+		Expect(history[1].Allowed).To(BeFalse())
+		Expect(k.History("bob")).To(BeNil())
+		Expect(k.History("carol")).To(BeNil())
+	})
+})
@@ -0,0 +1,64 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Various states of a hold
+const (
+	holdPending uint32 = iota
+	holdConfirmed
+	holdReleased
+)
+
+// Hold represents a token held provisionally, for flows where admission is decided
+// before knowing whether the operation will really run. Unless confirmed within its
+// timeout, the token is automatically refunded. Hold instances are thread-safe.
+type Hold struct {
+	limiter *Limiter
+	state   atomic.Uint32
+	timer   *time.Timer
+}
+
+// Hold takes a token provisionally, which must be confirmed within the timeout or it is
+// refunded. It fails with a *LimitedError if no token is available.
+func (rl *Limiter) Hold(timeout time.Duration) (*Hold, error) {
+	if rl.LimitN(1) {
+		return nil, rl.limited(ErrLimited, 0)
+	}
+
+	h := &Hold{limiter: rl}
+	h.timer = time.AfterFunc(timeout, h.release)
+	return h, nil
+}
+
+// Confirm keeps the token and returns true, unless the hold was already released or
+// timed out, in which case the operation should not run.
+func (h *Hold) Confirm() bool {
+	if !h.state.CompareAndSwap(holdPending, holdConfirmed) {
+		return h.state.Load() == holdConfirmed
+	}
+
+	h.timer.Stop()
+	return true
+}
+
+// Release refunds the token, unless the hold was already confirmed.
+func (h *Hold) Release() {
+	if h.timer.Stop() {
+		h.release()
+	}
+}
+
+// release refunds the token if the hold is still pending
+func (h *Hold) release() {
+	if h.state.CompareAndSwap(holdPending, holdReleased) {
+		h.limiter.relinquish(1)
+	}
+}
@@ -0,0 +1,54 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hold", func() {
+
+	It("should keep confirmed tokens", func() {
+		rl := New(2, time.Minute)
+		h, err := rl.Hold(time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rl.Remaining()).To(Equal(1))
+
+		Expect(h.Confirm()).To(BeTrue())
+		Expect(h.Confirm()).To(BeTrue())
+		h.Release()
+		Expect(rl.Remaining()).To(Equal(1))
+	})
+
+	It("should refund released tokens", func() {
+		rl := New(2, time.Minute)
+		h, err := rl.Hold(time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+
+		h.Release()
+		h.Release()
+		Expect(h.Confirm()).To(BeFalse())
+		Expect(rl.Remaining()).To(Equal(2))
+		Expect(rl.Stats().Allowed).To(BeZero())
+	})
+
+	It("should refund tokens which were not confirmed in time", func() {
+		rl := New(1, time.Minute)
+		h, err := rl.Hold(10 * time.Millisecond)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = rl.Hold(time.Minute)
+		Expect(errors.Is(err, ErrLimited)).To(BeTrue())
+
+		Eventually(rl.Remaining).Should(Equal(1))
+		Expect(h.Confirm()).To(BeFalse())
+	})
+
+})
@@ -0,0 +1,69 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// LimitedResponse represents the body of a response written by WriteLimited().
+type LimitedResponse struct {
+	Error     string `json:"error"`           // Description of the error
+	Limit     int    `json:"limit"`           // Number of calls allowed per period
+	Remaining int    `json:"remaining"`       // Number of calls currently allowed
+	Reset     int64  `json:"reset,omitempty"` // Unix time at which the allowance is fully restored
+}
+
+// WriteLimited writes a 429 Too Many Requests response from the state of the limiter,
+// with a Retry-After header in seconds and a JSON body with the limit, the remaining
+// allowance and the time at which it is fully restored.
+func WriteLimited(w http.ResponseWriter, rl *Limiter) error {
+	now := time.Unix(0, int64(rl.now()))
+	retry := rl.delay()
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds(retry), 10))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	return json.NewEncoder(w).Encode(LimitedResponse{
+		Error:     http.StatusText(http.StatusTooManyRequests),
+		Limit:     int(rl.load().rate),
+		Remaining: rl.Remaining(),
+		Reset:     now.Add(rl.UntilFull()).Unix(),
+	})
+}
+
+// WriteOverloaded writes a 503 Service Unavailable response from the state of the
+// resource, when a request is denied because too many requests are in flight rather
+// than because its client exceeded a rate. The JSON body has the same shape as the one
+// of WriteLimited(), with the capacity and the slots currently available.
+func WriteOverloaded(w http.ResponseWriter, r *Resource) error {
+	capacity := r.Capacity()
+	available := capacity - r.InUse()
+	if available < 0 {
+		available = 0
+	}
+
+	w.Header().Set("Retry-After", "1")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	return json.NewEncoder(w).Encode(LimitedResponse{
+		Error:     http.StatusText(http.StatusServiceUnavailable),
+		Limit:     capacity,
+		Remaining: available,
+	})
+}
+
+// seconds rounds the duration up to a whole number of seconds, of at least one
+func seconds(d time.Duration) int64 {
+	if s := int64((d + time.Second - 1) / time.Second); s > 1 {
+		return s
+	}
+	return 1
+}
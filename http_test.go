@@ -0,0 +1,64 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTP", func() {
+
+	It("should write a 429 response", func() {
+		clock := NewManualClock(time.Unix(1000, 0))
+		rl := New(2, time.Minute, WithClock(clock))
+		Expect(rl.LimitN(2)).To(BeFalse())
+
+		w := httptest.NewRecorder()
+		Expect(WriteLimited(w, rl)).To(Succeed())
+		Expect(w.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(w.Header().Get("Retry-After")).To(Equal("30"))
+		Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		var body LimitedResponse
+		Expect(json.Unmarshal(w.Body.Bytes(), &body)).To(Succeed())
+		Expect(body).To(Equal(LimitedResponse{
+			Error:     "Too Many Requests",
+			Limit:     2,
+			Remaining: 0,
+			Reset:     1060,
+		}))
+	})
+
+	It("should write a 503 response", func() {
+		r := NewResource(3)
+		r.LimitN(3)
+
+		w := httptest.NewRecorder()
+		Expect(WriteOverloaded(w, r)).To(Succeed())
+		Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(w.Header().Get("Retry-After")).To(Equal("1"))
+
+		var body LimitedResponse
+		Expect(json.Unmarshal(w.Body.Bytes(), &body)).To(Succeed())
+		Expect(body).To(Equal(LimitedResponse{
+			Error: "Service Unavailable",
+			Limit: 3,
+		}))
+	})
+
+	It("should round the retry up to a second", func() {
+		Expect(seconds(0)).To(Equal(int64(1)))
+		Expect(seconds(1500 * time.Millisecond)).To(Equal(int64(2)))
+		Expect(seconds(3 * time.Second)).To(Equal(int64(3)))
+	})
+
+})
@@ -0,0 +1,27 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// WithInitialTokens makes the limiter start with n tokens instead of a full allowance,
+// up to the maximum, so that limiters created on demand for new keys do not instantly
+// grant a full burst to a brand-new and possibly abusive client. A negative n is treated
+// as zero.
+func WithInitialTokens(n int) Option {
+	return func(rl *Limiter) {
+		if n < 0 {
+			n = 0
+		}
+
+		initial := uint64(n)
+		rl.extend().initial = &initial
+	}
+}
+
+// WithStartEmpty makes the limiter start without any token, so that the first calls are
+// only admitted at the rate. It is the same as WithInitialTokens(0).
+func WithStartEmpty() Option {
+	return WithInitialTokens(0)
+}
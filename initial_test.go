@@ -0,0 +1,38 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Initial", func() {
+
+	It("should start empty", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(10, time.Second, WithClock(clock), WithStartEmpty())
+		Expect(rl.Limit()).To(BeTrue())
+
+		clock.Advance(100 * time.Millisecond)
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+	})
+
+	It("should start with the initial tokens", func() {
+		rl := New(10, time.Hour, WithInitialTokens(3))
+		Expect(rl.Remaining()).To(Equal(3))
+		Expect(New(10, time.Hour, WithInitialTokens(30)).Remaining()).To(Equal(10))
+		Expect(New(10, time.Hour, WithInitialTokens(-1)).Remaining()).To(BeZero())
+	})
+
+	It("should apply to new keys", func() {
+		k := NewKeyed(10, time.Hour, WithLimiterOptions(WithStartEmpty()))
+		Expect(k.Limit("new")).To(BeTrue())
+	})
+})
@@ -0,0 +1,226 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"runtime/pprof"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// shards is the number of shards of a keyed limiter, must be a power of two
+const shards = 64
+
+// Keyed represents a set of limiters, one per key, which are created on demand with
+// the same configuration. Keyed instances are thread-safe.
+type Keyed struct {
+	shards   [shards]shard
+	rate     int
+	per      time.Duration
+	options  []Option                      // options of the limiters created
+	autoban  *autoban                      // optional ban on repeated violations
+	activity *activity                     // optional tracking of the recent activity of the keys
+	hasher   func(key string) uint32       // optional hash assigning the keys to shards
+	evicted  func(key string, final Stats) // optional consumer of the stats of removed keys
+	prewarm  map[string]State              // optional keys created along with the limiter
+	patterns []pattern                     // optional limits of the keys matching patterns
+}
+
+// shard represents a partition of the keyed limiters
+type shard struct {
+	sync.RWMutex
+	limiters map[string]*Limiter
+	bans     map[string]int64    // expiry of the bans, in unix ns
+	strikes  map[string]*strikes // violations of the keys, if auto-ban is enabled
+	heat     map[string]heat     // recent activity of the keys, if tracking is enabled
+}
+
+// KeyedOption represents an option which can be applied to a keyed limiter on creation.
+type KeyedOption func(*Keyed)
+
+// WithLimiterOptions sets the options applied to every limiter created for a key.
+func WithLimiterOptions(options ...Option) KeyedOption {
+	return func(k *Keyed) {
+		k.options = append(k.options, options...)
+	}
+}
+
+// WithHash sets the hash function assigning the keys to shards, for example to reuse
+// pre-computed hashes or to keep the assignment consistent with a proxy layer. The
+// function must not retain the key it is given. By default, a 32-bit FNV-1a hash is used.
+func WithHash(fn func(key string) uint32) KeyedOption {
+	return func(k *Keyed) {
+		k.hasher = fn
+	}
+}
+
+// NewKeyed creates a new keyed limiter, where each key is allowed the specified rate.
+func NewKeyed(rate int, per time.Duration, options ...KeyedOption) *Keyed {
+	k := &Keyed{
+		rate: rate,
+		per:  per,
+	}
+
+	for _, opt := range options {
+		opt(k)
+	}
+
+	for i := range k.shards {
+		k.shards[i].limiters = make(map[string]*Limiter)
+		k.shards[i].bans = make(map[string]int64)
+		k.shards[i].strikes = make(map[string]*strikes)
+		k.shards[i].heat = make(map[string]heat)
+	}
+
+	if k.prewarm != nil {
+		k.warm()
+	}
+	return k
+}
+
+// Get returns the limiter for the key, creating it if it does not exist yet.
+func (k *Keyed) Get(key string) *Limiter {
+	rl, _ := k.lookup(key)
+	return rl
+}
+
+// lookup returns the limiter for the key, creating it if it does not exist yet, along
+// with whether the key is currently banned
+func (k *Keyed) lookup(key string) (*Limiter, bool) {
+	s := k.shard(key)
+	s.RLock()
+	rl, ok := s.limiters[key]
+	until, banned := s.bans[key]
+	s.RUnlock()
+
+	if banned {
+		banned = time.Now().UnixNano() < until
+	}
+	if !ok {
+		rl = k.create(s, key)
+	}
+	return rl, banned
+}
+
+// create creates the limiter for the key, unless it already exists
+func (k *Keyed) create(s *shard, key string) *Limiter {
+	s.Lock()
+	defer s.Unlock()
+	if rl, ok := s.limiters[key]; ok {
+		return rl
+	}
+
+	rl := k.spawn(key)
+	s.limiters[key] = rl
+	return rl
+}
+
+// Set replaces the limiter for the key, allowing specific keys to be configured
+// differently from the rest.
+func (k *Keyed) Set(key string, rl *Limiter) {
+	s := k.shard(key)
+	s.Lock()
+	s.limiters[key] = rl
+	s.Unlock()
+}
+
+// Remove removes the limiter for the key, if any.
+func (k *Keyed) Remove(key string) {
+	s := k.shard(key)
+	s.Lock()
+	rl, ok := s.limiters[key]
+	delete(s.limiters, key)
+	delete(s.heat, key)
+	delete(s.strikes, key)
+	s.Unlock()
+
+	if ok && k.evicted != nil {
+		k.evicted(key, rl.Stats())
+	}
+}
+
+// Len returns the number of keys currently tracked.
+func (k *Keyed) Len() (n int) {
+	for i := range k.shards {
+		s := &k.shards[i]
+		s.RLock()
+		n += len(s.limiters)
+		s.RUnlock()
+	}
+	return
+}
+
+// Limit returns true if rate was exceeded for the key or if the key is banned.
+func (k *Keyed) Limit(key string) bool {
+	rl, banned := k.lookup(key)
+	limited := banned || rl.Limit()
+	if k.activity != nil {
+		k.observe(key, limited)
+	}
+	if limited && !banned && k.autoban != nil {
+		k.strike(key)
+	}
+	return limited
+}
+
+// LimitBytes returns true if rate was exceeded for the key or if the key is banned. It
+// is the same as Limit() but does not allocate a string for keys which already exist,
+// such as keys sliced out of a request buffer.
+func (k *Keyed) LimitBytes(key []byte) bool {
+	s := k.shard(*(*string)(unsafe.Pointer(&key))) // the hash does not retain the key
+	s.RLock()
+	rl, ok := s.limiters[string(key)]
+	_, banned := s.bans[string(key)]
+	s.RUnlock()
+
+	// Take the slow path for new or banned keys, or to track the activity of the key
+	if !ok || banned || k.activity != nil {
+		return k.Limit(string(key))
+	}
+
+	limited := rl.Limit()
+	if limited && k.autoban != nil {
+		k.strike(string(key))
+	}
+	return limited
+}
+
+// Wait blocks until a token is available for the key or the context is done. While
+// blocked, the goroutine carries a "key" pprof label with the key. Waiting for a banned
+// key fails with ErrBanned.
+func (k *Keyed) Wait(ctx context.Context, key string) error {
+	rl, banned := k.lookup(key)
+	if banned {
+		return ErrBanned
+	}
+
+	return rl.Wait(pprof.WithLabels(ctx, pprof.Labels("key", key)))
+}
+
+// shard returns the shard for the key
+func (k *Keyed) shard(key string) *shard {
+	return &k.shards[k.index(key)]
+}
+
+// index returns the index of the shard for the key
+func (k *Keyed) index(key string) uint32 {
+	if k.hasher != nil {
+		return k.hasher(key) & (shards - 1)
+	}
+	return hash(key) & (shards - 1)
+}
+
+// hash computes a 32-bit FNV-1a hash of the key
+func hash(key string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return h
+}
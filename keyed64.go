@@ -0,0 +1,201 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Keyed64 represents a set of limiters keyed by integers, such as user or connection IDs,
+// which are created on demand with the same configuration. It skips string handling
+// entirely and stores the limiters in open-addressed tables, which suits game servers
+// and packet processors. Keyed64 instances are thread-safe.
+type Keyed64 struct {
+	shards  [shards]table
+	rate    int
+	per     time.Duration
+	options []Option // options of the limiters created
+}
+
+// table represents an open-addressed hash table with linear probing, holding a partition
+// of the limiters
+type table struct {
+	sync.RWMutex
+	keys     []uint64
+	limiters []*Limiter // limiters of the keys, nil for empty slots
+	count    int        // number of occupied slots
+}
+
+// NewKeyed64 creates a new integer keyed limiter, where each key is allowed the specified
+// rate. The options are applied to every limiter created for a key.
+func NewKeyed64(rate int, per time.Duration, options ...Option) *Keyed64 {
+	k := &Keyed64{
+		rate:    rate,
+		per:     per,
+		options: options,
+	}
+
+	for i := range k.shards {
+		k.shards[i].keys = make([]uint64, 8)
+		k.shards[i].limiters = make([]*Limiter, 8)
+	}
+	return k
+}
+
+// Get returns the limiter for the key, creating it if it does not exist yet.
+func (k *Keyed64) Get(key uint64) *Limiter {
+	h := mix(key)
+	t := k.table(h)
+	t.RLock()
+	i, ok := t.find(key, h)
+	rl := t.limiters[i]
+	t.RUnlock()
+	if ok {
+		return rl
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if i, ok := t.find(key, h); ok {
+		return t.limiters[i]
+	}
+
+	rl = New(k.rate, k.per, k.options...)
+	t.insert(key, h, rl)
+	return rl
+}
+
+// Set replaces the limiter for the key, allowing specific keys to be configured
+// differently from the rest.
+func (k *Keyed64) Set(key uint64, rl *Limiter) {
+	h := mix(key)
+	t := k.table(h)
+	t.Lock()
+	defer t.Unlock()
+
+	if i, ok := t.find(key, h); ok {
+		t.limiters[i] = rl
+		return
+	}
+	t.insert(key, h, rl)
+}
+
+// Remove removes the limiter for the key, if any.
+func (k *Keyed64) Remove(key uint64) {
+	h := mix(key)
+	t := k.table(h)
+	t.Lock()
+	defer t.Unlock()
+
+	if i, ok := t.find(key, h); ok {
+		t.remove(i)
+	}
+}
+
+// Len returns the number of keys currently tracked.
+func (k *Keyed64) Len() (n int) {
+	for i := range k.shards {
+		t := &k.shards[i]
+		t.RLock()
+		n += t.count
+		t.RUnlock()
+	}
+	return
+}
+
+// Limit returns true if rate was exceeded for the key.
+func (k *Keyed64) Limit(key uint64) bool {
+	return k.Get(key).Limit()
+}
+
+// LimitN returns true if rate would be exceeded by n calls at once for the key,
+// otherwise it consumes n tokens.
+func (k *Keyed64) LimitN(key uint64, n int) bool {
+	return k.Get(key).LimitN(n)
+}
+
+// Wait blocks until a token is available for the key or the context is done.
+func (k *Keyed64) Wait(ctx context.Context, key uint64) error {
+	return k.Get(key).Wait(ctx)
+}
+
+// table returns the table for the hash of a key, picked by its top bits as the bottom ones
+// pick the slot
+func (k *Keyed64) table(h uint64) *table {
+	return &k.shards[h>>58] // 64 shards
+}
+
+// find returns the slot of the key and whether it was found, otherwise the empty slot
+// where it would be inserted
+func (t *table) find(key, h uint64) (int, bool) {
+	mask := uint64(len(t.keys) - 1)
+	for i := h & mask; ; i = (i + 1) & mask {
+		switch {
+		case t.limiters[i] == nil:
+			return int(i), false
+		case t.keys[i] == key:
+			return int(i), true
+		}
+	}
+}
+
+// insert adds a key which is not in the table yet, growing the table so that it remains
+// at most three quarters full
+func (t *table) insert(key, h uint64, rl *Limiter) {
+	if (t.count+1)*4 > len(t.keys)*3 {
+		t.grow()
+	}
+
+	i, _ := t.find(key, h)
+	t.keys[i] = key
+	t.limiters[i] = rl
+	t.count++
+}
+
+// grow doubles the size of the table and rehashes every key
+func (t *table) grow() {
+	keys, limiters := t.keys, t.limiters
+	t.keys = make([]uint64, len(keys)*2)
+	t.limiters = make([]*Limiter, len(keys)*2)
+	for i, rl := range limiters {
+		if rl != nil {
+			j, _ := t.find(keys[i], mix(keys[i]))
+			t.keys[j] = keys[i]
+			t.limiters[j] = rl
+		}
+	}
+}
+
+// remove empties the slot, shifting back the keys which follow it so that no probe
+// sequence is broken
+func (t *table) remove(i int) {
+	mask := len(t.keys) - 1
+	for j := (i + 1) & mask; t.limiters[j] != nil; j = (j + 1) & mask {
+		// A key can move into the hole only if its home slot is not between the hole
+		// and its current slot, cyclically
+		home := int(mix(t.keys[j]) & uint64(mask))
+		if (j > i && (home <= i || home > j)) || (j < i && home <= i && home > j) {
+			t.keys[i], t.limiters[i] = t.keys[j], t.limiters[j]
+			i = j
+		}
+	}
+
+	t.keys[i] = 0
+	t.limiters[i] = nil
+	t.count--
+}
+
+// mix scrambles the bits of the key so that sequential keys are evenly spread
+func mix(key uint64) uint64 {
+	key ^= key >> 33
+	key *= 0xff51afd7ed558ccd
+	key ^= key >> 33
+	key *= 0xc4ceb9fe1a85ec53
+	key ^= key >> 33
+	return key
+}
@@ -0,0 +1,110 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Keyed64", func() {
+
+	It("should limit each key independently", func() {
+		k := NewKeyed64(1, time.Hour)
+		Expect(k.Limit(1)).To(BeFalse())
+		Expect(k.Limit(1)).To(BeTrue())
+		Expect(k.Limit(2)).To(BeFalse())
+		Expect(k.Limit(0)).To(BeFalse())
+		Expect(k.Len()).To(Equal(3))
+	})
+
+	It("should allow overriding and removing a key", func() {
+		k := NewKeyed64(1, time.Hour)
+		k.Set(7, New(5, time.Hour))
+		Expect(k.LimitN(7, 5)).To(BeFalse())
+		Expect(k.Limit(7)).To(BeTrue())
+
+		k.Remove(7)
+		Expect(k.Len()).To(BeZero())
+		Expect(k.LimitN(7, 2)).To(BeTrue())
+	})
+
+	It("should apply limiter options", func() {
+		k := NewKeyed64(1, time.Hour, WithName("conn"))
+		Expect(k.Get(1).Name()).To(Equal("conn"))
+	})
+
+	It("should wait for a key", func() {
+		k := NewKeyed64(1, 10*time.Millisecond)
+		Expect(k.Wait(context.Background(), 1)).To(Succeed())
+		Expect(k.Wait(context.Background(), 1)).To(Succeed())
+	})
+
+	It("should keep every key reachable while growing and removing", func() {
+		k := NewKeyed64(1, time.Hour)
+		expected := make(map[uint64]*Limiter)
+		random := rand.New(rand.NewSource(1))
+		for i := 0; i < 20000; i++ {
+			key := uint64(random.Intn(5000))
+			if random.Intn(3) == 0 {
+				k.Remove(key)
+				delete(expected, key)
+			} else {
+				expected[key] = k.Get(key)
+			}
+		}
+
+		Expect(k.Len()).To(Equal(len(expected)))
+		for key, rl := range expected {
+			Expect(k.Get(key)).To(BeIdenticalTo(rl))
+		}
+	})
+
+	It("should not allocate for existing keys", func() {
+		k := NewKeyed64(1000000000, time.Second)
+		k.Limit(42)
+		Expect(testing.AllocsPerRun(100, func() { k.Limit(42) })).To(BeZero())
+	})
+
+	It("should be thread-safe", func() {
+		k := NewKeyed64(100, time.Hour)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					Expect(k.Limit(uint64(j))).To(BeFalse())
+				}
+			}()
+		}
+
+		wg.Wait()
+		Expect(k.Len()).To(Equal(100))
+	})
+})
+
+// --------------------------------------------------------------------
+
+func BenchmarkKeyed64(b *testing.B) {
+	k := NewKeyed64(1000000000, time.Second)
+	for i := 0; i < 1000; i++ {
+		k.Limit(uint64(i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k.Limit(uint64(i % 1000))
+	}
+}
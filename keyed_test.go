@@ -0,0 +1,161 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Keyed", func() {
+
+	It("should limit each key separately", func() {
+		k := NewKeyed(2, time.Minute)
+		Expect(k.Limit("a")).To(BeFalse())
+		Expect(k.Limit("a")).To(BeFalse())
+		Expect(k.Limit("a")).To(BeTrue())
+		Expect(k.Limit("b")).To(BeFalse())
+		Expect(k.Len()).To(Equal(2))
+
+		k.Remove("a")
+		Expect(k.Len()).To(Equal(1))
+		Expect(k.Limit("a")).To(BeFalse())
+	})
+
+	It("should allow overriding a key", func() {
+		k := NewKeyed(1, time.Minute)
+		k.Set("vip", New(3, time.Minute))
+		for i := 0; i < 3; i++ {
+			Expect(k.Limit("vip")).To(BeFalse())
+		}
+		Expect(k.Limit("vip")).To(BeTrue())
+	})
+
+	It("should apply limiter options", func() {
+		clock := NewManualClock(time.Now())
+		k := NewKeyed(1, time.Second, WithLimiterOptions(WithClock(clock)))
+		Expect(k.Limit("a")).To(BeFalse())
+		Expect(k.Limit("a")).To(BeTrue())
+
+		clock.Advance(time.Second)
+		Expect(k.Limit("a")).To(BeFalse())
+	})
+
+	It("should wait for a key", func() {
+		k := NewKeyed(1, time.Minute)
+		Expect(k.Wait(context.Background(), "a")).To(Succeed())
+		Expect(k.Limit("a")).To(BeTrue())
+	})
+
+	It("should label the goroutines waiting for a key", func() {
+		k := NewKeyed(1, time.Minute)
+		Expect(k.Limit("alice")).To(BeFalse())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go k.Wait(ctx, "alice")
+		rl, _ := k.lookup("alice")
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+
+		var buffer bytes.Buffer
+		Expect(pprof.Lookup("goroutine").WriteTo(&buffer, 1)).To(Succeed())
+		Expect(buffer.String()).To(ContainSubstring(`"key":"alice"`))
+	})
+
+	It("should be thread-safe", func() {
+		k := NewKeyed(100, time.Hour)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					Expect(k.Limit(strconv.Itoa(j))).To(BeFalse())
+				}
+			}()
+		}
+
+		wg.Wait()
+		Expect(k.Len()).To(Equal(100))
+	})
+
+	It("should limit byte keys", func() {
+		k := NewKeyed(1, time.Hour)
+		Expect(k.LimitBytes([]byte("a"))).To(BeFalse())
+		Expect(k.LimitBytes([]byte("a"))).To(BeTrue())
+		Expect(k.Limit("a")).To(BeTrue())
+		Expect(k.Len()).To(Equal(1))
+	})
+
+	It("should assign keys to shards with a custom hash", func() {
+		k := NewKeyed(1, time.Hour, WithHash(func(key string) uint32 {
+			return uint32(len(key))
+		}))
+
+		Expect(k.Limit("a")).To(BeFalse())
+		Expect(k.LimitBytes([]byte("ab"))).To(BeFalse())
+		Expect(k.LimitBytes([]byte("a"))).To(BeTrue())
+		Expect(k.shards[1].limiters).To(HaveKey("a"))
+		Expect(k.shards[2].limiters).To(HaveKey("ab"))
+	})
+
+	It("should not allocate for existing keys", func() {
+		k := NewKeyed(1000000000, time.Second)
+		key := []byte("alice")
+		k.Limit("alice")
+
+		Expect(testing.AllocsPerRun(100, func() { k.Limit("alice") })).To(BeZero())
+		Expect(testing.AllocsPerRun(100, func() { k.LimitBytes(key) })).To(BeZero())
+	})
+
+	It("should not allocate for denied byte keys", func() {
+		k := NewKeyed(1, time.Hour)
+		key := []byte("alice")
+		k.LimitBytes(key)
+
+		Expect(testing.AllocsPerRun(100, func() { k.LimitBytes(key) })).To(BeZero())
+	})
+
+})
+
+// --------------------------------------------------------------------
+
+func BenchmarkKeyed(b *testing.B) {
+	k := NewKeyed(1000000000, time.Second)
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k.Limit(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkKeyedBytes(b *testing.B) {
+	k := NewKeyed(1000000000, time.Second)
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+		k.LimitBytes(keys[i])
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k.LimitBytes(keys[i%len(keys)])
+	}
+}
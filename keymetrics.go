@@ -0,0 +1,146 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// KeyStats represents the stats of a single key of a keyed limiter.
+type KeyStats struct {
+	Key string
+	Stats
+}
+
+// weight returns the amount of traffic of the key, used to rank the heaviest keys
+func (s *KeyStats) weight() uint64 {
+	return s.Allowed + s.Denied + s.Shadowed
+}
+
+// Top returns the stats of the n heaviest keys by number of decisions, heaviest first,
+// along with the aggregated stats of all of the other keys. This keeps the cardinality
+// of exported metrics bounded, for example when keys are client addresses, while still
+// giving visibility into the keys which dominate the traffic.
+func (k *Keyed) Top(n int) (top []KeyStats, rest Stats) {
+	h := make(keyHeap, 0, n)
+	k.each(func(key string, rl *Limiter) {
+		entry := KeyStats{Key: key, Stats: rl.Stats()}
+		switch {
+		case len(h) < n:
+			heap.Push(&h, entry)
+		case n > 0 && entry.weight() > h[0].weight():
+			rest.add(h[0].Stats)
+			h[0] = entry
+			heap.Fix(&h, 0)
+		default:
+			rest.add(entry.Stats)
+		}
+	})
+
+	top = make([]KeyStats, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		top[i] = heap.Pop(&h).(KeyStats)
+	}
+	return top, rest
+}
+
+// Sample returns the stats of a fraction of the keys, sorted by key. Keys are sampled by
+// their hash, so the same keys are returned on every call and exported series do not
+// churn between scrapes.
+func (k *Keyed) Sample(fraction float64) []KeyStats {
+	threshold := uint64(fraction * math.MaxUint32)
+	out := make([]KeyStats, 0)
+	k.each(func(key string, rl *Limiter) {
+		if uint64(sampleHash(key)) < threshold {
+			out = append(out, KeyStats{Key: key, Stats: rl.Stats()})
+		}
+	})
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+// Range calls the function with the stats of every key until it returns false. Each shard
+// is only locked while its keys are copied, while the stats are read and the function is
+// called without holding any lock, so walking millions of keys does not stall the calls
+// on the hot path. Keys added or removed while ranging may or may not be visited.
+func (k *Keyed) Range(fn func(key string, stats Stats) bool) {
+	type entry struct {
+		key     string
+		limiter *Limiter
+	}
+
+	var batch []entry
+	for i := range k.shards {
+		s := &k.shards[i]
+		s.RLock()
+		batch = batch[:0]
+		for key, rl := range s.limiters {
+			batch = append(batch, entry{key: key, limiter: rl})
+		}
+		s.RUnlock()
+
+		for _, e := range batch {
+			if !fn(e.key, e.limiter.Stats()) {
+				return
+			}
+		}
+	}
+}
+
+// each calls the function for every key currently tracked, one shard at a time
+func (k *Keyed) each(fn func(key string, rl *Limiter)) {
+	for i := range k.shards {
+		s := &k.shards[i]
+		s.RLock()
+		for key, rl := range s.limiters {
+			fn(key, rl)
+		}
+		s.RUnlock()
+	}
+}
+
+// sampleHash mixes the hash of the key, so that the sampled keys are independent from
+// the shard they belong to
+func sampleHash(key string) uint32 {
+	h := hash(key)
+	h ^= h >> 16
+	h *= 0x45d9f3b
+	h ^= h >> 16
+	return h
+}
+
+// add accumulates the counters of other stats
+func (s *Stats) add(other Stats) {
+	s.Allowed += other.Allowed
+	s.Denied += other.Denied
+	s.Undone += other.Undone
+	s.Refunded += other.Refunded
+	s.Shadowed += other.Shadowed
+	s.Success += other.Success
+	s.Failure += other.Failure
+	for i := range s.Waits {
+		s.Waits[i] += other.Waits[i]
+	}
+}
+
+// keyHeap is a min-heap of key stats by weight
+type keyHeap []KeyStats
+
+func (h keyHeap) Len() int            { return len(h) }
+func (h keyHeap) Less(i, j int) bool  { return h[i].weight() < h[j].weight() }
+func (h keyHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *keyHeap) Push(x interface{}) { *h = append(*h, x.(KeyStats)) }
+func (h *keyHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
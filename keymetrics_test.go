@@ -0,0 +1,101 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("KeyStats", func() {
+
+	It("should return the heaviest keys", func() {
+		k := NewKeyed(100, time.Minute)
+		for i := 1; i <= 5; i++ {
+			key := strconv.Itoa(i)
+			for j := 0; j < i; j++ {
+				k.Limit(key)
+			}
+		}
+
+		top, rest := k.Top(2)
+		Expect(top).To(HaveLen(2))
+		Expect(top[0].Key).To(Equal("5"))
+		Expect(top[0].Allowed).To(Equal(uint64(5)))
+		Expect(top[1].Key).To(Equal("4"))
+		Expect(rest.Allowed).To(Equal(uint64(1 + 2 + 3)))
+	})
+
+	It("should return every key when there are few", func() {
+		k := NewKeyed(1, time.Minute)
+		k.Limit("a")
+		k.Limit("a")
+
+		top, rest := k.Top(10)
+		Expect(top).To(Equal([]KeyStats{{Key: "a", Stats: Stats{Allowed: 1, Denied: 1}}}))
+		Expect(rest).To(Equal(Stats{}))
+
+		top, rest = k.Top(0)
+		Expect(top).To(BeEmpty())
+		Expect(rest).To(Equal(Stats{Allowed: 1, Denied: 1}))
+	})
+
+	It("should sample a stable fraction of the keys", func() {
+		k := NewKeyed(10, time.Minute)
+		for i := 0; i < 1000; i++ {
+			k.Limit(strconv.Itoa(i))
+		}
+
+		sample := k.Sample(0.1)
+		Expect(len(sample)).To(BeNumerically("~", 100, 40))
+		Expect(k.Sample(0.1)).To(Equal(sample))
+		Expect(k.Sample(0)).To(BeEmpty())
+		Expect(k.Sample(1)).To(HaveLen(1000))
+	})
+
+	It("should range over every key", func() {
+		k := NewKeyed(10, time.Minute)
+		for i := 0; i < 100; i++ {
+			k.Limit(strconv.Itoa(i))
+		}
+
+		seen := make(map[string]uint64)
+		k.Range(func(key string, stats Stats) bool {
+			seen[key] = stats.Allowed
+			return true
+		})
+		Expect(seen).To(HaveLen(100))
+		Expect(seen["42"]).To(Equal(uint64(1)))
+	})
+
+	It("should stop ranging when asked to", func() {
+		k := NewKeyed(10, time.Minute)
+		for i := 0; i < 100; i++ {
+			k.Limit(strconv.Itoa(i))
+		}
+
+		visited := 0
+		k.Range(func(string, Stats) bool {
+			visited++
+			return visited < 10
+		})
+		Expect(visited).To(Equal(10))
+	})
+
+	It("should not hold the locks while ranging", func() {
+		k := NewKeyed(10, time.Minute)
+		k.Limit("a")
+		k.Range(func(key string, _ Stats) bool {
+			k.Remove(key)
+			k.Limit("b")
+			return true
+		})
+		Expect(k.Len()).To(BeNumerically(">=", 1))
+	})
+})
@@ -0,0 +1,62 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync/atomic"
+)
+
+// latch represents the transitions of a limiter between allowing and denying calls
+type latch struct {
+	recovery  float64     // fraction of the allowance which must be left to recover
+	denying   func()      // callback fired on the first denial
+	recovered func()      // callback fired once recovered
+	set       atomic.Bool // whether the limiter is currently denying
+}
+
+// WithDenialLatch calls the denying function once when the limiter goes from allowing to
+// denying calls, and the recovered function once it allows calls again with at least a
+// fraction of its maximum allowance left, such as 0.5. The gap between the two acts as
+// a hysteresis, so alerts do not fire on every denied call nor flap around the limit.
+// Either function may be nil.
+func WithDenialLatch(recovery float64, denying, recovered func()) Option {
+	return func(rl *Limiter) {
+		rl.extend().latch = &latch{
+			recovery:  recovery,
+			denying:   denying,
+			recovered: recovered,
+		}
+	}
+}
+
+// Denying returns whether the limiter denied a call and has not recovered since.
+func (rl *Limiter) Denying() bool {
+	l := rl.ext().latch
+	return l != nil && l.set.Load()
+}
+
+// check updates the state of the latch after a decision
+func (l *latch) check(rl *Limiter, limited bool) {
+	if limited {
+		if l.set.CompareAndSwap(false, true) && l.denying != nil {
+			l.denying()
+		}
+		return
+	}
+
+	if !l.set.Load() {
+		return
+	}
+
+	_, max := rl.limits(rl.now())
+	if left := minUint64(rl.allowance.Load(), max); float64(left) < l.recovery*float64(max) {
+		return
+	}
+
+	if l.set.CompareAndSwap(true, false) && l.recovered != nil {
+		l.recovered()
+	}
+}
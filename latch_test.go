@@ -0,0 +1,56 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Latch", func() {
+
+	It("should notify once when denying and once recovered", func() {
+		var denying, recovered int
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock), WithDenialLatch(0.5, func() {
+			denying++
+		}, func() {
+			recovered++
+		}))
+
+		Expect(rl.LimitN(10)).To(BeFalse())
+		Expect(rl.Denying()).To(BeFalse())
+		for i := 0; i < 5; i++ {
+			Expect(rl.Limit()).To(BeTrue())
+		}
+		Expect(rl.Denying()).To(BeTrue())
+		Expect(denying).To(Equal(1))
+
+		// Allowed again, but not enough allowance left to recover
+		clock.Advance(300 * time.Millisecond)
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.Denying()).To(BeTrue())
+		Expect(recovered).To(Equal(0))
+
+		clock.Advance(500 * time.Millisecond)
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.Denying()).To(BeFalse())
+		Expect(recovered).To(Equal(1))
+
+		Expect(rl.LimitN(10)).To(BeTrue())
+		Expect(denying).To(Equal(2))
+	})
+
+	It("should not be denying by default", func() {
+		rl := New(1, time.Second)
+		rl.Limit()
+		rl.Limit()
+		Expect(rl.Denying()).To(BeFalse())
+	})
+
+})
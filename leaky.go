@@ -0,0 +1,130 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"sync"
+)
+
+// Leaky is a leaky bucket holding up to a capacity of items, which leak out in order at
+// the rate of the limiter. Items are consumed either by pulling them with Next() or by
+// registering a consumer with OnDrain(), which turns it into a complete paced dispatcher.
+// Leaky instances are thread-safe.
+type Leaky[T any] struct {
+	limiter *Limiter
+	queue   chan T
+	ctx     context.Context // cancelled on close, stopping the consumers
+	cancel  context.CancelFunc
+	done    sync.WaitGroup
+}
+
+// NewLeaky creates a new leaky bucket holding up to capacity items, which are drained at
+// the rate of the limiter.
+func NewLeaky[T any](rl *Limiter, capacity int) *Leaky[T] {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Leaky[T]{
+		limiter: rl,
+		queue:   make(chan T, capacity),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Offer adds the item to the bucket and returns true, or returns false if the bucket is
+// full and the item overflows, or if the bucket was closed.
+func (l *Leaky[T]) Offer(item T) bool {
+	if l.ctx.Err() != nil {
+		return false
+	}
+
+	select {
+	case l.queue <- item:
+		return true
+	default:
+		return false
+	}
+}
+
+// Next blocks until the next item leaks out of the bucket or the context is done, and
+// fails with ErrClosed once the bucket was closed, including while it is blocked.
+func (l *Leaky[T]) Next(ctx context.Context) (T, error) {
+	var zero T
+	if l.ctx.Err() != nil {
+		return zero, ErrClosed
+	}
+
+	joined, cancel := l.join(ctx)
+	defer cancel()
+	if err := l.limiter.Wait(joined); err != nil {
+		return zero, l.failed(err)
+	}
+
+	select {
+	case item := <-l.queue:
+		return item, nil
+	case <-joined.Done():
+		l.limiter.Undo() // no item was drained with the token
+		return zero, l.failed(ctx.Err())
+	}
+}
+
+// join returns a context which is done once either the context or the bucket is, along
+// with the function releasing it
+func (l *Leaky[T]) join(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == l.ctx {
+		return ctx, func() {}
+	}
+
+	joined, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-l.ctx.Done():
+			cancel()
+		case <-joined.Done():
+		}
+	}()
+	return joined, cancel
+}
+
+// failed returns ErrClosed if the bucket was closed, otherwise the error
+func (l *Leaky[T]) failed(err error) error {
+	if l.ctx.Err() != nil {
+		return ErrClosed
+	}
+	return err
+}
+
+// OnDrain registers a consumer which is called with every item as it leaks out of the
+// bucket, from a background goroutine which runs until Close() is called. It may be
+// called several times to run several consumers, which share the drain rate.
+func (l *Leaky[T]) OnDrain(fn func(T)) {
+	l.done.Add(1)
+	go func() {
+		defer l.done.Done()
+		for {
+			item, err := l.Next(l.ctx)
+			if err != nil {
+				return
+			}
+
+			fn(item)
+		}
+	}()
+}
+
+// Len returns the number of items in the bucket.
+func (l *Leaky[T]) Len() int {
+	return len(l.queue)
+}
+
+// Close stops the consumers and waits for the calls in progress to return. Items left
+// in the bucket are discarded.
+func (l *Leaky[T]) Close() error {
+	l.cancel()
+	l.done.Wait()
+	return nil
+}
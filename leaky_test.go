@@ -0,0 +1,93 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Leaky", func() {
+
+	It("should overflow once full", func() {
+		l := NewLeaky[int](New(1, time.Hour), 2)
+		Expect(l.Offer(1)).To(BeTrue())
+		Expect(l.Offer(2)).To(BeTrue())
+		Expect(l.Offer(3)).To(BeFalse())
+		Expect(l.Len()).To(Equal(2))
+	})
+
+	It("should leak the items in order at the rate", func() {
+		l := NewLeaky[string](New(1, 10*time.Millisecond), 10)
+		l.Offer("a")
+		l.Offer("b")
+		l.Offer("c")
+
+		start := time.Now()
+		for _, expect := range []string{"a", "b", "c"} {
+			item, err := l.Next(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(item).To(Equal(expect))
+		}
+		Expect(time.Since(start)).To(BeNumerically(">=", 15*time.Millisecond))
+	})
+
+	It("should give the token back when no item arrives", func() {
+		rl := New(1, time.Hour)
+		l := NewLeaky[int](rl, 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		_, err := l.Next(ctx)
+		Expect(err).To(Equal(context.DeadlineExceeded))
+		Expect(rl.Remaining()).To(Equal(1))
+	})
+
+	It("should release the callers blocked in Next on close", func() {
+		rl := New(1, time.Hour)
+		l := NewLeaky[int](rl, 1)
+
+		done := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				_, err := l.Next(context.Background())
+				done <- err
+			}()
+		}
+
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+		Expect(l.Close()).To(Succeed())
+		Eventually(done).Should(Receive(Equal(ErrClosed)))
+		Eventually(done).Should(Receive(Equal(ErrClosed)))
+		Expect(rl.Remaining()).To(Equal(1))
+	})
+
+	It("should call the consumer at the drain rate", func() {
+		l := NewLeaky[int](New(1, 5*time.Millisecond), 10)
+		var lock sync.Mutex
+		var drained []int
+		l.OnDrain(func(item int) {
+			lock.Lock()
+			defer lock.Unlock()
+			drained = append(drained, item)
+		})
+
+		for i := 0; i < 4; i++ {
+			l.Offer(i)
+		}
+
+		Eventually(func() []int {
+			lock.Lock()
+			defer lock.Unlock()
+			return append([]int(nil), drained...)
+		}).Should(Equal([]int{0, 1, 2, 3}))
+		l.Close()
+	})
+})
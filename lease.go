@@ -0,0 +1,67 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// Lease represents tokens acquired for an operation whose actual cost is only known once
+// it completes. The caller marks the tokens actually used and the rest are refunded on
+// Close(). A lease is not thread-safe and should be owned by a single goroutine.
+type Lease struct {
+	limiter *Limiter
+	leased  uint64 // number of tokens acquired
+	used    uint64 // number of tokens marked as used
+	closed  bool
+}
+
+// Lease acquires n tokens at once, failing with a *LimitedError if they are not all
+// available.
+func (rl *Limiter) Lease(n int) (*Lease, error) {
+	if n < 1 {
+		return &Lease{limiter: rl}, nil
+	}
+
+	if rl.LimitN(n) {
+		return nil, rl.limited(ErrLimited, 0)
+	}
+
+	return &Lease{
+		limiter: rl,
+		leased:  uint64(n),
+	}, nil
+}
+
+// Use marks n more tokens of the lease as used, up to the number of tokens leased.
+func (l *Lease) Use(n int) {
+	if n > 0 {
+		l.used += uint64(n)
+	}
+	if l.used > l.leased {
+		l.used = l.leased
+	}
+}
+
+// Used returns the number of tokens marked as used.
+func (l *Lease) Used() int {
+	return int(l.used)
+}
+
+// Len returns the number of tokens leased.
+func (l *Lease) Len() int {
+	return int(l.leased)
+}
+
+// Close refunds the unused tokens of the lease back to the limiter.
+func (l *Lease) Close() error {
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+	if unused := l.leased - l.used; unused > 0 {
+		l.limiter.stats.allowed.Add(-unused)
+		l.limiter.refund(unused)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lease", func() {
+
+	It("should refund the unused tokens", func() {
+		rl := New(10, time.Minute)
+		l, err := rl.Lease(8)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(l.Len()).To(Equal(8))
+		Expect(rl.Remaining()).To(Equal(2))
+
+		l.Use(2)
+		l.Use(1)
+		Expect(l.Used()).To(Equal(3))
+
+		l.Close()
+		l.Close()
+		Expect(rl.Remaining()).To(Equal(7))
+		Expect(rl.Stats().Allowed).To(Equal(uint64(3)))
+	})
+
+	It("should not use more than leased", func() {
+		rl := New(10, time.Minute)
+		l, err := rl.Lease(4)
+		Expect(err).NotTo(HaveOccurred())
+		l.Use(10)
+		Expect(l.Used()).To(Equal(4))
+
+		l.Close()
+		Expect(rl.Remaining()).To(Equal(6))
+	})
+
+	It("should fail when the tokens are not available", func() {
+		rl := New(5, time.Minute)
+		_, err := rl.Lease(6)
+		Expect(errors.Is(err, ErrLimited)).To(BeTrue())
+		Expect(rl.Remaining()).To(Equal(5))
+
+		l, err := rl.Lease(0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(l.Len()).To(BeZero())
+		l.Close()
+	})
+
+})
@@ -0,0 +1,75 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"crypto/tls"
+	"net"
+	"net/netip"
+	"sync/atomic"
+)
+
+// HandshakeListener limits the rate of new connections accepted by a listener, keyed by
+// source IP. Connections over the limit are closed as soon as they are accepted, before
+// any TLS handshake work is done, which blunts handshake floods without affecting the
+// requests of connections already established. HandshakeListener instances are
+// thread-safe.
+type HandshakeListener struct {
+	net.Listener
+	limiter  *Keyed
+	rejected atomic.Uint64 // number of connections closed over the limit
+}
+
+// LimitHandshakes wraps the listener so that each source IP may only open new
+// connections at the rate of the keyed limiter. It is meant to be wrapped by
+// tls.NewListener, so that every accepted connection costs exactly one handshake.
+func LimitHandshakes(inner net.Listener, handshakes *Keyed) *HandshakeListener {
+	return &HandshakeListener{
+		Listener: inner,
+		limiter:  handshakes,
+	}
+}
+
+// ListenTLS wraps the listener so that new TLS handshakes are limited per source IP by
+// the keyed limiter before being served with the TLS configuration.
+func ListenTLS(inner net.Listener, config *tls.Config, handshakes *Keyed) net.Listener {
+	return tls.NewListener(LimitHandshakes(inner, handshakes), config)
+}
+
+// Accept waits for and returns the next connection within the limit of its source IP,
+// closing every connection over the limit in the meantime.
+func (l *HandshakeListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if !l.limiter.Limit(sourceIP(conn.RemoteAddr())) {
+			return conn, nil
+		}
+
+		l.rejected.Add(1)
+		conn.Close()
+	}
+}
+
+// Rejected returns the number of connections which were closed over the limit.
+func (l *HandshakeListener) Rejected() uint64 {
+	return l.rejected.Load()
+}
+
+// sourceIP returns the key of a remote address, its IP without the port
+func sourceIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+
+	if ap, err := netip.ParseAddrPort(addr.String()); err == nil {
+		return ap.Addr().Unmap().String()
+	}
+	return addr.String()
+}
@@ -0,0 +1,77 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HandshakeListener", func() {
+
+	It("should close the connections over the limit", func() {
+		inner, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer inner.Close()
+
+		l := LimitHandshakes(inner, NewKeyed(1, time.Hour))
+		accepted := make(chan net.Conn, 2)
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				accepted <- conn
+			}
+		}()
+
+		first, err := net.Dial("tcp", inner.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		defer first.Close()
+		Eventually(accepted).Should(Receive())
+
+		second, err := net.Dial("tcp", inner.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		defer second.Close()
+
+		second.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = second.Read(make([]byte, 1))
+		Expect(err).To(HaveOccurred())
+		Expect(l.Rejected()).To(Equal(uint64(1)))
+		Expect(accepted).NotTo(Receive())
+	})
+
+	It("should limit the TLS handshakes per source IP", func() {
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		l := LimitHandshakes(srv.Listener, NewKeyed(1, time.Hour))
+		srv.Listener = l
+		srv.StartTLS()
+		defer srv.Close()
+
+		client := srv.Client()
+		client.Transport.(*http.Transport).DisableKeepAlives = true
+
+		resp, err := client.Get(srv.URL)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+
+		_, err = client.Get(srv.URL)
+		Expect(err).To(HaveOccurred())
+		Expect(l.Rejected()).To(Equal(uint64(1)))
+	})
+
+	It("should key by the IP without the port", func() {
+		Expect(sourceIP(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234})).To(Equal("10.0.0.1"))
+		Expect(sourceIP(&net.TCPAddr{IP: net.ParseIP("::1"), Port: 1234})).To(Equal("::1"))
+		Expect(sourceIP(nil)).To(Equal(""))
+	})
+})
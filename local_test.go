@@ -0,0 +1,103 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Local", func() {
+
+	It("should replenish from the limiter in batches", func() {
+		rl := New(10, time.Hour)
+		local := rl.Local(4)
+		Expect(local.Limit()).To(BeFalse())
+		Expect(local.Len()).To(Equal(3))
+		Expect(rl.Remaining()).To(Equal(6))
+
+		for i := 0; i < 3; i++ {
+			Expect(local.Limit()).To(BeFalse())
+		}
+		Expect(rl.Remaining()).To(Equal(6))
+		Expect(local.Limit()).To(BeFalse())
+		Expect(rl.Remaining()).To(Equal(2))
+	})
+
+	It("should never exceed the rate across workers", func() {
+		rl := New(1000, time.Hour)
+		var admitted atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				local := rl.Local(16)
+				defer local.Close()
+				for j := 0; j < 500; j++ {
+					if !local.Limit() {
+						admitted.Add(1)
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+		Expect(admitted.Load()).To(Equal(int32(1000)))
+		Expect(rl.Stats().Allowed).To(Equal(uint64(1000)))
+	})
+
+	It("should return the tokens left on close", func() {
+		rl := New(10, time.Hour)
+		local := rl.Local(8)
+		Expect(local.Limit()).To(BeFalse())
+		Expect(rl.Remaining()).To(Equal(2))
+
+		Expect(local.Close()).To(Succeed())
+		Expect(local.Len()).To(BeZero())
+		Expect(rl.Remaining()).To(Equal(9))
+		Expect(rl.Stats().Allowed).To(Equal(uint64(1)))
+	})
+
+	It("should deny once the limiter is exhausted", func() {
+		rl := New(2, time.Hour)
+		local := rl.Local(8)
+		Expect(local.Limit()).To(BeFalse())
+		Expect(local.Limit()).To(BeFalse())
+		Expect(local.Limit()).To(BeTrue())
+		Expect(rl.Stats().Denied).To(Equal(uint64(1)))
+	})
+
+	It("should limit every call once closed", func() {
+		rl := New(10, time.Hour)
+		local := rl.Local(4)
+		Expect(local.Limit()).To(BeFalse())
+		Expect(local.Close()).To(Succeed())
+		Expect(local.Limit()).To(BeTrue())
+		Expect(rl.Remaining()).To(Equal(9))
+		Expect(rl.Stats().Allowed).To(Equal(uint64(1)))
+	})
+})
+
+// --------------------------------------------------------------------
+
+func BenchmarkLocal(b *testing.B) {
+	rl := New(1000000000, time.Second)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		local := rl.Local(64)
+		defer local.Close()
+		for pb.Next() {
+			local.Limit()
+		}
+	})
+}
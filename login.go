@@ -0,0 +1,199 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+// Login protects login endpoints against brute-force and credential stuffing attacks.
+// Attempts are limited both per account and per client address, failed attempts cost
+// more tokens than successful ones, and keys which keep failing are locked out for a
+// duration which doubles on every lockout. Login instances are thread-safe.
+type Login struct {
+	lock      sync.Mutex
+	accounts  *Keyed
+	addrs     *Keyed
+	cost      int                  // tokens charged for a failed attempt
+	threshold int                  // consecutive failures which trigger a lockout
+	lockout   time.Duration        // duration of the first lockout
+	max       time.Duration        // maximum duration of a lockout
+	failures  map[string]*failures // failures per account and per address
+	pruneAt   int                  // number of tracked keys beyond which stale ones are pruned
+	idle      [2]time.Duration     // idle time after which accounts and addresses expire
+	attempts  int                  // attempts since the idle keys were last expired
+	expireAt  int                  // number of attempts beyond which idle keys are expired
+}
+
+// failures represents the recent failed attempts of an account or an address
+type failures struct {
+	count int       // consecutive failures since the last lockout
+	level int       // number of lockouts so far, which doubles the next one
+	last  time.Time // time of the last failure
+}
+
+// LoginOption represents an option which can be applied to a login limiter on creation.
+type LoginOption func(*Login)
+
+// WithFailureCost sets the number of tokens charged for a failed attempt, while
+// successful ones cost a single token. By default, a failure costs 5 tokens.
+func WithFailureCost(n int) LoginOption {
+	return func(l *Login) {
+		l.cost = n
+	}
+}
+
+// WithLockout locks an account or an address out once it failed a number of consecutive
+// attempts, for the first duration, doubled on every next lockout up to the maximum. By
+// default, keys are locked out for a minute after 5 failures, up to a day.
+func WithLockout(failures int, first, max time.Duration) LoginOption {
+	return func(l *Login) {
+		l.threshold = failures
+		l.lockout = first
+		l.max = max
+	}
+}
+
+// NewLogin creates a new login limiter allowing the attempts per account and per client
+// address according to the policies.
+func NewLogin(account, addr Policy, options ...LoginOption) *Login {
+	l := &Login{
+		accounts:  NewKeyed(account.Rate, account.Per),
+		addrs:     NewKeyed(addr.Rate, addr.Per),
+		cost:      5,
+		threshold: 5,
+		lockout:   time.Minute,
+		max:       24 * time.Hour,
+		failures:  make(map[string]*failures),
+		pruneAt:   1024,
+		expireAt:  1024,
+	}
+
+	for _, opt := range options {
+		opt(l)
+	}
+
+	// A key idle for long enough to repay what its failures cost is as good as a new one
+	cost := time.Duration(1)
+	if l.cost > 1 {
+		cost = time.Duration(l.cost)
+	}
+	l.idle = [2]time.Duration{account.Per * cost, addr.Per * cost}
+	return l
+}
+
+// Allow returns nil if a login attempt for the account from the address may proceed, in
+// which case a token is consumed for both. Otherwise it fails with ErrBanned if either
+// is locked out, or with a *LimitedError if either exceeded its rate.
+func (l *Login) Allow(account, addr string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.attempts++; l.attempts > l.expireAt {
+		l.expire()
+	}
+
+	byAccount, accountBanned := l.accounts.lookup(account)
+	byAddr, addrBanned := l.addrs.lookup(addr)
+	switch {
+	case accountBanned || addrBanned:
+		return ErrBanned
+	case byAccount.PeekN(1):
+		byAccount.record(true, 1)
+		return byAccount.limited(ErrLimited, 0)
+	case byAddr.PeekN(1):
+		byAddr.record(true, 1)
+		return byAddr.limited(ErrLimited, 0)
+	}
+
+	byAccount.Limit()
+	byAddr.Limit()
+	return nil
+}
+
+// Fail reports that the attempt for the account from the address failed, charging the
+// extra cost of a failure and locking out the keys which failed too many times.
+func (l *Login) Fail(account, addr string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	l.fail(l.accounts, "account:"+account, account, now)
+	l.fail(l.addrs, "addr:"+addr, addr, now)
+
+	if len(l.failures) > l.pruneAt {
+		l.prune(now)
+	}
+}
+
+// Succeed reports that the attempt for the account succeeded, clearing the failures of
+// the account. The failures of the address are kept so that a single valid account does
+// not clear the record of an address trying many others.
+func (l *Login) Succeed(account string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.failures, "account:"+account)
+}
+
+// fail records a failure of the key, must be called while holding the lock
+func (l *Login) fail(k *Keyed, id, key string, now time.Time) {
+	if l.cost > 1 {
+		k.Get(key).ReportCost(1, l.cost)
+	}
+
+	f, ok := l.failures[id]
+	if !ok || now.Sub(f.last) > l.max {
+		f = new(failures) // forget the failures which are long gone
+		l.failures[id] = f
+	}
+
+	f.last = now
+	if f.count++; l.threshold > 0 && f.count >= l.threshold {
+		k.Ban(key, l.duration(f.level))
+		f.count = 0
+		f.level++
+	}
+}
+
+// duration returns the duration of a lockout after the specified number of lockouts
+func (l *Login) duration(level int) time.Duration {
+	d := l.lockout
+	for i := 0; i < level && d < l.max; i++ {
+		d *= 2
+	}
+	if d > l.max {
+		d = l.max
+	}
+	return d
+}
+
+// prune forgets the failures which are long gone, must be called while holding the lock
+func (l *Login) prune(now time.Time) {
+	for id, f := range l.failures {
+		if now.Sub(f.last) > l.max {
+			delete(l.failures, id)
+		}
+	}
+
+	if l.pruneAt = 2 * len(l.failures); l.pruneAt < 1024 {
+		l.pruneAt = 1024
+	}
+	l.expire()
+}
+
+// expire removes the accounts and addresses which were idle for long enough to be fully
+// refilled, so that keys sent by an attacker do not accumulate, must be called while
+// holding the lock. Bans are kept, as they are tracked apart from the limiters.
+func (l *Login) expire() {
+	l.accounts.Expire(l.idle[0])
+	l.addrs.Expire(l.idle[1])
+
+	l.attempts = 0
+	if l.expireAt = 2 * (l.accounts.Len() + l.addrs.Len()); l.expireAt < 1024 {
+		l.expireAt = 1024
+	}
+}
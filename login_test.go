@@ -0,0 +1,111 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Login", func() {
+
+	It("should limit per account and per address", func() {
+		l := NewLogin(Policy{Rate: 2, Per: time.Hour}, Policy{Rate: 3, Per: time.Hour})
+		Expect(l.Allow("alice", "10.0.0.1")).To(Succeed())
+		Expect(l.Allow("alice", "10.0.0.2")).To(Succeed())
+		Expect(errors.Is(l.Allow("alice", "10.0.0.3"), ErrLimited)).To(BeTrue())
+
+		// Credential stuffing from a single address
+		Expect(l.Allow("bob", "10.0.0.1")).To(Succeed())
+		Expect(l.Allow("carol", "10.0.0.1")).To(Succeed())
+		Expect(errors.Is(l.Allow("dave", "10.0.0.1"), ErrLimited)).To(BeTrue())
+		Expect(l.Allow("dave", "10.0.0.4")).To(Succeed())
+	})
+
+	It("should not consume a token when the other key is limited", func() {
+		l := NewLogin(Policy{Rate: 1, Per: time.Hour}, Policy{Rate: 5, Per: time.Hour})
+		Expect(l.Allow("alice", "10.0.0.1")).To(Succeed())
+		Expect(l.Allow("alice", "10.0.0.2")).NotTo(Succeed())
+		Expect(l.addrs.Get("10.0.0.2").Remaining()).To(Equal(5))
+	})
+
+	It("should charge more for failures", func() {
+		l := NewLogin(Policy{Rate: 10, Per: time.Hour}, Policy{Rate: 10, Per: time.Hour}, WithFailureCost(4))
+		Expect(l.Allow("alice", "10.0.0.1")).To(Succeed())
+		l.Fail("alice", "10.0.0.1")
+		Expect(l.accounts.Get("alice").Remaining()).To(Equal(6))
+		Expect(l.addrs.Get("10.0.0.1").Remaining()).To(Equal(6))
+	})
+
+	It("should escalate the lockouts", func() {
+		l := NewLogin(Policy{Rate: 100, Per: time.Hour}, Policy{Rate: 100, Per: time.Hour},
+			WithFailureCost(1), WithLockout(2, time.Minute, 3*time.Minute))
+
+		l.Fail("alice", "10.0.0.1")
+		Expect(l.Allow("alice", "10.0.0.1")).To(Succeed())
+		l.Fail("alice", "10.0.0.1")
+		Expect(l.Allow("alice", "10.0.0.1")).To(Equal(ErrBanned))
+		Expect(l.accounts.Banned()["alice"]).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+
+		l.accounts.Unban("alice")
+		l.addrs.Unban("10.0.0.1")
+		l.Fail("alice", "10.0.0.1")
+		l.Fail("alice", "10.0.0.1")
+		Expect(l.accounts.Banned()["alice"]).To(BeTemporally("~", time.Now().Add(2*time.Minute), time.Second))
+
+		l.accounts.Unban("alice")
+		l.Fail("alice", "10.0.0.1")
+		l.Fail("alice", "10.0.0.1")
+		Expect(l.accounts.Banned()["alice"]).To(BeTemporally("~", time.Now().Add(3*time.Minute), time.Second))
+	})
+
+	It("should clear the failures of an account on success", func() {
+		l := NewLogin(Policy{Rate: 100, Per: time.Hour}, Policy{Rate: 100, Per: time.Hour},
+			WithLockout(2, time.Minute, time.Hour))
+
+		l.Fail("alice", "10.0.0.1")
+		l.Succeed("alice")
+		l.Fail("alice", "10.0.0.2")
+		Expect(l.Allow("alice", "10.0.0.3")).To(Succeed())
+		Expect(l.failures).To(HaveKey("addr:10.0.0.1"))
+	})
+
+	It("should expire idle accounts and addresses", func() {
+		l := NewLogin(Policy{Rate: 100, Per: time.Millisecond}, Policy{Rate: 100, Per: time.Millisecond})
+		for i := 0; i < 1000; i++ {
+			Expect(l.Allow("user"+strconv.Itoa(i), "10.0.0."+strconv.Itoa(i))).To(Succeed())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		for i := 0; i < 1000; i++ {
+			Expect(l.Allow("bot"+strconv.Itoa(i), "10.0.1."+strconv.Itoa(i))).To(Succeed())
+		}
+
+		Expect(l.accounts.Len()).To(BeNumerically("<", 2000))
+		Expect(l.addrs.Len()).To(BeNumerically("<", 2000))
+	})
+
+	It("should prune stale failures", func() {
+		l := NewLogin(Policy{Rate: 100, Per: time.Hour}, Policy{Rate: 100, Per: time.Hour})
+		for i := 0; i < 1100; i++ {
+			l.Fail(strconv.Itoa(i), "10.0.0.1")
+		}
+		Expect(l.failures).To(HaveLen(1101))
+		Expect(l.pruneAt).To(BeNumerically(">", 1100))
+
+		for _, f := range l.failures {
+			f.last = f.last.Add(-48 * time.Hour)
+		}
+		l.Fail("alice", "10.0.0.2")
+		l.prune(time.Now())
+		Expect(l.failures).To(HaveLen(2))
+		Expect(l.pruneAt).To(Equal(1024))
+	})
+})
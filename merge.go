@@ -0,0 +1,53 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// Merge moves the allowance left in the other limiter into this one, such as when a
+// draining instance hands its unused budget over to a survivor or when shards are
+// consolidated. Only as many tokens as fit under the maximum are moved and the rest stays
+// with the other limiter. The debt owed by the other limiter and its statistics are moved
+// as well. It returns the number of tokens moved.
+func (rl *Limiter) Merge(other *Limiter) float64 {
+	_, max := rl.limits(rl.now())
+	room := float64(max-minUint64(rl.refill(), max)) / float64(rl.unit)
+
+	// Take what fits from the other limiter, converting between their units
+	other.refill()
+	var moved float64
+	for {
+		current := other.allowance.Load()
+		take := minUint64(current, uint64(room*float64(other.unit)))
+		if other.allowance.CompareAndSwap(current, current-take) {
+			moved = float64(take) / float64(other.unit)
+			break
+		}
+	}
+
+	rl.allowance.Add(uint64(moved * float64(rl.unit)))
+	rl.clamp(max)
+
+	if debt := other.debt.Swap(0); debt > 0 {
+		rl.charge(uint64(float64(debt) / float64(other.unit) * float64(rl.unit)))
+	}
+
+	rl.stats.add(other.stats.snapshot(true))
+	rl.wake()
+	return moved
+}
+
+// add adds the counted decisions to the counters
+func (c *counters) add(s Stats) {
+	c.allowed.Add(s.Allowed)
+	c.denied.Add(s.Denied)
+	c.undone.Add(s.Undone)
+	c.refunded.Add(s.Refunded)
+	c.shadowed.Add(s.Shadowed)
+	c.success.Add(s.Success)
+	c.failure.Add(s.Failure)
+	for i := range s.Waits {
+		c.waits[i].Add(s.Waits[i])
+	}
+}
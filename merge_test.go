@@ -0,0 +1,65 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Merge", func() {
+
+	It("should move the remaining allowance", func() {
+		clock := NewManualClock(time.Now())
+		survivor := New(10, time.Minute, WithClock(clock))
+		draining := New(10, time.Minute, WithClock(clock))
+		survivor.LimitN(8)
+		draining.LimitN(3)
+
+		Expect(survivor.Merge(draining)).To(BeNumerically("~", 7, 0.001))
+		Expect(survivor.Remaining()).To(Equal(9))
+		Expect(draining.Remaining()).To(BeZero())
+	})
+
+	It("should keep what does not fit", func() {
+		clock := NewManualClock(time.Now())
+		survivor := New(10, time.Minute, WithClock(clock))
+		draining := New(10, time.Minute, WithClock(clock))
+		survivor.LimitN(4)
+
+		Expect(survivor.Merge(draining)).To(BeNumerically("~", 4, 0.001))
+		Expect(survivor.Remaining()).To(Equal(10))
+		Expect(draining.Remaining()).To(Equal(6))
+	})
+
+	It("should convert between units", func() {
+		clock := NewManualClock(time.Now())
+		survivor := New(100, time.Second, WithClock(clock))
+		draining := New(10, time.Minute, WithClock(clock))
+		survivor.LimitN(100)
+
+		Expect(survivor.Merge(draining)).To(BeNumerically("~", 10, 0.001))
+		Expect(survivor.Remaining()).To(Equal(10))
+	})
+
+	It("should move the debt and the statistics", func() {
+		clock := NewManualClock(time.Now())
+		survivor := New(10, time.Minute, WithClock(clock))
+		draining := New(1, time.Minute, WithClock(clock))
+		draining.Limit()
+		draining.Limit()
+		draining.ReportCost(1, 3)
+
+		survivor.Merge(draining)
+		Expect(draining.Debt()).To(BeZero())
+		Expect(survivor.Remaining()).To(Equal(8))
+		Expect(survivor.Stats().Allowed).To(BeEquivalentTo(3))
+		Expect(survivor.Stats().Denied).To(BeEquivalentTo(1))
+		Expect(draining.Stats().Allowed).To(BeZero())
+	})
+})
@@ -0,0 +1,112 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metric represents the state of a single limiter as a flat set of labeled values, which
+// can be scraped or pushed to a metrics system without depending on its client library.
+type Metric struct {
+	Name      string    // Name of the limiter, if any
+	Key       string    // Key of the limiter within a keyed limiter, if any
+	Remaining int       // Number of calls currently allowed
+	Limit     int       // Number of calls allowed per period
+	ResetsAt  time.Time // Time at which the allowance is fully restored
+}
+
+// Metric returns the current state of the limiter.
+func (rl *Limiter) Metric() Metric {
+	now := time.Unix(0, int64(rl.now()))
+	return Metric{
+		Name:      rl.ext().name,
+		Remaining: rl.Remaining(),
+		Limit:     int(rl.load().rate),
+		ResetsAt:  now.Add(rl.UntilFull()),
+	}
+}
+
+// Metrics returns the current state of every key of the keyed limiter, sorted by key.
+func (k *Keyed) Metrics() []Metric {
+	var metrics []Metric
+	k.each(func(key string, rl *Limiter) {
+		m := rl.Metric()
+		m.Key = key
+		metrics = append(metrics, m)
+	})
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Key < metrics[j].Key
+	})
+	return metrics
+}
+
+// WriteMetrics writes the metrics in the OpenMetrics text format, as the rate_remaining,
+// rate_limit and rate_resets_at gauges labeled by name and key, the latter in unix
+// seconds. Empty labels are omitted.
+func WriteMetrics(w io.Writer, metrics []Metric) error {
+	families := []struct {
+		name, help string
+		value      func(m *Metric) string
+	}{
+		{"rate_remaining", "Number of calls currently allowed.", func(m *Metric) string {
+			return strconv.Itoa(m.Remaining)
+		}},
+		{"rate_limit", "Number of calls allowed per period.", func(m *Metric) string {
+			return strconv.Itoa(m.Limit)
+		}},
+		{"rate_resets_at", "Unix time at which the allowance is fully restored.", func(m *Metric) string {
+			return strconv.FormatFloat(float64(m.ResetsAt.UnixNano())/1e9, 'f', -1, 64)
+		}},
+	}
+
+	out := bufio.NewWriter(w)
+	for _, f := range families {
+		out.WriteString("# TYPE " + f.name + " gauge\n")
+		out.WriteString("# HELP " + f.name + " " + f.help + "\n")
+		for i := range metrics {
+			out.WriteString(f.name)
+			writeLabels(out, &metrics[i])
+			out.WriteString(" " + f.value(&metrics[i]) + "\n")
+		}
+	}
+
+	out.WriteString("# EOF\n")
+	return out.Flush()
+}
+
+// writeLabels writes the non-empty labels of the metric
+func writeLabels(out *bufio.Writer, m *Metric) {
+	if m.Name == "" && m.Key == "" {
+		return
+	}
+
+	out.WriteByte('{')
+	if m.Name != "" {
+		out.WriteString(`name="` + escapeLabel(m.Name) + `"`)
+	}
+	if m.Key != "" {
+		if m.Name != "" {
+			out.WriteByte(',')
+		}
+		out.WriteString(`key="` + escapeLabel(m.Key) + `"`)
+	}
+	out.WriteByte('}')
+}
+
+// labelEscaper escapes the characters which are not allowed in a label value
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes the label value
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
@@ -0,0 +1,65 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+
+	It("should return the state of a limiter", func() {
+		clock := NewManualClock(time.Unix(100, 0))
+		rl := New(10, time.Second, WithClock(clock), WithName("api"))
+		rl.LimitN(5)
+
+		Expect(rl.Metric()).To(Equal(Metric{
+			Name:      "api",
+			Remaining: 5,
+			Limit:     10,
+			ResetsAt:  time.Unix(100, int64(500*time.Millisecond)),
+		}))
+	})
+
+	It("should return the state of every key", func() {
+		k := NewKeyed(10, time.Second)
+		k.Limit("bob")
+		k.Limit("alice")
+
+		metrics := k.Metrics()
+		Expect(metrics).To(HaveLen(2))
+		Expect(metrics[0].Key).To(Equal("alice"))
+		Expect(metrics[1].Key).To(Equal("bob"))
+		Expect(metrics[1].Remaining).To(Equal(9))
+	})
+
+	It("should write the OpenMetrics text format", func() {
+		var out bytes.Buffer
+		Expect(WriteMetrics(&out, []Metric{
+			{Name: "api", Key: `a"b`, Remaining: 5, Limit: 10, ResetsAt: time.Unix(100, int64(500*time.Millisecond))},
+			{Remaining: 1, Limit: 2, ResetsAt: time.Unix(100, 0)},
+		})).To(Succeed())
+
+		Expect(out.String()).To(Equal(`# TYPE rate_remaining gauge
+# HELP rate_remaining Number of calls currently allowed.
+rate_remaining{name="api",key="a\"b"} 5
+rate_remaining 1
+# TYPE rate_limit gauge
+# HELP rate_limit Number of calls allowed per period.
+rate_limit{name="api",key="a\"b"} 10
+rate_limit 2
+# TYPE rate_resets_at gauge
+# HELP rate_resets_at Unix time at which the allowance is fully restored.
+rate_resets_at{name="api",key="a\"b"} 100.5
+rate_resets_at 100
+# EOF
+`))
+	})
+})
@@ -0,0 +1,276 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyFunc returns the key of an HTTP request, such as the address of the client.
+type KeyFunc func(*http.Request) string
+
+// Middleware limits HTTP requests per key, optionally with different limits per route,
+// and responds to the requests denied with WriteLimited(). Middleware instances are
+// thread-safe.
+type Middleware struct {
+	fallback  *Keyed  // limiter of the requests which match no route
+	routes    []route // limiters of specific routes, most specific first
+	key       KeyFunc // extracts the key of a request
+	options   []KeyedOption
+	v4, v6    int              // optional prefix lengths by which IP keys are grouped
+	classify  Classifier       // optional classifier of the requests
+	tiers     map[string]*tier // limiters of the tiers of the classifier
+	exemplars *Exemplars       // optional reservoir of denied requests
+	inflight  *Resource        // optional limit of the requests in flight
+	idle      time.Duration    // optional idle time after which the keys expire
+	done      chan struct{}    // closed when the middleware is closed
+	stop      sync.WaitGroup
+	once      sync.Once
+}
+
+// route represents a limit applied to the requests matching a pattern
+type route struct {
+	method   string   // method of the route, empty if any
+	segments []string // segments of the path, where "*" is a wildcard
+	rate     int
+	per      time.Duration
+	limiter  *Keyed
+}
+
+// MiddlewareOption represents an option which can be applied to a middleware on creation.
+type MiddlewareOption func(*Middleware)
+
+// WithKeyFunc sets the function extracting the key of a request. By default, requests
+// are limited per client IP address as found in the remote address of the connection,
+// see RemoteAddr().
+func WithKeyFunc(fn KeyFunc) MiddlewareOption {
+	return func(m *Middleware) {
+		m.key = fn
+	}
+}
+
+// WithKeyedOptions sets the options applied to every keyed limiter of the middleware.
+func WithKeyedOptions(options ...KeyedOption) MiddlewareOption {
+	return func(m *Middleware) {
+		m.options = append(m.options, options...)
+	}
+}
+
+// WithRoute applies a specific limit to the requests matching the pattern, such as
+// "POST /login" or "/api/*". The method is optional and a "*" segment matches any single
+// segment, or any non-empty remainder of the path when it is the last one. Routes with a
+// method take precedence over the ones without, otherwise routes are matched in order.
+func WithRoute(pattern string, rate int, per time.Duration) MiddlewareOption {
+	return func(m *Middleware) {
+		r := parseRoute(pattern)
+		r.rate, r.per = rate, per
+		m.routes = append(m.routes, r)
+	}
+}
+
+// WithMaxInFlight limits the number of requests served concurrently across all of the
+// clients. Requests beyond it are denied with a 503 response, see WriteOverloaded(),
+// while requests exceeding the rate of their client are denied with a 429 response.
+// Requests denied for either reason consume neither a slot nor a token.
+func WithMaxInFlight(n int) MiddlewareOption {
+	return func(m *Middleware) {
+		m.inflight = NewResource(n)
+	}
+}
+
+// WithIdleExpiry removes the keys which were not used for the idle duration, so that the
+// memory of the middleware stays bounded when the clients churn, such as rotating IP
+// addresses. It starts a background goroutine sweeping the keys at every half of the
+// idle duration, which is stopped by Close().
+func WithIdleExpiry(idle time.Duration) MiddlewareOption {
+	return func(m *Middleware) {
+		m.idle = idle
+	}
+}
+
+// NewMiddleware creates a new HTTP middleware which allows the rate per key to the
+// requests which match no specific route.
+func NewMiddleware(rate int, per time.Duration, options ...MiddlewareOption) *Middleware {
+	m := &Middleware{
+		key:   RemoteAddr(),
+		tiers: make(map[string]*tier),
+	}
+	for _, opt := range options {
+		opt(m)
+	}
+
+	// Routes with a method are more specific, keep them first
+	routes := make([]route, 0, len(m.routes))
+	for _, r := range m.routes {
+		if r.method != "" {
+			routes = append(routes, r)
+		}
+	}
+	for _, r := range m.routes {
+		if r.method == "" {
+			routes = append(routes, r)
+		}
+	}
+
+	for i := range routes {
+		routes[i].limiter = NewKeyed(routes[i].rate, routes[i].per, m.options...)
+	}
+	for _, t := range m.tiers {
+		t.limiter = NewKeyed(t.rate, t.per, m.options...)
+	}
+
+	if key := m.key; m.v4 > 0 || m.v6 > 0 {
+		m.key = func(r *http.Request) string {
+			return GroupIP(key(r), m.v4, m.v6)
+		}
+	}
+
+	m.routes = routes
+	m.fallback = NewKeyed(rate, per, m.options...)
+	m.done = make(chan struct{})
+	if m.idle > 0 {
+		m.stop.Add(1)
+		go m.sweep()
+	}
+	return m
+}
+
+// Expire removes the keys of every limiter of the middleware which were not used for at
+// least the idle duration and returns how many were removed.
+func (m *Middleware) Expire(idle time.Duration) int {
+	n := m.fallback.Expire(idle)
+	for _, r := range m.routes {
+		n += r.limiter.Expire(idle)
+	}
+	for _, t := range m.tiers {
+		n += t.limiter.Expire(idle)
+	}
+	return n
+}
+
+// Close stops expiring the idle keys, if WithIdleExpiry() was set.
+func (m *Middleware) Close() error {
+	m.once.Do(func() {
+		close(m.done)
+		m.stop.Wait()
+	})
+	return nil
+}
+
+// sweep expires the idle keys until the middleware is closed
+func (m *Middleware) sweep() {
+	defer m.stop.Done()
+	every := m.idle / 2
+	if every < time.Millisecond {
+		every = time.Millisecond
+	}
+
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.Expire(m.idle)
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// Handler wraps the HTTP handler, serving only the requests which are not limited.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter, key, skip := m.resolve(r)
+		if skip {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Take a slot first, so a request denied by the rate only has to release it
+		if m.inflight != nil {
+			if m.inflight.Limit() {
+				WriteOverloaded(w, m.inflight)
+				return
+			}
+			defer m.inflight.Release(1)
+		}
+
+		if limiter.Limit(key) {
+			if m.exemplars != nil {
+				m.exemplars.Record(key, traceID(r))
+			}
+			WriteLimited(w, limiter.Get(key))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// resolve returns the keyed limiter and the key applicable to the request, or whether
+// the request should not be limited at all
+func (m *Middleware) resolve(r *http.Request) (*Keyed, string, bool) {
+	if m.classify == nil {
+		return m.limiter(r), m.key(r), false
+	}
+
+	key, name, skip := m.classify(r)
+	if skip {
+		return nil, "", true
+	}
+	if key == "" {
+		key = m.key(r)
+	}
+	if t, ok := m.tiers[name]; ok {
+		return t.limiter, key, false
+	}
+	return m.limiter(r), key, false
+}
+
+// limiter returns the keyed limiter applicable to the request
+func (m *Middleware) limiter(r *http.Request) *Keyed {
+	for i := range m.routes {
+		if m.routes[i].match(r.Method, r.URL.Path) {
+			return m.routes[i].limiter
+		}
+	}
+	return m.fallback
+}
+
+// parseRoute parses a route pattern such as "POST /login"
+func parseRoute(pattern string) route {
+	var r route
+	if fields := strings.Fields(pattern); len(fields) == 2 {
+		r.method = strings.ToUpper(fields[0])
+		pattern = fields[1]
+	}
+
+	r.segments = strings.Split(strings.Trim(pattern, "/"), "/")
+	return r
+}
+
+// match returns whether the route matches the method and the path
+func (r *route) match(method, path string) bool {
+	if r.method != "" && r.method != method {
+		return false
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, s := range r.segments {
+		switch {
+		case i >= len(segments):
+			return false
+		case s == "*" && i == len(r.segments)-1:
+			return true
+		case s != "*" && s != segments[i]:
+			return false
+		}
+	}
+	return len(segments) == len(r.segments)
+}
@@ -0,0 +1,140 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Middleware", func() {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(h http.Handler, method, path, addr string) int {
+		r := httptest.NewRequest(method, path, nil)
+		r.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	It("should limit requests per client", func() {
+		h := NewMiddleware(2, time.Minute).Handler(ok)
+		Expect(serve(h, "GET", "/", "1.2.3.4:1000")).To(Equal(http.StatusOK))
+		Expect(serve(h, "GET", "/a", "1.2.3.4:2000")).To(Equal(http.StatusOK))
+		Expect(serve(h, "GET", "/b", "1.2.3.4:3000")).To(Equal(http.StatusTooManyRequests))
+		Expect(serve(h, "GET", "/", "5.6.7.8:1000")).To(Equal(http.StatusOK))
+	})
+
+	It("should apply limits per route", func() {
+		h := NewMiddleware(100, time.Second,
+			WithRoute("/api/*", 2, time.Minute),
+			WithRoute("POST /login", 1, time.Minute),
+			WithRoute("/users/*/posts", 1, time.Minute),
+		).Handler(ok)
+
+		addr := "1.2.3.4:1000"
+		Expect(serve(h, "POST", "/login", addr)).To(Equal(http.StatusOK))
+		Expect(serve(h, "POST", "/login", addr)).To(Equal(http.StatusTooManyRequests))
+		Expect(serve(h, "GET", "/login", addr)).To(Equal(http.StatusOK))
+
+		Expect(serve(h, "GET", "/api/a", addr)).To(Equal(http.StatusOK))
+		Expect(serve(h, "GET", "/api/b/c", addr)).To(Equal(http.StatusOK))
+		Expect(serve(h, "GET", "/api/d", addr)).To(Equal(http.StatusTooManyRequests))
+
+		Expect(serve(h, "GET", "/users/1/posts", addr)).To(Equal(http.StatusOK))
+		Expect(serve(h, "GET", "/users/2/posts", addr)).To(Equal(http.StatusTooManyRequests))
+		Expect(serve(h, "GET", "/users/2/posts/3", addr)).To(Equal(http.StatusOK))
+	})
+
+	It("should match route patterns", func() {
+		match := func(pattern, method, path string) bool {
+			r := parseRoute(pattern)
+			return r.match(method, path)
+		}
+
+		Expect(match("/", "GET", "/")).To(BeTrue())
+		Expect(match("/a", "GET", "/a/")).To(BeTrue())
+		Expect(match("/a", "GET", "/a/b")).To(BeFalse())
+		Expect(match("/a/*", "GET", "/a")).To(BeFalse())
+		Expect(match("/*", "GET", "/anything/at/all")).To(BeTrue())
+		Expect(match("/a/*/c", "GET", "/a/b/c")).To(BeTrue())
+		Expect(match("/a/*/c", "GET", "/a/b")).To(BeFalse())
+		Expect(match("post /a", "POST", "/a")).To(BeTrue())
+		Expect(match("POST /a", "GET", "/a")).To(BeFalse())
+	})
+
+	It("should expire the idle keys", func() {
+		m := NewMiddleware(1, time.Hour,
+			WithRoute("/login", 1, time.Hour),
+			WithIdleExpiry(10*time.Millisecond),
+		)
+		defer m.Close()
+
+		h := m.Handler(ok)
+		Expect(serve(h, "GET", "/", "10.0.0.1:1234")).To(Equal(http.StatusOK))
+		Expect(serve(h, "GET", "/login", "10.0.0.2:1234")).To(Equal(http.StatusOK))
+		Expect(m.fallback.Len() + m.routes[0].limiter.Len()).To(Equal(2))
+
+		Eventually(func() int {
+			return m.fallback.Len() + m.routes[0].limiter.Len()
+		}, "200ms", "5ms").Should(BeZero())
+		Expect(m.Close()).To(Succeed())
+		Expect(m.Close()).To(Succeed())
+	})
+
+	It("should expire the keys on demand", func() {
+		m := NewMiddleware(1, time.Hour)
+		Expect(serve(m.Handler(ok), "GET", "/", "10.0.0.1:1234")).To(Equal(http.StatusOK))
+		Expect(m.Expire(time.Hour)).To(BeZero())
+		Expect(m.Expire(0)).To(Equal(1))
+	})
+
+	It("should use a custom key", func() {
+		h := NewMiddleware(1, time.Minute, WithKeyFunc(func(r *http.Request) string {
+			return r.Header.Get("X-User")
+		})).Handler(ok)
+
+		Expect(serve(h, "GET", "/", "1.2.3.4:1000")).To(Equal(http.StatusOK))
+		Expect(serve(h, "GET", "/", "5.6.7.8:1000")).To(Equal(http.StatusTooManyRequests))
+	})
+
+	It("should limit the requests in flight", func() {
+		release := make(chan struct{})
+		entered := make(chan struct{})
+		slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entered <- struct{}{}
+			<-release
+		})
+
+		m := NewMiddleware(2, time.Minute, WithMaxInFlight(1))
+		h := m.Handler(slow)
+		done := make(chan int)
+		go func() { done <- serve(h, "GET", "/", "1.2.3.4:1000") }()
+		<-entered
+
+		Expect(serve(h, "GET", "/", "5.6.7.8:1000")).To(Equal(http.StatusServiceUnavailable))
+		close(release)
+		Expect(<-done).To(Equal(http.StatusOK))
+
+		// The overloaded request did not consume a token of its client
+		go func() { <-entered }()
+		Expect(serve(h, "GET", "/", "5.6.7.8:1000")).To(Equal(http.StatusOK))
+		go func() { <-entered }()
+		Expect(serve(h, "GET", "/", "5.6.7.8:1000")).To(Equal(http.StatusOK))
+		Expect(serve(h, "GET", "/", "5.6.7.8:1000")).To(Equal(http.StatusTooManyRequests))
+
+		// Neither did the rate limited request keep its slot
+		Expect(m.inflight.InUse()).To(BeZero())
+	})
+
+})
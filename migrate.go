@@ -0,0 +1,47 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// State represents the remaining allowance of a key handed over to another instance
+// during a live migration. It marshals to JSON so it can travel with the tenant.
+type State struct {
+	Tokens float64 `json:"tokens"` // remaining allowance, in tokens
+	Time   int64   `json:"time"`   // time at which the state was exported, in unix ns
+}
+
+// Export removes the key and returns its remaining allowance, so that it moves with the
+// tenant to another instance instead of being credited on both. The allowance is taken
+// out of the limiter, so callers still holding it are denied rather than double-credited.
+// It returns false if the key is not tracked.
+func (k *Keyed) Export(key string) (State, bool) {
+	s := k.shard(key)
+	s.Lock()
+	rl, ok := s.limiters[key]
+	delete(s.limiters, key)
+	delete(s.heat, key)
+	s.Unlock()
+
+	if !ok {
+		return State{}, false
+	}
+	return State(rl.drain()), true
+}
+
+// Import replaces the allowance of the key with a state exported by another instance,
+// so that a migrated tenant is neither limited twice nor credited twice. The allowance
+// accrued since the export is added on the next refill, up to the burst of the key.
+func (k *Keyed) Import(key string, state State) {
+	k.Get(key).restore(saved(state))
+}
+
+// drain takes the whole allowance out of the limiter and returns it
+func (rl *Limiter) drain() saved {
+	rl.refill()
+	return saved{
+		Tokens: float64(rl.allowance.Swap(0)) / float64(rl.unit),
+		Time:   int64(rl.now()),
+	}
+}
@@ -0,0 +1,71 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Export", func() {
+	var clock *ManualClock
+	var source, target *Keyed
+
+	BeforeEach(func() {
+		clock = NewManualClock(time.Unix(100, 0))
+		source = NewKeyed(10, time.Second, WithLimiterOptions(WithClock(clock)))
+		target = NewKeyed(10, time.Second, WithLimiterOptions(WithClock(clock)))
+	})
+
+	It("should move the remaining allowance", func() {
+		Expect(source.Get("alice").LimitN(7)).To(BeFalse())
+		state, ok := source.Export("alice")
+		Expect(ok).To(BeTrue())
+		Expect(state.Tokens).To(BeNumerically("~", 3, 0.001))
+		Expect(source.Len()).To(BeZero())
+
+		target.Import("alice", state)
+		Expect(target.Get("alice").LimitN(3)).To(BeFalse())
+		Expect(target.Limit("alice")).To(BeTrue())
+	})
+
+	It("should deny callers still holding the exported limiter", func() {
+		rl := source.Get("alice")
+		_, ok := source.Export("alice")
+		Expect(ok).To(BeTrue())
+		Expect(rl.Limit()).To(BeTrue())
+	})
+
+	It("should credit the allowance accrued during the migration", func() {
+		Expect(source.Get("alice").LimitN(10)).To(BeFalse())
+		state, _ := source.Export("alice")
+
+		clock.Advance(500 * time.Millisecond)
+		target.Import("alice", state)
+		Expect(target.Get("alice").LimitN(5)).To(BeFalse())
+		Expect(target.Limit("alice")).To(BeTrue())
+	})
+
+	It("should survive a round trip through JSON", func() {
+		Expect(source.Get("alice").LimitN(4)).To(BeFalse())
+		state, _ := source.Export("alice")
+
+		data, err := json.Marshal(state)
+		Expect(err).NotTo(HaveOccurred())
+
+		var decoded State
+		Expect(json.Unmarshal(data, &decoded)).To(Succeed())
+		Expect(decoded).To(Equal(state))
+	})
+
+	It("should not export an unknown key", func() {
+		_, ok := source.Export("bob")
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,116 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync/atomic"
+)
+
+// Request represents the demand of a single operation on a multi-dimensional limiter.
+type Request struct {
+	Requests int // Number of requests, typically one
+	Bytes    int // Number of bytes transferred
+	Streams  int // Number of concurrent streams opened
+}
+
+// Multi is a limiter which enforces several dimensions at once: a rate of requests, a
+// rate of bytes and a maximum number of concurrent streams. Multi instances are
+// thread-safe.
+type Multi struct {
+	requests   *Limiter     // rate of requests, optional
+	bytes      *Limiter     // rate of bytes, optional
+	streams    atomic.Int64 // number of streams currently open
+	maxStreams int64        // maximum number of streams, zero if unbounded
+}
+
+// NewMulti creates a new multi-dimensional limiter. Any of the limiters can be nil and
+// a zero maximum number of streams means concurrency is not limited.
+func NewMulti(requests, bytes *Limiter, maxStreams int) *Multi {
+	return &Multi{
+		requests:   requests,
+		bytes:      bytes,
+		maxStreams: int64(maxStreams),
+	}
+}
+
+// Acquire returns true if the request was admitted on every dimension. If any of the
+// dimensions is exceeded, nothing is consumed from the others. Streams acquired must
+// be returned with Release() once they are closed.
+func (m *Multi) Acquire(req Request) bool {
+	if !m.acquireStreams(int64(req.Streams)) {
+		return false
+	}
+
+	if !take(m.requests, req.Requests) {
+		m.Release(req.Streams)
+		return false
+	}
+
+	if !take(m.bytes, req.Bytes) {
+		m.Release(req.Streams)
+		untake(m.requests, req.Requests)
+		return false
+	}
+
+	return true
+}
+
+// Release closes the specified number of streams.
+func (m *Multi) Release(streams int) {
+	if streams > 0 {
+		m.streams.Add(-int64(streams))
+	}
+}
+
+// Streams returns the number of streams currently open.
+func (m *Multi) Streams() int {
+	return int(m.streams.Load())
+}
+
+// acquireStreams opens n streams, unless the maximum would be exceeded
+func (m *Multi) acquireStreams(n int64) bool {
+	if n <= 0 {
+		return true
+	}
+
+	for {
+		current := m.streams.Load()
+		if m.maxStreams > 0 && n > m.maxStreams-current {
+			return false
+		}
+
+		if m.streams.CompareAndSwap(current, current+n) {
+			return true
+		}
+	}
+}
+
+// AcquireAll takes a token from every limiter and returns true, or takes none if any of
+// them is exceeded. It is typically used when an operation is subject to several
+// independent budgets, such as a tenant, an endpoint and a global one.
+func AcquireAll(limiters ...*Limiter) bool {
+	for i, rl := range limiters {
+		if !take(rl, 1) {
+			for _, taken := range limiters[:i] {
+				untake(taken, 1)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// take consumes n tokens from an optional limiter and returns whether it succeeded
+func take(rl *Limiter, n int) bool {
+	return n <= 0 || rl == nil || !rl.LimitN(n)
+}
+
+// untake returns n tokens consumed by take(), as if they were never consumed
+func untake(rl *Limiter, n int) {
+	if n > 0 && rl != nil {
+		rl.relinquish(uint64(n))
+	}
+}
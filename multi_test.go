@@ -0,0 +1,74 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"math"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Multi", func() {
+
+	It("should enforce every dimension", func() {
+		m := NewMulti(New(3, time.Minute), New(1000, time.Minute), 2)
+		Expect(m.Acquire(Request{Requests: 1, Bytes: 400, Streams: 1})).To(BeTrue())
+		Expect(m.Acquire(Request{Requests: 1, Bytes: 400, Streams: 1})).To(BeTrue())
+		Expect(m.Acquire(Request{Requests: 1, Bytes: 100, Streams: 1})).To(BeFalse())
+		Expect(m.Streams()).To(Equal(2))
+
+		m.Release(1)
+		Expect(m.Acquire(Request{Requests: 1, Bytes: 400, Streams: 1})).To(BeFalse())
+		Expect(m.Acquire(Request{Requests: 1, Bytes: 200, Streams: 1})).To(BeTrue())
+		Expect(m.Acquire(Request{Requests: 1})).To(BeFalse())
+	})
+
+	It("should refund on partial failure", func() {
+		requests, bytes := New(2, time.Minute), New(100, time.Minute)
+		m := NewMulti(requests, bytes, 0)
+		Expect(m.Acquire(Request{Requests: 1, Bytes: 500})).To(BeFalse())
+		Expect(requests.Stats()).To(Equal(Stats{}))
+		Expect(bytes.Stats()).To(Equal(Stats{Denied: 1}))
+
+		Expect(m.Acquire(Request{Requests: 2, Bytes: 100})).To(BeTrue())
+		Expect(m.Streams()).To(BeZero())
+	})
+
+	It("should allow optional dimensions", func() {
+		m := NewMulti(nil, New(10, time.Minute), 0)
+		for i := 0; i < 10; i++ {
+			Expect(m.Acquire(Request{Requests: 1, Bytes: 1, Streams: 1})).To(BeTrue())
+		}
+		Expect(m.Acquire(Request{Bytes: 1})).To(BeFalse())
+		Expect(m.Streams()).To(Equal(10))
+	})
+
+	It("should not overflow with huge demands", func() {
+		requests := New(10, time.Hour)
+		m := NewMulti(requests, New(1000, time.Hour), 2)
+		Expect(m.Acquire(Request{Requests: 1, Streams: 1})).To(BeTrue())
+		Expect(m.Acquire(Request{Requests: 1, Streams: math.MaxInt})).To(BeFalse())
+		Expect(m.Acquire(Request{Requests: 1, Bytes: math.MaxInt32})).To(BeFalse())
+		Expect(m.Streams()).To(Equal(1))
+		Expect(requests.Remaining()).To(Equal(9))
+	})
+
+	It("should acquire from every limiter or none", func() {
+		tenant := New(5, time.Minute)
+		endpoint := New(1, time.Minute)
+		global := New(5, time.Minute)
+
+		Expect(AcquireAll(tenant, endpoint, nil, global)).To(BeTrue())
+		Expect(AcquireAll(tenant, endpoint, global)).To(BeFalse())
+		Expect(tenant.Remaining()).To(Equal(4))
+		Expect(global.Remaining()).To(Equal(4))
+		Expect(tenant.Stats().Allowed).To(Equal(uint64(1)))
+		Expect(AcquireAll()).To(BeTrue())
+	})
+
+})
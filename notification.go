@@ -0,0 +1,184 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+// totalKey is the store key of the global counter of a notification quota, recipient
+// keys are prefixed so they can never collide with it
+const totalKey = "total"
+
+// QuotaStore represents a storage of counters per key and window, which can be backed
+// by a database so that quotas survive restarts and are shared across instances.
+type QuotaStore interface {
+	// Incr atomically adds n, which may be negative or zero, to the counter of the key
+	// for the window starting at the specified time and returns the new count.
+	Incr(key string, window time.Time, n int) (int, error)
+}
+
+// MemoryStore is an in-memory QuotaStore which only keeps the counters of the most
+// recent window. MemoryStore instances are thread-safe.
+type MemoryStore struct {
+	lock   sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+// NewMemoryStore creates a new in-memory quota store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counts: make(map[string]int),
+	}
+}
+
+// Incr adds n to the counter of the key for the window and returns the new count.
+func (s *MemoryStore) Incr(key string, window time.Time, n int) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch {
+	case window.After(s.window):
+		s.window = window
+		s.counts = make(map[string]int)
+	case window.Before(s.window):
+		return 0, nil // counters of past windows are gone
+	}
+
+	count := s.counts[key] + n
+	if count <= 0 {
+		delete(s.counts, key)
+		return 0, nil
+	}
+
+	s.counts[key] = count
+	return count, nil
+}
+
+// ----------------------------------- Notifications -----------------------------------
+
+// NotificationQuota limits outbound notifications such as emails or text messages, both
+// per recipient and in total per window, for example 3 emails per user and 50000 in total
+// per day. Windows are aligned on multiples of their duration since the unix epoch.
+type NotificationQuota struct {
+	perRecipient int        // notifications allowed per recipient and window
+	total        int        // notifications allowed in total per window
+	window       int64      // window duration, in ns
+	store        QuotaStore // storage of the counters
+	clock        Clock      // optional source of time
+}
+
+// NotificationOption represents an option which can be applied to a notification quota
+// on creation.
+type NotificationOption func(*NotificationQuota)
+
+// WithNotificationStore sets the store of the counters, an in-memory store by default.
+func WithNotificationStore(store QuotaStore) NotificationOption {
+	return func(q *NotificationQuota) {
+		q.store = store
+	}
+}
+
+// WithNotificationClock sets the clock used by the quota instead of the system time.
+func WithNotificationClock(clock Clock) NotificationOption {
+	return func(q *NotificationQuota) {
+		q.clock = clock
+	}
+}
+
+// NewNotificationQuota creates a new quota allowing perRecipient notifications to each
+// recipient and total notifications altogether per window. A total of zero or less
+// disables the global quota.
+func NewNotificationQuota(perRecipient, total int, window time.Duration, options ...NotificationOption) *NotificationQuota {
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
+
+	q := &NotificationQuota{
+		perRecipient: perRecipient,
+		total:        total,
+		window:       int64(window),
+	}
+
+	for _, opt := range options {
+		opt(q)
+	}
+
+	if q.store == nil {
+		q.store = NewMemoryStore()
+	}
+	return q
+}
+
+// Limit returns true if a notification to the recipient would exceed either quota,
+// otherwise it counts the notification against both.
+func (q *NotificationQuota) Limit(recipient string) (bool, error) {
+	window, recipient := q.start(), "to:"+recipient
+	count, err := q.store.Incr(recipient, window, 1)
+	if err != nil {
+		return true, err
+	}
+
+	if count > q.perRecipient {
+		_, err := q.store.Incr(recipient, window, -1)
+		return true, err
+	}
+
+	if q.total > 0 {
+		total, err := q.store.Incr(totalKey, window, 1)
+		if err != nil || total > q.total {
+			q.store.Incr(recipient, window, -1)
+			if err == nil {
+				_, err = q.store.Incr(totalKey, window, -1)
+			}
+			return true, err
+		}
+	}
+
+	return false, nil
+}
+
+// Remaining returns the number of notifications which can still be sent to the recipient
+// in the current window, taking the global quota into account.
+func (q *NotificationQuota) Remaining(recipient string) (int, error) {
+	window := q.start()
+	count, err := q.store.Incr("to:"+recipient, window, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	remaining := q.perRecipient - count
+	if q.total > 0 {
+		total, err := q.store.Incr(totalKey, window, 0)
+		if err != nil {
+			return 0, err
+		}
+		if left := q.total - total; left < remaining {
+			remaining = left
+		}
+	}
+
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, nil
+}
+
+// Reset returns the time at which the current window ends.
+func (q *NotificationQuota) Reset() time.Time {
+	return q.start().Add(time.Duration(q.window))
+}
+
+// start returns the start of the current window
+func (q *NotificationQuota) start() time.Time {
+	now := time.Now().UnixNano()
+	if q.clock != nil {
+		now = q.clock.Now()
+	}
+	return time.Unix(0, now/q.window*q.window)
+}
@@ -0,0 +1,91 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// failingStore is a quota store which is unavailable
+type failingStore struct{}
+
+func (failingStore) Incr(string, time.Time, int) (int, error) {
+	return 0, errors.New("unavailable")
+}
+
+var _ = Describe("NotificationQuota", func() {
+
+	It("should limit per recipient", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		q := NewNotificationQuota(2, 0, 24*time.Hour, WithNotificationClock(clock))
+		for _, expect := range []bool{false, false, true} {
+			limited, err := q.Limit("alice")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(limited).To(Equal(expect))
+		}
+
+		limited, err := q.Limit("bob")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(limited).To(BeFalse())
+
+		clock.Advance(24 * time.Hour)
+		Expect(q.Remaining("alice")).To(Equal(2))
+		Expect(q.Reset()).To(Equal(time.Unix(0, 0).Add(48 * time.Hour)))
+	})
+
+	It("should limit in total", func() {
+		q := NewNotificationQuota(3, 2, time.Hour)
+		limited, _ := q.Limit("alice")
+		Expect(limited).To(BeFalse())
+		limited, _ = q.Limit("bob")
+		Expect(limited).To(BeFalse())
+
+		limited, err := q.Limit("carol")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(limited).To(BeTrue())
+		Expect(q.Remaining("carol")).To(BeZero())
+		Expect(q.Remaining("alice")).To(BeZero())
+	})
+
+	It("should not charge denied notifications", func() {
+		store := NewMemoryStore()
+		q := NewNotificationQuota(1, 10, time.Hour, WithNotificationStore(store))
+		q.Limit("alice")
+		q.Limit("alice")
+		q.Limit("alice")
+
+		total, err := store.Incr("total", q.Reset().Add(-time.Hour), 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(total).To(Equal(1))
+		Expect(q.Remaining("bob")).To(Equal(1))
+	})
+
+	It("should fail closed when the store is unavailable", func() {
+		q := NewNotificationQuota(1, 10, time.Hour, WithNotificationStore(failingStore{}))
+		limited, err := q.Limit("alice")
+		Expect(err).To(HaveOccurred())
+		Expect(limited).To(BeTrue())
+
+		_, err = q.Remaining("alice")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("MemoryStore", func() {
+
+	It("should only keep the latest window", func() {
+		s := NewMemoryStore()
+		day1, day2 := time.Unix(0, 0), time.Unix(86400, 0)
+		Expect(s.Incr("a", day1, 2)).To(Equal(2))
+		Expect(s.Incr("a", day2, 1)).To(Equal(1))
+		Expect(s.Incr("a", day1, 1)).To(Equal(0))
+		Expect(s.Incr("a", day2, -5)).To(Equal(0))
+	})
+})
@@ -0,0 +1,174 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Pacer emits a fixed number of permits spread evenly over a duration, which is handy for
+// drip migrations or warmup scripts where the rate would be awkward to compute. Pacer
+// instances are thread-safe.
+type Pacer struct {
+	lock    sync.Mutex
+	start   time.Time // time at which the pacer was created
+	over    time.Duration
+	total   int // number of permits to emit
+	emitted int // number of permits emitted so far
+}
+
+// Pace creates a new pacer emitting n permits evenly over the duration, starting now.
+// The first permit is emitted right away and the last one a single interval before the
+// end of the duration.
+func Pace(n int, over time.Duration) *Pacer {
+	if n < 0 {
+		n = 0
+	}
+
+	return &Pacer{
+		start: time.Now(),
+		over:  over,
+		total: n,
+	}
+}
+
+// Wait blocks until the next permit is due or the context is done, and fails with
+// ErrExhausted once every permit was emitted. Callers running behind schedule receive
+// their permits right away so the pacer still completes on time.
+func (p *Pacer) Wait(ctx context.Context) error {
+	p.lock.Lock()
+	if p.emitted >= p.total {
+		p.lock.Unlock()
+		return ErrExhausted
+	}
+
+	due := p.due(p.emitted)
+	p.emitted++
+	p.lock.Unlock()
+
+	if err := sleepUntil(ctx, due); err != nil {
+		p.lock.Lock()
+		p.emitted-- // give the permit back
+		p.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Remaining returns the number of permits which are yet to be emitted.
+func (p *Pacer) Remaining() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.total - p.emitted
+}
+
+// due returns the time at which the i-th permit is due
+func (p *Pacer) due(i int) time.Time {
+	return p.start.Add(time.Duration(float64(p.over) * float64(i) / float64(p.total)))
+}
+
+// sleepUntil blocks until the specified time or until the context is done
+func sleepUntil(ctx context.Context, t time.Time) error {
+	delay := time.Until(t)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ------------------------------------ Deadline ------------------------------------
+
+// DeadlinePacer paces a batch of work so that it completes by a deadline, continuously
+// adjusting its rate to the work left and the time remaining. It speeds up when the work
+// falls behind schedule and slows down when it gets ahead. DeadlinePacer instances are
+// thread-safe.
+type DeadlinePacer struct {
+	lock      sync.Mutex
+	deadline  time.Time // target completion time
+	last      time.Time // time of the last permit emitted
+	total     int       // number of items of work
+	completed int       // number of items reported as done
+}
+
+// PaceUntil creates a new pacer for n items of work which should complete by the deadline.
+func PaceUntil(n int, deadline time.Time) *DeadlinePacer {
+	return &DeadlinePacer{
+		deadline: deadline,
+		last:     time.Now(),
+		total:    n,
+	}
+}
+
+// Wait blocks until the next item may be started or the context is done, and fails with
+// ErrExhausted once every item was reported as done. Each permit is spaced from the
+// previous one by the time left divided by the number of items left.
+func (p *DeadlinePacer) Wait(ctx context.Context) error {
+	p.lock.Lock()
+	left := p.total - p.completed
+	if left <= 0 {
+		p.lock.Unlock()
+		return ErrExhausted
+	}
+
+	now := time.Now()
+	due := p.last
+	if interval := p.deadline.Sub(p.last) / time.Duration(left); interval > 0 {
+		due = due.Add(interval)
+	}
+	if due.Before(now) {
+		due = now
+	}
+
+	p.last = due
+	p.lock.Unlock()
+
+	return sleepUntil(ctx, due)
+}
+
+// Done reports n items of work as completed.
+func (p *DeadlinePacer) Done(n int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.completed += n; p.completed > p.total {
+		p.completed = p.total
+	}
+}
+
+// Remaining returns the number of items which are not completed yet.
+func (p *DeadlinePacer) Remaining() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.total - p.completed
+}
+
+// Rate returns the number of items per second required to complete by the deadline, or
+// zero once the work is complete. Past the deadline, the rate is unbounded.
+func (p *DeadlinePacer) Rate() float64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	left := p.total - p.completed
+	until := time.Until(p.deadline)
+	switch {
+	case left <= 0:
+		return 0
+	case until <= 0:
+		return math.Inf(1)
+	default:
+		return float64(left) / until.Seconds()
+	}
+}
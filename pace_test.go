@@ -0,0 +1,98 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pacer", func() {
+
+	It("should spread permits evenly", func() {
+		p := Pace(4, 40*time.Millisecond)
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			Expect(p.Wait(context.Background())).To(Succeed())
+			Expect(time.Since(start)).To(BeNumerically(">=", time.Duration(i)*10*time.Millisecond))
+		}
+
+		Expect(p.Remaining()).To(BeZero())
+		Expect(p.Wait(context.Background())).To(Equal(ErrExhausted))
+	})
+
+	It("should catch up when running behind", func() {
+		p := Pace(3, 15*time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			Expect(p.Wait(context.Background())).To(Succeed())
+		}
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Millisecond))
+	})
+
+	It("should give the permit back when the context is done", func() {
+		p := Pace(2, time.Hour)
+		Expect(p.Wait(context.Background())).To(Succeed())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		Expect(p.Wait(ctx)).To(Equal(context.DeadlineExceeded))
+		Expect(p.Remaining()).To(Equal(1))
+	})
+
+	It("should handle no permits", func() {
+		Expect(Pace(-1, time.Second).Wait(context.Background())).To(Equal(ErrExhausted))
+	})
+})
+
+var _ = Describe("DeadlinePacer", func() {
+
+	It("should complete by the deadline", func() {
+		start := time.Now()
+		p := PaceUntil(4, start.Add(40*time.Millisecond))
+		for i := 0; i < 4; i++ {
+			Expect(p.Wait(context.Background())).To(Succeed())
+			p.Done(1)
+		}
+
+		elapsed := time.Since(start)
+		Expect(elapsed).To(BeNumerically(">=", 35*time.Millisecond))
+		Expect(elapsed).To(BeNumerically("<", 80*time.Millisecond))
+		Expect(p.Wait(context.Background())).To(Equal(ErrExhausted))
+		Expect(p.Rate()).To(BeZero())
+	})
+
+	It("should speed up when behind", func() {
+		p := PaceUntil(10, time.Now().Add(100*time.Millisecond))
+		Expect(p.Rate()).To(BeNumerically("~", 100, 5))
+
+		time.Sleep(50 * time.Millisecond)
+		Expect(p.Rate()).To(BeNumerically(">", 150))
+	})
+
+	It("should slow down when ahead", func() {
+		p := PaceUntil(10, time.Now().Add(time.Second))
+		before := p.Rate()
+		p.Done(5)
+		Expect(p.Rate()).To(BeNumerically("<", before/1.5))
+		Expect(p.Remaining()).To(Equal(5))
+	})
+
+	It("should be unbounded past the deadline", func() {
+		p := PaceUntil(2, time.Now().Add(-time.Second))
+		Expect(p.Wait(context.Background())).To(Succeed())
+		Expect(p.Wait(context.Background())).To(Succeed())
+		Expect(p.Rate()).To(BeNumerically(">", 1e9))
+
+		p.Done(5)
+		Expect(p.Remaining()).To(BeZero())
+	})
+})
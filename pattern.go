@@ -0,0 +1,87 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"strings"
+	"time"
+)
+
+// pattern represents the limit of the keys matching a wildcard pattern
+type pattern struct {
+	parts   []string // literal parts of the pattern, around its wildcards
+	literal int      // number of literal characters, the more the more specific
+	rate    int
+	per     time.Duration
+	options []Option // options of the limiters created, after those of every key
+}
+
+// WithPattern sets the limit of the keys matching a pattern, in which a '*' matches any
+// sequence of characters, so that "internal-*" grants a higher limit to a whole fleet of
+// service identities without an override per key. When several patterns match, the most
+// specific one wins, being the one with the most literal characters, then the first one
+// declared. Limiters set explicitly with Set() always take precedence over patterns.
+func WithPattern(glob string, rate int, per time.Duration, options ...Option) KeyedOption {
+	parts := strings.Split(glob, "*")
+	return func(k *Keyed) {
+		k.patterns = append(k.patterns, pattern{
+			parts:   parts,
+			literal: len(glob) - len(parts) + 1,
+			rate:    rate,
+			per:     per,
+			options: options,
+		})
+	}
+}
+
+// spawn creates a new limiter for the key, with the limit of the most specific pattern
+// matching it, if any
+func (k *Keyed) spawn(key string) *Limiter {
+	rate, per, options := k.template(key)
+	return New(rate, per, options...)
+}
+
+// template returns the rate, the period and the options of the limiters created for the
+// key, those of the most specific pattern matching it, if any
+func (k *Keyed) template(key string) (int, time.Duration, []Option) {
+	var best *pattern
+	for i := range k.patterns {
+		if p := &k.patterns[i]; (best == nil || p.literal > best.literal) && p.match(key) {
+			best = p
+		}
+	}
+
+	if best == nil {
+		return k.rate, k.per, k.options
+	}
+
+	options := make([]Option, 0, len(k.options)+len(best.options))
+	options = append(append(options, k.options...), best.options...)
+	return best.rate, best.per, options
+}
+
+// match returns whether the key matches the pattern
+func (p *pattern) match(key string) bool {
+	if len(p.parts) == 1 {
+		return key == p.parts[0]
+	}
+
+	// The first and last parts are anchored, the others are found in order in between
+	first, last := p.parts[0], p.parts[len(p.parts)-1]
+	if len(key) < len(first)+len(last) || !strings.HasPrefix(key, first) || !strings.HasSuffix(key, last) {
+		return false
+	}
+
+	key = key[len(first) : len(key)-len(last)]
+	for _, part := range p.parts[1 : len(p.parts)-1] {
+		i := strings.Index(key, part)
+		if i < 0 {
+			return false
+		}
+		key = key[i+len(part):]
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WithPattern", func() {
+
+	It("should limit the keys matching a prefix", func() {
+		k := NewKeyed(1, time.Hour, WithPattern("internal-*", 100, time.Hour))
+		Expect(k.Get("internal-billing").Remaining()).To(Equal(100))
+		Expect(k.Get("external-billing").Remaining()).To(Equal(1))
+		Expect(k.Get("internal").Remaining()).To(Equal(1))
+	})
+
+	It("should prefer the most specific pattern", func() {
+		k := NewKeyed(1, time.Hour,
+			WithPattern("internal-*", 100, time.Hour),
+			WithPattern("internal-batch-*", 10, time.Hour),
+			WithPattern("*", 5, time.Hour),
+		)
+
+		Expect(k.Get("internal-batch-reports").Remaining()).To(Equal(10))
+		Expect(k.Get("internal-api").Remaining()).To(Equal(100))
+		Expect(k.Get("alice").Remaining()).To(Equal(5))
+	})
+
+	It("should prefer the first of equally specific patterns", func() {
+		k := NewKeyed(1, time.Hour,
+			WithPattern("svc-*", 20, time.Hour),
+			WithPattern("*-eu", 30, time.Hour),
+		)
+
+		Expect(k.Get("svc-eu").Remaining()).To(Equal(20))
+		Expect(k.Get("web-eu").Remaining()).To(Equal(30))
+	})
+
+	It("should let explicit limiters take precedence", func() {
+		k := NewKeyed(1, time.Hour, WithPattern("internal-*", 100, time.Hour))
+		k.Set("internal-legacy", New(3, time.Hour))
+		Expect(k.Get("internal-legacy").Remaining()).To(Equal(3))
+	})
+
+	It("should apply the options of the pattern", func() {
+		k := NewKeyed(10, time.Hour,
+			WithLimiterOptions(WithName("tenant")),
+			WithPattern("internal-*", 100, time.Hour, WithBurst(20)),
+		)
+
+		Expect(k.Get("internal-api").Remaining()).To(Equal(20))
+		Expect(k.Get("alice").Remaining()).To(Equal(10))
+	})
+
+	It("should match globs", func() {
+		for glob, keys := range map[string][]bool{
+			"a*b*c": {true, true, false, false},
+			"*":     {true, true, true, true},
+			"abc":   {true, false, false, false},
+			"a*":    {true, true, false, true},
+		} {
+			k := &Keyed{}
+			WithPattern(glob, 1, time.Hour)(k)
+			p := &k.patterns[0]
+
+			for i, key := range []string{"abc", "axbyc", "bca", "ab"} {
+				Expect(p.match(key)).To(Equal(keys[i]), glob+" / "+key)
+			}
+		}
+	})
+})
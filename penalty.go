@@ -0,0 +1,66 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// penalty represents a cooldown applied after repeated violations
+type penalty struct {
+	lock      sync.Mutex
+	threshold int           // number of denials which trigger the penalty
+	window    uint64        // window within which denials are counted, in ns
+	cooldown  uint64        // duration of the penalty, in ns
+	factor    float64       // factor applied to the rate during the penalty
+	start     uint64        // start of the current counting window, in unix ns
+	count     int           // denials within the current counting window
+	until     atomic.Uint64 // end of the current penalty, in unix ns
+}
+
+// WithPenalty punishes clients which keep hammering the limiter: once k calls have been
+// denied or reported as failed within the window, the rate is multiplied by the factor
+// for the duration of the cooldown. A zero factor blocks every call until the cooldown
+// expires.
+func WithPenalty(k int, window, cooldown time.Duration, factor float64) Option {
+	return func(rl *Limiter) {
+		rl.extend().penalty = &penalty{
+			threshold: k,
+			window:    uint64(window),
+			cooldown:  uint64(cooldown),
+			factor:    factor,
+		}
+	}
+}
+
+// Penalized returns whether the limiter is currently applying a penalty.
+func (rl *Limiter) Penalized() bool {
+	p := rl.ext().penalty
+	return p != nil && p.active(rl.now())
+}
+
+// active returns whether the penalty applies at the specified time
+func (p *penalty) active(now uint64) bool {
+	return now < p.until.Load()
+}
+
+// violate records a denial or a failure at the specified time
+func (p *penalty) violate(now uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if now-p.start >= p.window {
+		p.start = now
+		p.count = 0
+	}
+
+	if p.count++; p.count >= p.threshold {
+		p.until.Store(now + p.cooldown)
+		p.count = 0
+	}
+}
@@ -0,0 +1,64 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Penalty", func() {
+
+	It("should block after repeated violations", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(10, time.Second, WithClock(clock), WithPenalty(3, time.Second, time.Minute, 0))
+		for i := 0; i < 10; i++ {
+			Expect(rl.Limit()).To(BeFalse())
+		}
+
+		Expect(rl.Limit()).To(BeTrue())
+		Expect(rl.Limit()).To(BeTrue())
+		Expect(rl.Penalized()).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+		Expect(rl.Penalized()).To(BeTrue())
+
+		clock.Advance(30 * time.Second)
+		Expect(rl.Limit()).To(BeTrue())
+
+		clock.Advance(30 * time.Second)
+		Expect(rl.Penalized()).To(BeFalse())
+		Expect(rl.Limit()).To(BeFalse())
+	})
+
+	It("should only count violations within the window", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(1, time.Hour, WithClock(clock), WithPenalty(2, time.Second, time.Minute, 0))
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+
+		clock.Advance(2 * time.Second)
+		Expect(rl.Limit()).To(BeTrue())
+		Expect(rl.Penalized()).To(BeFalse())
+	})
+
+	It("should reduce the rate during the cooldown", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(10, time.Second, WithClock(clock), WithPenalty(1, time.Second, time.Minute, 0.5))
+		for i := 0; i < 10; i++ {
+			Expect(rl.Limit()).To(BeFalse())
+		}
+
+		Expect(rl.Limit()).To(BeTrue())
+		Expect(rl.Penalized()).To(BeTrue())
+
+		clock.Advance(200 * time.Millisecond)
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+	})
+
+})
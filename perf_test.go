@@ -0,0 +1,106 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+//go:build perf
+
+package rate
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// raceEnabled is set when the tests run with the race detector, which skews the timings
+var raceEnabled bool
+
+// budget represents the maximum cost of a hot path, as a multiple of the cost of the
+// reference benchmark so that the budgets hold on machines of any speed
+type budget struct {
+	bench  func(b *testing.B)
+	ratio  float64 // maximum ns/op over the ns/op of the reference
+	allocs int64   // maximum allocations per op
+}
+
+// hotPaths are the budgets of the hot paths, about three times the ratios observed when
+// they were set, so that a regression fails long before the cost of a decision triples.
+// Being sensitive to the load of the machine, they are only checked with "-tags perf",
+// the same benchmarks can otherwise be compared with BenchmarkReference.
+var hotPaths = map[string]budget{
+	"Limit": {bench: BenchmarkLimit, ratio: 4},
+	"Keyed": {bench: BenchmarkKeyed, ratio: 6},
+	"Wait":  {bench: BenchmarkWait, ratio: 9},
+}
+
+var _ = Describe("Hot path", func() {
+
+	It("should stay within its budget", func() {
+		if testing.Short() || raceEnabled {
+			Skip("timings are skewed in short mode or with the race detector")
+		}
+
+		restore := withBenchtime("50ms")
+		defer restore()
+
+		reference := median(BenchmarkReference)
+		for name, b := range hotPaths {
+			result := measure(b.bench)
+			ratio := result.ns / reference
+			fmt.Fprintf(GinkgoWriter, "%s: %.1f ns/op, %.2fx the reference\n", name, result.ns, ratio)
+			Expect(ratio).To(BeNumerically("<=", b.ratio), name)
+			Expect(result.allocs).To(BeNumerically("<=", b.allocs), name)
+		}
+	})
+})
+
+// result represents the median cost of a benchmark
+type result struct {
+	ns     float64
+	allocs int64
+}
+
+// measure runs the benchmark several times and returns its median cost, the same way
+// benchstat summarizes multiple runs
+func measure(bench func(b *testing.B)) result {
+	var allocs int64
+	ns := median(func(b *testing.B) {
+		b.ReportAllocs()
+		bench(b)
+	})
+
+	r := testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		bench(b)
+	})
+	if allocs = r.AllocsPerOp(); allocs < 0 {
+		allocs = 0
+	}
+	return result{ns: ns, allocs: allocs}
+}
+
+// median returns the median ns/op of five runs of the benchmark
+func median(bench func(b *testing.B)) float64 {
+	runs := make([]float64, 5)
+	for i := range runs {
+		r := testing.Benchmark(bench)
+		runs[i] = float64(r.T.Nanoseconds()) / float64(r.N)
+	}
+
+	sort.Float64s(runs)
+	return runs[len(runs)/2]
+}
+
+// withBenchtime sets the duration of each benchmark run and returns a function which
+// restores the previous one
+func withBenchtime(d string) func() {
+	f := flag.Lookup("test.benchtime")
+	previous := f.Value.String()
+	f.Value.Set(d)
+	return func() { f.Value.Set(previous) }
+}
@@ -0,0 +1,219 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// saved represents the persisted state of a single limiter
+type saved struct {
+	Tokens float64 `json:"tokens"` // allowance, in tokens
+	Time   int64   `json:"time"`   // time at which the allowance was saved, in unix ns
+}
+
+// snapshot returns the current state of the limiter
+func (rl *Limiter) snapshot() saved {
+	return saved{
+		Tokens: float64(rl.refill()) / float64(rl.unit),
+		Time:   int64(rl.now()),
+	}
+}
+
+// restore replaces the state of the limiter with a saved one, the allowance accrued since
+// it was saved is added on the next refill
+func (rl *Limiter) restore(s saved) {
+	now := rl.now()
+	_, max := rl.limits(now)
+
+	allowance := uint64(0)
+	if s.Tokens > 0 {
+		allowance = uint64(s.Tokens * float64(rl.unit))
+	}
+	if allowance > max {
+		allowance = max
+	}
+
+	at := now
+	if s.Time > 0 && uint64(s.Time) < now {
+		at = uint64(s.Time)
+	}
+
+	rl.allowance.Store(allowance)
+	rl.lastCheck.Store(at)
+}
+
+// Persister periodically saves the state of a limiter, or of every key of a keyed
+// limiter, to a local file so single-node daemons keep enforcing their quotas across
+// restarts. Persister instances are thread-safe.
+type Persister struct {
+	lock sync.Mutex
+	path string
+	save func() map[string]saved
+	err  error         // last error of a periodic save
+	done chan struct{} // closed when the persister is closed
+	stop sync.WaitGroup
+	once sync.Once
+}
+
+// Persist restores the state of the limiter from the file, if it exists, and then saves
+// it at every interval, or every second if it is not positive. It starts a background
+// goroutine which is stopped by Close() of either the persister or the limiter, saving
+// the state one last time.
+func Persist(rl *Limiter, path string, every time.Duration) (*Persister, error) {
+	states, err := readSaved(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if s, ok := states[""]; ok {
+		rl.restore(s)
+	}
+
+	p := newPersister(path, every, func() map[string]saved {
+		return map[string]saved{"": rl.snapshot()}
+	})
+	rl.attach(p)
+	return p, nil
+}
+
+// PersistKeyed restores the state of every key of the keyed limiter from the file, if it
+// exists, and then saves it at every interval, or every second if it is not positive. It
+// starts a background goroutine which is stopped by Close(), saving the state one last
+// time.
+func PersistKeyed(k *Keyed, path string, every time.Duration) (*Persister, error) {
+	states, err := readSaved(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, s := range states {
+		k.Get(key).restore(s)
+	}
+
+	return newPersister(path, every, func() map[string]saved {
+		states := make(map[string]saved, k.Len())
+		k.each(func(key string, rl *Limiter) {
+			states[key] = rl.snapshot()
+		})
+		return states
+	}), nil
+}
+
+// newPersister creates a new persister and starts saving at every interval
+func newPersister(path string, every time.Duration, save func() map[string]saved) *Persister {
+	if every <= 0 {
+		every = time.Second
+	}
+
+	p := &Persister{
+		path: path,
+		save: save,
+		done: make(chan struct{}),
+	}
+
+	p.stop.Add(1)
+	go func() {
+		defer p.stop.Done()
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := p.write(); err != nil {
+					p.lock.Lock()
+					p.err = err
+					p.lock.Unlock()
+				}
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// Save writes the current state to the file right away. The file is replaced atomically
+// so a crash never leaves it partially written. It fails with ErrClosed once the
+// persister was closed, as the state was already saved one last time.
+func (p *Persister) Save() error {
+	select {
+	case <-p.done:
+		return ErrClosed
+	default:
+		return p.write()
+	}
+}
+
+// write writes the current state to the file, replacing it atomically
+func (p *Persister) write() error {
+	data, err := json.Marshal(p.save())
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), filepath.Base(p.path)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	// Flush to the disk before renaming, otherwise a crash could leave an empty file
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p.path)
+}
+
+// Err returns the error of the last periodic save which failed, if any.
+func (p *Persister) Err() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.err
+}
+
+// Close stops the periodic saves and saves the state one last time.
+func (p *Persister) Close() (err error) {
+	p.once.Do(func() {
+		close(p.done)
+		p.stop.Wait()
+		err = p.write()
+	})
+	return
+}
+
+// readSaved reads the states saved in the file, a missing file having no state
+func readSaved(path string) (map[string]saved, error) {
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	var states map[string]saved
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
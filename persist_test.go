@@ -0,0 +1,106 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Persister", func() {
+	var dir, path string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "persist")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "state.json")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should restore a limiter after a restart", func() {
+		rl := New(10, time.Hour)
+		_, err := Persist(rl, path, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rl.LimitN(7)).To(BeFalse())
+		Expect(rl.Close()).To(Succeed())
+
+		restarted := New(10, time.Hour)
+		p, err := Persist(restarted, path, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		defer p.Close()
+
+		Expect(restarted.Remaining()).To(Equal(3))
+		Expect(restarted.LimitN(4)).To(BeTrue())
+	})
+
+	It("should refill for the time elapsed while stopped", func() {
+		clock := NewManualClock(time.Unix(1000, 0))
+		rl := New(10, time.Second, WithClock(clock))
+		p, err := Persist(rl, path, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rl.LimitN(10)).To(BeFalse())
+		Expect(p.Close()).To(Succeed())
+
+		clock.Advance(500 * time.Millisecond)
+		restarted := New(10, time.Second, WithClock(clock))
+		p, err = Persist(restarted, path, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		defer p.Close()
+		Expect(restarted.Remaining()).To(Equal(5))
+	})
+
+	It("should save periodically", func() {
+		rl := New(10, time.Hour)
+		p, err := Persist(rl, path, 5*time.Millisecond)
+		Expect(err).NotTo(HaveOccurred())
+		defer p.Close()
+
+		Eventually(func() error {
+			_, err := os.Stat(path)
+			return err
+		}).Should(Succeed())
+		Expect(p.Err()).NotTo(HaveOccurred())
+	})
+
+	It("should default an interval which is not positive", func() {
+		p, err := Persist(New(10, time.Hour), path, -time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.Close()).To(Succeed())
+	})
+
+	It("should restore every key of a keyed limiter", func() {
+		k := NewKeyed(5, time.Hour)
+		p, err := PersistKeyed(k, path, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		k.Get("a").LimitN(5)
+		k.Get("b").LimitN(2)
+		Expect(p.Close()).To(Succeed())
+		Expect(p.Close()).To(Succeed())
+
+		restarted := NewKeyed(5, time.Hour)
+		p, err = PersistKeyed(restarted, path, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		defer p.Close()
+
+		Expect(restarted.Len()).To(Equal(2))
+		Expect(restarted.Limit("a")).To(BeTrue())
+		Expect(restarted.Get("b").Remaining()).To(Equal(3))
+	})
+
+	It("should fail on a corrupted file", func() {
+		Expect(os.WriteFile(path, []byte("{"), 0644)).To(Succeed())
+		_, err := Persist(New(1, time.Second), path, time.Hour)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,65 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// Color represents the verdict of a policer.
+type Color uint8
+
+// Various colors of a policer verdict
+const (
+	Green  Color = iota // Conforms to the committed rate
+	Yellow              // Exceeds the committed rate, but conforms to the peak rate
+	Red                 // Exceeds the peak rate
+)
+
+// String returns the name of the color.
+func (c Color) String() string {
+	switch c {
+	case Green:
+		return "green"
+	case Yellow:
+		return "yellow"
+	default:
+		return "red"
+	}
+}
+
+// Policer is a two-rate three-color marker, in the spirit of RFC 2698. It combines a
+// committed bucket for the sustained rate with a peak bucket for short-term bursts and
+// returns a verdict, so callers can mark and degrade traffic rather than just drop it.
+// Policer instances are thread-safe.
+type Policer struct {
+	committed *Limiter
+	peak      *Limiter
+}
+
+// NewPolicer creates a new policer from a committed limiter, which represents the
+// sustained rate, and a peak limiter, which represents the maximum burst rate.
+func NewPolicer(committed, peak *Limiter) *Policer {
+	return &Policer{
+		committed: committed,
+		peak:      peak,
+	}
+}
+
+// Mark returns the color of a single operation.
+func (p *Policer) Mark() Color {
+	return p.MarkN(1)
+}
+
+// MarkN returns the color of an operation costing n tokens, such as a packet of n bytes.
+// Red operations consume nothing, yellow ones only consume from the peak bucket and green
+// ones consume from both buckets.
+func (p *Policer) MarkN(n int) Color {
+	switch {
+	case p.peak.LimitN(n):
+		return Red
+	case p.committed.LimitN(n):
+		return Yellow
+	default:
+		return Green
+	}
+}
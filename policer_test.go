@@ -0,0 +1,42 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Policer", func() {
+
+	It("should mark operations", func() {
+		p := NewPolicer(New(2, time.Minute), New(5, time.Minute))
+
+		var colors []Color
+		for i := 0; i < 6; i++ {
+			colors = append(colors, p.Mark())
+		}
+
+		Expect(colors).To(Equal([]Color{Green, Green, Yellow, Yellow, Yellow, Red}))
+	})
+
+	It("should mark operations by cost", func() {
+		p := NewPolicer(New(1000, time.Minute), New(1500, time.Minute))
+		Expect(p.MarkN(800)).To(Equal(Green))
+		Expect(p.MarkN(500)).To(Equal(Yellow))
+		Expect(p.MarkN(500)).To(Equal(Red))
+		Expect(p.MarkN(200)).To(Equal(Green))
+	})
+
+	It("should name colors", func() {
+		Expect(Green.String()).To(Equal("green"))
+		Expect(Yellow.String()).To(Equal("yellow"))
+		Expect(Red.String()).To(Equal("red"))
+	})
+
+})
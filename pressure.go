@@ -0,0 +1,111 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+)
+
+// pressure represents the recent denial ratio of a limiter, estimated from its counters
+// over a sliding window of its period
+type pressure struct {
+	sync.Mutex
+	started  bool    // whether the first window was started
+	start    uint64  // start of the current window, in unix ns
+	allowed  uint64  // allowed counter at the start of the current window
+	denied   uint64  // denied counter at the start of the current window
+	previous float64 // ratio of the previous window, negative if it had no calls
+}
+
+// Pressure returns a normalized saturation signal between 0 and 1, the largest of the
+// recent ratio of calls denied and of how full the wait queue is. The queue is full
+// when it reaches WithMaxWaiters() or WithMaxDelay(), and otherwise half full with a
+// period worth of waiters. Applications can use it to propagate backpressure upstream
+// or to feed autoscalers.
+func (rl *Limiter) Pressure() float64 {
+	denials := rl.denialRatio()
+	if queue := rl.queueFill(); queue > denials {
+		return queue
+	}
+	return denials
+}
+
+// denialRatio returns the ratio of calls denied over roughly the last period, weighting
+// the previous window by how much of it the sliding window still covers
+func (rl *Limiter) denialRatio() float64 {
+	p := &rl.extend().pressure
+	p.Lock()
+	defer p.Unlock()
+
+	now := rl.now()
+	allowed, denied := rl.stats.allowed.Load(), rl.stats.denied.Load()
+	if !p.started {
+		p.started, p.start, p.previous = true, now, -1
+	}
+
+	// Roll over to a new window, the previous one only counts if it was the last period
+	if elapsed := now - p.start; elapsed >= rl.unit {
+		p.previous = -1
+		if elapsed < 2*rl.unit {
+			p.previous = ratio(allowed-p.allowed, denied-p.denied)
+		}
+		p.start, p.allowed, p.denied = now, allowed, denied
+	}
+
+	current := ratio(allowed-p.allowed, denied-p.denied)
+	weight := float64(now-p.start) / float64(rl.unit)
+	switch {
+	case p.previous < 0 && current < 0:
+		return 0
+	case p.previous < 0:
+		return current
+	case current < 0:
+		return p.previous * (1 - weight)
+	default:
+		return weight*current + (1-weight)*p.previous
+	}
+}
+
+// ratio returns the ratio of calls denied, or -1 if there were none
+func ratio(allowed, denied uint64) float64 {
+	if total := allowed + denied; total > 0 {
+		return float64(denied) / float64(total)
+	}
+	return -1
+}
+
+// queueFill returns how full the wait queue is, between 0 and 1
+func (rl *Limiter) queueFill() float64 {
+	e := rl.optional.Load()
+	if e == nil {
+		return 0 // nobody ever waited
+	}
+
+	q := &e.waiters
+	q.Lock()
+	defer q.Unlock()
+
+	n := q.list.Len()
+	if n == 0 {
+		return 0
+	}
+
+	var fill float64
+	switch {
+	case q.maxLen > 0:
+		fill = float64(n) / float64(q.maxLen)
+	case q.maxDelay > 0:
+		fill = float64(rl.estimate(n)) / float64(q.maxDelay)
+	default:
+		rate, _ := rl.limits(rl.now())
+		fill = float64(n) / float64(uint64(n)+rate)
+	}
+
+	if fill > 1 {
+		fill = 1
+	}
+	return fill
+}
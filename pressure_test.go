@@ -0,0 +1,69 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pressure", func() {
+
+	It("should follow the ratio of denials", func() {
+		rl := New(10, time.Second, WithTicks())
+		Expect(rl.Pressure()).To(BeZero())
+
+		for i := 0; i < 40; i++ {
+			rl.Limit()
+		}
+		Expect(rl.Pressure()).To(Equal(0.75))
+
+		// Remains within the period, then decays once the denials stop
+		rl.Tick(500 * time.Millisecond)
+		for i := 0; i < 5; i++ {
+			rl.Limit()
+		}
+		Expect(rl.Pressure()).To(BeNumerically("~", 0.667, 0.001))
+
+		rl.Tick(time.Second)
+		Expect(rl.Pressure()).To(BeNumerically("~", 0.667, 0.001))
+		rl.Tick(500 * time.Millisecond)
+		Expect(rl.Pressure()).To(BeNumerically("~", 0.333, 0.001))
+		rl.Tick(time.Second)
+		Expect(rl.Pressure()).To(BeZero())
+	})
+
+	It("should follow the depth of the queue", func() {
+		rl := New(1, time.Second, WithTicks(), WithMaxWaiters(4))
+		rl.Limit()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		for i := 0; i < 2; i++ {
+			go rl.Wait(ctx)
+		}
+
+		Eventually(rl.Pressure).Should(Equal(0.5))
+		cancel()
+		Eventually(rl.queueFill).Should(BeZero())
+	})
+
+	It("should be half full with a period of waiters when unbounded", func() {
+		rl := New(2, time.Second, WithTicks())
+		rl.LimitN(2)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		for i := 0; i < 2; i++ {
+			go rl.Wait(ctx)
+		}
+
+		Eventually(rl.queueFill).Should(Equal(0.5))
+	})
+})
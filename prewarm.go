@@ -0,0 +1,32 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// WithKeys creates the limiters of a known set of keys along with the keyed limiter, each
+// starting with its state, such as one loaded from persistence or exported by Export().
+// Services with a stable set of tenants avoid a herd of lazy creations on startup. The
+// allowance accrued since the time of a state is added on the first refill, and a state
+// without a time starts with its tokens as they are.
+func WithKeys(states map[string]State) KeyedOption {
+	return func(k *Keyed) {
+		if k.prewarm == nil {
+			k.prewarm = make(map[string]State, len(states))
+		}
+		for key, state := range states {
+			k.prewarm[key] = state
+		}
+	}
+}
+
+// warm creates the limiters of the keys to start with
+func (k *Keyed) warm() {
+	for key, state := range k.prewarm {
+		rl := k.spawn(key)
+		rl.restore(saved(state))
+		k.shard(key).limiters[key] = rl
+	}
+	k.prewarm = nil
+}
@@ -0,0 +1,56 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WithKeys", func() {
+
+	It("should create the keys on construction", func() {
+		k := NewKeyed(10, time.Hour, WithKeys(map[string]State{
+			"alice": {Tokens: 10},
+			"bob":   {Tokens: 2},
+			"carol": {},
+		}))
+
+		Expect(k.Len()).To(Equal(3))
+		Expect(k.Get("alice").Remaining()).To(Equal(10))
+		Expect(k.Get("bob").Remaining()).To(Equal(2))
+		Expect(k.Limit("carol")).To(BeTrue())
+		Expect(k.Get("dave").Remaining()).To(Equal(10))
+	})
+
+	It("should credit the allowance accrued since the state was saved", func() {
+		clock := NewManualClock(time.Unix(100, 0))
+		k := NewKeyed(10, time.Second, WithLimiterOptions(WithClock(clock)), WithKeys(map[string]State{
+			"alice": {Tokens: 2, Time: time.Unix(99, int64(500*time.Millisecond)).UnixNano()},
+		}))
+
+		Expect(k.Get("alice").Remaining()).To(Equal(7))
+	})
+
+	It("should cap the allowance at the burst", func() {
+		k := NewKeyed(10, time.Hour, WithKeys(map[string]State{
+			"alice": {Tokens: 50},
+		}))
+
+		Expect(k.Get("alice").Remaining()).To(Equal(10))
+	})
+
+	It("should merge the keys of several options", func() {
+		k := NewKeyed(10, time.Hour,
+			WithKeys(map[string]State{"alice": {Tokens: 1}}),
+			WithKeys(map[string]State{"bob": {Tokens: 1}}),
+		)
+
+		Expect(k.Len()).To(Equal(2))
+	})
+})
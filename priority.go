@@ -0,0 +1,95 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"time"
+)
+
+// priorities is the number of priority levels of the waiters
+const priorities = 2
+
+// Priority represents the priority of a goroutine queued in WaitPriority().
+type Priority uint8
+
+// Various priorities of the waiters
+const (
+	PriorityHigh Priority = iota // served for most of the turns, this is the default
+	PriorityLow                  // served for the remaining turns, so it never starves
+)
+
+// WithPriorityWeights sets the share of the tokens handed over to the waiters of each
+// priority while both are queued, such as 4 and 1 for an 80/20 split, which is the
+// default. Weights which are not positive are ignored.
+func WithPriorityWeights(high, low int) Option {
+	return func(rl *Limiter) {
+		if high > 0 && low > 0 {
+			rl.extend().waiters.weights = [priorities]int{high, low}
+		}
+	}
+}
+
+// WaitPriority blocks until a token is available or the context is done, as Wait() does.
+// While waiters of both priorities are queued, the head of the queue is handed over in
+// weighted turns so that low-priority traffic still makes progress behind a continuous
+// stream of high-priority traffic. Waiters of the same priority are served in the order
+// they arrived.
+func (rl *Limiter) WaitPriority(ctx context.Context, priority Priority) error {
+	if priority >= priorities {
+		priority = PriorityLow
+	}
+
+	if rl.ext().shadow && !rl.Closed() {
+		rl.record(rl.limit(), 1)
+		return nil
+	}
+
+	start := time.Now()
+	err := rl.wait(ctx, priority)
+	switch {
+	case err == nil:
+		rl.record(false, 1)
+	case errors.Is(err, ErrLimited): // a cancelled context or closed limiter is no denial
+		rl.record(true, 1)
+	}
+	rl.stats.observe(time.Since(start))
+	return err
+}
+
+// next returns the waiter which should become the head of the queue, must be called while
+// holding the queue lock
+func (q *queue) next() *list.Element {
+	front := q.list.Front()
+	if front == nil || q.count[PriorityHigh] == 0 || q.count[PriorityLow] == 0 {
+		return front
+	}
+
+	weights := q.weights
+	if weights[PriorityHigh] == 0 {
+		weights = [priorities]int{4, 1}
+	}
+
+	// Start a new round once both priorities took all of their turns
+	if q.turns[PriorityHigh] >= weights[PriorityHigh] && q.turns[PriorityLow] >= weights[PriorityLow] {
+		q.turns = [priorities]int{}
+	}
+
+	priority := PriorityHigh
+	if q.turns[PriorityHigh] >= weights[PriorityHigh] {
+		priority = PriorityLow
+	}
+
+	q.turns[priority]++
+	for elem := front; elem != nil; elem = elem.Next() {
+		if elem.Value.(*waiter).priority == priority {
+			return elem
+		}
+	}
+	return front
+}
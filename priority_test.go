@@ -0,0 +1,72 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Priority", func() {
+
+	// serve queues the waiters behind a blocked head and returns the priorities in the
+	// order they were served, handing over one token at a time
+	serve := func(rl *Limiter, high, low int) (order []Priority) {
+		rl.Limit()
+		served := make(chan Priority, high+low+1)
+		queue := func(p Priority) {
+			go func() {
+				if rl.WaitPriority(context.Background(), p) == nil {
+					served <- p
+				}
+			}()
+		}
+
+		queue(PriorityHigh)
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+		for i := 0; i < high; i++ {
+			queue(PriorityHigh)
+		}
+		for i := 0; i < low; i++ {
+			queue(PriorityLow)
+		}
+		Eventually(func() int { return waiting(rl) }).Should(Equal(high + low + 1))
+
+		for i := 0; i <= high+low; i++ {
+			rl.Undo()
+			if p := <-served; i > 0 {
+				order = append(order, p)
+			}
+		}
+		return
+	}
+
+	const H, L = PriorityHigh, PriorityLow
+
+	It("should split the turns 80/20 by default", func() {
+		rl := New(1, time.Hour)
+		Expect(serve(rl, 8, 4)).To(Equal([]Priority{H, H, H, H, L, H, H, H, H, L, L, L}))
+	})
+
+	It("should apply the weights", func() {
+		rl := New(1, time.Hour, WithPriorityWeights(1, 1))
+		Expect(serve(rl, 3, 3)).To(Equal([]Priority{H, L, H, L, H, L}))
+	})
+
+	It("should serve the low priority alone", func() {
+		rl := New(1, time.Hour)
+		Expect(serve(rl, 0, 3)).To(Equal([]Priority{L, L, L}))
+	})
+
+	It("should keep the stats of the waiters", func() {
+		rl := New(1, time.Hour)
+		Expect(rl.WaitPriority(context.Background(), PriorityLow)).To(Succeed())
+		Expect(rl.Stats().Allowed).To(BeEquivalentTo(1))
+	})
+})
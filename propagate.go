@@ -0,0 +1,68 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BudgetHeader is the request header carrying the remaining budget of the originating
+// tenant across the services of an internal call chain.
+const BudgetHeader = "X-RateLimit-Budget"
+
+// PropagateBudget encodes the remaining budget of the limiter into the header of an
+// outgoing request, along with its rate, such as "remaining=5;rate=10;per=1s", so that
+// the next service of the call chain can respect the quota of the originating tenant.
+func PropagateBudget(h http.Header, rl *Limiter) {
+	h.Set(BudgetHeader, "remaining="+strconv.Itoa(rl.Remaining())+
+		";rate="+strconv.FormatUint(rl.load().rate, 10)+
+		";per="+time.Duration(rl.unit).String())
+}
+
+// BudgetFrom reconstructs a child limiter from the budget header of an incoming request,
+// which admits at most the remaining budget right away and then refills at the rate of
+// the originating limiter. It returns false if the header is missing or malformed. The
+// child limiter may propagate the budget further down the call chain in turn.
+func BudgetFrom(h http.Header, options ...Option) (*Limiter, bool) {
+	remaining, rate, per, ok := parseBudget(h.Get(BudgetHeader))
+	if !ok {
+		return nil, false
+	}
+
+	rl := New(rate, per, options...)
+	rl.clamp(rl.units(uint64(remaining)))
+	return rl, true
+}
+
+// parseBudget parses the value of a budget header
+func parseBudget(v string) (remaining, rate int, per time.Duration, ok bool) {
+	var found int
+	for _, field := range strings.Split(v, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		var err error
+		switch name {
+		case "remaining":
+			remaining, err = strconv.Atoi(value)
+		case "rate":
+			rate, err = strconv.Atoi(value)
+		case "per":
+			per, err = time.ParseDuration(value)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return 0, 0, 0, false
+		}
+		found++
+	}
+
+	ok = found == 3 && remaining >= 0 && rate > 0 && per > 0
+	return
+}
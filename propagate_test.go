@@ -0,0 +1,59 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Propagate", func() {
+
+	It("should propagate the remaining budget", func() {
+		rl := New(10, time.Second)
+		rl.LimitN(7)
+
+		h := http.Header{}
+		PropagateBudget(h, rl)
+		Expect(h.Get(BudgetHeader)).To(Equal("remaining=3;rate=10;per=1s"))
+
+		child, ok := BudgetFrom(h)
+		Expect(ok).To(BeTrue())
+		Expect(child.Remaining()).To(Equal(3))
+		Expect(child.LimitN(3)).To(BeFalse())
+		Expect(child.Limit()).To(BeTrue())
+		Expect(child.View().Burst()).To(Equal(10))
+	})
+
+	It("should apply the options to the child", func() {
+		h := http.Header{}
+		h.Set(BudgetHeader, "remaining=0; rate=1; per=1h")
+
+		child, ok := BudgetFrom(h, WithName("child"))
+		Expect(ok).To(BeTrue())
+		Expect(child.Name()).To(Equal("child"))
+		Expect(child.Limit()).To(BeTrue())
+	})
+
+	It("should reject malformed headers", func() {
+		for _, v := range []string{
+			"",
+			"remaining=1;rate=1",
+			"remaining=x;rate=1;per=1s",
+			"remaining=1;rate=0;per=1s",
+			"remaining=-1;rate=1;per=1s",
+			"remaining=1;rate=1;per=soon",
+		} {
+			h := http.Header{}
+			h.Set(BudgetHeader, v)
+			_, ok := BudgetFrom(h)
+			Expect(ok).To(BeFalse(), v)
+		}
+	})
+})
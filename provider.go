@@ -0,0 +1,100 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+// Provider applies the rates supplied by an external source, such as a control plane or
+// a feature-flag system, to a limiter. Provider instances are thread-safe.
+type Provider struct {
+	limiter *Limiter
+	last    int           // last rate applied
+	done    chan struct{} // closed when the provider is closed
+	once    sync.Once
+}
+
+// PollRate calls the function right away and then at every interval, or every second if
+// it is not positive, applying the rate it returns to the limiter. If the function fails
+// or the rate is not positive, the current rate is kept. It starts a background goroutine
+// which is stopped by Close() of either the provider or the limiter.
+func PollRate(rl *Limiter, every time.Duration, fn func() (int, error)) *Provider {
+	if every <= 0 {
+		every = time.Second
+	}
+
+	p := newProvider(rl)
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+
+		for {
+			if rate, err := fn(); err == nil {
+				p.apply(rate)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// FollowRate applies the rates pushed on the channel to the limiter, until the channel
+// is closed. Rates which are not positive are ignored. It starts a background goroutine
+// which is stopped by Close() of either the provider or the limiter, or once the channel
+// is closed.
+func FollowRate(rl *Limiter, rates <-chan int) *Provider {
+	p := newProvider(rl)
+	go func() {
+		for {
+			select {
+			case rate, ok := <-rates:
+				if !ok {
+					return
+				}
+				p.apply(rate)
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// newProvider creates a new provider for the limiter
+func newProvider(rl *Limiter) *Provider {
+	p := &Provider{
+		limiter: rl,
+		last:    int(rl.load().rate),
+		done:    make(chan struct{}),
+	}
+
+	rl.attach(p)
+	return p
+}
+
+// Close stops the provider, the limiter keeps the last rate applied.
+func (p *Provider) Close() error {
+	p.once.Do(func() {
+		close(p.done)
+	})
+	return nil
+}
+
+// apply updates the rate of the limiter if it has changed, only called by the goroutine
+// of the provider
+func (p *Provider) apply(rate int) {
+	if rate > 0 && rate != p.last {
+		p.limiter.UpdateRate(rate)
+		p.last = rate
+	}
+}
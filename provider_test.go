@@ -0,0 +1,76 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Provider", func() {
+	rateOf := func(rl *Limiter) func() uint64 {
+		return func() uint64 { return rl.load().rate }
+	}
+
+	It("should poll the rate", func() {
+		var rate atomic.Int64
+		rate.Store(20)
+
+		rl := New(10, time.Second)
+		p := PollRate(rl, 5*time.Millisecond, func() (int, error) {
+			if r := rate.Load(); r > 0 {
+				return int(r), nil
+			}
+			return 0, errors.New("unavailable")
+		})
+		defer p.Close()
+
+		Eventually(rateOf(rl)).Should(Equal(uint64(20)))
+		rate.Store(30)
+		Eventually(rateOf(rl)).Should(Equal(uint64(30)))
+
+		rate.Store(0)
+		Consistently(rateOf(rl), 30*time.Millisecond).Should(Equal(uint64(30)))
+	})
+
+	It("should default an interval which is not positive", func() {
+		rl := New(10, time.Second)
+		p := PollRate(rl, 0, func() (int, error) { return 20, nil })
+		defer p.Close()
+		Eventually(rateOf(rl)).Should(Equal(uint64(20)))
+	})
+
+	It("should follow the rates pushed", func() {
+		rates := make(chan int)
+		rl := New(10, time.Second)
+		p := FollowRate(rl, rates)
+		defer p.Close()
+
+		rates <- 20
+		Eventually(rateOf(rl)).Should(Equal(uint64(20)))
+		rates <- -1
+		rates <- 40
+		Eventually(rateOf(rl)).Should(Equal(uint64(40)))
+		close(rates)
+	})
+
+	It("should stop on close", func() {
+		rates := make(chan int, 1)
+		rl := New(10, time.Second)
+		p := FollowRate(rl, rates)
+		Expect(p.Close()).To(Succeed())
+		Expect(p.Close()).To(Succeed())
+
+		time.Sleep(5 * time.Millisecond)
+		rates <- 20
+		Consistently(rateOf(rl), 20*time.Millisecond).Should(Equal(uint64(10)))
+	})
+
+})
@@ -0,0 +1,152 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+// Quota is a fixed-window quota which allows a number of operations per window, such as
+// 1000 calls per day. Windows are aligned on multiples of their duration since the unix
+// epoch. Quota instances are thread-safe.
+type Quota struct {
+	lock   sync.Mutex
+	limit  int     // operations allowed per window
+	window int64   // window duration, in ns
+	carry  float64 // fraction of the unused quota carried over to the next window
+	cap    int     // maximum number of operations carried over
+	clock  Clock   // optional source of time
+	start  int64   // start of the current window, in unix ns
+	used   int     // operations used in the current window
+	bonus  int     // operations carried over from the previous window
+}
+
+// QuotaOption represents an option which can be applied to a quota on creation.
+type QuotaOption func(*Quota)
+
+// WithCarryOver lets a fraction of the unused quota roll over into the next window, up
+// to a maximum number of operations.
+func WithCarryOver(fraction float64, max int) QuotaOption {
+	return func(q *Quota) {
+		q.carry = fraction
+		q.cap = max
+	}
+}
+
+// WithQuotaClock sets the clock used by the quota instead of the system time.
+func WithQuotaClock(clock Clock) QuotaOption {
+	return func(q *Quota) {
+		q.clock = clock
+	}
+}
+
+// NewQuota creates a new quota allowing n operations per window.
+func NewQuota(n int, window time.Duration, options ...QuotaOption) *Quota {
+	if window <= 0 {
+		window = time.Second
+	}
+
+	q := &Quota{
+		limit:  n,
+		window: int64(window),
+	}
+
+	for _, opt := range options {
+		opt(q)
+	}
+
+	q.start = q.now() / q.window * q.window
+	return q
+}
+
+// Limit returns true if the quota of the current window was exceeded.
+func (q *Quota) Limit() bool {
+	return q.LimitN(1)
+}
+
+// LimitN returns true if the quota of the current window would be exceeded by n
+// operations, otherwise it consumes them.
+func (q *Quota) LimitN(n int) bool {
+	if n < 1 {
+		return false
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.roll()
+	if n > q.limit+q.bonus-q.used {
+		return true
+	}
+
+	q.used += n
+	return false
+}
+
+// Undo reverts the last Limit() call, returning the consumed operation.
+func (q *Quota) Undo() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.roll()
+	if q.used > 0 {
+		q.used--
+	}
+}
+
+// Remaining returns the number of operations remaining in the current window.
+func (q *Quota) Remaining() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.roll()
+	return q.limit + q.bonus - q.used
+}
+
+// Reset returns the time at which the current window ends.
+func (q *Quota) Reset() time.Time {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.roll()
+	return time.Unix(0, q.start+q.window)
+}
+
+// roll moves to the current window, carrying over the unused quota, must be called
+// while holding the lock
+func (q *Quota) roll() {
+	now := q.now()
+	if now < q.start+q.window {
+		return
+	}
+
+	// Carry over from each elapsed window, the ones we skipped were entirely unused and
+	// thus carry over the same bonus as soon as it stops changing
+	for elapsed := (now - q.start) / q.window; elapsed > 0 && q.carry > 0; elapsed-- {
+		bonus := int(float64(q.limit+q.bonus-q.used) * q.carry)
+		if bonus > q.cap {
+			bonus = q.cap
+		}
+
+		settled := q.used == 0 && bonus == q.bonus
+		q.bonus, q.used = bonus, 0
+		if settled {
+			break
+		}
+	}
+
+	q.used = 0
+	q.start = now / q.window * q.window
+}
+
+// now returns the current time as unix nanoseconds
+func (q *Quota) now() int64 {
+	if q.clock == nil {
+		return time.Now().UnixNano()
+	}
+	return q.clock.Now()
+}
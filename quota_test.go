@@ -0,0 +1,70 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"math"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Quota", func() {
+
+	It("should allow a number of operations per window", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		q := NewQuota(3, time.Minute, WithQuotaClock(clock))
+		Expect(q.Reset()).To(Equal(time.Unix(60, 0)))
+		Expect(q.Limit()).To(BeFalse())
+		Expect(q.LimitN(2)).To(BeFalse())
+		Expect(q.Limit()).To(BeTrue())
+		Expect(q.Remaining()).To(Equal(0))
+		Expect(q.LimitN(-5)).To(BeFalse())
+		Expect(q.LimitN(math.MaxInt)).To(BeTrue())
+		Expect(q.Remaining()).To(Equal(0))
+
+		q.Undo()
+		Expect(q.Remaining()).To(Equal(1))
+
+		clock.Advance(time.Minute)
+		Expect(q.Remaining()).To(Equal(3))
+		Expect(q.Reset()).To(Equal(time.Unix(120, 0)))
+	})
+
+	It("should not carry over by default", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		q := NewQuota(10, time.Minute, WithQuotaClock(clock))
+		clock.Advance(time.Minute)
+		Expect(q.Remaining()).To(Equal(10))
+	})
+
+	It("should carry over unused quota", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		q := NewQuota(10, time.Minute, WithQuotaClock(clock), WithCarryOver(0.5, 8))
+		Expect(q.LimitN(6)).To(BeFalse())
+
+		clock.Advance(time.Minute)
+		Expect(q.Remaining()).To(Equal(12))
+		Expect(q.LimitN(2)).To(BeFalse())
+
+		clock.Advance(time.Minute)
+		Expect(q.Remaining()).To(Equal(15))
+
+		clock.Advance(10 * time.Minute)
+		Expect(q.Remaining()).To(Equal(18))
+	})
+
+	It("should carry over quickly after many windows", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		q := NewQuota(10, time.Nanosecond, WithQuotaClock(clock), WithCarryOver(0.5, 100))
+		Expect(q.LimitN(6)).To(BeFalse())
+
+		clock.Advance(100000 * time.Hour)
+		Expect(q.Remaining()).To(Equal(19))
+	})
+
+})
@@ -0,0 +1,139 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// maxAllowance is the largest allowance a limiter may hold, in units of rate * ns, which
+// leaves enough headroom for refills to never overflow
+const maxAllowance = math.MaxInt64
+
+// Rate represents a validated rate of a number of tokens per period, such as 100 per
+// second. Unlike New(), which silently clamps invalid arguments, NewRate() rejects them.
+type Rate struct {
+	n   int
+	per time.Duration
+}
+
+// NewRate creates a new rate of n tokens per period, failing with ErrInvalidRate if
+// either is not positive or if they would overflow the allowance of a limiter.
+func NewRate(n int, per time.Duration) (Rate, error) {
+	switch {
+	case n < 1:
+		return Rate{}, fmt.Errorf("%w: %d tokens per %v, must be at least 1", ErrInvalidRate, n, per)
+	case per <= 0:
+		return Rate{}, fmt.Errorf("%w: period of %v, must be positive", ErrInvalidRate, per)
+	case uint64(n) > maxAllowance/uint64(per):
+		return Rate{}, fmt.Errorf("%w: %d tokens per %v overflows, use a shorter period", ErrInvalidRate, n, per)
+	}
+	return Rate{n: n, per: per}, nil
+}
+
+// MustRate creates a new rate of n tokens per period, as NewRate() does, but panics if it
+// is invalid. It is meant for rates which are constant.
+func MustRate(n int, per time.Duration) Rate {
+	r, err := NewRate(n, per)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// N returns the number of tokens per period.
+func (r Rate) N() int {
+	return r.n
+}
+
+// Per returns the period of the rate.
+func (r Rate) Per() time.Duration {
+	return r.per
+}
+
+// PerSecond returns the rate in tokens per second.
+func (r Rate) PerSecond() float64 {
+	return PerSecond(r.n, r.per)
+}
+
+// String returns the rate formatted as tokens per period, such as "100/1s".
+func (r Rate) String() string {
+	return strconv.Itoa(r.n) + "/" + r.per.String()
+}
+
+// Representable returns whether the interval between two tokens of the rate is at least
+// the resolution of the system time, so that the limiter can pace them accurately.
+func (r Rate) Representable() bool {
+	return r.n > 0 && Interval(r.n, r.per) >= Resolution()
+}
+
+// Burst represents the maximum number of tokens a limiter can accumulate, which is also
+// the largest number of tokens a single call may take. By default, it equals the number
+// of tokens per period of the rate.
+type Burst int
+
+// NewBurst creates a new burst of n tokens, failing with ErrInvalidBurst unless it is
+// positive.
+func NewBurst(n int) (Burst, error) {
+	if n < 1 {
+		return 0, fmt.Errorf("%w: %d tokens, must be at least 1", ErrInvalidBurst, n)
+	}
+	return Burst(n), nil
+}
+
+// WithBurst sets the maximum number of tokens the limiter can accumulate, instead of the
+// number of tokens per period. The burst is kept when the rate is updated. A burst which
+// is not positive is ignored.
+func WithBurst(burst Burst) Option {
+	return func(rl *Limiter) {
+		if burst > 0 {
+			rl.extend().capacity = uint64(burst)
+		}
+	}
+}
+
+// NewLimiter creates a new rate limiter instance with a validated rate and burst, failing
+// with ErrInvalidRate or ErrInvalidBurst rather than silently clamping them as New() does.
+// A zero burst defaults to the number of tokens per period of the rate.
+func NewLimiter(rate Rate, burst Burst, options ...Option) (*Limiter, error) {
+	switch {
+	case rate.n < 1 || rate.per <= 0:
+		return nil, fmt.Errorf("%w: rate was not created with NewRate()", ErrInvalidRate)
+	case burst < 0:
+		return nil, fmt.Errorf("%w: %d tokens, must be at least 1", ErrInvalidBurst, burst)
+	case uint64(burst) > maxAllowance/uint64(rate.per):
+		return nil, fmt.Errorf("%w: %d tokens per %v overflows, use a shorter period", ErrInvalidBurst, burst, rate.per)
+	}
+
+	if burst > 0 {
+		options = append(options[:len(options):len(options)], WithBurst(burst))
+	}
+	return New(rate.n, rate.per, options...), nil
+}
+
+// NewChecked creates a new rate limiter instance as New() does, but fails with a
+// descriptive ErrInvalidRate instead of silently coercing a rate or period which is not
+// positive, or which would overflow the allowance.
+func NewChecked(rate int, per time.Duration, options ...Option) (*Limiter, error) {
+	r, err := NewRate(rate, per)
+	if err != nil {
+		return nil, err
+	}
+	return NewLimiter(r, 0, options...)
+}
+
+// MustNew creates a new rate limiter instance as NewChecked() does, but panics if the
+// configuration is invalid. It is meant for limiters created on startup.
+func MustNew(rate int, per time.Duration, options ...Option) *Limiter {
+	rl, err := NewChecked(rate, per, options...)
+	if err != nil {
+		panic(err)
+	}
+	return rl
+}
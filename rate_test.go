@@ -0,0 +1,95 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rate", func() {
+
+	It("should validate rates", func() {
+		r, err := NewRate(100, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.N()).To(Equal(100))
+		Expect(r.Per()).To(Equal(time.Second))
+		Expect(r.PerSecond()).To(Equal(100.0))
+		Expect(r.String()).To(Equal("100/1s"))
+
+		for _, tc := range []struct {
+			n   int
+			per time.Duration
+		}{{0, time.Second}, {-1, time.Second}, {1, 0}, {1, -time.Second}, {math.MaxInt32, 24 * time.Hour}} {
+			_, err := NewRate(tc.n, tc.per)
+			Expect(errors.Is(err, ErrInvalidRate)).To(BeTrue())
+		}
+
+		Expect(func() { MustRate(0, time.Second) }).To(Panic())
+	})
+
+	It("should validate bursts", func() {
+		b, err := NewBurst(10)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b).To(Equal(Burst(10)))
+
+		_, err = NewBurst(0)
+		Expect(errors.Is(err, ErrInvalidBurst)).To(BeTrue())
+	})
+
+	It("should create limiters with a burst", func() {
+		rl, err := NewLimiter(MustRate(10, time.Second), 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rl.View().Burst()).To(Equal(2))
+		Expect(rl.LimitN(2)).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+
+		rl.UpdateRate(20)
+		Expect(rl.View().Burst()).To(Equal(2))
+		Expect(rl.Clone(CloneFresh).View().Burst()).To(Equal(2))
+	})
+
+	It("should default the burst to the rate", func() {
+		rl, err := NewLimiter(MustRate(10, time.Second), 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rl.View().Burst()).To(Equal(10))
+	})
+
+	It("should reject invalid limiters", func() {
+		_, err := NewLimiter(Rate{}, 1)
+		Expect(errors.Is(err, ErrInvalidRate)).To(BeTrue())
+
+		_, err = NewLimiter(MustRate(1, time.Hour), -1)
+		Expect(errors.Is(err, ErrInvalidBurst)).To(BeTrue())
+
+		_, err = NewLimiter(MustRate(1, time.Hour), math.MaxInt32)
+		Expect(errors.Is(err, ErrInvalidBurst)).To(BeTrue())
+	})
+
+	It("should create checked limiters", func() {
+		rl, err := NewChecked(10, time.Second, WithName("api"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rl.Name()).To(Equal("api"))
+		Expect(rl.Remaining()).To(Equal(10))
+		Expect(MustNew(5, time.Minute).Remaining()).To(Equal(5))
+	})
+
+	It("should reject invalid configurations", func() {
+		_, err := NewChecked(0, time.Second)
+		Expect(errors.Is(err, ErrInvalidRate)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("0 tokens per 1s"))
+
+		_, err = NewChecked(10, -time.Second)
+		Expect(errors.Is(err, ErrInvalidRate)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("period of -1s"))
+
+		Expect(func() { MustNew(1, 0) }).To(Panic())
+	})
+})
@@ -0,0 +1,229 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+// Package ratebench compares rate limiting algorithms by replaying canned traffic
+// patterns against them on a manual clock, and reports how closely each one follows the
+// configured rate along with its overhead per decision.
+package ratebench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kelindar/rate"
+)
+
+// Algorithm represents a rate limiting algorithm under comparison.
+type Algorithm struct {
+	Name string                                                       // Name of the algorithm
+	New  func(n int, per time.Duration, clock rate.Clock) func() bool // Creates a limit function
+}
+
+// Various algorithms which can be compared
+var (
+	TokenBucket = Algorithm{"token-bucket", func(n int, per time.Duration, clock rate.Clock) func() bool {
+		return rate.New(n, per, rate.WithClock(clock)).Limit
+	}}
+
+	GCRA = Algorithm{"gcra", newGCRA}
+
+	SlidingWindow = Algorithm{"sliding-window", func(n int, per time.Duration, clock rate.Clock) func() bool {
+		s := rate.NewSliding(n, per, rate.WithSlidingClock(clock))
+		return func() bool { return s.Limit("") }
+	}}
+)
+
+// Algorithms returns every algorithm which can be compared.
+func Algorithms() []Algorithm {
+	return []Algorithm{TokenBucket, GCRA, SlidingWindow}
+}
+
+// Find returns the algorithm with the name, if any.
+func Find(name string) (Algorithm, bool) {
+	for _, a := range Algorithms() {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Algorithm{}, false
+}
+
+// newGCRA creates a reference implementation of the generic cell rate algorithm, which
+// tracks the theoretical arrival time of the next call and tolerates a burst of n calls
+func newGCRA(n int, per time.Duration, clock rate.Clock) func() bool {
+	interval := int64(per) / int64(n)
+	tolerance := interval * int64(n-1)
+	tat := clock.Now()
+	return func() bool {
+		now := clock.Now()
+		if now < tat-tolerance {
+			return true
+		}
+
+		if tat < now {
+			tat = now
+		}
+		tat += interval
+		return false
+	}
+}
+
+// ----------------------------------- Patterns -----------------------------------
+
+// Pattern represents a canned traffic pattern, as the offsets of the arrivals of the
+// calls from the start, in ascending order.
+type Pattern struct {
+	Name     string
+	Arrivals []time.Duration
+}
+
+// Steady creates a pattern of calls evenly spaced at the rate per second for the duration.
+func Steady(perSecond float64, duration time.Duration) Pattern {
+	interval := time.Duration(float64(time.Second) / perSecond)
+	var arrivals []time.Duration
+	for at := time.Duration(0); at < duration; at += interval {
+		arrivals = append(arrivals, at)
+	}
+	return Pattern{Name: fmt.Sprintf("steady-%g", perSecond), Arrivals: arrivals}
+}
+
+// Bursty creates a pattern of bursts of n simultaneous calls at every interval for the
+// duration.
+func Bursty(n int, every, duration time.Duration) Pattern {
+	var arrivals []time.Duration
+	for at := time.Duration(0); at < duration; at += every {
+		for i := 0; i < n; i++ {
+			arrivals = append(arrivals, at)
+		}
+	}
+	return Pattern{Name: fmt.Sprintf("bursty-%dx%v", n, every), Arrivals: arrivals}
+}
+
+// Poisson creates a pattern of calls arriving at random at an average rate per second
+// for the duration. The seed makes the pattern reproducible.
+func Poisson(perSecond float64, duration time.Duration, seed int64) Pattern {
+	rnd := rand.New(rand.NewSource(seed))
+	var arrivals []time.Duration
+	for at := time.Duration(0); ; {
+		at += time.Duration(rnd.ExpFloat64() / perSecond * float64(time.Second))
+		if at >= duration {
+			break
+		}
+		arrivals = append(arrivals, at)
+	}
+	return Pattern{Name: fmt.Sprintf("poisson-%g", perSecond), Arrivals: arrivals}
+}
+
+// Patterns returns a canned set of patterns for a rate of n calls per period, offering
+// under, at and over the rate, in bursts and at random, over a hundred periods.
+func Patterns(n int, per time.Duration) []Pattern {
+	perSecond := rate.PerSecond(n, per)
+	duration := 100 * per
+	return []Pattern{
+		Steady(perSecond/2, duration),
+		Steady(perSecond*2, duration),
+		Bursty(n*2, per, duration),
+		Poisson(perSecond, duration, 1),
+		Poisson(perSecond*3, duration, 2),
+	}
+}
+
+// ----------------------------------- Results -----------------------------------
+
+// Result represents the outcome of replaying a pattern against an algorithm.
+type Result struct {
+	Algorithm string        // Name of the algorithm
+	Pattern   string        // Name of the pattern
+	Offered   int           // Number of calls offered
+	Admitted  int           // Number of calls admitted
+	Ideal     int           // Number of calls an exact sliding log would have admitted
+	Accuracy  float64       // How closely the admitted calls match the ideal, from 0 to 1
+	Peak      int           // Maximum number of calls admitted within any period
+	Overhead  time.Duration // Average time spent per decision
+}
+
+// Run replays the pattern against the algorithm configured for n calls per period.
+func Run(a Algorithm, p Pattern, n int, per time.Duration) Result {
+	clock := rate.NewManualClock(time.Unix(0, 0))
+	limit := a.New(n, per, clock)
+	admitted := make([]time.Duration, 0, len(p.Arrivals))
+
+	var elapsed, spent time.Duration
+	for _, at := range p.Arrivals {
+		clock.Advance(at - elapsed)
+		elapsed = at
+
+		start := time.Now()
+		limited := limit()
+		spent += time.Since(start)
+		if !limited {
+			admitted = append(admitted, at)
+		}
+	}
+
+	r := Result{
+		Algorithm: a.Name,
+		Pattern:   p.Name,
+		Offered:   len(p.Arrivals),
+		Admitted:  len(admitted),
+		Ideal:     ideal(p.Arrivals, n, per),
+		Peak:      rate.Peak(admitted, per),
+	}
+
+	if r.Offered > 0 {
+		r.Overhead = spent / time.Duration(r.Offered)
+	}
+	if r.Accuracy = 1; r.Ideal > 0 {
+		r.Accuracy = math.Max(0, 1-math.Abs(float64(r.Admitted-r.Ideal))/float64(r.Ideal))
+	}
+	return r
+}
+
+// Compare replays every pattern against every algorithm configured for n calls per
+// period and returns the results, grouped by pattern.
+func Compare(algorithms []Algorithm, patterns []Pattern, n int, per time.Duration) []Result {
+	results := make([]Result, 0, len(algorithms)*len(patterns))
+	for _, p := range patterns {
+		for _, a := range algorithms {
+			results = append(results, Run(a, p, n, per))
+		}
+	}
+	return results
+}
+
+// Write writes the results as an aligned table.
+func Write(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATTERN\tALGORITHM\tOFFERED\tADMITTED\tIDEAL\tACCURACY\tPEAK\tOVERHEAD")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%.3f\t%d\t%v\n",
+			r.Pattern, r.Algorithm, r.Offered, r.Admitted, r.Ideal, r.Accuracy, r.Peak, r.Overhead)
+	}
+	return tw.Flush()
+}
+
+// ideal returns the number of arrivals an exact sliding log would admit, never more than
+// n within any period
+func ideal(arrivals []time.Duration, n int, per time.Duration) int {
+	sorted := append([]time.Duration(nil), arrivals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	log := make([]time.Duration, 0, len(sorted))
+	oldest := 0
+	for _, at := range sorted {
+		for oldest < len(log) && at-log[oldest] >= per {
+			oldest++
+		}
+		if len(log)-oldest < n {
+			log = append(log, at)
+		}
+	}
+	return len(log)
+}
@@ -0,0 +1,81 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package ratebench
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compare", func() {
+
+	It("should find the algorithms by name", func() {
+		a, ok := Find("gcra")
+		Expect(ok).To(BeTrue())
+		Expect(a.Name).To(Equal("gcra"))
+
+		_, ok = Find("unknown")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should admit everything under the rate", func() {
+		for _, a := range Algorithms() {
+			r := Run(a, Steady(5, time.Minute), 10, time.Second)
+			Expect(r.Admitted).To(Equal(r.Offered), a.Name)
+			Expect(r.Accuracy).To(Equal(1.0), a.Name)
+		}
+	})
+
+	It("should follow the rate over it", func() {
+		for _, a := range Algorithms() {
+			r := Run(a, Steady(20, time.Minute), 10, time.Second)
+			Expect(r.Ideal).To(Equal(600))
+			Expect(r.Accuracy).To(BeNumerically(">", 0.95), a.Name)
+			Expect(r.Peak).To(BeNumerically("<=", 20), a.Name)
+		}
+	})
+
+	It("should create reproducible patterns", func() {
+		Expect(Poisson(10, time.Minute, 1)).To(Equal(Poisson(10, time.Minute, 1)))
+		Expect(Bursty(3, time.Second, 2*time.Second).Arrivals).To(Equal([]time.Duration{
+			0, 0, 0, time.Second, time.Second, time.Second,
+		}))
+	})
+
+	It("should write the results", func() {
+		results := Compare(Algorithms(), Patterns(10, time.Second), 10, time.Second)
+		Expect(results).To(HaveLen(15))
+
+		var out bytes.Buffer
+		Expect(Write(&out, results)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("token-bucket"))
+		Expect(bytes.Count(out.Bytes(), []byte("\n"))).To(Equal(16))
+	})
+})
+
+func TestGinkgoSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "github.com/kelindar/rate/ratebench")
+}
+
+// --------------------------------------------------------------------
+
+func BenchmarkCompare(b *testing.B) {
+	patterns := Patterns(100, time.Second)
+	for _, a := range Algorithms() {
+		b.Run(a.Name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, p := range patterns {
+					Run(a, p, 100, time.Second)
+				}
+			}
+		})
+	}
+}
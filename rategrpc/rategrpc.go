@@ -0,0 +1,59 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+// Package rategrpc maps the rejections of the rate limiters to gRPC statuses. It is a
+// separate module so that the rate package does not depend on gRPC.
+package rategrpc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kelindar/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Error converts a rejection of a limiter into a ResourceExhausted gRPC error which tells
+// the client how long to back off through RetryInfo details. Other errors are returned
+// unchanged.
+func Error(err error) error {
+	var limited *rate.LimitedError
+	if !errors.As(err, &limited) {
+		return err
+	}
+
+	return Limited(limited.Error(), limited.RetryAfter)
+}
+
+// Limited returns a ResourceExhausted gRPC error with the message, which tells the client
+// to back off for the specified duration through RetryInfo details.
+func Limited(msg string, retryAfter time.Duration) error {
+	st := status.New(codes.ResourceExhausted, msg)
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retryAfter),
+	}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// RetryAfter returns how long the client should back off according to the RetryInfo
+// details of a gRPC error, if any.
+func RetryAfter(err error) (time.Duration, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,58 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rategrpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kelindar/rate"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Error", func() {
+
+	It("should map rejections to ResourceExhausted", func() {
+		rl := rate.New(1, time.Minute)
+		Expect(rl.Limit()).To(BeFalse())
+
+		err := Error(rl.Try())
+		Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+
+		retry, ok := RetryAfter(err)
+		Expect(ok).To(BeTrue())
+		Expect(retry).To(BeNumerically("~", time.Minute, time.Second))
+	})
+
+	It("should keep other errors unchanged", func() {
+		other := errors.New("boom")
+		Expect(Error(other)).To(Equal(other))
+		Expect(Error(nil)).To(BeNil())
+
+		_, ok := RetryAfter(other)
+		Expect(ok).To(BeFalse())
+		_, ok = RetryAfter(status.Error(codes.Internal, "boom"))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should create errors with a back off", func() {
+		err := Limited("slow down", 3*time.Second)
+		Expect(status.Convert(err).Message()).To(Equal("slow down"))
+		retry, ok := RetryAfter(err)
+		Expect(ok).To(BeTrue())
+		Expect(retry).To(Equal(3 * time.Second))
+	})
+
+})
+
+func TestGinkgoSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "github.com/kelindar/rate/rategrpc")
+}
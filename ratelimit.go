@@ -6,17 +6,134 @@
 package rate
 
 import (
+	"math"
+	"math/bits"
 	"sync/atomic"
 	"time"
 )
 
+// The allowance is kept in fixed-point with a scale of one token per unit, the period in
+// nanoseconds, and every nanosecond elapsed accrues the rate in sub-units. The accrual is
+// therefore exact integer arithmetic whatever the rate: 1 per 6 hours accrues 1 sub-unit
+// per nanosecond and a token every 21.6e12 sub-units, without any rounding drift over
+// long periods.
+
 // Limiter instances are thread-safe.
 type Limiter struct {
-	rate, allowance, max, unit, lastCheck uint64
+	allowance atomic.Uint64              // current allowance, in units of rate * ns
+	lastCheck atomic.Uint64              // time of the last refill, in unix ns
+	config    atomic.Pointer[config]     // current rate configuration
+	unit      uint64                     // unit size, in ns
+	clock     Clock                      // optional source of time
+	stats     counters                   // decision counters
+	reserved  atomic.Uint64              // rate reserved by budgets of batch jobs
+	debt      atomic.Uint64              // allowance owed after under-charged calls
+	refunds   refunds                    // tokens given back, per reason
+	optional  atomic.Pointer[extensions] // optional features, allocated on first use
+}
+
+// extensions represents the optional features of a limiter, kept apart so that the
+// limiters which use none of them, such as most keys of a keyed limiter, stay small.
+// They are set by the options on creation, except for the ones guarded by their own
+// lock or atomic which may also be used later on.
+type extensions struct {
+	waiters  queue                  // goroutines blocked in Wait()
+	name     string                 // optional name of the limiter
+	schedule *scheduled             // optional schedule of rates
+	ramp     time.Duration          // optional duration of rate transitions
+	penalty  *penalty               // optional penalty for repeated violations
+	shadow   bool                   // whether denials are only counted, not enforced
+	burst    *burst                 // optional detector of sustained bursts
+	reserve  float64                // fraction of the allowance reserved for critical calls
+	attached attached               // background components closed along with the limiter
+	soft     *soft                  // optional soft limit which only warns
+	drift    *drift                 // optional detector of drift from the configured rate
+	pressure pressure               // recent denial ratio, sampled by Pressure()
+	wheel    *TimerWheel            // optional timing wheel scheduling the wake-ups
+	options  []Option               // options of the limiter, applied again by Clone()
+	strict   bool                   // whether permits are evenly spaced, without bursts
+	feedback []func(err error)      // consumers of the outcomes reported by the callers
+	dedup    *dedup                 // optional identifiers of the calls admitted recently
+	capacity uint64                 // optional maximum number of tokens, the rate if zero
+	tags     map[string]*tag        // optional sub-budgets of the categories of calls
+	initial  *uint64                // optional number of tokens to start with, full if nil
+	children children               // child limiters entitled to a share of the rate
+	latch    *latch                 // optional notifications of denials and recoveries
+	arrivals *arrivals              // optional histogram of the calls arrived per period
+	expiry   *expiry                // optional expiry of the allowance of idle limiters
+	startup  *startup               // optional cap of the burst right after creation
+	history  atomic.Pointer[events] // optional recent decisions, recorded on demand
+	standing *reputation            // optional burst adapted to the standing of the caller
+	parent   *Limiter               // optional parent which the admitted calls draw from
+}
+
+// none are the extensions of the limiters without any optional feature, never modified
+var none extensions
+
+// ext returns the optional features of the limiter for reading, which are never nil
+func (rl *Limiter) ext() *extensions {
+	if e := rl.optional.Load(); e != nil {
+		return e
+	}
+	return &none
+}
+
+// extend returns the optional features of the limiter for writing, allocating them on
+// first use
+func (rl *Limiter) extend() *extensions {
+	if e := rl.optional.Load(); e != nil {
+		return e
+	}
+
+	rl.optional.CompareAndSwap(nil, new(extensions))
+	return rl.optional.Load()
 }
 
-// New creates a new rate limiter instance
-func New(rate int, per time.Duration) *Limiter {
+// Option represents an option which can be applied to a limiter on creation.
+type Option func(*Limiter)
+
+// WithName sets the name of the limiter, used to identify it in profiles.
+func WithName(name string) Option {
+	return func(rl *Limiter) {
+		rl.extend().name = name
+	}
+}
+
+// config represents the rate configuration, swapped as a whole so that the rate and
+// the maximum allowance are always observed together.
+type config struct {
+	rate, max         uint64
+	from, start, ramp uint64 // optional transition from a previous rate, in ns
+	version           uint64 // version stamped by the control plane, see ApplyIfNewer()
+	sequence          uint64 // number of updates applied, see Sequence()
+}
+
+// at returns the effective rate and maximum allowance at the specified time
+func (c *config) at(now, unit uint64) (rate, max uint64) {
+	if c.ramp == 0 || now >= c.start+c.ramp {
+		return c.rate, c.max
+	}
+
+	var progress float64
+	if now > c.start {
+		progress = float64(now-c.start) / float64(c.ramp)
+	}
+
+	rate = uint64(float64(c.from) + (float64(c.rate)-float64(c.from))*progress)
+	return rate, rate * unit
+}
+
+// newConfig creates a new configuration for a rate and a unit size
+func newConfig(rate, unit uint64) *config {
+	return &config{
+		rate: rate,        // store the rate
+		max:  rate * unit, // remember our maximum allowance
+	}
+}
+
+// New creates a new rate limiter instance. A rate or period which is not positive is
+// silently replaced, see NewChecked() or NewLimiter() to validate them instead.
+func New(rate int, per time.Duration, options ...Option) *Limiter {
 	nano := uint64(per)
 	if nano < 1 {
 		nano = uint64(time.Second)
@@ -25,58 +142,394 @@ func New(rate int, per time.Duration) *Limiter {
 		rate = 1
 	}
 
-	return &Limiter{
-		rate:      uint64(rate),        // store the rate
-		allowance: uint64(rate) * nano, // set our allowance to max in the beginning
-		max:       uint64(rate) * nano, // remember our maximum allowance
-		unit:      nano,                // remember our unit size
-		lastCheck: unixNano(),
+	rl := &Limiter{
+		unit: nano, // remember our unit size
+	}
+
+	if len(options) > 0 {
+		rl.extend().options = options
+	}
+	for _, opt := range options {
+		opt(rl)
+	}
+
+	// If we follow a schedule, start with the rate currently applicable
+	e := rl.ext()
+	if s := e.schedule; s != nil {
+		now := time.Unix(0, int64(rl.now()))
+		rate = s.RateAt(now)
+		s.next.Store(s.boundary(now))
+	}
+
+	if e.startup != nil {
+		e.startup.until = rl.now() + e.startup.period
+	}
+	if e.standing != nil {
+		e.standing.last.Store(rl.now()) // standing is earned from the creation
+	}
+	if e.expiry != nil {
+		e.expiry.last.Store(rl.now()) // idle from the creation
 	}
+
+	rl.lastCheck.Store(rl.now())
+	rl.config.Store(newConfig(uint64(rate), nano))
+	_, max := rl.limits(rl.now())
+	rl.allowance.Store(max) // set our allowance to max in the beginning
+	if e.initial != nil && *e.initial*rl.unit < max {
+		rl.allowance.Store(*e.initial * rl.unit)
+	}
+	if e.tags != nil {
+		rl.retag(rate)
+	}
+	return rl
+}
+
+// Name returns the name of the limiter, if any.
+func (rl *Limiter) Name() string {
+	return rl.ext().name
 }
 
-// UpdateRate allows to update the allowed rate
+// UpdateRate allows to update the allowed rate. When the rate is lowered, any allowance
+// accumulated above the new maximum is dropped immediately. If the limiter was created
+// with WithRamp(), the rate transitions gradually instead.
 func (rl *Limiter) UpdateRate(rate int) {
-	atomic.StoreUint64(&rl.rate, uint64(rate))
-	atomic.StoreUint64(&rl.max, uint64(rate)*rl.unit)
+	rl.configure(rate, func(current *config) (uint64, bool) {
+		return current.version, true // keep the version of the configuration
+	})
+}
+
+// configure replaces the rate configuration, unless the accept function rejects the
+// current one, and returns whether it was replaced. The accept function returns the
+// version of the new configuration.
+func (rl *Limiter) configure(rate int, accept func(current *config) (uint64, bool)) bool {
+	now := rl.now()
+	for {
+		current := rl.load()
+		version, ok := accept(current)
+		if !ok {
+			return false
+		}
+
+		cfg := newConfig(uint64(rate), rl.unit)
+		cfg.version = version
+		cfg.sequence = current.sequence + 1
+		if ramp := rl.ext().ramp; ramp > 0 {
+			cfg.from, _ = current.at(now, rl.unit)
+			cfg.start = now
+			cfg.ramp = uint64(ramp)
+		}
+
+		if rl.config.CompareAndSwap(current, cfg) {
+			break
+		}
+	}
+
+	_, max := rl.limits(now)
+	rl.clamp(max)
+	rl.wake()
+	if rl.ext().tags != nil {
+		rl.retag(rate)
+	}
+	rl.rescope(rate)
+	return true
+}
+
+// WithRamp makes rate updates transition linearly from the current rate to the new one
+// over the specified duration, so downstream systems see a smooth change instead of a
+// step.
+func WithRamp(d time.Duration) Option {
+	return func(rl *Limiter) {
+		rl.extend().ramp = d
+	}
+}
+
+// clamp ensures the allowance does not exceed the maximum
+func (rl *Limiter) clamp(max uint64) {
+	for {
+		current := rl.allowance.Load()
+		if current <= max || rl.allowance.CompareAndSwap(current, max) {
+			return
+		}
+	}
+}
+
+// load returns the current configuration
+func (rl *Limiter) load() *config {
+	return rl.config.Load()
+}
+
+// limits returns the effective rate and maximum allowance at the specified time
+func (rl *Limiter) limits(now uint64) (rate, max uint64) {
+	rate, max = rl.load().at(now, rl.unit)
+	e := rl.optional.Load()
+	if e == nil {
+		return // no optional feature caps the allowance
+	}
+
+	if e.capacity > 0 {
+		max = e.capacity * rl.unit
+	}
+	if e.standing != nil {
+		max = e.standing.cap(now, rl.unit)
+	}
+	if e.startup != nil {
+		max = e.startup.cap(now, max, rl.unit)
+	}
+	if e.strict && max > rl.unit {
+		max = rl.unit // a single token can be accumulated
+	}
+	return
 }
 
 // Limit returns true if rate was exceeded
 func (rl *Limiter) Limit() bool {
+	return rl.record(rl.limit(), 1)
+}
+
+// limit returns true if rate was exceeded, without recording the decision
+func (rl *Limiter) limit() bool {
+	// If our allowance is less than one unit, rate-limit!
+	if current := rl.refill(); current < rl.unit+rl.headroom() {
+		return true
+	}
+
+	// Not limited, subtract a unit
+	rl.allowance.Add(-rl.unit)
+	return rl.inherit(1)
+}
+
+// LimitN returns true if rate would be exceeded by n calls at once, otherwise it
+// consumes n tokens. It is typically used for operations with varying costs.
+func (rl *Limiter) LimitN(n int) bool {
+	if n < 1 {
+		return false
+	}
+
+	return rl.record(rl.limitN(uint64(n)), uint64(n))
+}
+
+// limitN returns true if rate would be exceeded by n calls, without recording the decision
+func (rl *Limiter) limitN(n uint64) bool {
+	return rl.consume(n, rl.headroom())
+}
+
+// consume returns true if n calls would leave less than the reserved allowance, otherwise
+// it consumes n units
+func (rl *Limiter) consume(n, reserved uint64) bool {
+	if !rl.fits(rl.refill(), n, reserved) {
+		return true
+	}
+
+	rl.allowance.Add(-(n * rl.unit))
+	return rl.inherit(n)
+}
+
+// Peek returns true if a call to Limit() would currently be limited, without consuming
+// any allowance.
+func (rl *Limiter) Peek() bool {
+	return rl.PeekN(1)
+}
+
+// PeekN returns true if a call to LimitN(n) would currently be limited, without consuming
+// any allowance.
+func (rl *Limiter) PeekN(n int) bool {
+	if n < 1 {
+		return false
+	}
+
+	return !rl.fits(rl.refill(), uint64(n), rl.headroom())
+}
+
+// fits returns whether n tokens fit within the allowance above the reserved one, without
+// multiplying n which may be large enough to overflow
+func (rl *Limiter) fits(current, n, reserved uint64) bool {
+	return current >= reserved && n <= (current-reserved)/rl.unit
+}
+
+// units returns n tokens in units of allowance, saturating instead of overflowing
+func (rl *Limiter) units(n uint64) uint64 {
+	return accrue(n, rl.unit)
+}
+
+// Remaining returns the number of calls which would currently be allowed.
+func (rl *Limiter) Remaining() int {
+	if available := rl.refill(); available > rl.headroom() {
+		return int((available - rl.headroom()) / rl.unit)
+	}
+	return 0
+}
+
+// NextAllowed returns the earliest time at which n tokens will be available, without
+// reserving them. If n exceeds the maximum allowance, the zero time is returned as the
+// call can never be admitted.
+func (rl *Limiter) NextAllowed(n int) time.Time {
+	if n < 1 {
+		n = 0
+	}
+
+	now := rl.now()
+	d, ok := rl.until(now, rl.units(uint64(n)))
+	if !ok {
+		return time.Time{}
+	}
+
+	return time.Unix(0, int64(now)).Add(d)
+}
+
+// UntilFull returns how long until the allowance is fully restored, assuming no further
+// calls are made in the meantime.
+func (rl *Limiter) UntilFull() time.Duration {
+	now := rl.now()
+	_, max := rl.limits(now)
+	d, ok := rl.until(now, max)
+	if !ok {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return d
+}
+
+// until returns the time needed for the allowance to reach the target, if it ever does
+func (rl *Limiter) until(now, target uint64) (time.Duration, bool) {
+	current := rl.refill()
+	rate, max := rl.limits(now)
+	switch {
+	case current >= target:
+		return 0, true
+	case target > max || rate == 0:
+		return 0, false
+	default:
+		return time.Duration((target - current + rate - 1) / rate), true
+	}
+}
+
+// take consumes up to n tokens of allowance at once and returns how many were consumed
+func (rl *Limiter) take(n uint64) uint64 {
+	rl.refill()
+	for {
+		current := rl.allowance.Load()
+		taken := minUint64(current/rl.unit, n)
+		if rl.allowance.CompareAndSwap(current, current-taken*rl.unit) {
+			return rl.inheritUpTo(taken)
+		}
+	}
+}
+
+// refill adds the allowance accrued since the last check and returns it
+func (rl *Limiter) refill() uint64 {
 	// Calculate the number of ns that have passed since our last call
-	now := unixNano()
-	passed := now - atomic.SwapUint64(&rl.lastCheck, now)
+	now := rl.now()
+	if rl.ext().schedule != nil {
+		rl.reschedule(now)
+	}
+
+	passed := rl.advance(now)
 
 	// Add them to our allowance
-	rate := atomic.LoadUint64(&rl.rate)
-	current := atomic.AddUint64(&rl.allowance, passed*rate)
+	rate, max, blocked := rl.effective(now)
+	if blocked {
+		return 0 // fully blocked during the cooldown
+	}
+
+	refilled := accrue(passed, rate)
+	if rl.debt.Load() > 0 {
+		refilled = rl.repay(refilled)
+	}
+	if refilled > max {
+		refilled = max // never overflows the allowance, which is capped at max anyway
+	}
+
+	current := rl.allowance.Add(refilled)
 
 	// Ensure our allowance is not over maximum
-	if max := atomic.LoadUint64(&rl.max); current > max {
-		atomic.AddUint64(&rl.allowance, max-current)
+	if current > max {
+		rl.allowance.Add(max - current)
 		current = max
 	}
 
-	// If our allowance is less than one unit, rate-limit!
-	if current < rl.unit {
-		return true
+	if x := rl.ext().expiry; x != nil {
+		current = x.expire(rl, now, current)
 	}
+	return current
+}
 
-	// Not limited, subtract a unit
-	atomic.AddUint64(&rl.allowance, -rl.unit)
-	return false
+// advance moves the time of the last refill forward to now and returns the ns elapsed
+// since, which is zero if a concurrent refill already went further or the clock stepped
+// back, so that an out of order refill never wraps around
+func (rl *Limiter) advance(now uint64) uint64 {
+	for {
+		last := rl.lastCheck.Load()
+		if now <= last {
+			return 0
+		}
+		if rl.lastCheck.CompareAndSwap(last, now) {
+			return now - last
+		}
+	}
 }
 
-// Undo reverts the last Limit() call, returning consumed allowance
+// accrue returns the sub-units of allowance accrued over the elapsed nanoseconds at the
+// rate, saturating instead of overflowing after long idle periods at high rates
+func accrue(elapsed, rate uint64) uint64 {
+	if hi, lo := bits.Mul64(elapsed, rate); hi == 0 {
+		return lo
+	}
+	return math.MaxUint64
+}
+
+// effective returns the rate at which the allowance is refilled at the specified time,
+// once the reserved rate and any penalty are applied, along with the maximum allowance
+// and whether a penalty blocks every call
+func (rl *Limiter) effective(now uint64) (rate, max uint64, blocked bool) {
+	rate, max = rl.limits(now)
+	if r := rl.reserved.Load(); r > 0 {
+		rate -= minUint64(r, rate) // leave the reserved rate to the budgets
+	}
+	if p := rl.ext().penalty; p != nil && p.active(now) {
+		if p.factor <= 0 {
+			return 0, max, true
+		}
+		rate = uint64(float64(rate) * p.factor)
+	}
+	return rate, max, false
+}
+
+// Undo reverts the last Limit() call, returning consumed allowance. It is accounted as a
+// refund with the "undo" reason, see Refund().
 func (rl *Limiter) Undo() {
-	current := atomic.AddUint64(&rl.allowance, rl.unit)
+	rl.RefundN("undo", 1)
+}
 
-	// Ensure our allowance is not over maximum
-	if max := atomic.LoadUint64(&rl.max); current > max {
-		atomic.AddUint64(&rl.allowance, max-current)
+// refund returns n units of allowance, without exceeding the maximum
+func (rl *Limiter) refund(n uint64) {
+	_, max := rl.limits(rl.now())
+	units := rl.units(n)
+	for {
+		current, next := rl.allowance.Load(), max
+		if current < max && units < max-current {
+			next = current + units
+		}
+		if rl.allowance.CompareAndSwap(current, next) {
+			break
+		}
+	}
+	rl.wake()
+}
+
+// now returns the current time of the limiter's clock as unix nanoseconds
+func (rl *Limiter) now() uint64 {
+	if rl.clock == nil {
+		return unixNano()
 	}
+
+	return uint64(rl.clock.Now())
 }
 
-// now as unix nanoseconds
-func unixNano() uint64 {
-	return uint64(time.Now().UnixNano())
+// derive creates a limiter of the rate over the same period and on the same clock as the
+// limiter, with the options applied after the clock
+func (rl *Limiter) derive(rate int, options ...Option) *Limiter {
+	if rl.clock != nil {
+		options = append([]Option{WithClock(rl.clock)}, options...)
+	}
+	return New(rate, time.Duration(rl.unit), options...)
 }
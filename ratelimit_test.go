@@ -6,9 +6,12 @@
 package rate
 
 import (
+	"math"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -118,6 +121,234 @@ var _ = Describe("Limiter", func() {
 		Expect(count).To(Equal(15))
 	})
 
+	It("should clamp allowance when lowering rate", func() {
+		var count int
+		rl := New(10, time.Minute)
+		rl.UpdateRate(3)
+		Expect(rl.allowance.Load()).To(Equal(3 * rl.unit))
+
+		for !rl.Limit() {
+			count++
+		}
+		Expect(count).To(Equal(3))
+	})
+
+	It("should ramp when updating rate", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(10, time.Second, WithClock(clock), WithRamp(10*time.Second))
+		rate := func() uint64 {
+			rate, _ := rl.limits(rl.now())
+			return rate
+		}
+
+		rl.UpdateRate(110)
+		Expect(rate()).To(Equal(uint64(10)))
+
+		clock.Advance(5 * time.Second)
+		_, max := rl.limits(rl.now())
+		Expect(rate()).To(Equal(uint64(60)))
+		Expect(max).To(Equal(60 * rl.unit))
+
+		rl.UpdateRate(10)
+		Expect(rate()).To(Equal(uint64(60)))
+
+		clock.Advance(5 * time.Second)
+		Expect(rate()).To(Equal(uint64(35)))
+
+		clock.Advance(time.Hour)
+		Expect(rate()).To(Equal(uint64(10)))
+	})
+
+	It("should update rate and maximum together", func() {
+		rl := New(5, time.Second)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 1; i <= 1000; i++ {
+				rl.UpdateRate(i)
+			}
+		}()
+
+		for running := true; running; {
+			select {
+			case <-done:
+				running = false
+			default:
+				rl.Limit()
+				cfg := rl.load()
+				Expect(cfg.max).To(Equal(cfg.rate * rl.unit))
+			}
+		}
+	})
+
+	It("should limit multiple calls at once", func() {
+		rl := New(10, time.Minute)
+		Expect(rl.LimitN(4)).To(BeFalse())
+		Expect(rl.LimitN(4)).To(BeFalse())
+		Expect(rl.LimitN(4)).To(BeTrue())
+		Expect(rl.LimitN(0)).To(BeFalse())
+		Expect(rl.LimitN(2)).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+	})
+
+	It("should peek without consuming allowance", func() {
+		rl := New(3, time.Minute)
+		Expect(rl.Peek()).To(BeFalse())
+		Expect(rl.PeekN(3)).To(BeFalse())
+		Expect(rl.PeekN(4)).To(BeTrue())
+		Expect(rl.PeekN(0)).To(BeFalse())
+
+		Expect(rl.LimitN(3)).To(BeFalse())
+		Expect(rl.Peek()).To(BeTrue())
+		Expect(rl.Stats()).To(Equal(Stats{Allowed: 3}))
+	})
+
+	It("should return when tokens will be available", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock))
+		Expect(rl.NextAllowed(10)).To(BeTemporally("==", time.Unix(0, 0)))
+		Expect(rl.NextAllowed(11)).To(BeZero())
+		Expect(rl.LimitN(10)).To(BeFalse())
+
+		Expect(rl.NextAllowed(1)).To(BeTemporally("==", time.Unix(0, 0).Add(100*time.Millisecond)))
+		Expect(rl.NextAllowed(5)).To(BeTemporally("==", time.Unix(0, 0).Add(500*time.Millisecond)))
+
+		clock.Advance(200 * time.Millisecond)
+		Expect(rl.Peek()).To(BeFalse())
+		Expect(rl.NextAllowed(5)).To(BeTemporally("==", time.Unix(0, 0).Add(500*time.Millisecond)))
+	})
+
+	It("should return when the allowance is fully restored", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock))
+		Expect(rl.UntilFull()).To(BeZero())
+		Expect(rl.LimitN(4)).To(BeFalse())
+		Expect(rl.UntilFull()).To(Equal(400 * time.Millisecond))
+
+		clock.Advance(300 * time.Millisecond)
+		Expect(rl.UntilFull()).To(Equal(100 * time.Millisecond))
+	})
+
+	It("should return the remaining allowance", func() {
+		rl := New(10, time.Minute)
+		Expect(rl.Remaining()).To(Equal(10))
+		Expect(rl.LimitN(7)).To(BeFalse())
+		Expect(rl.Remaining()).To(Equal(3))
+	})
+
+	It("should align atomic fields on 64-bit boundaries", func() {
+		var rl Limiter
+		Expect(unsafe.Offsetof(rl.allowance) % 8).To(BeZero())
+		Expect(unsafe.Offsetof(rl.lastCheck) % 8).To(BeZero())
+		Expect(unsafe.Alignof(rl.allowance)).To(BeEquivalentTo(8))
+		Expect(unsafe.Alignof(rl.lastCheck)).To(BeEquivalentTo(8))
+	})
+
+	It("should not allocate the extensions without optional features", func() {
+		rl := New(10, time.Minute)
+		Expect(rl.LimitN(3)).To(BeFalse())
+		rl.UpdateRate(20)
+		rl.Undo()
+		Expect(rl.Closed()).To(BeFalse())
+		Expect(rl.Children()).To(BeEmpty())
+		Expect(rl.optional.Load()).To(BeNil())
+		Expect(int(unsafe.Sizeof(*rl))).To(BeNumerically("<=", 256))
+
+		Expect(New(10, time.Minute, WithName("x")).optional.Load()).NotTo(BeNil())
+	})
+
+	It("should accrue tiny rates precisely over days", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(1, 6*time.Hour, WithClock(clock), WithBurst(200), WithStartEmpty())
+
+		// Refill at an awkward step, so the refills never align with the tokens
+		const step = 7*time.Minute + 13*time.Second + 123456789
+		var elapsed time.Duration
+		for elapsed+step <= 30*24*time.Hour {
+			clock.Advance(step)
+			elapsed += step
+			rl.Remaining()
+		}
+
+		Expect(rl.Remaining()).To(Equal(int(elapsed / (6 * time.Hour))))
+		Expect(rl.UntilFull()).To(Equal(200*6*time.Hour - elapsed))
+	})
+
+	It("should accrue a token exactly at the end of a long period", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(1, 6*time.Hour, WithClock(clock))
+		Expect(rl.Limit()).To(BeFalse())
+
+		clock.Advance(6*time.Hour - 1)
+		Expect(rl.Limit()).To(BeTrue())
+		clock.Advance(1)
+		Expect(rl.Limit()).To(BeFalse())
+	})
+
+	It("should not overflow after long idle periods at high rates", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(1000000000, time.Second, WithClock(clock))
+		Expect(rl.LimitN(1000000000)).To(BeFalse())
+
+		for _, idle := range []time.Duration{time.Minute, 24 * time.Hour, 365 * 24 * time.Hour} {
+			clock.Advance(idle)
+			Expect(rl.Remaining()).To(Equal(1000000000))
+			Expect(rl.LimitN(1000000000)).To(BeFalse())
+		}
+	})
+
+	It("should saturate the accrued allowance", func() {
+		Expect(accrue(3, 4)).To(Equal(uint64(12)))
+		Expect(accrue(math.MaxUint64/2, 3)).To(Equal(uint64(math.MaxUint64)))
+	})
+
+	It("should not overflow with huge numbers of tokens", func() {
+		const huge = math.MaxInt32 // huge * 1h overflows the allowance
+		rl := New(10, time.Hour)
+		Expect(rl.LimitN(huge)).To(BeTrue())
+		Expect(rl.PeekN(huge)).To(BeTrue())
+		Expect(rl.LimitCriticalN(huge)).To(BeTrue())
+		Expect(rl.Decide(huge).Reason).To(Equal(ReasonBurst))
+		Expect(rl.NextAllowed(huge).IsZero()).To(BeTrue())
+		Expect(rl.Remaining()).To(Equal(10))
+
+		rl.ReportCost(huge, 0)
+		Expect(rl.Remaining()).To(Equal(10))
+		rl.ReportCost(0, math.MaxInt)
+		Expect(rl.Remaining()).To(BeZero())
+		Expect(rl.Debt()).To(BeNumerically(">", 5000000)) // saturated rather than wrapped around
+	})
+
+	It("should not refill when the last refill is ahead of the clock", func() {
+		clock := NewManualClock(time.Unix(100, 0))
+		rl := New(1000, time.Hour, WithClock(clock), WithStartEmpty())
+		rl.lastCheck.Store(rl.now() + uint64(time.Second)) // a concurrent refill or a clock step back
+
+		Expect(rl.Limit()).To(BeTrue())
+		Expect(rl.Remaining()).To(BeZero())
+	})
+
+	It("should not refill an empty bucket under concurrency", func() {
+		rl := New(1000, time.Hour, WithStartEmpty())
+
+		var admitted atomic.Int64
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10000; j++ {
+					if !rl.Limit() {
+						admitted.Add(1)
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+		Expect(admitted.Load()).To(BeNumerically("<=", 1))
+	})
+
 })
 
 // --------------------------------------------------------------------
@@ -131,6 +362,16 @@ func BenchmarkLimit(b *testing.B) {
 	}
 }
 
+// BenchmarkReference is the floor of the cost of a decision, reading the time and
+// updating a counter, against which the budgets of the hot paths are expressed, see the
+// "perf" build tag
+func BenchmarkReference(b *testing.B) {
+	var counter atomic.Uint64
+	for i := 0; i < b.N; i++ {
+		counter.Add(uint64(time.Now().UnixNano()))
+	}
+}
+
 // --------------------------------------------------------------------
 
 func TestGinkgoSuite(t *testing.T) {
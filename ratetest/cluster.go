@@ -0,0 +1,188 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package ratetest
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kelindar/rate"
+)
+
+// ErrPartitioned is returned by the store to the instances which are partitioned away.
+var ErrPartitioned = errors.New("ratetest: instance is partitioned from the store")
+
+// ---------------------------------- Store ----------------------------------
+
+// Store is a fake quota store shared by the instances of a cluster, with an injectable
+// latency and partitions. Store instances are thread-safe.
+type Store struct {
+	lock        sync.Mutex
+	store       rate.QuotaStore // backing in-memory store
+	latency     time.Duration   // delay of every call
+	partitioned map[int]bool    // instances which can not reach the store
+	calls       int             // number of calls which reached the store
+}
+
+// NewStore creates a new fake quota store, backed by a rate.MemoryStore.
+func NewStore() *Store {
+	return &Store{
+		store:       rate.NewMemoryStore(),
+		partitioned: make(map[int]bool),
+	}
+}
+
+// SetLatency sets the delay of every call made to the store, in real time.
+func (s *Store) SetLatency(d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.latency = d
+}
+
+// Calls returns the number of calls which reached the store.
+func (s *Store) Calls() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.calls
+}
+
+// For returns the view of the store of the instance, which fails with ErrPartitioned
+// while the instance is partitioned away.
+func (s *Store) For(instance int) rate.QuotaStore {
+	return &view{store: s, instance: instance}
+}
+
+// incr increments the counter of the window on behalf of the instance
+func (s *Store) incr(instance int, key string, window time.Time, n int) (int, error) {
+	s.lock.Lock()
+	latency, partitioned := s.latency, s.partitioned[instance]
+	s.lock.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if partitioned {
+		return 0, ErrPartitioned
+	}
+
+	s.lock.Lock()
+	s.calls++
+	s.lock.Unlock()
+	return s.store.Incr(key, window, n)
+}
+
+// view represents the store as seen by a single instance
+type view struct {
+	store    *Store
+	instance int
+}
+
+// Incr increments the counter of the window by n and returns the new count.
+func (v *view) Incr(key string, window time.Time, n int) (int, error) {
+	return v.store.incr(v.instance, key, window, n)
+}
+
+// ---------------------------------- Cluster ----------------------------------
+
+// Cluster represents several in-process instances of a distributed limiter sharing a
+// fake store and a clock, so that distributed limiting can be verified in unit tests,
+// including its accuracy while instances are partitioned away or fail.
+type Cluster struct {
+	Store     *Store
+	Clock     *rate.ManualClock
+	instances []*rate.Distributed
+}
+
+// NewCluster creates n instances of a distributed limiter allowing the rate per period
+// globally under the key. The instances only sync with the store when Sync() is called or
+// once their leased slice runs out, so the tests remain deterministic.
+func NewCluster(n int, key string, limit int, per time.Duration) *Cluster {
+	c := &Cluster{
+		Store: NewStore(),
+		Clock: NewClock(),
+	}
+
+	for i := 0; i < n; i++ {
+		c.instances = append(c.instances, rate.NewDistributed(c.Store.For(i), key, limit, per,
+			time.Hour, rate.WithDistributedClock(c.Clock)))
+	}
+	return c
+}
+
+// Instance returns the i-th instance of the cluster.
+func (c *Cluster) Instance(i int) *rate.Distributed {
+	return c.instances[i]
+}
+
+// Len returns the number of instances of the cluster.
+func (c *Cluster) Len() int {
+	return len(c.instances)
+}
+
+// Partition cuts the i-th instance off the store, it then only serves its leased slice.
+func (c *Cluster) Partition(i int) {
+	c.Store.lock.Lock()
+	defer c.Store.lock.Unlock()
+	c.Store.partitioned[i] = true
+}
+
+// Heal reconnects the i-th instance to the store.
+func (c *Cluster) Heal(i int) {
+	c.Store.lock.Lock()
+	defer c.Store.lock.Unlock()
+	delete(c.Store.partitioned, i)
+}
+
+// Fail closes the i-th instance, as if it crashed, the tokens it leased are lost for the
+// current window.
+func (c *Cluster) Fail(i int) {
+	c.instances[i].Close()
+}
+
+// Sync syncs every instance with the store and returns the first error, if any. Failed
+// instances are skipped.
+func (c *Cluster) Sync() (err error) {
+	for _, d := range c.instances {
+		if e := d.Sync(); e != nil && e != rate.ErrClosed && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Allowed makes the calls spread round-robin over the instances which did not fail,
+// syncing an instance with the store whenever it runs out of leased tokens, and returns
+// the number of calls allowed across the cluster.
+func (c *Cluster) Allowed(calls int) (allowed int) {
+	active := make([]*rate.Distributed, 0, len(c.instances))
+	for _, d := range c.instances {
+		if d.Sync() != rate.ErrClosed {
+			active = append(active, d)
+		}
+	}
+
+	for i := 0; i < calls && len(active) > 0; i++ {
+		d := active[i%len(active)]
+		if !d.Limit() {
+			allowed++
+			continue
+		}
+
+		if d.Sync() == nil && !d.Limit() {
+			allowed++
+		}
+	}
+	return
+}
+
+// Close closes every instance of the cluster.
+func (c *Cluster) Close() error {
+	for _, d := range c.instances {
+		d.Close()
+	}
+	return nil
+}
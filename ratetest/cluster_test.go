@@ -0,0 +1,70 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package ratetest
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cluster", func() {
+
+	It("should enforce the global rate across instances", func() {
+		c := NewCluster(3, "api", 30, time.Second)
+		defer c.Close()
+
+		Expect(c.Len()).To(Equal(3))
+		Expect(c.Allowed(100)).To(Equal(30))
+
+		c.Clock.Advance(time.Second)
+		Expect(c.Allowed(100)).To(Equal(30))
+	})
+
+	It("should stay accurate while an instance is partitioned", func() {
+		c := NewCluster(2, "api", 10, time.Second)
+		defer c.Close()
+
+		c.Partition(0)
+		Expect(c.Allowed(100)).To(Equal(10))
+		Expect(c.Instance(0).Err()).To(Equal(ErrPartitioned))
+
+		c.Clock.Advance(time.Second)
+		Expect(c.Allowed(100)).To(Equal(10))
+
+		c.Heal(0)
+		Expect(c.Sync()).To(Succeed())
+		c.Clock.Advance(time.Second)
+		Expect(c.Allowed(100)).To(Equal(10))
+	})
+
+	It("should fail over to the other instances", func() {
+		c := NewCluster(2, "api", 10, time.Second)
+		defer c.Close()
+
+		c.Fail(0)
+		Expect(c.Allowed(100)).To(Equal(0))
+
+		c.Clock.Advance(time.Second)
+		Expect(c.Allowed(100)).To(Equal(10))
+		Expect(c.Sync()).To(Succeed())
+	})
+
+	It("should delay the calls to the store", func() {
+		c := NewCluster(1, "api", 10, time.Second)
+		defer c.Close()
+
+		c.Store.SetLatency(20 * time.Millisecond)
+		c.Clock.Advance(time.Second)
+		Expect(c.Instance(0).LimitN(10)).To(BeFalse())
+
+		start := time.Now()
+		Expect(c.Sync()).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 20*time.Millisecond))
+		Expect(c.Store.Calls()).To(BeNumerically(">=", 2))
+	})
+})
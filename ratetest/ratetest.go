@@ -0,0 +1,137 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+// Package ratetest provides utilities for testing rate limiter configurations
+// deterministically, without relying on the wall clock.
+package ratetest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kelindar/rate"
+)
+
+// TB is the subset of testing.TB used by the assertions.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Factory creates a limiter under test, driven by the provided clock. It typically
+// passes rate.WithClock(clock) along with the options of the configuration under test.
+type Factory func(clock rate.Clock) *rate.Limiter
+
+// NewClock returns a fake clock which only advances when told to.
+func NewClock() *rate.ManualClock {
+	return rate.NewManualClock(time.Unix(0, 0))
+}
+
+// ---------------------------------- Recorder ----------------------------------
+
+// Decision represents a single recorded decision of a limiter.
+type Decision struct {
+	At      time.Time // The time of the decision, according to the clock
+	Limited bool      // Whether the call was rate-limited
+}
+
+// Recorder is a limiter which records every decision it made.
+type Recorder struct {
+	*rate.Limiter
+	clock     rate.Clock
+	lock      sync.Mutex
+	decisions []Decision
+}
+
+// NewRecorder creates a new recording limiter, using the factory and the clock provided.
+func NewRecorder(clock rate.Clock, fn Factory) *Recorder {
+	return &Recorder{
+		Limiter: fn(clock),
+		clock:   clock,
+	}
+}
+
+// Limit returns true if rate was exceeded and records the decision.
+func (r *Recorder) Limit() bool {
+	limited := r.Limiter.Limit()
+	r.lock.Lock()
+	r.decisions = append(r.decisions, Decision{
+		At:      time.Unix(0, r.clock.Now()),
+		Limited: limited,
+	})
+	r.lock.Unlock()
+	return limited
+}
+
+// Decisions returns a copy of all of the recorded decisions, in order.
+func (r *Recorder) Decisions() []Decision {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]Decision(nil), r.decisions...)
+}
+
+// Allowed returns the number of recorded calls which were not rate-limited.
+func (r *Recorder) Allowed() (n int) {
+	for _, d := range r.Decisions() {
+		if !d.Limited {
+			n++
+		}
+	}
+	return
+}
+
+// ---------------------------------- Assertions ----------------------------------
+
+// windows is the number of windows over which the sustained rate is asserted
+const windows = 10
+
+// AssertBurst asserts that a freshly created limiter admits exactly n calls at once.
+func AssertBurst(t TB, fn Factory, n int) bool {
+	t.Helper()
+	rl := fn(NewClock())
+	if admitted := drain(rl); admitted != n {
+		t.Errorf("ratetest: expected a burst of %d calls, got %d", n, admitted)
+		return false
+	}
+	return true
+}
+
+// AssertRate asserts that, once its initial burst is exhausted, the limiter sustains
+// n admitted calls per window of the specified duration when flooded with calls.
+func AssertRate(t TB, fn Factory, n int, per time.Duration) bool {
+	t.Helper()
+	clock := NewClock()
+	rl := fn(clock)
+	drain(rl)
+
+	// Flood the limiter at 10 times the expected rate, counting the admitted calls
+	step := per / time.Duration(10*n)
+	if step <= 0 {
+		step = 1
+	}
+
+	ok := true
+	for w := 0; w < windows; w++ {
+		var admitted int
+		for elapsed := time.Duration(0); elapsed < per; elapsed += step {
+			clock.Advance(step)
+			admitted += drain(rl)
+		}
+
+		if admitted < n-1 || admitted > n+1 {
+			t.Errorf("ratetest: expected %d calls per %v in window %d, got %d", n, per, w, admitted)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// drain calls the limiter until it rate-limits and returns the number of admitted calls
+func drain(rl *rate.Limiter) (n int) {
+	for !rl.Limit() {
+		n++
+	}
+	return
+}
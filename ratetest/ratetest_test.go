@@ -0,0 +1,75 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package ratetest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kelindar/rate"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ratetest", func() {
+	factory := func(n int, per time.Duration) Factory {
+		return func(clock rate.Clock) *rate.Limiter {
+			return rate.New(n, per, rate.WithClock(clock))
+		}
+	}
+
+	It("should assert the burst", func() {
+		t := new(fakeT)
+		Expect(AssertBurst(t, factory(10, time.Second), 10)).To(BeTrue())
+		Expect(AssertBurst(t, factory(10, time.Second), 5)).To(BeFalse())
+		Expect(t.errors).To(HaveLen(1))
+	})
+
+	It("should assert the rate", func() {
+		t := new(fakeT)
+		Expect(AssertRate(t, factory(100, time.Second), 100, time.Second)).To(BeTrue())
+		Expect(AssertRate(t, factory(7, 350*time.Millisecond), 20, time.Second)).To(BeTrue())
+		Expect(t.errors).To(BeEmpty())
+
+		Expect(AssertRate(t, factory(100, time.Second), 50, time.Second)).To(BeFalse())
+		Expect(t.errors).NotTo(BeEmpty())
+	})
+
+	It("should record decisions", func() {
+		clock := NewClock()
+		r := NewRecorder(clock, factory(2, time.Second))
+		Expect(r.Limit()).To(BeFalse())
+		clock.Advance(time.Millisecond)
+		Expect(r.Limit()).To(BeFalse())
+		Expect(r.Limit()).To(BeTrue())
+
+		Expect(r.Allowed()).To(Equal(2))
+		Expect(r.Decisions()).To(Equal([]Decision{
+			{At: time.Unix(0, 0)},
+			{At: time.Unix(0, int64(time.Millisecond))},
+			{At: time.Unix(0, int64(time.Millisecond)), Limited: true},
+		}))
+	})
+
+})
+
+// fakeT records the assertion failures
+type fakeT struct {
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+// --------------------------------------------------------------------
+
+func TestGinkgoSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "github.com/kelindar/rate/ratetest")
+}
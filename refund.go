@@ -0,0 +1,82 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync/atomic"
+)
+
+// refunds represents the tokens given back to a limiter, per reason. The reasons are set
+// on creation, so that they are counted without any lock and cannot grow unbounded.
+type refunds struct {
+	undo    atomic.Uint64             // tokens given back by Undo()
+	other   atomic.Uint64             // tokens given back with a reason not registered
+	reasons map[string]*atomic.Uint64 // tokens given back per registered reason
+}
+
+// WithRefundReasons registers the reasons which Refunds() accounts separately, such as
+// "cache-hit" or "validation-failed". The tokens given back with any other reason, except
+// for the "undo" reason of Undo(), are accounted under the "other" reason.
+func WithRefundReasons(reasons ...string) Option {
+	return func(rl *Limiter) {
+		if rl.refunds.reasons == nil {
+			rl.refunds.reasons = make(map[string]*atomic.Uint64, len(reasons))
+		}
+		for _, reason := range reasons {
+			rl.refunds.reasons[reason] = new(atomic.Uint64)
+		}
+	}
+}
+
+// Refund gives a token consumed by an operation back to the limiter, as Undo() does, and
+// accounts it under the reason, see WithRefundReasons().
+func (rl *Limiter) Refund(reason string) {
+	rl.RefundN(reason, 1)
+}
+
+// RefundN gives n tokens back to the limiter and accounts them under the reason, so that
+// code paths which systematically over-acquire and refund can be found. The waiters are
+// woken up to compete for the tokens given back.
+func (rl *Limiter) RefundN(reason string, n int) {
+	if n < 1 {
+		return
+	}
+
+	switch counter := rl.refunds.reasons[reason]; {
+	case reason == "undo":
+		rl.refunds.undo.Add(uint64(n))
+	case counter != nil:
+		counter.Add(uint64(n))
+	default:
+		rl.refunds.other.Add(uint64(n))
+	}
+
+	rl.stats.undone.Add(1)
+	rl.stats.refunded.Add(uint64(n))
+	rl.refund(uint64(n))
+	if p := rl.ext().parent; p != nil {
+		p.RefundN(reason, n) // the tokens of a child were consumed from its parent too
+	}
+}
+
+// Refunds returns the number of tokens given back per reason since the limiter was
+// created, including those of Undo() under the "undo" reason and those of the reasons
+// not registered under the "other" reason. The reasons without any refund are omitted.
+func (rl *Limiter) Refunds() map[string]uint64 {
+	out := make(map[string]uint64, len(rl.refunds.reasons)+2)
+	for reason, counter := range rl.refunds.reasons {
+		if n := counter.Load(); n > 0 {
+			out[reason] = n
+		}
+	}
+	if n := rl.refunds.other.Load(); n > 0 {
+		out["other"] += n
+	}
+	if n := rl.refunds.undo.Load(); n > 0 {
+		out["undo"] += n
+	}
+	return out
+}
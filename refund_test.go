@@ -0,0 +1,77 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Refund", func() {
+
+	It("should account the refunds per reason", func() {
+		rl := New(10, time.Hour, WithRefundReasons("cache-hit", "validation-failed", "unused"))
+		rl.LimitN(10)
+		rl.Refund("cache-hit")
+		rl.RefundN("cache-hit", 2)
+		rl.RefundN("validation-failed", 3)
+		rl.RefundN("ignored", 0)
+		rl.RefundN("unregistered", 2)
+		rl.Undo()
+
+		Expect(rl.Remaining()).To(Equal(9))
+		Expect(rl.Refunds()).To(Equal(map[string]uint64{
+			"cache-hit":         3,
+			"validation-failed": 3,
+			"other":             2,
+			"undo":              1,
+		}))
+
+		stats := rl.Stats()
+		Expect(stats.Undone).To(BeEquivalentTo(5))
+		Expect(stats.Refunded).To(BeEquivalentTo(9))
+	})
+
+	It("should count the refunds without any lock", func() {
+		rl := New(10, time.Hour)
+		allocs := testing.AllocsPerRun(100, func() {
+			rl.Limit()
+			rl.Undo()
+			rl.Refund("aborted")
+		})
+		Expect(allocs).To(BeZero())
+		Expect(rl.optional.Load()).To(BeNil())
+		Expect(rl.Refunds()).To(Equal(map[string]uint64{
+			"other": 101,
+			"undo":  101,
+		}))
+	})
+
+	It("should wake up the waiters", func() {
+		rl := New(1, time.Hour)
+		rl.Limit()
+
+		done := make(chan error)
+		go func() { done <- rl.Wait(context.Background()) }()
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+
+		rl.Refund("aborted")
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	It("should merge the refunded tokens", func() {
+		a, b := New(10, time.Hour), New(10, time.Hour)
+		b.LimitN(5)
+		b.RefundN("aborted", 2)
+		a.LimitN(10)
+		a.Merge(b)
+		Expect(a.Stats().Refunded).To(BeEquivalentTo(2))
+	})
+})
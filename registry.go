@@ -0,0 +1,100 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrDuplicate is returned when registering a limiter under a name which is taken.
+var ErrDuplicate = errors.New("rate: limiter is already registered")
+
+// Default is the process-wide registry of limiters.
+var Default = NewRegistry()
+
+// Registry represents a set of limiters registered by name, so they can be enumerated
+// and shared across packages. Registry instances are thread-safe.
+type Registry struct {
+	lock     sync.RWMutex
+	limiters map[string]*Limiter
+}
+
+// NewRegistry creates a new, empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		limiters: make(map[string]*Limiter),
+	}
+}
+
+// Register adds the limiter to the registry under the specified name.
+func (r *Registry) Register(name string, rl *Limiter) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.limiters[name]; ok {
+		return ErrDuplicate
+	}
+
+	r.limiters[name] = rl
+	return nil
+}
+
+// Unregister removes the limiter registered under the specified name, if any.
+func (r *Registry) Unregister(name string) {
+	r.lock.Lock()
+	delete(r.limiters, name)
+	r.lock.Unlock()
+}
+
+// Get returns the limiter registered under the specified name.
+func (r *Registry) Get(name string) (*Limiter, bool) {
+	r.lock.RLock()
+	rl, ok := r.limiters[name]
+	r.lock.RUnlock()
+	return rl, ok
+}
+
+// GetOrCreate returns the limiter registered under the specified name, or creates and
+// registers it using the constructor function if it does not exist yet.
+func (r *Registry) GetOrCreate(name string, create func() *Limiter) *Limiter {
+	if rl, ok := r.Get(name); ok {
+		return rl
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if rl, ok := r.limiters[name]; ok {
+		return rl
+	}
+
+	rl := create()
+	r.limiters[name] = rl
+	return rl
+}
+
+// Names returns the sorted names of all of the registered limiters.
+func (r *Registry) Names() []string {
+	r.lock.RLock()
+	names := make([]string, 0, len(r.limiters))
+	for name := range r.limiters {
+		names = append(names, name)
+	}
+	r.lock.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// Range calls the function for each registered limiter, in order of their names, until
+// the function returns false.
+func (r *Registry) Range(fn func(name string, rl *Limiter) bool) {
+	for _, name := range r.Names() {
+		if rl, ok := r.Get(name); ok && !fn(name, rl) {
+			return
+		}
+	}
+}
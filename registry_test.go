@@ -0,0 +1,53 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+
+	It("should register limiters by name", func() {
+		r := NewRegistry()
+		rl := New(10, time.Second)
+		Expect(r.Register("api", rl)).To(Succeed())
+		Expect(r.Register("api", New(5, time.Second))).To(Equal(ErrDuplicate))
+
+		found, ok := r.Get("api")
+		Expect(ok).To(BeTrue())
+		Expect(found).To(BeIdenticalTo(rl))
+
+		r.Unregister("api")
+		_, ok = r.Get("api")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should create limiters on demand", func() {
+		r := NewRegistry()
+		rl := r.GetOrCreate("api", func() *Limiter { return New(10, time.Second) })
+		Expect(r.GetOrCreate("api", func() *Limiter { return nil })).To(BeIdenticalTo(rl))
+	})
+
+	It("should enumerate limiters", func() {
+		r := NewRegistry()
+		Expect(r.Register("b", New(1, time.Second))).To(Succeed())
+		Expect(r.Register("a", New(1, time.Second))).To(Succeed())
+		Expect(r.Register("c", New(1, time.Second))).To(Succeed())
+		Expect(r.Names()).To(Equal([]string{"a", "b", "c"}))
+
+		var names []string
+		r.Range(func(name string, rl *Limiter) bool {
+			names = append(names, name)
+			return name != "b"
+		})
+		Expect(names).To(Equal([]string{"a", "b"}))
+	})
+
+})
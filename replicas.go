@@ -0,0 +1,66 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+)
+
+// SplitRate keeps the limiter at an equal share of a global rate across the replicas of
+// a deployment, so scaling it does not silently multiply the effective global limit. The
+// function returns the current number of replicas, for example from the Downward API or
+// from the number of endpoints of a Kubernetes service, and is polled at every interval.
+// It starts a background goroutine which is stopped by Close() of either the provider or
+// the limiter.
+func SplitRate(rl *Limiter, global int, every time.Duration, replicas func() (int, error)) *Provider {
+	return PollRate(rl, every, func() (int, error) {
+		n, err := replicas()
+		if err != nil {
+			return 0, err
+		}
+		return share(global, n), nil
+	})
+}
+
+// FollowReplicas keeps the limiter at an equal share of a global rate across the replicas
+// of a deployment, as the number of replicas is pushed on the channel, until it is closed.
+// See SplitRate().
+func FollowReplicas(rl *Limiter, global int, replicas <-chan int) *Provider {
+	rates := make(chan int)
+	p := FollowRate(rl, rates)
+	go func() {
+		defer close(rates)
+		for {
+			select {
+			case n, ok := <-replicas:
+				if !ok {
+					return
+				}
+
+				select {
+				case rates <- share(global, n):
+				case <-p.done:
+					return
+				}
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// share returns the share of the global rate of a single replica, rounded down so the
+// replicas never exceed the global rate together, and of at least one
+func share(global, replicas int) int {
+	if replicas < 1 {
+		replicas = 1
+	}
+	if rate := global / replicas; rate > 1 {
+		return rate
+	}
+	return 1
+}
@@ -0,0 +1,61 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SplitRate", func() {
+	rateOf := func(rl *Limiter) func() uint64 {
+		return func() uint64 { return rl.load().rate }
+	}
+
+	It("should split the global rate across the replicas", func() {
+		var replicas atomic.Int64
+		replicas.Store(4)
+
+		rl := New(100, time.Second)
+		p := SplitRate(rl, 100, 5*time.Millisecond, func() (int, error) {
+			if n := replicas.Load(); n > 0 {
+				return int(n), nil
+			}
+			return 0, errors.New("unavailable")
+		})
+		defer p.Close()
+
+		Eventually(rateOf(rl)).Should(Equal(uint64(25)))
+		replicas.Store(3)
+		Eventually(rateOf(rl)).Should(Equal(uint64(33)))
+
+		replicas.Store(0)
+		Consistently(rateOf(rl), 20*time.Millisecond).Should(Equal(uint64(33)))
+	})
+
+	It("should follow the replicas pushed", func() {
+		replicas := make(chan int)
+		rl := New(10, time.Second)
+		p := FollowReplicas(rl, 10, replicas)
+
+		replicas <- 2
+		Eventually(rateOf(rl)).Should(Equal(uint64(5)))
+		replicas <- 50
+		Eventually(rateOf(rl)).Should(Equal(uint64(1)))
+		Expect(rl.Close()).To(Succeed())
+		Expect(p.Close()).To(Succeed())
+	})
+
+	It("should compute the share of a replica", func() {
+		Expect(share(100, 3)).To(Equal(33))
+		Expect(share(100, 0)).To(Equal(100))
+		Expect(share(2, 5)).To(Equal(1))
+	})
+})
@@ -0,0 +1,51 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sort"
+	"time"
+)
+
+// Usage represents the decisions made for a key within a reporting window.
+type Usage struct {
+	Key string // Key of the usage
+	Bucket
+}
+
+// Report returns the usage of every key within each of the reporting windows tracked by
+// WithActivity(), for example 1440 buckets of a minute for the last 24 hours, so billing
+// or SLA systems can pull the usage straight from the limiter. The usage is ordered by
+// window, oldest first, then by key, and windows without any decision for a key are
+// skipped. It returns nil if activity tracking is not enabled.
+func (k *Keyed) Report() []Usage {
+	if k.activity == nil {
+		return nil
+	}
+
+	now := time.Now()
+	out := make([]Usage, 0)
+	for i := range k.shards {
+		s := &k.shards[i]
+		s.RLock()
+		for key, h := range s.heat {
+			for _, b := range k.activity.read(h, now) {
+				if b.Allowed > 0 || b.Denied > 0 {
+					out = append(out, Usage{Key: key, Bucket: b})
+				}
+			}
+		}
+		s.RUnlock()
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Start.Equal(out[j].Start) {
+			return out[i].Start.Before(out[j].Start)
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
@@ -0,0 +1,57 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Report", func() {
+
+	It("should report the usage per key and window", func() {
+		k := NewKeyed(2, time.Minute, WithActivity(24, time.Hour))
+		for i := 0; i < 3; i++ {
+			k.Limit("b")
+		}
+		k.Limit("a")
+
+		report := k.Report()
+		Expect(report).To(HaveLen(2))
+		Expect(report[0].Key).To(Equal("a"))
+		Expect(report[0].Allowed).To(Equal(uint64(1)))
+		Expect(report[1].Key).To(Equal("b"))
+		Expect(report[1].Allowed).To(Equal(uint64(2)))
+		Expect(report[1].Denied).To(Equal(uint64(1)))
+		Expect(report[1].Start).To(Equal(report[0].Start))
+	})
+
+	It("should order the usage by window", func() {
+		k := NewKeyed(10, time.Minute, WithActivity(3, time.Minute))
+		s := k.shard("a")
+		s.heat["a"] = make(heat, 3)
+		s.heat["z"] = make(heat, 3)
+
+		now := time.Now()
+		for i, key := range []string{"z", "a"} {
+			k.activity.record(s.heat[key], now.Add(-time.Duration(1-i)*time.Minute), false)
+		}
+
+		report := k.Report()
+		Expect(report).To(HaveLen(2))
+		Expect(report[0].Key).To(Equal("z"))
+		Expect(report[1].Key).To(Equal("a"))
+		Expect(report[0].Start.Before(report[1].Start)).To(BeTrue())
+	})
+
+	It("should not report without activity tracking", func() {
+		k := NewKeyed(10, time.Minute)
+		k.Limit("a")
+		Expect(k.Report()).To(BeNil())
+	})
+})
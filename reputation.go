@@ -0,0 +1,90 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// reputation represents the standing of the caller of a limiter, which scales its burst
+// between a minimum and a maximum
+type reputation struct {
+	lock  sync.Mutex    // serializes the updates of the standing
+	score atomic.Uint64 // standing between 0 and 1 as of the last decision, as float64 bits
+	last  atomic.Uint64 // time of the last decision, in unix ns
+	min   uint64        // burst of the worst standing, in tokens
+	max   uint64        // burst of the best standing, in tokens
+	decay float64       // time constant over which standing is earned, in ns
+}
+
+// WithReputation adapts the burst of the limiter to the behavior of its caller, between
+// min and max tokens. The limiter starts with the minimum burst and earns its way towards
+// the maximum from its creation, closing most of the gap within the decay, while every
+// denied call halves its standing. On a keyed limiter, it rewards long-lived and
+// well-behaved clients with smoother service and shrinks the burst of frequent violators.
+func WithReputation(min, max int, decay time.Duration) Option {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	if decay <= 0 {
+		decay = time.Nanosecond
+	}
+
+	return func(rl *Limiter) {
+		rl.extend().standing = &reputation{
+			min:   uint64(min),
+			max:   uint64(max),
+			decay: float64(decay),
+		}
+	}
+}
+
+// Reputation returns the standing of the caller, between 0 and 1, or 1 without a
+// reputation.
+func (rl *Limiter) Reputation() float64 {
+	s := rl.ext().standing
+	if s == nil {
+		return 1
+	}
+	return s.at(rl.now())
+}
+
+// observe updates the standing of the caller with a decision
+func (r *reputation) observe(now uint64, limited bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	score := r.at(now)
+	if limited {
+		score /= 2
+	}
+
+	r.score.Store(math.Float64bits(score))
+	if now > r.last.Load() {
+		r.last.Store(now)
+	}
+}
+
+// at returns the standing at the specified time, including what was earned since the
+// last decision
+func (r *reputation) at(now uint64) float64 {
+	score := math.Float64frombits(r.score.Load())
+	if last := r.last.Load(); now > last {
+		score += (1 - score) * (1 - math.Exp(-float64(now-last)/r.decay))
+	}
+	return score
+}
+
+// cap returns the maximum allowance for the standing at the specified time
+func (r *reputation) cap(now, unit uint64) uint64 {
+	return (r.min + uint64(r.at(now)*float64(r.max-r.min))) * unit
+}
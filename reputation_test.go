@@ -0,0 +1,68 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WithReputation", func() {
+	var clock *ManualClock
+
+	BeforeEach(func() {
+		clock = NewManualClock(time.Unix(100, 0))
+	})
+
+	It("should start with the minimum burst", func() {
+		rl := New(100, time.Second, WithClock(clock), WithReputation(5, 50, time.Minute))
+		Expect(rl.Remaining()).To(Equal(5))
+		Expect(rl.Reputation()).To(BeZero())
+	})
+
+	It("should earn a larger burst over time", func() {
+		rl := New(100, time.Second, WithClock(clock), WithReputation(5, 50, time.Minute))
+		clock.Advance(10 * time.Minute)
+		Expect(rl.Reputation()).To(BeNumerically(">", 0.99))
+		Expect(rl.Remaining()).To(Equal(49))
+	})
+
+	It("should shrink the burst of violators", func() {
+		rl := New(100, time.Second, WithClock(clock), WithReputation(5, 50, time.Minute))
+		clock.Advance(10 * time.Minute)
+		Expect(rl.LimitN(40)).To(BeFalse())
+
+		for i := 0; i < 10; i++ {
+			rl.LimitN(100)
+		}
+
+		Expect(rl.Reputation()).To(BeNumerically("<", 0.01))
+		clock.Advance(time.Second)
+		Expect(rl.Remaining()).To(Equal(5))
+	})
+
+	It("should keep the reputation of each key", func() {
+		k := NewKeyed(100, time.Second, WithLimiterOptions(WithClock(clock), WithReputation(5, 50, time.Minute)))
+		k.Get("alice")
+		k.Get("mallory")
+		clock.Advance(10 * time.Minute)
+
+		for i := 0; i < 10; i++ {
+			k.Get("mallory").LimitN(100)
+		}
+
+		Expect(k.Get("alice").Reputation()).To(BeNumerically(">", 0.99))
+		Expect(k.Get("mallory").Reputation()).To(BeNumerically("<", 0.01))
+	})
+
+	It("should not affect a limiter without reputation", func() {
+		rl := New(10, time.Second)
+		Expect(rl.Reputation()).To(Equal(1.0))
+		Expect(rl.Remaining()).To(Equal(10))
+	})
+})
@@ -0,0 +1,41 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// WithReserve keeps a percentage of the capacity, between 0 and 100, reserved for critical
+// calls such as health checks and admin operations. Regular calls are limited once the
+// allowance drops to the reserve, while LimitCritical() may use all of it.
+func WithReserve(percent float64) Option {
+	return func(rl *Limiter) {
+		rl.extend().reserve = clampPercent(percent) / 100
+	}
+}
+
+// LimitCritical returns true if rate was exceeded, ignoring the reserve.
+func (rl *Limiter) LimitCritical() bool {
+	return rl.LimitCriticalN(1)
+}
+
+// LimitCriticalN returns true if rate would be exceeded by n calls at once, ignoring the
+// reserve, otherwise it consumes n tokens.
+func (rl *Limiter) LimitCriticalN(n int) bool {
+	if n < 1 {
+		return false
+	}
+
+	return rl.record(rl.consume(uint64(n), 0), uint64(n))
+}
+
+// headroom returns the allowance reserved for critical calls
+func (rl *Limiter) headroom() uint64 {
+	reserve := rl.ext().reserve
+	if reserve == 0 {
+		return 0
+	}
+
+	_, max := rl.limits(rl.now())
+	return uint64(float64(max) * reserve)
+}
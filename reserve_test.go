@@ -0,0 +1,54 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reserve", func() {
+
+	It("should keep headroom for critical calls", func() {
+		rl := New(10, time.Minute, WithReserve(20))
+		Expect(rl.LimitN(8)).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+		Expect(rl.Peek()).To(BeTrue())
+
+		Expect(rl.LimitCritical()).To(BeFalse())
+		Expect(rl.LimitCriticalN(0)).To(BeFalse())
+		Expect(rl.LimitCriticalN(2)).To(BeTrue())
+		Expect(rl.LimitCritical()).To(BeFalse())
+		Expect(rl.LimitCritical()).To(BeTrue())
+	})
+
+	It("should let critical calls use the reserve first", func() {
+		rl := New(10, time.Minute, WithReserve(50))
+		Expect(rl.LimitCriticalN(6)).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		Expect(rl.Wait(ctx)).To(HaveOccurred())
+	})
+
+	It("should wait until the allowance exceeds the reserve", func() {
+		rl := New(10, 100*time.Millisecond, WithReserve(50))
+		Expect(rl.LimitN(5)).To(BeFalse())
+		Expect(rl.delay()).To(BeNumerically("~", 10*time.Millisecond, time.Millisecond))
+		Expect(rl.Wait(context.Background())).To(Succeed())
+	})
+
+	It("should not reserve anything by default", func() {
+		rl := New(10, time.Minute)
+		Expect(rl.LimitN(10)).To(BeFalse())
+		Expect(rl.LimitCritical()).To(BeTrue())
+	})
+
+})
@@ -0,0 +1,145 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Resource limits the use of a fixed number of slots, such as open file descriptors or
+// connections. Unlike a Limiter, slots are not refilled over time but must be explicitly
+// released once the resource is no longer in use.
+type Resource struct {
+	lock     sync.Mutex
+	capacity int
+	used     int
+	released chan struct{} // closed and replaced whenever slots are released
+	stats    counters
+}
+
+// NewResource creates a new resource limiter with the specified capacity.
+func NewResource(capacity int) *Resource {
+	if capacity < 0 {
+		capacity = 0
+	}
+
+	return &Resource{
+		capacity: capacity,
+		released: make(chan struct{}),
+	}
+}
+
+// Limit acquires a single slot and returns true if none is available.
+func (r *Resource) Limit() bool {
+	return r.LimitN(1)
+}
+
+// LimitN acquires n slots at once and returns true if they are not all available.
+func (r *Resource) LimitN(n int) bool {
+	if n < 1 {
+		return false
+	}
+
+	r.lock.Lock()
+	limited := r.used+n > r.capacity
+	if !limited {
+		r.used += n
+	}
+	r.lock.Unlock()
+
+	if limited {
+		r.stats.denied.Add(1)
+	} else {
+		r.stats.allowed.Add(uint64(n))
+	}
+	return limited
+}
+
+// Wait blocks until a slot is acquired or the context is done.
+func (r *Resource) Wait(ctx context.Context) error {
+	start := time.Now()
+	defer func() { r.stats.observe(time.Since(start)) }()
+
+	for {
+		r.lock.Lock()
+		if r.used < r.capacity {
+			r.used++
+			r.lock.Unlock()
+			r.stats.allowed.Add(1)
+			return nil
+		}
+
+		released := r.released
+		r.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+// Release returns n previously acquired slots back to the resource.
+func (r *Resource) Release(n int) {
+	if n < 1 {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.used -= n; r.used < 0 {
+		r.used = 0
+	}
+
+	r.wake()
+}
+
+// UpdateCapacity changes the number of available slots. Slots already in use above the
+// new capacity remain in use until they are released.
+func (r *Resource) UpdateCapacity(capacity int) {
+	if capacity < 0 {
+		capacity = 0
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.capacity = capacity
+	r.wake()
+}
+
+// wake notifies the waiters that slots may have become available
+func (r *Resource) wake() {
+	close(r.released)
+	r.released = make(chan struct{})
+}
+
+// InUse returns the number of slots currently acquired.
+func (r *Resource) InUse() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.used
+}
+
+// Capacity returns the total number of slots.
+func (r *Resource) Capacity() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.capacity
+}
+
+// Stats returns the counters accumulated since the resource was created or since the
+// last call to ResetStats().
+func (r *Resource) Stats() Stats {
+	return r.stats.snapshot(false)
+}
+
+// ResetStats returns the counters accumulated since the last read and resets them.
+func (r *Resource) ResetStats() Stats {
+	return r.stats.snapshot(true)
+}
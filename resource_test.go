@@ -0,0 +1,78 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resource", func() {
+	var _ Interface = NewResource(1)
+
+	It("should limit to the capacity", func() {
+		r := NewResource(3)
+		Expect(r.LimitN(2)).To(BeFalse())
+		Expect(r.LimitN(2)).To(BeTrue())
+		Expect(r.Limit()).To(BeFalse())
+		Expect(r.Limit()).To(BeTrue())
+		Expect(r.InUse()).To(Equal(3))
+		Expect(r.Stats()).To(Equal(Stats{Allowed: 3, Denied: 2}))
+	})
+
+	It("should not refill over time", func() {
+		r := NewResource(1)
+		Expect(r.Limit()).To(BeFalse())
+		time.Sleep(5 * time.Millisecond)
+		Expect(r.Limit()).To(BeTrue())
+
+		r.Release(1)
+		Expect(r.Limit()).To(BeFalse())
+	})
+
+	It("should not release below zero", func() {
+		r := NewResource(2)
+		r.Release(5)
+		Expect(r.InUse()).To(BeZero())
+		Expect(r.LimitN(3)).To(BeTrue())
+	})
+
+	It("should update capacity", func() {
+		r := NewResource(2)
+		Expect(r.LimitN(2)).To(BeFalse())
+		r.UpdateCapacity(1)
+		Expect(r.Capacity()).To(Equal(1))
+
+		r.Release(1)
+		Expect(r.Limit()).To(BeTrue())
+		r.Release(1)
+		Expect(r.Limit()).To(BeFalse())
+	})
+
+	It("should wait for a release", func() {
+		r := NewResource(1)
+		Expect(r.Limit()).To(BeFalse())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			r.Release(1)
+		}()
+
+		Expect(r.Wait(context.Background())).To(Succeed())
+		Expect(r.InUse()).To(Equal(1))
+		Expect(r.ResetStats().Allowed).To(Equal(uint64(2)))
+		Expect(r.Stats()).To(Equal(Stats{}))
+	})
+
+	It("should stop waiting when the context is done", func() {
+		r := NewResource(0)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		Expect(r.Wait(ctx)).To(Equal(context.DeadlineExceeded))
+	})
+})
@@ -0,0 +1,90 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// Route represents how a logical endpoint draws from a shared budget.
+type Route struct {
+	Weight int // Tokens of the shared budget taken by each call, 1 if zero
+	Cap    int // Maximum tokens the endpoint may take per period of the budget, if not zero
+}
+
+// Routes maps several logical endpoints, such as the versions of an API, onto a single
+// shared budget, each call of an endpoint taking its weight from the budget and from the
+// cap of the endpoint, if any. Routes instances are thread-safe.
+type Routes struct {
+	budget *Limiter
+	routes map[string]weighted
+}
+
+// weighted represents an endpoint along with the limiter enforcing its cap
+type weighted struct {
+	weight int
+	cap    *Limiter // optional cap of the endpoint
+}
+
+// NewRoutes creates a mapping of the endpoints onto the shared budget, so that "v1 and
+// v2 share 1000/min but v2 may use at most 600" is expressed by a budget of 1000 per
+// minute and a cap of 600 for v2. Calls of an endpoint which is not mapped take a single
+// token from the budget. The caps do not follow rate updates of the budget.
+func NewRoutes(budget *Limiter, routes map[string]Route) *Routes {
+	r := &Routes{
+		budget: budget,
+		routes: make(map[string]weighted, len(routes)),
+	}
+
+	for name, config := range routes {
+		rt := weighted{weight: config.Weight}
+		if rt.weight < 1 {
+			rt.weight = 1
+		}
+
+		if config.Cap > 0 {
+			rt.cap = budget.derive(config.Cap)
+		}
+		r.routes[name] = rt
+	}
+	return r
+}
+
+// Limit returns true if a call of the endpoint would exceed the shared budget or the cap
+// of the endpoint.
+func (r *Routes) Limit(endpoint string) bool {
+	return r.LimitN(endpoint, 1)
+}
+
+// LimitN returns true if n calls of the endpoint at once would exceed the shared budget
+// or the cap of the endpoint, otherwise their weight is consumed from both.
+func (r *Routes) LimitN(endpoint string, n int) bool {
+	rt, ok := r.routes[endpoint]
+	if !ok || n < 1 {
+		return r.budget.LimitN(n)
+	}
+
+	cost := n * rt.weight
+	if rt.cap == nil {
+		return r.budget.LimitN(cost)
+	}
+
+	if rt.cap.LimitN(cost) {
+		return r.budget.record(true, uint64(cost))
+	}
+
+	if r.budget.LimitN(cost) {
+		untake(rt.cap, cost)
+		return true
+	}
+	return false
+}
+
+// Stats returns the decision counters of the cap of the endpoint, or zero stats if the
+// endpoint has no cap. Calls denied by the shared budget are not counted as denied by
+// the cap.
+func (r *Routes) Stats(endpoint string) Stats {
+	if rt, ok := r.routes[endpoint]; ok && rt.cap != nil {
+		return rt.cap.Stats()
+	}
+	return Stats{}
+}
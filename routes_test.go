@@ -0,0 +1,68 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Routes", func() {
+
+	It("should cap an endpoint within the shared budget", func() {
+		budget := New(1000, time.Minute)
+		r := NewRoutes(budget, map[string]Route{
+			"v1": {},
+			"v2": {Cap: 600},
+		})
+
+		Expect(r.LimitN("v2", 600)).To(BeFalse())
+		Expect(r.Limit("v2")).To(BeTrue())
+		Expect(r.LimitN("v1", 400)).To(BeFalse())
+		Expect(r.Limit("v1")).To(BeTrue())
+		Expect(r.Stats("v2").Denied).To(Equal(uint64(1)))
+	})
+
+	It("should give back the cap when the shared budget is exceeded", func() {
+		budget := New(10, time.Minute)
+		r := NewRoutes(budget, map[string]Route{
+			"v2": {Cap: 6},
+		})
+
+		Expect(r.LimitN("legacy", 8)).To(BeFalse())
+		Expect(r.LimitN("v2", 4)).To(BeTrue())
+		Expect(r.LimitN("v2", 2)).To(BeFalse())
+		Expect(r.Stats("v2").Allowed).To(Equal(uint64(2)))
+	})
+
+	It("should weigh the calls of an endpoint", func() {
+		budget := New(10, time.Minute)
+		r := NewRoutes(budget, map[string]Route{
+			"search": {Weight: 3},
+		})
+
+		Expect(r.LimitN("search", 3)).To(BeFalse())
+		Expect(r.Limit("search")).To(BeTrue())
+		Expect(r.Limit("other")).To(BeFalse())
+		Expect(budget.Remaining()).To(BeZero())
+	})
+
+	It("should refill the caps with the budget", func() {
+		clock := NewManualClock(time.Unix(100, 0))
+		budget := New(10, time.Second, WithClock(clock))
+		r := NewRoutes(budget, map[string]Route{
+			"v2": {Cap: 5},
+		})
+
+		Expect(r.LimitN("v2", 5)).To(BeFalse())
+		Expect(r.Limit("v2")).To(BeTrue())
+
+		clock.Advance(time.Second)
+		Expect(r.LimitN("v2", 5)).To(BeFalse())
+	})
+})
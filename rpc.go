@@ -0,0 +1,146 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"net/rpc"
+	"sync"
+)
+
+// Func represents a generic RPC method, such as a handler of a non-HTTP RPC stack.
+type Func[Req, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+// RPCOption represents an option which can be applied to a wrapped RPC method or codec.
+type RPCOption func(*rpcOptions)
+
+// rpcOptions represents the options of a wrapped RPC method or codec
+type rpcOptions struct {
+	exemplars *Exemplars                   // optional reservoir of denied calls
+	trace     func(context.Context) string // optional extractor of the trace ID
+}
+
+// WithRPCExemplars records a sample of the denied calls in the reservoir, along with
+// their trace ID as returned by the function, which may be nil.
+func WithRPCExemplars(e *Exemplars, trace func(context.Context) string) RPCOption {
+	return func(o *rpcOptions) {
+		o.exemplars = e
+		o.trace = trace
+	}
+}
+
+// record records a denied call, if exemplars are enabled
+func (o *rpcOptions) record(ctx context.Context, key string) {
+	if o.exemplars == nil {
+		return
+	}
+
+	var id string
+	if o.trace != nil {
+		id = o.trace(ctx)
+	}
+	o.exemplars.Record(key, id)
+}
+
+// newRPCOptions applies the options
+func newRPCOptions(options []RPCOption) *rpcOptions {
+	o := new(rpcOptions)
+	for _, opt := range options {
+		opt(o)
+	}
+	return o
+}
+
+// Wrap wraps the function so that the calls denied by the limiter fail with a
+// *LimitedError, which rategrpc.Error() maps to a gRPC status, without being run.
+func Wrap[Req, Resp any](rl *Limiter, fn Func[Req, Resp], options ...RPCOption) Func[Req, Resp] {
+	o := newRPCOptions(options)
+	return func(ctx context.Context, req Req) (Resp, error) {
+		if err := rl.Try(); err != nil {
+			o.record(ctx, rl.ext().name)
+
+			var zero Resp
+			return zero, err
+		}
+		return fn(ctx, req)
+	}
+}
+
+// WrapKeyed wraps the function so that the calls denied by the keyed limiter, for the
+// key of the request, fail with a *LimitedError without being run.
+func WrapKeyed[Req, Resp any](k *Keyed, key func(context.Context, Req) string, fn Func[Req, Resp], options ...RPCOption) Func[Req, Resp] {
+	o := newRPCOptions(options)
+	return func(ctx context.Context, req Req) (Resp, error) {
+		id := key(ctx, req)
+		if k.Limit(id) {
+			o.record(ctx, id)
+
+			var zero Resp
+			return zero, k.Get(id).limited(ErrLimited, 0)
+		}
+		return fn(ctx, req)
+	}
+}
+
+// ------------------------------------ net/rpc ------------------------------------
+
+// serverCodec represents a codec of a net/rpc server which answers the requests denied
+// by a keyed limiter itself, so that they never reach the server
+type serverCodec struct {
+	rpc.ServerCodec
+	lock    sync.Mutex // serializes the responses written by the server and by us
+	limiter *Keyed
+	key     func(*rpc.Request) string
+	options *rpcOptions
+}
+
+// LimitServerCodec wraps the codec of a net/rpc connection so that the requests denied
+// by the keyed limiter, for the key of the request such as its service method or the
+// client of the connection, are answered with the error of a *LimitedError without
+// being served.
+func LimitServerCodec(codec rpc.ServerCodec, k *Keyed, key func(*rpc.Request) string, options ...RPCOption) rpc.ServerCodec {
+	return &serverCodec{
+		ServerCodec: codec,
+		limiter:     k,
+		key:         key,
+		options:     newRPCOptions(options),
+	}
+}
+
+// ReadRequestHeader reads the header of the next request which is not limited.
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	for {
+		if err := c.ServerCodec.ReadRequestHeader(r); err != nil {
+			return err
+		}
+
+		id := c.key(r)
+		if !c.limiter.Limit(id) {
+			return nil
+		}
+
+		// Discard the body and answer right away, then move on to the next request
+		c.options.record(context.Background(), id)
+		if err := c.ServerCodec.ReadRequestBody(nil); err != nil {
+			return err
+		}
+
+		if err := c.WriteResponse(&rpc.Response{
+			ServiceMethod: r.ServiceMethod,
+			Seq:           r.Seq,
+			Error:         c.limiter.Get(id).limited(ErrLimited, 0).Error(),
+		}, struct{}{}); err != nil {
+			return err
+		}
+	}
+}
+
+// WriteResponse writes a response.
+func (c *serverCodec) WriteResponse(r *rpc.Response, body any) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.ServerCodec.WriteResponse(r, body)
+}
@@ -0,0 +1,99 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Wrap", func() {
+
+	It("should reject the calls denied by the limiter", func() {
+		calls := 0
+		fn := Wrap(New(1, time.Hour), func(_ context.Context, n int) (int, error) {
+			calls++
+			return n * 2, nil
+		})
+
+		out, err := fn(context.Background(), 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(4))
+
+		out, err = fn(context.Background(), 3)
+		Expect(errors.Is(err, ErrLimited)).To(BeTrue())
+		Expect(out).To(BeZero())
+		Expect(calls).To(Equal(1))
+	})
+
+	It("should limit per key and record exemplars", func() {
+		exemplars := NewExemplars(10, 1)
+		trace := func(context.Context) string { return "trace" }
+		fn := WrapKeyed(NewKeyed(1, time.Hour), func(_ context.Context, user string) string {
+			return user
+		}, func(_ context.Context, user string) (string, error) {
+			return "hello " + user, nil
+		}, WithRPCExemplars(exemplars, trace))
+
+		Expect(fn(context.Background(), "alice")).To(Equal("hello alice"))
+		Expect(fn(context.Background(), "bob")).To(Equal("hello bob"))
+
+		_, err := fn(context.Background(), "alice")
+		var limited *LimitedError
+		Expect(errors.As(err, &limited)).To(BeTrue())
+		Expect(limited.RetryAfter).To(BeNumerically(">", 0))
+		Expect(exemplars.List()).To(HaveLen(1))
+		Expect(exemplars.List()[0].Key).To(Equal("alice"))
+		Expect(exemplars.List()[0].TraceID).To(Equal("trace"))
+	})
+})
+
+var _ = Describe("LimitServerCodec", func() {
+
+	It("should answer the denied requests without serving them", func() {
+		echo := new(echoService)
+		server := rpc.NewServer()
+		Expect(server.RegisterName("Echo", echo)).To(Succeed())
+
+		serverConn, clientConn := net.Pipe()
+		k := NewKeyed(2, time.Hour)
+		go server.ServeCodec(LimitServerCodec(jsonrpc.NewServerCodec(serverConn), k, func(r *rpc.Request) string {
+			return r.ServiceMethod
+		}))
+
+		client := jsonrpc.NewClient(clientConn)
+		defer client.Close()
+
+		var reply string
+		Expect(client.Call("Echo.Echo", "a", &reply)).To(Succeed())
+		Expect(client.Call("Echo.Echo", "b", &reply)).To(Succeed())
+		Expect(reply).To(Equal("b"))
+
+		err := client.Call("Echo.Echo", "c", &reply)
+		Expect(err).To(BeAssignableToTypeOf(rpc.ServerError("")))
+		Expect(err.Error()).To(ContainSubstring(ErrLimited.Error()))
+		Expect(echo.calls).To(Equal(2))
+	})
+})
+
+// echoService represents a net/rpc service used in tests
+type echoService struct {
+	calls int
+}
+
+// Echo replies with the request.
+func (s *echoService) Echo(req string, reply *string) error {
+	s.calls++
+	*reply = req
+	return nil
+}
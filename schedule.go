@@ -0,0 +1,121 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Window represents a daily time window with its rate. The bounds are offsets from
+// midnight in wall clock time, so a window from 9h to 17h always covers business hours,
+// regardless of daylight saving time transitions. A window whose start is after its end
+// spans midnight.
+type Window struct {
+	From time.Duration // Start of the window, inclusive
+	To   time.Duration // End of the window, exclusive
+	Rate int           // Rate applied during the window
+}
+
+// contains returns whether the wall clock offset falls within the window
+func (w Window) contains(offset time.Duration) bool {
+	if w.From <= w.To {
+		return offset >= w.From && offset < w.To
+	}
+	return offset >= w.From || offset < w.To
+}
+
+// Schedule maps daily time windows to rates, for example a lower rate during business
+// hours and a higher one overnight for batch processing.
+type Schedule struct {
+	Location *time.Location // Time zone of the windows, local time if nil
+	Windows  []Window       // Windows of the day, the first matching one applies
+	Default  int            // Rate applied outside of any window
+}
+
+// RateAt returns the rate applicable at the specified time.
+func (s *Schedule) RateAt(t time.Time) int {
+	h, m, sec := t.In(s.location()).Clock()
+	offset := time.Duration(h)*time.Hour +
+		time.Duration(m)*time.Minute +
+		time.Duration(sec)*time.Second +
+		time.Duration(t.Nanosecond())
+
+	for _, w := range s.Windows {
+		if w.contains(offset) {
+			return w.Rate
+		}
+	}
+	return s.Default
+}
+
+// Next returns the first window boundary strictly after the specified time.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.In(s.location())
+	y, m, d := t.Date()
+
+	var next time.Time
+	for day := 0; day <= 1; day++ {
+		for _, w := range s.Windows {
+			for _, bound := range [2]time.Duration{w.From, w.To} {
+				at := time.Date(y, m, d+day,
+					int(bound/time.Hour),
+					int(bound%time.Hour/time.Minute),
+					int(bound%time.Minute/time.Second),
+					int(bound%time.Second), s.location())
+				if at.After(t) && (next.IsZero() || at.Before(next)) {
+					next = at
+				}
+			}
+		}
+	}
+	return next
+}
+
+// location returns the time zone of the schedule
+func (s *Schedule) location() *time.Location {
+	if s.Location == nil {
+		return time.Local
+	}
+	return s.Location
+}
+
+// scheduled represents a schedule applied to a limiter
+type scheduled struct {
+	Schedule
+	next atomic.Int64 // time of the next boundary, in unix ns
+}
+
+// WithSchedule makes the limiter follow the schedule, automatically updating its rate
+// as time crosses the boundaries of the windows.
+func WithSchedule(schedule Schedule) Option {
+	return func(rl *Limiter) {
+		rl.extend().schedule = &scheduled{Schedule: schedule}
+	}
+}
+
+// reschedule updates the rate of the limiter if a window boundary has been crossed
+func (rl *Limiter) reschedule(now uint64) {
+	s := rl.ext().schedule
+	next := s.next.Load()
+	if int64(now) < next {
+		return
+	}
+
+	t := time.Unix(0, int64(now))
+	if s.next.CompareAndSwap(next, s.boundary(t)) {
+		rl.UpdateRate(s.RateAt(t))
+	}
+}
+
+// boundary returns the time of the next boundary in unix nanoseconds
+func (s *scheduled) boundary(t time.Time) int64 {
+	if next := s.Next(t); !next.IsZero() {
+		return next.UnixNano()
+	}
+	return math.MaxInt64
+}
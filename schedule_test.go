@@ -0,0 +1,76 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Schedule", func() {
+	utc := time.UTC
+	schedule := Schedule{
+		Location: utc,
+		Default:  10,
+		Windows: []Window{
+			{From: 9 * time.Hour, To: 17 * time.Hour, Rate: 100},
+			{From: 22 * time.Hour, To: 6 * time.Hour, Rate: 500},
+		},
+	}
+
+	It("should return the rate of the window", func() {
+		Expect(schedule.RateAt(time.Date(2020, 1, 1, 8, 59, 59, 0, utc))).To(Equal(10))
+		Expect(schedule.RateAt(time.Date(2020, 1, 1, 9, 0, 0, 0, utc))).To(Equal(100))
+		Expect(schedule.RateAt(time.Date(2020, 1, 1, 16, 59, 0, 0, utc))).To(Equal(100))
+		Expect(schedule.RateAt(time.Date(2020, 1, 1, 17, 0, 0, 0, utc))).To(Equal(10))
+		Expect(schedule.RateAt(time.Date(2020, 1, 1, 23, 0, 0, 0, utc))).To(Equal(500))
+		Expect(schedule.RateAt(time.Date(2020, 1, 1, 3, 0, 0, 0, utc))).To(Equal(500))
+	})
+
+	It("should return the next boundary", func() {
+		Expect(schedule.Next(time.Date(2020, 1, 1, 7, 0, 0, 0, utc))).To(Equal(time.Date(2020, 1, 1, 9, 0, 0, 0, utc)))
+		Expect(schedule.Next(time.Date(2020, 1, 1, 9, 0, 0, 0, utc))).To(Equal(time.Date(2020, 1, 1, 17, 0, 0, 0, utc)))
+		Expect(schedule.Next(time.Date(2020, 1, 1, 23, 0, 0, 0, utc))).To(Equal(time.Date(2020, 1, 2, 6, 0, 0, 0, utc)))
+		Expect((&Schedule{}).Next(time.Now()).IsZero()).To(BeTrue())
+	})
+
+	It("should follow daylight saving time", func() {
+		ny, err := time.LoadLocation("America/New_York")
+		Expect(err).NotTo(HaveOccurred())
+
+		s := Schedule{Location: ny, Windows: []Window{{From: 9 * time.Hour, To: 17 * time.Hour, Rate: 100}}}
+		before := time.Date(2021, 3, 13, 14, 0, 0, 0, utc) // 9:00 EST
+		after := time.Date(2021, 3, 15, 13, 0, 0, 0, utc)  // 9:00 EDT
+		Expect(s.RateAt(before)).To(Equal(100))
+		Expect(s.RateAt(before.Add(-time.Minute))).To(Equal(0))
+		Expect(s.RateAt(after)).To(Equal(100))
+		Expect(s.RateAt(after.Add(-time.Minute))).To(Equal(0))
+		Expect(s.Next(time.Date(2021, 3, 14, 22, 0, 0, 0, utc))).To(BeTemporally("==", after))
+	})
+
+	It("should update the rate of the limiter", func() {
+		clock := NewManualClock(time.Date(2020, 1, 1, 8, 59, 0, 0, utc))
+		rl := New(1, time.Minute, WithClock(clock), WithSchedule(schedule))
+		Expect(rl.load().rate).To(Equal(uint64(10)))
+
+		clock.Advance(time.Minute)
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.load().rate).To(Equal(uint64(100)))
+
+		clock.Advance(8 * time.Hour)
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.load().rate).To(Equal(uint64(10)))
+
+		var count int
+		for !rl.Limit() {
+			count++
+		}
+		Expect(count).To(Equal(9))
+	})
+
+})
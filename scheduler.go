@@ -0,0 +1,189 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+// Step represents a planned change of rate.
+type Step struct {
+	At   time.Time // Time at which the rate changes
+	Rate int       // Rate applied from then on
+}
+
+// Scheduler applies planned rate changes to a limiter, either as one-off steps or as
+// recurring changes following cron expressions. Scheduler instances are thread-safe.
+type Scheduler struct {
+	limiter *Limiter
+	lock    sync.Mutex
+	steps   []Step        // pending one-off steps
+	crons   []cronStep    // recurring steps
+	wake    chan struct{} // signals that the plan has changed
+	done    chan struct{} // closed when the scheduler is closed
+	once    sync.Once
+}
+
+// cronStep represents a recurring change of rate
+type cronStep struct {
+	cron *Cron
+	rate int
+	next time.Time
+}
+
+// NewScheduler creates a new scheduler for the limiter, applying the specified steps. It
+// starts a background goroutine which is stopped by Close() of either the scheduler or
+// the limiter.
+func NewScheduler(rl *Limiter, steps ...Step) *Scheduler {
+	s := &Scheduler{
+		limiter: rl,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	for _, step := range steps {
+		s.At(step.At, step.Rate)
+	}
+
+	rl.attach(s)
+	go s.run()
+	return s
+}
+
+// At plans a change of rate at the specified time.
+func (s *Scheduler) At(at time.Time, rate int) {
+	s.lock.Lock()
+	s.steps = append(s.steps, Step{At: at, Rate: rate})
+	s.lock.Unlock()
+	s.notify()
+}
+
+// Cron plans a recurring change of rate following the cron expression, evaluated in
+// local time. It fails with ErrClosed once the scheduler was closed.
+func (s *Scheduler) Cron(expr string, rate int) error {
+	if s.closed() {
+		return ErrClosed
+	}
+
+	cron, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	s.crons = append(s.crons, cronStep{cron: cron, rate: rate, next: cron.Next(time.Now())})
+	s.lock.Unlock()
+	s.notify()
+	return nil
+}
+
+// Close stops the scheduler, pending changes are discarded.
+func (s *Scheduler) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+	})
+	return nil
+}
+
+// closed returns whether the scheduler was closed
+func (s *Scheduler) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// notify wakes up the background goroutine
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run applies the changes as they become due, until the scheduler is closed
+func (s *Scheduler) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		if next, ok := s.apply(time.Now()); ok {
+			timer.Reset(time.Until(next))
+		}
+
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// apply applies the changes which are due and returns the time of the next one
+func (s *Scheduler) apply(now time.Time) (next time.Time, ok bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Apply the latest of the due changes, in chronological order
+	var due *Step
+	pending := s.steps[:0]
+	for i := range s.steps {
+		step := s.steps[i]
+		switch {
+		case step.At.After(now):
+			pending = append(pending, step)
+		case due == nil || !step.At.Before(due.At):
+			due = &Step{At: step.At, Rate: step.Rate}
+		}
+	}
+	s.steps = pending
+
+	for i := range s.crons {
+		c := &s.crons[i]
+		if c.next.IsZero() || c.next.After(now) {
+			continue
+		}
+
+		if due == nil || !c.next.Before(due.At) {
+			due = &Step{At: c.next, Rate: c.rate}
+		}
+		c.next = c.cron.Next(now)
+	}
+
+	if due != nil {
+		s.limiter.UpdateRate(due.Rate)
+	}
+
+	// Find out when the next change is due
+	for _, step := range s.steps {
+		if !ok || step.At.Before(next) {
+			next, ok = step.At, true
+		}
+	}
+	for _, c := range s.crons {
+		if !c.next.IsZero() && (!ok || c.next.Before(next)) {
+			next, ok = c.next, true
+		}
+	}
+	return
+}
@@ -0,0 +1,73 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scheduler", func() {
+	rateOf := func(rl *Limiter) func() uint64 {
+		return func() uint64 { return rl.load().rate }
+	}
+
+	It("should apply steps when due", func() {
+		rl := New(10, time.Second)
+		s := NewScheduler(rl, Step{At: time.Now().Add(20 * time.Millisecond), Rate: 20})
+		defer s.Close()
+
+		s.At(time.Now().Add(40*time.Millisecond), 30)
+		Expect(rl.load().rate).To(Equal(uint64(10)))
+		Eventually(rateOf(rl)).Should(Equal(uint64(20)))
+		Eventually(rateOf(rl)).Should(Equal(uint64(30)))
+	})
+
+	It("should apply the latest of past steps", func() {
+		rl := New(10, time.Second)
+		s := NewScheduler(rl,
+			Step{At: time.Now().Add(-time.Minute), Rate: 30},
+			Step{At: time.Now().Add(-time.Hour), Rate: 20},
+		)
+		defer s.Close()
+
+		Eventually(rateOf(rl)).Should(Equal(uint64(30)))
+	})
+
+	It("should apply cron steps", func() {
+		rl := New(10, time.Second)
+		s := NewScheduler(rl)
+		defer s.Close()
+
+		Expect(s.Cron("* * * * *", 20)).To(Succeed())
+		Expect(s.Cron("invalid", 20)).NotTo(Succeed())
+
+		s.lock.Lock()
+		next := s.crons[0].next
+		s.lock.Unlock()
+
+		_, ok := s.apply(next)
+		Expect(ok).To(BeTrue())
+		Expect(rl.load().rate).To(Equal(uint64(20)))
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		Expect(s.crons[0].next).To(Equal(next.Add(time.Minute)))
+	})
+
+	It("should stop when closed", func() {
+		rl := New(10, time.Second)
+		s := NewScheduler(rl)
+		Expect(s.Close()).To(Succeed())
+		Expect(s.Close()).To(Succeed())
+
+		s.At(time.Now(), 20)
+		Consistently(rateOf(rl), "20ms").Should(Equal(uint64(10)))
+	})
+
+})
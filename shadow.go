@@ -0,0 +1,20 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// WithShadow runs the limiter in shadow mode, where nothing is limited and Wait() never
+// blocks, but the calls which would have been denied are counted in Stats().Shadowed.
+// This allows validating a new limit against production traffic before enforcing it.
+func WithShadow() Option {
+	return func(rl *Limiter) {
+		rl.extend().shadow = true
+	}
+}
+
+// Shadow returns whether the limiter runs in shadow mode.
+func (rl *Limiter) Shadow() bool {
+	return rl.ext().shadow
+}
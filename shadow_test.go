@@ -0,0 +1,44 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shadow", func() {
+
+	It("should count denials without enforcing them", func() {
+		rl := New(3, time.Minute, WithShadow())
+		Expect(rl.Shadow()).To(BeTrue())
+		for i := 0; i < 5; i++ {
+			Expect(rl.Limit()).To(BeFalse())
+		}
+		Expect(rl.LimitN(2)).To(BeFalse())
+
+		Expect(rl.Stats()).To(Equal(Stats{Allowed: 3, Shadowed: 3}))
+	})
+
+	It("should not block waiters", func() {
+		rl := New(1, time.Hour, WithShadow())
+		Expect(rl.Wait(context.Background())).To(Succeed())
+		Expect(rl.Wait(context.Background())).To(Succeed())
+		Expect(rl.Stats()).To(Equal(Stats{Allowed: 1, Shadowed: 1}))
+	})
+
+	It("should enforce by default", func() {
+		rl := New(1, time.Minute)
+		Expect(rl.Shadow()).To(BeFalse())
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+		Expect(rl.Stats()).To(Equal(Stats{Allowed: 1, Denied: 1}))
+	})
+
+})
@@ -0,0 +1,194 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// Layout of the shared memory segment, every field being a 64-bit word
+const (
+	sharedMagic     = 0x726174656c696d31 // identifies an initialized segment
+	sharedSize      = 5 * 8
+	offsetMagic     = 0
+	offsetRate      = 8
+	offsetUnit      = 16
+	offsetAllowance = 24
+	offsetLastCheck = 32
+)
+
+// Errors returned by the shared limiter
+var (
+	ErrUnsupported = errors.New("rate: shared limiter is not supported on this platform")
+	ErrMismatch    = errors.New("rate: shared limiter was created with a different rate")
+)
+
+// Shared is a limiter whose state lives in a memory-mapped file, so that every process
+// on the host mapping the same file shares a single budget, for example the workers of
+// a prefork server. Shared instances are thread-safe and process-safe.
+type Shared struct {
+	data      []byte
+	unmap     func() error
+	once      sync.Once
+	allowance *uint64 // current allowance, in units of rate * ns
+	lastCheck *uint64 // time of the last refill, in unix ns
+	rate      uint64
+	unit      uint64
+	stats     counters     // decision counters of this process
+	calls     sync.RWMutex // held for reading by the calls using the segment
+	closed    atomic.Bool  // whether the segment was unmapped
+}
+
+// NewShared maps the file, creating it if needed, and returns a limiter allowing the rate
+// across every process which maps it. All processes must use the same rate, otherwise it
+// fails with ErrMismatch.
+func NewShared(path string, rate int, per time.Duration) (*Shared, error) {
+	unit := uint64(per)
+	if unit < 1 {
+		unit = uint64(time.Second)
+	}
+	if rate < 1 {
+		rate = 1
+	}
+
+	s := &Shared{
+		rate: uint64(rate),
+		unit: unit,
+	}
+
+	data, unmap, err := mapShared(path, sharedSize, s.init)
+	if err != nil {
+		return nil, err
+	}
+
+	s.data, s.unmap = data, unmap
+	s.allowance = s.word(offsetAllowance)
+	s.lastCheck = s.word(offsetLastCheck)
+	return s, nil
+}
+
+// init initializes the segment unless it already is, called while holding the file lock
+func (s *Shared) init(data []byte) error {
+	word := func(offset int) *uint64 {
+		return (*uint64)(unsafe.Pointer(&data[offset]))
+	}
+
+	if atomic.LoadUint64(word(offsetMagic)) == sharedMagic {
+		if atomic.LoadUint64(word(offsetRate)) != s.rate || atomic.LoadUint64(word(offsetUnit)) != s.unit {
+			return ErrMismatch
+		}
+		return nil
+	}
+
+	atomic.StoreUint64(word(offsetRate), s.rate)
+	atomic.StoreUint64(word(offsetUnit), s.unit)
+	atomic.StoreUint64(word(offsetAllowance), s.rate*s.unit)
+	atomic.StoreUint64(word(offsetLastCheck), unixNano())
+	atomic.StoreUint64(word(offsetMagic), sharedMagic)
+	return nil
+}
+
+// word returns a pointer to the word of the segment at the offset
+func (s *Shared) word(offset int) *uint64 {
+	return (*uint64)(unsafe.Pointer(&s.data[offset]))
+}
+
+// Limit returns true if rate was exceeded
+func (s *Shared) Limit() bool {
+	return s.LimitN(1)
+}
+
+// LimitN returns true if the rate would be exceeded by n calls, otherwise it consumes
+// them. Every call is limited once the limiter was closed.
+func (s *Shared) LimitN(n int) bool {
+	if n < 1 {
+		return false
+	}
+
+	s.calls.RLock()
+	defer s.calls.RUnlock()
+	if s.closed.Load() {
+		return true
+	}
+
+	for {
+		current := s.refill()
+		if uint64(n) > current/s.unit {
+			s.stats.denied.Add(1)
+			return true
+		}
+
+		if atomic.CompareAndSwapUint64(s.allowance, current, current-uint64(n)*s.unit) {
+			s.stats.allowed.Add(uint64(n))
+			return false
+		}
+	}
+}
+
+// Remaining returns the number of calls which can currently be made without being
+// limited, which is zero once the limiter was closed.
+func (s *Shared) Remaining() int {
+	s.calls.RLock()
+	defer s.calls.RUnlock()
+	if s.closed.Load() {
+		return 0
+	}
+	return int(s.refill() / s.unit)
+}
+
+// refill adds the allowance accrued since the last check and returns it. The time of the
+// last check only moves forward, so that a process which is behind or a clock stepping
+// back never accrues anything, even after a long gap since the file was last used.
+func (s *Shared) refill() uint64 {
+	now := unixNano()
+	max := s.rate * s.unit
+	for {
+		last := atomic.LoadUint64(s.lastCheck)
+		if now <= last {
+			return atomic.LoadUint64(s.allowance)
+		}
+		if atomic.CompareAndSwapUint64(s.lastCheck, last, now) {
+			refilled := accrue(now-last, s.rate)
+			for {
+				current := atomic.LoadUint64(s.allowance)
+				next := max
+				if current < max && refilled < max-current {
+					next = current + refilled
+				}
+				if atomic.CompareAndSwapUint64(s.allowance, current, next) {
+					return next
+				}
+			}
+		}
+	}
+}
+
+// Stats returns the counters of the decisions made by this process since the limiter was
+// mapped or since the last call to ResetStats().
+func (s *Shared) Stats() Stats {
+	return s.stats.snapshot(false)
+}
+
+// ResetStats returns the counters accumulated since the last read and resets them.
+func (s *Shared) ResetStats() Stats {
+	return s.stats.snapshot(true)
+}
+
+// Close unmaps the shared memory segment once the calls still running are done, after
+// which every call is limited. The state remains in the file for the other processes.
+func (s *Shared) Close() (err error) {
+	s.once.Do(func() {
+		s.calls.Lock()
+		defer s.calls.Unlock()
+		s.closed.Store(true)
+		err = s.unmap()
+	})
+	return
+}
@@ -0,0 +1,13 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+//go:build !unix
+
+package rate
+
+// mapShared is not supported on this platform
+func mapShared(path string, size int, init func([]byte) error) ([]byte, func() error, error) {
+	return nil, nil, ErrUnsupported
+}
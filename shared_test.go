@@ -0,0 +1,163 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+//go:build unix
+
+package rate
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shared", func() {
+	var dir, path string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "shared")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "limiter")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should share the budget across mappings", func() {
+		a, err := NewShared(path, 10, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		defer a.Close()
+
+		b, err := NewShared(path, 10, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		defer b.Close()
+
+		Expect(a.LimitN(6)).To(BeFalse())
+		Expect(b.Remaining()).To(Equal(4))
+		Expect(b.LimitN(5)).To(BeTrue())
+		Expect(b.LimitN(4)).To(BeFalse())
+		Expect(a.Limit()).To(BeTrue())
+
+		Expect(a.Stats()).To(Equal(Stats{Allowed: 6, Denied: 1}))
+		Expect(b.ResetStats()).To(Equal(Stats{Allowed: 4, Denied: 1}))
+	})
+
+	It("should keep the state once unmapped", func() {
+		a, err := NewShared(path, 10, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(a.LimitN(10)).To(BeFalse())
+		Expect(a.Close()).To(Succeed())
+
+		b, err := NewShared(path, 10, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		defer b.Close()
+		Expect(b.Limit()).To(BeTrue())
+	})
+
+	It("should refill over time", func() {
+		s, err := NewShared(path, 100, 100*time.Millisecond)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		Expect(s.LimitN(100)).To(BeFalse())
+		time.Sleep(20 * time.Millisecond)
+		Expect(s.Remaining()).To(BeNumerically(">=", 10))
+	})
+
+	It("should not overflow after a long gap", func() {
+		s, err := NewShared(path, 1000000000, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		Expect(s.LimitN(1000000000)).To(BeFalse())
+		atomic.StoreUint64(s.lastCheck, 1) // last used decades ago
+		Expect(s.Remaining()).To(Equal(1000000000))
+		Expect(s.LimitN(math.MaxInt)).To(BeTrue())
+	})
+
+	It("should not refill when the clock steps back", func() {
+		s, err := NewShared(path, 10, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		Expect(s.LimitN(10)).To(BeFalse())
+		atomic.StoreUint64(s.lastCheck, unixNano()+uint64(time.Hour))
+		Expect(s.Remaining()).To(BeZero())
+	})
+
+	It("should not over-admit under contention", func() {
+		var wg sync.WaitGroup
+		var lock sync.Mutex
+		admitted := 0
+		for i := 0; i < 4; i++ {
+			s, err := NewShared(path, 1000, time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			defer s.Close()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 500; j++ {
+					if !s.Limit() {
+						lock.Lock()
+						admitted++
+						lock.Unlock()
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+		Expect(admitted).To(BeNumerically("~", 1000, 2))
+	})
+
+	It("should limit every call once closed", func() {
+		s, err := NewShared(path, 10, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Close()).To(Succeed())
+		Expect(s.Close()).To(Succeed())
+
+		Expect(s.Limit()).To(BeTrue())
+		Expect(s.Remaining()).To(BeZero())
+	})
+
+	It("should close while calls are running", func() {
+		s, err := NewShared(path, 1000000, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10000; j++ {
+					s.Limit()
+					s.Remaining()
+				}
+			}()
+		}
+
+		time.Sleep(time.Millisecond)
+		Expect(s.Close()).To(Succeed())
+		wg.Wait()
+	})
+
+	It("should reject a different rate", func() {
+		a, err := NewShared(path, 10, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		defer a.Close()
+
+		_, err = NewShared(path, 20, time.Hour)
+		Expect(err).To(Equal(ErrMismatch))
+	})
+})
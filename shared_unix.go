@@ -0,0 +1,50 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+//go:build unix
+
+package rate
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapShared maps the first size bytes of the file into memory, calling init while holding
+// an exclusive lock on the file so that concurrent processes initialize it only once
+func mapShared(path string, size int, init func([]byte) error) ([]byte, func() error, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer file.Close() // the mapping remains valid once the file is closed
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return nil, nil, err
+	}
+
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	if info, err := file.Stat(); err != nil {
+		return nil, nil, err
+	} else if info.Size() < int64(size) {
+		if err := file.Truncate(int64(size)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := init(data); err != nil {
+		syscall.Munmap(data)
+		return nil, nil, err
+	}
+
+	return data, func() error {
+		return syscall.Munmap(data)
+	}, nil
+}
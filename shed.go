@@ -0,0 +1,41 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// AdmitWithin waits for a token for work which is expected to take the estimated duration
+// once started. It fails right away with ErrDeadline if, given the waiters queued and the
+// rate, the work could not start early enough to complete before the deadline of the
+// context, and so does a queued caller once that time has passed. Doomed requests thus
+// fail fast and free the capacity for the others. Without a deadline, it is the same as
+// Wait().
+func (rl *Limiter) AdmitWithin(ctx context.Context, estimated time.Duration) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return rl.Wait(ctx)
+	}
+
+	// The latest time at which the work may start and still complete in time
+	start := deadline.Add(-estimated)
+	if now := time.Unix(0, int64(rl.now())); !now.Before(start) {
+		rl.record(true, 1)
+		return rl.limited(ErrDeadline, 0)
+	}
+
+	wait, cancel := context.WithDeadline(ctx, start)
+	defer cancel()
+
+	err := rl.Wait(wait)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return rl.limited(ErrDeadline, 0)
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AdmitWithin", func() {
+
+	It("should admit work which can complete in time", func() {
+		rl := New(10, time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		Expect(rl.AdmitWithin(ctx, 100*time.Millisecond)).To(Succeed())
+	})
+
+	It("should reject work which can not complete in time", func() {
+		rl := New(10, time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err := rl.AdmitWithin(ctx, time.Second)
+		Expect(errors.Is(err, ErrDeadline)).To(BeTrue())
+		Expect(rl.Remaining()).To(Equal(10))
+		Expect(rl.Stats().Denied).To(Equal(uint64(1)))
+	})
+
+	It("should use the clock of the limiter", func() {
+		clock := NewManualClock(time.Now().Add(time.Hour))
+		rl := New(10, time.Second, WithClock(clock))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		err := rl.AdmitWithin(ctx, time.Second)
+		Expect(errors.Is(err, ErrDeadline)).To(BeTrue())
+		Expect(rl.Remaining()).To(Equal(10))
+	})
+
+	It("should queue on the clock of the limiter", func() {
+		clock := NewManualClock(time.Now().Add(time.Hour))
+		rl := New(10, time.Second, WithClock(clock))
+		Expect(rl.LimitN(10)).To(BeFalse())
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		err := rl.Wait(ctx)
+		Expect(errors.Is(err, ErrDeadline)).To(BeTrue())
+	})
+
+	It("should reject work which could not start in time", func() {
+		rl := New(1, time.Hour)
+		Expect(rl.Limit()).To(BeFalse())
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		start := time.Now()
+		err := rl.AdmitWithin(ctx, 30*time.Second)
+		Expect(errors.Is(err, ErrDeadline)).To(BeTrue())
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	})
+
+	It("should reject a queued caller once the work could no longer complete", func() {
+		rl := New(2, 600*time.Millisecond)
+		Expect(rl.LimitN(2)).To(BeFalse())
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			rl.ReportCost(0, 2) // pushes the next token past the latest start
+		}()
+
+		err := rl.AdmitWithin(ctx, 500*time.Millisecond)
+		Expect(errors.Is(err, ErrDeadline)).To(BeTrue())
+		Expect(ctx.Err()).To(BeNil())
+	})
+
+	It("should wait without a deadline", func() {
+		rl := New(10, time.Second)
+		Expect(rl.AdmitWithin(context.Background(), time.Hour)).To(Succeed())
+	})
+})
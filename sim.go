@@ -0,0 +1,55 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sort"
+	"time"
+)
+
+// Simulate replays a scripted arrival pattern against a limiter driven by a manual clock.
+// The arrivals are offsets from the start of the simulation and each one represents a
+// single call to Limit(). It returns the offsets of the arrivals which were admitted.
+func Simulate(arrivals []time.Duration, rate int, per time.Duration, options ...Option) []time.Duration {
+	arrivals = append([]time.Duration(nil), arrivals...)
+	sort.Slice(arrivals, func(i, j int) bool {
+		return arrivals[i] < arrivals[j]
+	})
+
+	clock := NewManualClock(time.Unix(0, 0))
+	rl := New(rate, per, append(options, WithClock(clock))...)
+	admitted := make([]time.Duration, 0, len(arrivals))
+
+	var elapsed time.Duration
+	for _, at := range arrivals {
+		clock.Advance(at - elapsed)
+		if at > elapsed {
+			elapsed = at
+		}
+
+		if !rl.Limit() {
+			admitted = append(admitted, at)
+		}
+	}
+
+	return admitted
+}
+
+// Peak returns the maximum number of admitted calls observed within any window of the
+// specified length. The admitted offsets must be sorted in ascending order, as returned
+// by Simulate().
+func Peak(admitted []time.Duration, window time.Duration) (peak int) {
+	for lo, hi := 0, 0; hi < len(admitted); hi++ {
+		for admitted[hi]-admitted[lo] >= window {
+			lo++
+		}
+
+		if n := hi - lo + 1; n > peak {
+			peak = n
+		}
+	}
+	return
+}
@@ -0,0 +1,87 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"testing"
+	"testing/quick"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Simulate", func() {
+
+	It("should admit a full burst", func() {
+		arrivals := make([]time.Duration, 20)
+		Expect(Simulate(arrivals, 10, time.Second)).To(HaveLen(10))
+	})
+
+	It("should admit evenly spaced arrivals", func() {
+		var arrivals []time.Duration
+		for i := 0; i < 100; i++ {
+			arrivals = append(arrivals, time.Duration(i)*100*time.Millisecond)
+		}
+
+		admitted := Simulate(arrivals, 10, time.Second)
+		Expect(admitted).To(Equal(arrivals))
+		Expect(Peak(admitted, time.Second)).To(Equal(10))
+	})
+
+	It("should throttle arrivals above the rate", func() {
+		var arrivals []time.Duration
+		for i := 0; i < 1000; i++ {
+			arrivals = append(arrivals, time.Duration(i)*10*time.Millisecond)
+		}
+
+		admitted := Simulate(arrivals, 10, time.Second)
+		Expect(admitted).To(HaveLen(10 + 99))
+		Expect(Peak(admitted, time.Second)).To(BeNumerically("<=", 20))
+	})
+
+	It("should never exceed the rate within a window", func() {
+		Expect(quick.Check(func(rate uint8, offsets []uint16) bool {
+			n := int(rate%50) + 1
+			arrivals := make([]time.Duration, 0, len(offsets))
+			for _, v := range offsets {
+				arrivals = append(arrivals, time.Duration(v)*time.Millisecond)
+			}
+
+			admitted := Simulate(arrivals, n, time.Second)
+			return Peak(admitted, time.Second) <= 2*n
+		}, nil)).To(Succeed())
+	})
+
+	It("should compute the peak within a window", func() {
+		admitted := []time.Duration{0, 1, 2, 10, 11, 20}
+		Expect(Peak(admitted, 5)).To(Equal(3))
+		Expect(Peak(admitted, 12)).To(Equal(5))
+		Expect(Peak(nil, 5)).To(Equal(0))
+	})
+
+})
+
+// --------------------------------------------------------------------
+
+func FuzzSimulate(f *testing.F) {
+	f.Add(uint8(10), []byte{0, 0, 0, 0, 1, 2, 3})
+	f.Fuzz(func(t *testing.T, rate uint8, gaps []byte) {
+		n := int(rate%50) + 1
+		arrivals := make([]time.Duration, 0, len(gaps))
+
+		var at time.Duration
+		for _, gap := range gaps {
+			at += time.Duration(gap) * time.Millisecond
+			arrivals = append(arrivals, at)
+		}
+
+		admitted := Simulate(arrivals, n, 100*time.Millisecond)
+		if peak := Peak(admitted, 100*time.Millisecond); peak > 2*n {
+			t.Fatalf("admitted %d calls within a window, rate is %d", peak, n)
+		}
+	})
+}
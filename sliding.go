@@ -0,0 +1,192 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+// Sliding is a keyed quota which allows a number of operations in any window of time,
+// such as 1000 calls per hour, without the bursts a token bucket would allow over such
+// long windows. Each key divides the window into sixty buckets of 32-bit counters, so
+// its memory stays constant whatever the quota, and the window slides in steps of a
+// sixtieth of its duration. Sliding instances are thread-safe.
+type Sliding struct {
+	shards [shards]slidingShard
+	limit  int   // operations allowed over a window
+	width  int64 // duration of a bucket, in ns
+	clock  Clock // optional source of time
+}
+
+// slidingShard represents a partition of the keys of a sliding quota
+type slidingShard struct {
+	sync.Mutex
+	windows map[string]*window
+	pruneAt int // number of keys beyond which stale ones are pruned
+}
+
+// window represents the operations of a key over the buckets of a sliding window
+type window struct {
+	head    int64  // index of the newest bucket, in bucket widths since the unix epoch
+	total   uint32 // operations over every bucket
+	buckets [trailingBuckets]uint32
+}
+
+// SlidingOption represents an option which can be applied to a sliding quota on creation.
+type SlidingOption func(*Sliding)
+
+// WithSlidingClock sets the clock used by the sliding quota instead of the system time.
+func WithSlidingClock(clock Clock) SlidingOption {
+	return func(s *Sliding) {
+		s.clock = clock
+	}
+}
+
+// NewSliding creates a new sliding quota allowing n operations per key in any window.
+func NewSliding(n int, per time.Duration, options ...SlidingOption) *Sliding {
+	if per < trailingBuckets {
+		per = time.Second
+	}
+
+	s := &Sliding{
+		limit: n,
+		width: int64(per) / trailingBuckets,
+	}
+
+	for _, opt := range options {
+		opt(s)
+	}
+
+	for i := range s.shards {
+		s.shards[i].windows = make(map[string]*window)
+		s.shards[i].pruneAt = 1024
+	}
+	return s
+}
+
+// Limit returns true if the quota of the key was exceeded over the window.
+func (s *Sliding) Limit(key string) bool {
+	return s.LimitN(key, 1)
+}
+
+// LimitN returns true if n operations at once would exceed the quota of the key over the
+// window, otherwise they are consumed.
+func (s *Sliding) LimitN(key string, n int) bool {
+	if n < 0 {
+		n = 0
+	}
+
+	shard := s.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	now := s.now() / s.width
+	w, ok := shard.windows[key]
+	if !ok {
+		if n > s.limit {
+			return true
+		}
+
+		w = &window{head: now}
+		shard.windows[key] = w
+		if len(shard.windows) > shard.pruneAt {
+			shard.prune(now)
+		}
+	}
+
+	w.advance(now)
+	if int(w.total)+n > s.limit {
+		return true
+	}
+
+	w.buckets[now%trailingBuckets] += uint32(n)
+	w.total += uint32(n)
+	return false
+}
+
+// Remaining returns the number of operations the key may still perform in the window.
+func (s *Sliding) Remaining(key string) int {
+	shard := s.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	used := 0
+	if w, ok := shard.windows[key]; ok {
+		w.advance(s.now() / s.width)
+		used = int(w.total)
+	}
+
+	if left := s.limit - used; left > 0 {
+		return left
+	}
+	return 0
+}
+
+// Remove forgets the operations of the key.
+func (s *Sliding) Remove(key string) {
+	shard := s.shard(key)
+	shard.Lock()
+	delete(shard.windows, key)
+	shard.Unlock()
+}
+
+// Len returns the number of keys tracked, including keys whose window has expired and
+// which were not pruned yet.
+func (s *Sliding) Len() (n int) {
+	for i := range s.shards {
+		s.shards[i].Lock()
+		n += len(s.shards[i].windows)
+		s.shards[i].Unlock()
+	}
+	return
+}
+
+// shard returns the shard for the key
+func (s *Sliding) shard(key string) *slidingShard {
+	return &s.shards[hash(key)&(shards-1)]
+}
+
+// now returns the current time as unix nanoseconds
+func (s *Sliding) now() int64 {
+	if s.clock == nil {
+		return time.Now().UnixNano()
+	}
+	return s.clock.Now()
+}
+
+// advance moves the window to the bucket of the current time, clearing the buckets which
+// slid out of it
+func (w *window) advance(now int64) {
+	switch {
+	case now <= w.head:
+		return
+	case now-w.head >= trailingBuckets:
+		*w = window{head: now}
+		return
+	}
+
+	for i := w.head + 1; i <= now; i++ {
+		b := &w.buckets[i%trailingBuckets]
+		w.total -= *b
+		*b = 0
+	}
+	w.head = now
+}
+
+// prune removes the keys whose window has entirely expired, must be called while holding
+// the lock
+func (s *slidingShard) prune(now int64) {
+	for key, w := range s.windows {
+		if now-w.head >= trailingBuckets {
+			delete(s.windows, key)
+		}
+	}
+
+	if s.pruneAt = 2 * len(s.windows); s.pruneAt < 1024 {
+		s.pruneAt = 1024
+	}
+}
@@ -0,0 +1,77 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sliding", func() {
+
+	It("should enforce the quota over any window", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		s := NewSliding(100, time.Hour, WithSlidingClock(clock))
+
+		Expect(s.LimitN("alice", 60)).To(BeFalse())
+		clock.Advance(30 * time.Minute)
+		Expect(s.LimitN("alice", 40)).To(BeFalse())
+		Expect(s.Limit("alice")).To(BeTrue())
+		Expect(s.Remaining("bob")).To(Equal(100))
+
+		// The first operations slide out of the window after an hour
+		clock.Advance(30 * time.Minute)
+		Expect(s.Remaining("alice")).To(Equal(60))
+		clock.Advance(30 * time.Minute)
+		Expect(s.Remaining("alice")).To(Equal(100))
+	})
+
+	It("should reject calls larger than the quota", func() {
+		s := NewSliding(10, time.Hour)
+		Expect(s.LimitN("alice", 11)).To(BeTrue())
+		Expect(s.Len()).To(BeZero())
+		Expect(s.LimitN("alice", 10)).To(BeFalse())
+		Expect(s.Limit("alice")).To(BeTrue())
+
+		s.Remove("alice")
+		Expect(s.Remaining("alice")).To(Equal(10))
+	})
+
+	It("should prune the expired keys", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		s := NewSliding(1, time.Minute, WithSlidingClock(clock))
+
+		for i := 0; i < 64*1024; i++ {
+			s.Limit(strconv.Itoa(i))
+		}
+		before := s.Len()
+
+		clock.Advance(time.Minute)
+		for i := 0; i < 64*1024; i++ {
+			s.Limit("new" + strconv.Itoa(i))
+		}
+		Expect(s.Len()).To(BeNumerically("<", before+64*1024))
+	})
+})
+
+// --------------------------------------------------------------------
+
+func BenchmarkSliding(b *testing.B) {
+	s := NewSliding(1000000000, time.Hour)
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Limit(keys[i%len(keys)])
+	}
+}
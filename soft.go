@@ -0,0 +1,49 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync/atomic"
+)
+
+// soft represents a soft limit below the hard rate, which warns without limiting
+type soft struct {
+	threshold float64     // fraction of the allowance which triggers the warning
+	warn      func()      // callback fired when the threshold is crossed
+	over      atomic.Bool // whether the threshold is currently exceeded
+}
+
+// WithSoftLimit sets a soft limit, as a fraction of the maximum allowance such as 0.8,
+// beyond which traffic is still admitted but the function is called, once each time the
+// threshold is crossed. It allows warning clients before they actually get denied.
+func WithSoftLimit(threshold float64, warn func()) Option {
+	return func(rl *Limiter) {
+		rl.extend().soft = &soft{
+			threshold: threshold,
+			warn:      warn,
+		}
+	}
+}
+
+// SoftLimited returns whether the soft limit is currently exceeded.
+func (rl *Limiter) SoftLimited() bool {
+	s := rl.ext().soft
+	return s != nil && s.over.Load()
+}
+
+// check updates the state of the soft limit after a decision
+func (s *soft) check(rl *Limiter) {
+	_, max := rl.limits(rl.now())
+	used := max - minUint64(rl.allowance.Load(), max)
+	if float64(used) < s.threshold*float64(max) {
+		s.over.Store(false)
+		return
+	}
+
+	if s.over.CompareAndSwap(false, true) && s.warn != nil {
+		s.warn()
+	}
+}
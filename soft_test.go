@@ -0,0 +1,45 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Soft", func() {
+
+	It("should warn when crossing the soft limit", func() {
+		var warnings int
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock), WithSoftLimit(0.8, func() {
+			warnings++
+		}))
+
+		Expect(rl.LimitN(7)).To(BeFalse())
+		Expect(rl.SoftLimited()).To(BeFalse())
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.SoftLimited()).To(BeTrue())
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(warnings).To(Equal(1))
+
+		clock.Advance(time.Second)
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.SoftLimited()).To(BeFalse())
+
+		Expect(rl.LimitN(8)).To(BeFalse())
+		Expect(warnings).To(Equal(2))
+	})
+
+	It("should not be soft limited by default", func() {
+		rl := New(1, time.Second)
+		rl.Limit()
+		Expect(rl.SoftLimited()).To(BeFalse())
+	})
+
+})
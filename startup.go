@@ -0,0 +1,42 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+)
+
+// startup represents the cap of the burst right after the limiter was created
+type startup struct {
+	period uint64 // duration of the cap, in ns
+	until  uint64 // time at which the cap is lifted, in unix ns
+	burst  uint64 // maximum number of tokens while capped
+}
+
+// WithStartupBurst caps the burst at the specified number of tokens for the duration
+// following the creation of the limiter, whatever its maximum allowance, so that a fleet
+// restarting at once does not collectively send full bursts downstream the instant it
+// comes back. The rate is unaffected and the full burst accumulates once the cap is lifted.
+func WithStartupBurst(d time.Duration, burst int) Option {
+	return func(rl *Limiter) {
+		if burst < 0 {
+			burst = 0
+		}
+
+		rl.extend().startup = &startup{
+			period: uint64(d),
+			burst:  uint64(burst),
+		}
+	}
+}
+
+// cap returns the maximum allowance at the specified time, capped while starting up
+func (s *startup) cap(now, max, unit uint64) uint64 {
+	if now < s.until && s.burst*unit < max {
+		return s.burst * unit
+	}
+	return max
+}
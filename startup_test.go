@@ -0,0 +1,43 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Startup", func() {
+	var clock *ManualClock
+	BeforeEach(func() {
+		clock = NewManualClock(time.Unix(0, 0))
+	})
+
+	It("should cap the burst while starting up", func() {
+		rl := New(100, time.Second, WithClock(clock), WithStartupBurst(10*time.Second, 5))
+		Expect(rl.Remaining()).To(Equal(5))
+		Expect(rl.LimitN(6)).To(BeTrue())
+
+		clock.Advance(5 * time.Second)
+		Expect(rl.Remaining()).To(Equal(5))
+	})
+
+	It("should lift the cap once started", func() {
+		rl := New(100, time.Second, WithClock(clock), WithStartupBurst(10*time.Second, 5))
+		clock.Advance(10*time.Second - 1)
+		Expect(rl.Remaining()).To(Equal(5))
+
+		clock.Advance(time.Second)
+		Expect(rl.Remaining()).To(Equal(100))
+	})
+
+	It("should not raise the burst", func() {
+		rl := New(3, time.Second, WithClock(clock), WithStartupBurst(10*time.Second, 5))
+		Expect(rl.Remaining()).To(Equal(3))
+	})
+})
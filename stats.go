@@ -0,0 +1,155 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WaitBuckets are the upper bounds of the wait-time histogram buckets. Waits longer than
+// the last bound are counted in an additional overflow bucket.
+var WaitBuckets = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Histogram represents the number of Wait() calls per bucket of WaitBuckets, where the
+// last element counts the waits which exceeded the last bound.
+type Histogram [len(WaitBuckets) + 1]uint64
+
+// Stats represents a snapshot of the decisions made by a limiter.
+type Stats struct {
+	Allowed  uint64    // Number of operations allowed
+	Denied   uint64    // Number of operations denied
+	Undone   uint64    // Number of operations undone
+	Refunded uint64    // Number of tokens given back by Undo() and Refund()
+	Shadowed uint64    // Number of operations which would have been denied in shadow mode
+	Success  uint64    // Number of operations reported as succeeded
+	Failure  uint64    // Number of operations reported as failed
+	Waits    Histogram // Distribution of time spent in Wait()
+	Drift    float64   // Ratio of the admitted to the configured rate while drifting, or zero
+	Version  uint64    // Version of the active configuration, see ApplyIfNewer()
+}
+
+// counters represents the decision counters of a limiter
+type counters struct {
+	allowed, denied, undone, shadowed atomic.Uint64
+	success, failure, refunded        atomic.Uint64
+	waits                             [len(Histogram{})]atomic.Uint64
+}
+
+// observe records the time spent in a single Wait() call
+func (c *counters) observe(d time.Duration) {
+	i := 0
+	for i < len(WaitBuckets) && d > WaitBuckets[i] {
+		i++
+	}
+	c.waits[i].Add(1)
+}
+
+// record records a single decision for n operations and returns whether it is enforced
+func (rl *Limiter) record(limited bool, n uint64) bool {
+	e := rl.optional.Load()
+	if e == nil {
+		if limited {
+			rl.stats.denied.Add(1)
+		} else {
+			rl.stats.allowed.Add(n)
+		}
+		return limited // none of the optional features observe the decisions
+	}
+
+	if a := e.arrivals; a != nil {
+		a.observe(rl.now(), n, rl.unit)
+	}
+
+	if e.burst != nil || e.drift != nil {
+		now := rl.now()
+		rate, _ := rl.limits(now)
+		if b := e.burst; b != nil {
+			b.observe(now, n, rate, rl.unit)
+		}
+		if d := e.drift; d != nil {
+			d.observe(now, n, limited, rate, rl.unit)
+		}
+	}
+
+	switch {
+	case !limited:
+		rl.stats.allowed.Add(n)
+	case e.shadow:
+		rl.stats.shadowed.Add(1)
+	default:
+		rl.stats.denied.Add(1)
+	}
+
+	if limited && e.penalty != nil {
+		e.penalty.violate(rl.now())
+	}
+	if e.soft != nil {
+		e.soft.check(rl)
+	}
+	if e.latch != nil {
+		e.latch.check(rl, limited)
+	}
+	if e.standing != nil {
+		e.standing.observe(rl.now(), limited)
+	}
+	if e.expiry != nil {
+		e.expiry.last.Store(rl.now())
+	}
+	if h := e.history.Load(); h != nil {
+		h.observe(rl, limited && !e.shadow, n)
+	}
+	return limited && !e.shadow
+}
+
+// Stats returns the counters accumulated since the limiter was created or since the
+// last call to ResetStats().
+func (rl *Limiter) Stats() Stats {
+	stats := rl.stats.snapshot(false)
+	stats.Drift = rl.ext().drift.current()
+	stats.Version = rl.load().version
+	return stats
+}
+
+// ResetStats returns the counters accumulated since the last read and resets them.
+func (rl *Limiter) ResetStats() Stats {
+	stats := rl.stats.snapshot(true)
+	stats.Drift = rl.ext().drift.current()
+	stats.Version = rl.load().version
+	return stats
+}
+
+// snapshot reads the counters, optionally resetting them
+func (c *counters) snapshot(reset bool) Stats {
+	read := (*atomic.Uint64).Load
+	if reset {
+		read = func(v *atomic.Uint64) uint64 { return v.Swap(0) }
+	}
+
+	stats := Stats{
+		Allowed:  read(&c.allowed),
+		Denied:   read(&c.denied),
+		Undone:   read(&c.undone),
+		Refunded: read(&c.refunded),
+		Shadowed: read(&c.shadowed),
+		Success:  read(&c.success),
+		Failure:  read(&c.failure),
+	}
+
+	for i := range stats.Waits {
+		stats.Waits[i] = read(&c.waits[i])
+	}
+	return stats
+}
@@ -0,0 +1,74 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stats", func() {
+
+	It("should count decisions", func() {
+		rl := New(5, time.Minute)
+		for i := 0; i < 8; i++ {
+			rl.Limit()
+		}
+		rl.Undo()
+
+		Expect(rl.Stats()).To(Equal(Stats{Allowed: 5, Denied: 3, Undone: 1, Refunded: 1}))
+	})
+
+	It("should count batches and waits", func() {
+		rl := New(5, time.Minute)
+		b := rl.Batch(3)
+		b.Limit()
+		b.Close()
+
+		Expect(rl.Wait(context.Background())).To(Succeed())
+		Expect(rl.Stats()).To(Equal(Stats{Allowed: 2, Waits: Histogram{1}}))
+	})
+
+	It("should reset on read", func() {
+		rl := New(1, time.Minute)
+		rl.Limit()
+		rl.Limit()
+
+		Expect(rl.ResetStats()).To(Equal(Stats{Allowed: 1, Denied: 1}))
+		Expect(rl.Stats()).To(Equal(Stats{}))
+
+		rl.Limit()
+		Expect(rl.ResetStats()).To(Equal(Stats{Denied: 1}))
+	})
+
+	It("should record wait times", func() {
+		rl := New(1, 20*time.Millisecond)
+		Expect(rl.Wait(context.Background())).To(Succeed())
+		Expect(rl.Wait(context.Background())).To(Succeed())
+
+		stats := rl.Stats()
+		Expect(stats.Waits[0]).To(Equal(uint64(1)))
+		Expect(stats.Waits[3]).To(Equal(uint64(1)))
+	})
+
+	It("should bucket wait times", func() {
+		var c counters
+		c.observe(0)
+		c.observe(time.Millisecond)
+		c.observe(2 * time.Millisecond)
+		c.observe(time.Second)
+		c.observe(time.Hour)
+
+		Expect(c.waits[0].Load()).To(Equal(uint64(2)))
+		Expect(c.waits[1].Load()).To(Equal(uint64(1)))
+		Expect(c.waits[6].Load()).To(Equal(uint64(1)))
+		Expect(c.waits[8].Load()).To(Equal(uint64(1)))
+	})
+
+})
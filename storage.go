@@ -0,0 +1,107 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// StorageTransport is an http.RoundTripper for object-storage clients (S3, GCS and the
+// like) which caps both the request rate and the aggregate bandwidth. Request and
+// response bodies are throttled as they are streamed, so concurrent multipart uploads
+// and ranged downloads share the same bandwidth budget. Either limiter may be nil.
+type StorageTransport struct {
+	Base      http.RoundTripper // Underlying transport, http.DefaultTransport if nil
+	Requests  *Limiter          // Limiter pacing the requests, including each part of an upload
+	Bandwidth *ByteLimiter      // Limiter capping the bytes uploaded and downloaded
+}
+
+// NewStorageTransport creates a new transport allowing the specified number of requests
+// per period and the specified bandwidth in bytes per second, shared by all transfers.
+func NewStorageTransport(requests int, per time.Duration, bytesPerSecond int64) *StorageTransport {
+	return &StorageTransport{
+		Requests:  New(requests, per),
+		Bandwidth: NewByteLimiter(bytesPerSecond, 0),
+	}
+}
+
+// RoundTrip waits for a request token and sends the request, throttling its body as it
+// is uploaded and the body of its response as it is downloaded.
+func (t *StorageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if t.Requests != nil {
+		if err := t.Requests.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if t.Bandwidth != nil && req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(ctx)
+		req.Body = t.throttle(ctx, req.Body)
+		if getBody := req.GetBody; getBody != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return t.throttle(ctx, body), nil
+			}
+		}
+	}
+
+	res, err := base.RoundTrip(req)
+	if err == nil && t.Bandwidth != nil && res.Body != nil {
+		res.Body = t.throttle(ctx, res.Body)
+	}
+	return res, err
+}
+
+// throttle wraps the body so that reading it consumes bandwidth
+func (t *StorageTransport) throttle(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	return &throttledBody{
+		ctx:   ctx,
+		body:  body,
+		bytes: t.Bandwidth,
+	}
+}
+
+// throttledBody represents a request or response body which is read at a limited rate
+type throttledBody struct {
+	ctx   context.Context
+	body  io.ReadCloser
+	bytes *ByteLimiter
+}
+
+// Read reads from the body once the bandwidth allows it, returning the unused part of
+// the reservation when the read is short.
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return b.body.Read(p)
+	}
+
+	if err := b.bytes.WaitBytes(b.ctx, int64(len(p))); err != nil {
+		return 0, err
+	}
+
+	n, err := b.body.Read(p)
+	if n < len(p) {
+		b.bytes.ReturnBytes(int64(len(p) - n))
+	}
+	return n, err
+}
+
+// Close closes the underlying body.
+func (b *throttledBody) Close() error {
+	return b.body.Close()
+}
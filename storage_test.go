@@ -0,0 +1,73 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StorageTransport", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n, _ := io.Copy(io.Discard, r.Body)
+			if n == 0 {
+				w.Write(make([]byte, 5000))
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should cap the request rate", func() {
+		t := NewStorageTransport(2, time.Minute, 1<<20)
+		client := &http.Client{Transport: t}
+		for i := 0; i < 2; i++ {
+			res, err := client.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			res.Body.Close()
+		}
+
+		Expect(t.Requests.Remaining()).To(BeZero())
+	})
+
+	It("should share the bandwidth across uploads and downloads", func() {
+		t := NewStorageTransport(100, time.Second, 10000)
+		client := &http.Client{Transport: t}
+		start := time.Now()
+
+		res, err := client.Post(server.URL, "application/octet-stream", bytes.NewReader(make([]byte, 8000)))
+		Expect(err).NotTo(HaveOccurred())
+		res.Body.Close()
+
+		res, err = client.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		body, err := io.ReadAll(res.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(HaveLen(5000))
+		res.Body.Close()
+
+		Expect(time.Since(start)).To(BeNumerically(">=", 250*time.Millisecond))
+	})
+
+	It("should pass through without limiters", func() {
+		client := &http.Client{Transport: &StorageTransport{}}
+		res, err := client.Post(server.URL, "text/plain", bytes.NewReader([]byte("part")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		res.Body.Close()
+	})
+})
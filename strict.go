@@ -0,0 +1,16 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// WithStrictSpacing spaces the permits at least per/rate apart, without accumulating any
+// allowance for bursts, for pacing calls to systems such as some telephony or payment
+// APIs which reject any two calls closer than a minimum interval. Since a single token is
+// ever available, LimitN() with more than one token is always limited.
+func WithStrictSpacing() Option {
+	return func(rl *Limiter) {
+		rl.extend().strict = true
+	}
+}
@@ -0,0 +1,51 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WithStrictSpacing", func() {
+
+	It("should not allow bursts", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(10, time.Second, WithClock(clock), WithStrictSpacing())
+		Expect(rl.Remaining()).To(Equal(1))
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+
+		clock.Advance(99 * time.Millisecond)
+		Expect(rl.Limit()).To(BeTrue())
+		clock.Advance(time.Millisecond)
+		Expect(rl.Limit()).To(BeFalse())
+
+		clock.Advance(time.Minute)
+		Expect(rl.Remaining()).To(Equal(1))
+		Expect(rl.LimitN(2)).To(BeTrue())
+	})
+
+	It("should keep the shape after a rate update", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(10, time.Second, WithClock(clock), WithStrictSpacing())
+		rl.UpdateRate(100)
+		clock.Advance(time.Second)
+		Expect(rl.Remaining()).To(Equal(1))
+	})
+
+	It("should space the waiters", func() {
+		rl := New(1, 10*time.Millisecond, WithStrictSpacing())
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			Expect(rl.Wait(context.Background())).To(Succeed())
+		}
+		Expect(time.Since(start)).To(BeNumerically(">=", 30*time.Millisecond))
+	})
+})
@@ -0,0 +1,117 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Overflow represents what a subscription does with messages arriving faster than the
+// rate of its limiter.
+type Overflow uint8
+
+// Various overflow policies
+const (
+	OverflowDrop   Overflow = iota // drops the messages exceeding the rate, this is the default
+	OverflowBuffer                 // buffers the messages and delivers them at the rate, dropping them once full
+)
+
+// Subscription paces the delivery of messages received by a subscription callback, such
+// as the one of a NATS or MQTT client, so bursty publishers do not overwhelm the handler.
+type Subscription[M any] struct {
+	limiter  *Limiter
+	callback func(M)
+	buffer   chan M
+	dropped  atomic.Uint64
+	closed   atomic.Bool
+	cancel   context.CancelFunc
+	done     sync.WaitGroup
+}
+
+// NewSubscription creates a new subscription pacing the calls to the callback on the
+// limiter. With OverflowBuffer, up to capacity messages are queued and delivered in
+// order by a background goroutine which runs until Close() is called.
+func NewSubscription[M any](rl *Limiter, overflow Overflow, capacity int, callback func(M)) *Subscription[M] {
+	s := &Subscription[M]{
+		limiter:  rl,
+		callback: callback,
+		cancel:   func() {},
+	}
+
+	if overflow == OverflowBuffer {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.buffer = make(chan M, capacity)
+		s.cancel = cancel
+		s.done.Add(1)
+		go s.drain(ctx)
+	}
+	return s
+}
+
+// Handle receives a message and either delivers, queues or drops it. This is the function
+// to register as the callback of the underlying subscription. Messages received once the
+// subscription was closed are dropped.
+func (s *Subscription[M]) Handle(msg M) {
+	if s.closed.Load() {
+		s.dropped.Add(1)
+		return
+	}
+
+	if s.buffer == nil {
+		if s.limiter.Limit() {
+			s.dropped.Add(1)
+			return
+		}
+
+		s.callback(msg)
+		return
+	}
+
+	select {
+	case s.buffer <- msg:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// drain delivers the buffered messages at the rate of the limiter
+func (s *Subscription[M]) drain(ctx context.Context) {
+	defer s.done.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-s.buffer:
+			if err := s.limiter.Wait(ctx); err != nil {
+				s.dropped.Add(1)
+				continue
+			}
+
+			s.callback(msg)
+		}
+	}
+}
+
+// Dropped returns the number of messages dropped so far.
+func (s *Subscription[M]) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Buffered returns the number of messages waiting to be delivered.
+func (s *Subscription[M]) Buffered() int {
+	return len(s.buffer)
+}
+
+// Close stops delivering the buffered messages and waits for the callback in progress to
+// return. Messages left in the buffer are discarded.
+func (s *Subscription[M]) Close() error {
+	s.closed.Store(true)
+	s.cancel()
+	s.done.Wait()
+	return nil
+}
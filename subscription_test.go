@@ -0,0 +1,71 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Subscription", func() {
+
+	It("should drop messages exceeding the rate", func() {
+		var received []int
+		s := NewSubscription(New(2, time.Minute), OverflowDrop, 0, func(msg int) {
+			received = append(received, msg)
+		})
+		defer s.Close()
+
+		for i := 0; i < 5; i++ {
+			s.Handle(i)
+		}
+
+		Expect(received).To(Equal([]int{0, 1}))
+		Expect(s.Dropped()).To(Equal(uint64(3)))
+	})
+
+	It("should buffer and deliver messages in order", func() {
+		var lock sync.Mutex
+		var received []int
+		s := NewSubscription(New(1, 5*time.Millisecond), OverflowBuffer, 10, func(msg int) {
+			lock.Lock()
+			defer lock.Unlock()
+			received = append(received, msg)
+		})
+		defer s.Close()
+
+		for i := 0; i < 4; i++ {
+			s.Handle(i)
+		}
+
+		Eventually(func() []int {
+			lock.Lock()
+			defer lock.Unlock()
+			return append([]int(nil), received...)
+		}).Should(Equal([]int{0, 1, 2, 3}))
+		Expect(s.Dropped()).To(BeZero())
+	})
+
+	It("should drop messages once the buffer is full", func() {
+		rl := New(1, time.Hour)
+		rl.Limit()
+
+		s := NewSubscription(rl, OverflowBuffer, 2, func(int) {})
+		s.Handle(0)
+		Eventually(s.Buffered).Should(BeZero())
+
+		for i := 1; i < 5; i++ {
+			s.Handle(i)
+		}
+
+		Expect(s.Dropped()).To(Equal(uint64(2)))
+		Expect(s.Buffered()).To(Equal(2))
+		s.Close()
+	})
+})
@@ -0,0 +1,87 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"math"
+)
+
+// tag represents the sub-budget of a category of calls within a limiter
+type tag struct {
+	share   float64  // fraction of the rate of the limiter
+	limiter *Limiter // sub-budget of the category
+}
+
+// WithTagBudget caps the calls tagged with a category, such as "read" or "expensive", at
+// a percentage of the rate, between 0 and 100, while they still share the overall limit
+// with the other calls. Capping the reads at 70% guarantees that writes are never crowded
+// out of the remaining 30% by a flood of reads. The sub-budgets follow rate updates.
+func WithTagBudget(name string, percent float64) Option {
+	return func(rl *Limiter) {
+		e := rl.extend()
+		if e.tags == nil {
+			e.tags = make(map[string]*tag)
+		}
+		e.tags[name] = &tag{share: clampPercent(percent) / 100}
+	}
+}
+
+// LimitTag returns true if the rate or the sub-budget of the tag was exceeded. Calls with
+// a tag which has no sub-budget are only subject to the overall limit.
+func (rl *Limiter) LimitTag(name string) bool {
+	return rl.LimitTagN(name, 1)
+}
+
+// LimitTagN returns true if n calls at once would exceed the rate or the sub-budget of
+// the tag, otherwise they are consumed from both.
+func (rl *Limiter) LimitTagN(name string, n int) bool {
+	t, ok := rl.ext().tags[name]
+	if !ok || n < 1 {
+		return rl.LimitN(n)
+	}
+
+	if t.limiter.LimitN(n) {
+		return rl.record(true, uint64(n))
+	}
+
+	if rl.LimitN(n) {
+		untake(t.limiter, n)
+		return true
+	}
+	return false
+}
+
+// TagStats returns the decision counters of the sub-budget of the tag, or zero stats if
+// the tag has no sub-budget. Calls denied by the overall limit are not counted as denied
+// by the sub-budget.
+func (rl *Limiter) TagStats(name string) Stats {
+	if t, ok := rl.ext().tags[name]; ok {
+		return t.limiter.Stats()
+	}
+	return Stats{}
+}
+
+// retag creates or updates the sub-budgets of the tags for the rate of the limiter
+func (rl *Limiter) retag(rate int) {
+	for _, t := range rl.ext().tags {
+		n := portion(rate, t.share)
+
+		if t.limiter != nil {
+			t.limiter.UpdateRate(n)
+			continue
+		}
+
+		t.limiter = rl.derive(n)
+	}
+}
+
+// portion returns the share of the rate, rounded to at least a single token
+func portion(rate int, share float64) int {
+	if n := int(math.Round(float64(rate) * share)); n > 1 {
+		return n
+	}
+	return 1
+}
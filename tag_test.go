@@ -0,0 +1,44 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tag", func() {
+
+	It("should keep room for the other tags", func() {
+		rl := New(10, time.Hour, WithTagBudget("read", 70))
+		for i := 0; i < 7; i++ {
+			Expect(rl.LimitTag("read")).To(BeFalse())
+		}
+
+		Expect(rl.LimitTag("read")).To(BeTrue())
+		Expect(rl.Remaining()).To(Equal(3))
+		Expect(rl.LimitTagN("write", 3)).To(BeFalse())
+		Expect(rl.LimitTag("write")).To(BeTrue())
+	})
+
+	It("should share the overall limit", func() {
+		rl := New(10, time.Hour, WithTagBudget("read", 70))
+		Expect(rl.LimitN(9)).To(BeFalse())
+		Expect(rl.LimitTagN("read", 2)).To(BeTrue())
+		Expect(rl.TagStats("read").Allowed).To(BeZero())
+		Expect(rl.LimitTag("read")).To(BeFalse())
+		Expect(rl.TagStats("read").Allowed).To(BeEquivalentTo(1))
+	})
+
+	It("should follow rate updates", func() {
+		rl := New(10, time.Hour, WithTagBudget("read", 50))
+		rl.UpdateRate(20)
+		Expect(rl.ext().tags["read"].limiter.View().Burst()).To(Equal(10))
+		Expect(rl.TagStats("write")).To(Equal(Stats{}))
+	})
+})
@@ -0,0 +1,85 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// errToken is returned when a token is not a well-formed JWT
+var errToken = errors.New("rate: malformed token")
+
+// TokenVerifier verifies a bearer token, such as the signature and the expiry of a JWT,
+// and returns its claims.
+type TokenVerifier func(token string) (map[string]interface{}, error)
+
+// BearerClaim returns a key function using a claim of the bearer token of the request,
+// such as "sub" or "tenant", so that limits apply per account. The token is verified
+// first and requests without a valid token or without the claim are keyed by their
+// remote address instead.
+func BearerClaim(claim string, verify TokenVerifier) KeyFunc {
+	fallback := RemoteAddr()
+	return func(r *http.Request) string {
+		token, ok := bearer(r)
+		if !ok {
+			return fallback(r)
+		}
+
+		claims, err := verify(token)
+		if err != nil {
+			return fallback(r)
+		}
+
+		switch v := claims[claim].(type) {
+		case nil:
+			return fallback(r)
+		case string:
+			if v == "" {
+				return fallback(r)
+			}
+			return v
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+}
+
+// UnverifiedClaims decodes the claims of a JWT without verifying it. It must only be used
+// as a TokenVerifier when tokens were already verified upstream, for example by an API
+// gateway, since anyone can otherwise forge a token with the claims of their choosing.
+func UnverifiedClaims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, errToken
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errToken
+	}
+	return claims, nil
+}
+
+// bearer returns the bearer token of the request, if any
+func bearer(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
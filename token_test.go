@@ -0,0 +1,62 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Token", func() {
+	jwt := func(payload string) string {
+		return "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".sig"
+	}
+
+	request := func(auth string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "1.2.3.4:1000"
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		return r
+	}
+
+	It("should decode unverified claims", func() {
+		claims, err := UnverifiedClaims(jwt(`{"sub":"alice","org":42}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(claims).To(HaveKeyWithValue("sub", "alice"))
+
+		for _, invalid := range []string{"abc", "a.!!.c", jwt("not json")} {
+			_, err := UnverifiedClaims(invalid)
+			Expect(err).To(Equal(errToken))
+		}
+	})
+
+	It("should key requests by claim", func() {
+		Expect(BearerClaim("sub", UnverifiedClaims)(request("Bearer " + jwt(`{"sub":"alice"}`)))).To(Equal("alice"))
+		Expect(BearerClaim("org", UnverifiedClaims)(request("bearer " + jwt(`{"org":42}`)))).To(Equal("42"))
+	})
+
+	It("should fall back on the remote address", func() {
+		key := BearerClaim("sub", UnverifiedClaims)
+		Expect(key(request(""))).To(Equal("1.2.3.4"))
+		Expect(key(request("Basic abc"))).To(Equal("1.2.3.4"))
+		Expect(key(request("Bearer garbage"))).To(Equal("1.2.3.4"))
+		Expect(key(request("Bearer " + jwt(`{"org":"x"}`)))).To(Equal("1.2.3.4"))
+		Expect(key(request("Bearer " + jwt(`{"sub":""}`)))).To(Equal("1.2.3.4"))
+
+		reject := BearerClaim("sub", func(string) (map[string]interface{}, error) {
+			return nil, errors.New("invalid signature")
+		})
+		Expect(reject(request("Bearer " + jwt(`{"sub":"alice"}`)))).To(Equal("1.2.3.4"))
+	})
+
+})
@@ -0,0 +1,143 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+// trailingBuckets is the number of buckets a trailing window is divided into
+const trailingBuckets = 60
+
+// Trailing is a limiter which admits calls as long as the average admitted rate over a
+// trailing window stays under the limit, so that short spikes are allowed when they are
+// compensated by quiet periods. This suits metering rather than protecting a service.
+// The window slides in steps of a sixtieth of its duration. Trailing instances are
+// thread-safe.
+type Trailing struct {
+	lock    sync.Mutex
+	limit   int   // operations allowed over the window
+	width   int64 // duration of a bucket, in ns
+	clock   Clock // optional source of time
+	buckets [trailingBuckets]admitted
+}
+
+// admitted represents the operations admitted within a bucket of a trailing window
+type admitted struct {
+	start int64 // start of the bucket, in unix ns
+	count int   // operations admitted within the bucket
+}
+
+// TrailingOption represents an option which can be applied to a trailing limiter on
+// creation.
+type TrailingOption func(*Trailing)
+
+// WithTrailingClock sets the clock used by the trailing limiter instead of the system
+// time.
+func WithTrailingClock(clock Clock) TrailingOption {
+	return func(t *Trailing) {
+		t.clock = clock
+	}
+}
+
+// NewTrailing creates a new limiter admitting the specified rate on average over the
+// trailing window, such as 10 per second averaged over a minute, which admits up to 600
+// calls in any minute.
+func NewTrailing(rate int, per, window time.Duration, options ...TrailingOption) *Trailing {
+	if per <= 0 {
+		per = time.Second
+	}
+	if window < trailingBuckets {
+		window = per // too short to be divided into buckets
+	}
+
+	t := &Trailing{
+		limit: int(float64(rate) * float64(window) / float64(per)),
+		width: int64(window) / trailingBuckets,
+	}
+
+	for _, opt := range options {
+		opt(t)
+	}
+	return t
+}
+
+// Limit returns true if admitting the call would exceed the average rate.
+func (t *Trailing) Limit() bool {
+	return t.LimitN(1)
+}
+
+// LimitN returns true if admitting n calls at once would exceed the average rate,
+// otherwise they are admitted.
+func (t *Trailing) LimitN(n int) bool {
+	if n < 1 {
+		return false
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.now()
+	if t.admitted(now)+n > t.limit {
+		return true
+	}
+
+	b := t.bucket(now)
+	b.count += n
+	return false
+}
+
+// Remaining returns the number of calls which would currently be admitted.
+func (t *Trailing) Remaining() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if left := t.limit - t.admitted(t.now()); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// Average returns the average number of calls admitted per second over the window.
+func (t *Trailing) Average() float64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	window := float64(t.width*trailingBuckets) / float64(time.Second)
+	return float64(t.admitted(t.now())) / window
+}
+
+// admitted returns the number of calls admitted over the window, must be called while
+// holding the lock
+func (t *Trailing) admitted(now int64) (n int) {
+	oldest := now/t.width*t.width - t.width*(trailingBuckets-1)
+	for _, b := range t.buckets {
+		if b.start >= oldest && b.start <= now {
+			n += b.count
+		}
+	}
+	return
+}
+
+// bucket returns the bucket of the current time, recycling it if it is stale, must be
+// called while holding the lock
+func (t *Trailing) bucket(now int64) *admitted {
+	start := now / t.width * t.width
+	b := &t.buckets[(start/t.width)%trailingBuckets]
+	if b.start != start {
+		b.start, b.count = start, 0
+	}
+	return b
+}
+
+// now returns the current time as unix nanoseconds
+func (t *Trailing) now() int64 {
+	if t.clock == nil {
+		return time.Now().UnixNano()
+	}
+	return t.clock.Now()
+}
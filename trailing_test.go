@@ -0,0 +1,59 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Trailing", func() {
+
+	It("should allow spikes compensated by quiet periods", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		t := NewTrailing(1, time.Second, time.Minute, WithTrailingClock(clock))
+
+		Expect(t.LimitN(60)).To(BeFalse())
+		Expect(t.Limit()).To(BeTrue())
+		Expect(t.Remaining()).To(BeZero())
+		Expect(t.Average()).To(BeNumerically("~", 1, 0.001))
+
+		clock.Advance(30 * time.Second)
+		Expect(t.Limit()).To(BeTrue())
+	})
+
+	It("should slide the window", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		t := NewTrailing(1, time.Second, time.Minute, WithTrailingClock(clock))
+
+		Expect(t.LimitN(30)).To(BeFalse())
+		clock.Advance(30 * time.Second)
+		Expect(t.LimitN(30)).To(BeFalse())
+		Expect(t.Limit()).To(BeTrue())
+
+		clock.Advance(30 * time.Second)
+		Expect(t.Remaining()).To(Equal(30))
+		clock.Advance(30 * time.Second)
+		Expect(t.Remaining()).To(Equal(60))
+		Expect(t.Average()).To(BeZero())
+	})
+
+	It("should reject calls larger than the window allows", func() {
+		t := NewTrailing(10, time.Second, time.Second)
+		Expect(t.LimitN(11)).To(BeTrue())
+		Expect(t.LimitN(10)).To(BeFalse())
+	})
+
+	It("should ignore calls for less than one operation", func() {
+		t := NewTrailing(10, time.Second, time.Second)
+		Expect(t.LimitN(-5)).To(BeFalse())
+		Expect(t.LimitN(0)).To(BeFalse())
+		Expect(t.Remaining()).To(Equal(10))
+		Expect(t.LimitN(11)).To(BeTrue())
+	})
+})
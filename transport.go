@@ -0,0 +1,123 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Transport is an http.RoundTripper which paces outbound requests on a limiter, each
+// request waiting for a token before being sent. Rules allow a single transport to
+// respect several distinct upstream quotas, such as the core and the search API of a
+// provider, or its regional endpoints.
+type Transport struct {
+	Base    http.RoundTripper        // Underlying transport, http.DefaultTransport if nil
+	Limiter *Limiter                 // Limiter pacing the requests which match no rule
+	Rules   []Rule                   // Optional limiters of specific hosts and paths
+	Cost    func(*http.Response) int // Optional actual cost of a request, given its response
+	Sync    bool                     // Whether to mirror the quota headers of the responses
+	once    sync.Once
+	rules   []endpoint // parsed rules
+}
+
+// Rule paces the outbound requests matching a pattern on a specific limiter. The pattern
+// is a host, optionally followed by a path, such as "api.github.com/search/*". A host
+// starting with "*." matches any of its subdomains, and a "*" segment of the path matches
+// any single segment, or any non-empty remainder of the path when it is the last one. The
+// host is matched regardless of case, the path is case-sensitive.
+type Rule struct {
+	Pattern string   // Host and optional path of the requests
+	Limiter *Limiter // Limiter pacing the matching requests
+}
+
+// endpoint represents a parsed rule
+type endpoint struct {
+	host    string // host, or the suffix of the subdomains if it starts with "."
+	path    *route // optional path of the rule
+	limiter *Limiter
+}
+
+// RoundTrip waits for a token of the limiter of the first rule matching the request, or
+// else of the default limiter, and sends the request. If a cost function is set, the
+// limiter is reconciled with the cost it reports once the response is received. If
+// Sync is set, the limiter mirrors the quota advertised by the response headers. The
+// requests which match no rule are sent right away if there is no default limiter.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rl := t.limiter(req)
+	if rl != nil {
+		if err := rl.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	res, err := base.RoundTrip(req)
+	if err != nil || rl == nil {
+		return res, err
+	}
+
+	if t.Cost != nil {
+		if cost := t.Cost(res); cost > 0 {
+			rl.ReportCost(1, cost)
+		}
+	}
+	if t.Sync {
+		SyncHeaders(rl, res.Header)
+	}
+	return res, nil
+}
+
+// limiter returns the limiter applicable to the request, parsing the rules on first use
+func (t *Transport) limiter(req *http.Request) *Limiter {
+	t.once.Do(func() {
+		for _, r := range t.Rules {
+			t.rules = append(t.rules, parseEndpoint(r))
+		}
+	})
+
+	host := strings.ToLower(req.URL.Hostname())
+	for i := range t.rules {
+		if t.rules[i].match(host, req.URL.Path) {
+			return t.rules[i].limiter
+		}
+	}
+	return t.Limiter
+}
+
+// parseEndpoint parses the pattern of a rule
+func parseEndpoint(r Rule) endpoint {
+	host, path, hasPath := strings.Cut(r.Pattern, "/")
+	e := endpoint{
+		host:    strings.TrimPrefix(strings.ToLower(host), "*"), // only hosts are case-insensitive
+		limiter: r.Limiter,
+	}
+
+	if hasPath {
+		p := parseRoute("/" + path)
+		e.path = &p
+	}
+	return e
+}
+
+// match returns whether the endpoint matches the host and the path
+func (e *endpoint) match(host, path string) bool {
+	switch {
+	case strings.HasPrefix(e.host, "."):
+		if !strings.HasSuffix(host, e.host) {
+			return false
+		}
+	case host != e.host:
+		return false
+	}
+
+	return e.path == nil || e.path.match("", path)
+}
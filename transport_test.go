@@ -0,0 +1,135 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transport", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Cost", r.URL.Query().Get("cost"))
+			w.Header().Set("X-RateLimit-Remaining", "2")
+			w.Header().Set("X-RateLimit-Reset", "60")
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should pace requests", func() {
+		rl := New(1, time.Minute)
+		client := &http.Client{Transport: &Transport{Limiter: rl}}
+		res, err := client.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		res.Body.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+		_, err = client.Do(req)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should charge the cost reported by responses", func() {
+		rl := New(10, time.Minute)
+		client := &http.Client{Transport: &Transport{
+			Limiter: rl,
+			Cost: func(res *http.Response) int {
+				cost, _ := strconv.Atoi(res.Header.Get("X-Cost"))
+				return cost
+			},
+		}}
+
+		res, err := client.Get(server.URL + "?cost=5")
+		Expect(err).NotTo(HaveOccurred())
+		res.Body.Close()
+		Expect(rl.Remaining()).To(Equal(5))
+
+		res, err = client.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		res.Body.Close()
+		Expect(rl.Remaining()).To(Equal(4))
+	})
+
+	It("should mirror the quota of the responses", func() {
+		rl := New(10, time.Second)
+		client := &http.Client{Transport: &Transport{Limiter: rl, Sync: true}}
+		res, err := client.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		res.Body.Close()
+
+		Expect(rl.Remaining()).To(Equal(2))
+		Expect(rl.Debt()).To(BeNumerically(">", 500))
+	})
+
+	It("should pace requests on the limiter of their endpoint", func() {
+		search, core := New(5, time.Minute), New(5, time.Minute)
+		client := &http.Client{Transport: &Transport{
+			Limiter: core,
+			Rules: []Rule{
+				{Pattern: "127.0.0.1/search/*", Limiter: search},
+				{Pattern: "example.com", Limiter: New(1, time.Minute)},
+			},
+		}}
+
+		for _, path := range []string{"/search/code", "/search/issues", "/repos"} {
+			res, err := client.Get(server.URL + path)
+			Expect(err).NotTo(HaveOccurred())
+			res.Body.Close()
+		}
+
+		Expect(search.Remaining()).To(Equal(3))
+		Expect(core.Remaining()).To(Equal(4))
+	})
+
+	It("should send the requests matching no rule without a default limiter", func() {
+		client := &http.Client{Transport: &Transport{
+			Rules: []Rule{{Pattern: "example.com", Limiter: New(1, time.Minute)}},
+		}}
+
+		for i := 0; i < 3; i++ {
+			res, err := client.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			res.Body.Close()
+		}
+	})
+
+	It("should match hosts and paths", func() {
+		for _, tc := range []struct {
+			pattern, host, path string
+			match               bool
+		}{
+			{"api.github.com", "api.github.com", "/anything", true},
+			{"API.github.com", "api.github.com", "/", true},
+			{"api.github.com", "github.com", "/", false},
+			{"*.amazonaws.com", "s3.eu-west-1.amazonaws.com", "/bucket", true},
+			{"*.amazonaws.com", "amazonaws.com", "/bucket", false},
+			{"api.github.com/search/*", "api.github.com", "/search/code", true},
+			{"api.github.com/search/*", "api.github.com", "/search", false},
+			{"api.github.com/repos/*/issues", "api.github.com", "/repos/rate/issues", true},
+			{"api.github.com/repos/*/issues", "api.github.com", "/repos/rate/pulls", false},
+			{"API.github.com/Search/*", "api.github.com", "/Search/code", true},
+			{"api.github.com/Search/*", "api.github.com", "/search/code", false},
+		} {
+			e := parseEndpoint(Rule{Pattern: tc.pattern})
+			Expect(e.match(tc.host, tc.path)).To(Equal(tc.match), "%s %s%s", tc.pattern, tc.host, tc.path)
+		}
+	})
+
+})
@@ -0,0 +1,48 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import "context"
+
+// TupleKey returns the key of a tuple of parts, such as a user and a route, for the
+// Keyed64 limiter. The parts are hashed in place rather than concatenated, so the key
+// never allocates, and each part is terminated by its length so that ("ab", "c") and
+// ("a", "bc") are different tuples.
+func TupleKey(parts ...string) uint64 {
+	h := uint64(14695981039346656037)
+	for _, part := range parts {
+		for i := 0; i < len(part); i++ {
+			h ^= uint64(part[i])
+			h *= 1099511628211
+		}
+
+		for n := len(part); ; n >>= 8 {
+			h ^= uint64(n & 0xff)
+			h *= 1099511628211
+			if n < 0x100 {
+				break
+			}
+		}
+	}
+	return h
+}
+
+// GetTuple returns the limiter of the tuple of parts, creating it if needed.
+func (k *Keyed64) GetTuple(parts ...string) *Limiter {
+	return k.Get(TupleKey(parts...))
+}
+
+// LimitTuple returns true if rate was exceeded for the tuple of parts, such as a user and
+// a route for "per user per endpoint" limits.
+func (k *Keyed64) LimitTuple(parts ...string) bool {
+	return k.Get(TupleKey(parts...)).Limit()
+}
+
+// WaitTuple blocks until a token is available for the tuple of parts or the context is
+// done.
+func (k *Keyed64) WaitTuple(ctx context.Context, parts ...string) error {
+	return k.Get(TupleKey(parts...)).Wait(ctx)
+}
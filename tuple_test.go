@@ -0,0 +1,63 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TupleKey", func() {
+
+	It("should tell the parts apart", func() {
+		Expect(TupleKey("ab", "c")).NotTo(Equal(TupleKey("a", "bc")))
+		Expect(TupleKey("a", "")).NotTo(Equal(TupleKey("", "a")))
+		Expect(TupleKey("a")).NotTo(Equal(TupleKey("a", "")))
+		Expect(TupleKey("alice", "/login")).To(Equal(TupleKey("alice", "/login")))
+	})
+
+	It("should limit each user per endpoint", func() {
+		k := NewKeyed64(1, time.Hour)
+		Expect(k.LimitTuple("alice", "/login")).To(BeFalse())
+		Expect(k.LimitTuple("alice", "/login")).To(BeTrue())
+		Expect(k.LimitTuple("alice", "/search")).To(BeFalse())
+		Expect(k.LimitTuple("bob", "/login")).To(BeFalse())
+		Expect(k.GetTuple("bob", "/login").Remaining()).To(BeZero())
+		Expect(k.Len()).To(Equal(3))
+	})
+
+	It("should wait for the tuple", func() {
+		k := NewKeyed64(1, 10*time.Millisecond)
+		Expect(k.WaitTuple(context.Background(), "alice", "/login")).To(Succeed())
+		Expect(k.WaitTuple(context.Background(), "alice", "/login")).To(Succeed())
+	})
+
+	It("should not allocate", func() {
+		k := NewKeyed64(1000, time.Second)
+		user, route := "alice", "/login"
+		k.LimitTuple(user, route)
+		Expect(testing.AllocsPerRun(100, func() { TupleKey(user, route) })).To(BeZero())
+		Expect(testing.AllocsPerRun(100, func() { k.LimitTuple(user, route) })).To(BeZero())
+	})
+})
+
+// --------------------------------------------------------------------
+
+func BenchmarkLimitTuple(b *testing.B) {
+	k := NewKeyed64(1000000000, time.Second)
+	users := []string{"alice", "bob", "carol", "dave"}
+	routes := []string{"/login", "/search", "/checkout"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k.LimitTuple(users[i%len(users)], routes[i%len(routes)])
+	}
+}
@@ -0,0 +1,64 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SyncQuota mirrors the quota of an upstream provider, which allows a number of remaining
+// calls until it resets. The allowance is lowered to the remaining calls and the tokens
+// refilled until the reset are owed, so that the limiter never admits more calls than
+// the provider would, then resumes its normal rate.
+func (rl *Limiter) SyncQuota(remaining int, reset time.Duration) {
+	if remaining < 0 {
+		remaining = 0
+	}
+	if reset < 0 {
+		reset = 0
+	}
+
+	limit := rl.units(uint64(remaining))
+	rl.clamp(limit)
+
+	now := rl.now()
+	rate, _ := rl.limits(now)
+	current := minUint64(rl.refill(), limit)
+	if refilled := rate * uint64(reset); current+refilled > limit {
+		rl.debt.Store(current + refilled - limit)
+	} else {
+		rl.debt.Store(0)
+	}
+}
+
+// SyncHeaders mirrors the quota advertised by the headers of an upstream response, such
+// as X-RateLimit-Remaining and X-RateLimit-Reset or their RateLimit-* equivalents, and
+// returns whether any was found. Resets are either unix times in seconds or a number of
+// seconds from now.
+func SyncHeaders(rl *Limiter, h http.Header) bool {
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		remaining, err := strconv.Atoi(h.Get(prefix + "Remaining"))
+		if err != nil {
+			continue
+		}
+
+		reset, err := strconv.ParseInt(h.Get(prefix+"Reset"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		d := time.Duration(reset) * time.Second
+		if reset > 1e9 { // a unix time rather than a delay
+			d = time.Until(time.Unix(reset, 0))
+		}
+
+		rl.SyncQuota(remaining, d)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Upstream", func() {
+
+	It("should not admit more than the upstream quota", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock))
+		rl.SyncQuota(3, 2*time.Second)
+		Expect(rl.Remaining()).To(Equal(3))
+
+		var admitted int
+		for i := 0; i < 20; i++ {
+			if !rl.Limit() {
+				admitted++
+			}
+			clock.Advance(100 * time.Millisecond)
+		}
+
+		Expect(admitted).To(Equal(3))
+		Expect(rl.Debt()).To(BeZero())
+		clock.Advance(time.Second)
+		Expect(rl.Remaining()).To(Equal(10))
+	})
+
+	It("should not owe anything when the quota is generous", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock))
+		rl.SyncQuota(1000, time.Second)
+		Expect(rl.Debt()).To(BeZero())
+		Expect(rl.Remaining()).To(Equal(10))
+	})
+
+	It("should parse quota headers", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := New(10, time.Second, WithClock(clock))
+		Expect(SyncHeaders(rl, http.Header{})).To(BeFalse())
+		Expect(SyncHeaders(rl, http.Header{"X-Ratelimit-Remaining": {"x"}, "X-Ratelimit-Reset": {"1"}})).To(BeFalse())
+
+		Expect(SyncHeaders(rl, http.Header{"Ratelimit-Remaining": {"2"}, "Ratelimit-Reset": {"1"}})).To(BeTrue())
+		Expect(rl.Remaining()).To(Equal(2))
+		Expect(rl.Debt()).To(Equal(10))
+
+		reset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+		Expect(SyncHeaders(rl, http.Header{"X-Ratelimit-Remaining": {"1"}, "X-Ratelimit-Reset": {reset}})).To(BeTrue())
+		Expect(rl.Remaining()).To(Equal(1))
+		Expect(rl.Debt()).To(BeNumerically("~", 36000, 20))
+	})
+
+})
@@ -0,0 +1,23 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+// ApplyIfNewer updates the rate, as UpdateRate() does, only if the version is newer than
+// the one of the active configuration, and returns whether it was applied. Control planes
+// pushing limits to many instances stamp every update with an increasing version, so that
+// updates delivered out of order never revert a newer setting. Updates made with
+// UpdateRate() keep the active version.
+func (rl *Limiter) ApplyIfNewer(version uint64, rate int) bool {
+	return rl.configure(rate, func(current *config) (uint64, bool) {
+		return version, version > current.version
+	})
+}
+
+// Version returns the version of the active configuration, zero until an update was
+// applied with ApplyIfNewer().
+func (rl *Limiter) Version() uint64 {
+	return rl.load().version
+}
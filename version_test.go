@@ -0,0 +1,55 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ApplyIfNewer", func() {
+
+	It("should ignore updates delivered out of order", func() {
+		rl := New(10, time.Second)
+		Expect(rl.Version()).To(BeZero())
+
+		Expect(rl.ApplyIfNewer(2, 20)).To(BeTrue())
+		Expect(rl.ApplyIfNewer(1, 5)).To(BeFalse())
+		Expect(rl.ApplyIfNewer(2, 5)).To(BeFalse())
+		Expect(rl.View().Burst()).To(Equal(20))
+		Expect(rl.Stats().Version).To(BeEquivalentTo(2))
+
+		Expect(rl.ApplyIfNewer(3, 30)).To(BeTrue())
+		Expect(rl.View().Burst()).To(Equal(30))
+	})
+
+	It("should keep the version on unversioned updates", func() {
+		rl := New(10, time.Second)
+		rl.ApplyIfNewer(4, 20)
+		rl.UpdateRate(8)
+		Expect(rl.Version()).To(BeEquivalentTo(4))
+		Expect(rl.View().Burst()).To(Equal(8))
+	})
+
+	It("should keep the newest of concurrent updates", func() {
+		rl := New(10, time.Second)
+		var wg sync.WaitGroup
+		for i := 1; i <= 50; i++ {
+			wg.Add(1)
+			go func(v int) {
+				defer wg.Done()
+				rl.ApplyIfNewer(uint64(v), v)
+			}(i)
+		}
+
+		wg.Wait()
+		Expect(rl.Version()).To(BeEquivalentTo(50))
+		Expect(rl.View().Burst()).To(Equal(50))
+	})
+})
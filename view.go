@@ -0,0 +1,50 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import "time"
+
+// LimiterView is a read-only view of a limiter which can be handed to reporting or
+// diagnostic code without exposing the methods which consume or configure it.
+type LimiterView struct {
+	limiter *Limiter
+}
+
+// View returns a read-only view of the limiter.
+func (rl *Limiter) View() LimiterView {
+	return LimiterView{limiter: rl}
+}
+
+// Name returns the name of the limiter, if any.
+func (v LimiterView) Name() string {
+	return v.limiter.ext().name
+}
+
+// Tokens returns the number of tokens currently available, including fractions of a
+// token being refilled.
+func (v LimiterView) Tokens() float64 {
+	rl := v.limiter
+	return float64(rl.refill()) / float64(rl.unit)
+}
+
+// Rate returns the current rate of the limiter, in tokens per second.
+func (v LimiterView) Rate() float64 {
+	rl := v.limiter
+	rate, _ := rl.limits(rl.now())
+	return float64(rate) * float64(time.Second) / float64(rl.unit)
+}
+
+// Burst returns the maximum number of tokens which can be accumulated.
+func (v LimiterView) Burst() int {
+	rl := v.limiter
+	_, max := rl.limits(rl.now())
+	return int(max / rl.unit)
+}
+
+// Stats returns the decision counters of the limiter.
+func (v LimiterView) Stats() Stats {
+	return v.limiter.Stats()
+}
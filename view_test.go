@@ -0,0 +1,41 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LimiterView", func() {
+
+	It("should report the state of the limiter", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(10, time.Minute, WithName("api"), WithClock(clock))
+		view := rl.View()
+
+		Expect(rl.LimitN(4)).To(BeFalse())
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(view.Name()).To(Equal("api"))
+		Expect(view.Tokens()).To(BeNumerically("~", 5, 0.001))
+		Expect(view.Rate()).To(BeNumerically("~", 10.0/60, 0.001))
+		Expect(view.Burst()).To(Equal(10))
+		Expect(view.Stats().Allowed).To(BeEquivalentTo(5))
+
+		clock.Advance(3 * time.Second)
+		Expect(view.Tokens()).To(BeNumerically("~", 5.5, 0.001))
+	})
+
+	It("should follow rate updates", func() {
+		rl := New(10, time.Second)
+		view := rl.View()
+		rl.UpdateRate(20)
+		Expect(view.Rate()).To(BeNumerically("~", 20, 0.001))
+		Expect(view.Burst()).To(Equal(20))
+	})
+})
@@ -0,0 +1,432 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"math/rand"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// queue represents a FIFO queue of goroutines blocked in Wait()
+type queue struct {
+	sync.Mutex
+	list     list.List
+	maxLen   int                           // maximum number of waiters, zero if unbounded
+	maxDelay time.Duration                 // maximum expected queueing delay, zero if unbounded
+	policy   DropPolicy                    // policy applied when the queue is full
+	timed    int                           // number of waiters with a deadline
+	closed   bool                          // whether new waiters are rejected
+	drained  chan struct{}                 // closed once the queue is empty, if requested
+	parked   atomic.Pointer[chan struct{}] // closed to wake up the sleeping head waiter
+	head     *list.Element                 // waiter competing for the tokens, if any
+	count    [priorities]int               // number of waiters per priority
+	weights  [priorities]int               // share of the turns of each priority, if set
+	turns    [priorities]int               // turns taken by each priority in the current round
+}
+
+// WithMaxWaiters sets the maximum number of goroutines which can be queued in Wait(),
+// beyond which Wait() fails fast with ErrQueueFull.
+func WithMaxWaiters(n int) Option {
+	return func(rl *Limiter) {
+		rl.extend().waiters.maxLen = n
+	}
+}
+
+// WithMaxDelay sets the maximum expected time a goroutine can be queued in Wait(), beyond
+// which Wait() fails fast with ErrQueueFull.
+func WithMaxDelay(d time.Duration) Option {
+	return func(rl *Limiter) {
+		rl.extend().waiters.maxDelay = d
+	}
+}
+
+// DropPolicy represents the policy applied to Wait() calls when the queue is full.
+type DropPolicy uint8
+
+// Various drop policies
+const (
+	DropTail   DropPolicy = iota // rejects the new waiter, this is the default
+	DropHead                     // evicts the oldest queued waiter to make room
+	DropRandom                   // evicts a randomly chosen queued waiter to make room
+)
+
+// WithDropPolicy sets the policy applied when Wait() is called while the queue is full.
+// The evicted waiters fail with ErrQueueFull.
+func WithDropPolicy(policy DropPolicy) Option {
+	return func(rl *Limiter) {
+		rl.extend().waiters.policy = policy
+	}
+}
+
+// waiter represents a single goroutine blocked in Wait()
+type waiter struct {
+	ready    chan struct{} // closed once the waiter reaches the head of the queue
+	evict    chan struct{} // closed if the waiter was evicted from the queue
+	err      error         // the reason of the eviction
+	deadline time.Time     // the deadline of the waiter, if any
+	queued   bool          // whether the waiter is still in the queue
+	timer    *time.Timer   // timer of the head waiter, reused across sleeps
+	wheel    *TimerWheel   // optional timing wheel used instead of the timer
+	alarm    *alarm        // alarm of the head waiter scheduled on the wheel
+	priority Priority      // priority of the waiter
+}
+
+// Wait blocks until a token is available or the context is done. Waiters are served in
+// the order they arrived: only the waiter at the head of the queue competes for a refilled
+// token, so a goroutine can not be starved by others which arrived after it. The others are
+// parked without consuming any CPU, while the head sleeps until the next token is due or
+// allowance is given back, so thousands of waiters cost no more than one. While blocked,
+// the goroutine carries a "limiter" pprof label with the limiter name, if any, along with
+// the labels of the context. A token granted once the context is done is refunded and
+// the context error returned. Wait() queues with PriorityHigh, see WaitPriority().
+func (rl *Limiter) Wait(ctx context.Context) error {
+	return rl.WaitPriority(ctx, PriorityHigh)
+}
+
+// wait blocks until a token is available or the context is done
+func (rl *Limiter) wait(ctx context.Context, priority Priority) error {
+	q := &rl.extend().waiters
+	q.Lock()
+	if q.closed {
+		q.Unlock()
+		return ErrClosed
+	}
+
+	if q.list.Len() == 0 && !rl.limit() {
+		q.Unlock()
+		return rl.granted(ctx)
+	}
+
+	// Make room or reject if the queue is full or we would be waiting for too long
+	for rl.full() {
+		if !q.drop(rl.limited(ErrQueueFull, q.list.Len())) {
+			err := rl.limited(ErrQueueFull, q.list.Len())
+			q.Unlock()
+			return err
+		}
+	}
+
+	// Fail fast if we would not be served before our deadline
+	deadline, timed := ctx.Deadline()
+	if timed && rl.estimate(q.list.Len()) > deadline.Sub(time.Unix(0, int64(rl.now()))) {
+		err := rl.limited(ErrDeadline, q.list.Len())
+		q.Unlock()
+		return err
+	}
+
+	// Join the queue, we are the head only if nobody else is waiting
+	w := &waiter{
+		ready:    make(chan struct{}),
+		evict:    make(chan struct{}),
+		deadline: deadline,
+		queued:   true,
+		wheel:    rl.ext().wheel,
+		priority: priority,
+	}
+	if timed {
+		q.timed++
+	}
+
+	elem := q.list.PushBack(w)
+	q.count[priority]++
+	if q.head == nil {
+		q.head = elem
+		close(w.ready)
+	}
+	q.Unlock()
+
+	// Label the blocked goroutine so it can be attributed in goroutine profiles, with
+	// the name of the limiter and the labels of the context, such as the key of Keyed
+	var labels []string
+	if name := rl.ext().name; name != "" {
+		labels = append(labels, "limiter", name)
+	}
+
+	labeled := len(labels) > 0
+	pprof.ForLabels(ctx, func(_, _ string) bool {
+		labeled = true
+		return false
+	})
+	if !labeled {
+		return rl.block(ctx, w, elem)
+	}
+
+	var err error
+	pprof.Do(ctx, pprof.Labels(labels...), func(ctx context.Context) {
+		err = rl.block(ctx, w, elem)
+	})
+	return err
+}
+
+// block blocks a queued waiter until a token is available or the context is done
+func (rl *Limiter) block(ctx context.Context, w *waiter, elem *list.Element) error {
+	select {
+	case <-w.ready:
+	case <-w.evict:
+		return w.err
+	case <-ctx.Done():
+		rl.dequeue(elem)
+		return ctx.Err()
+	}
+
+	// We are at the head of the queue, wait until a token becomes available. We park
+	// before checking so that allowance given back in the meantime still wakes us up.
+	defer rl.dequeue(elem)
+	defer w.stop()
+	for {
+		wake := rl.extend().waiters.park()
+		if !rl.limit() {
+			return rl.granted(ctx)
+		}
+
+		if err := w.sleep(ctx, rl.delay(), wake); err != nil {
+			return err
+		}
+	}
+}
+
+// granted is called once a waiter was granted a token. If its context is done by then,
+// the caller would not proceed with the token so it is refunded right away, as Undo()
+// can not be placed correctly by the caller in that race.
+func (rl *Limiter) granted(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		rl.refund(1)
+		return err
+	}
+	return nil
+}
+
+// sleep blocks for the specified duration, until woken up, until the context is done or
+// the waiter is evicted from the queue
+func (w *waiter) sleep(ctx context.Context, d time.Duration, wake <-chan struct{}) error {
+	var expired <-chan time.Time
+	var fired <-chan struct{}
+	switch {
+	case w.wheel != nil:
+		w.alarm = w.wheel.schedule(d)
+		fired = w.alarm.C
+	case w.timer == nil:
+		w.timer = time.NewTimer(d)
+		expired = w.timer.C
+	default:
+		w.timer.Reset(d)
+		expired = w.timer.C
+	}
+
+	select {
+	case <-expired:
+		return nil
+	case <-fired:
+		return nil
+	case <-wake:
+		w.stop()
+		return nil
+	case <-w.evict:
+		return w.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stop stops the timer or the alarm of the waiter, draining the timer if it already fired
+// so it can be reset
+func (w *waiter) stop() {
+	if w.alarm != nil {
+		w.wheel.stop(w.alarm)
+		w.alarm = nil
+	}
+
+	if w.timer != nil && !w.timer.Stop() {
+		select {
+		case <-w.timer.C:
+		default:
+		}
+	}
+}
+
+// park returns a channel which is closed on the next call to wake()
+func (q *queue) park() <-chan struct{} {
+	wake := make(chan struct{})
+	q.parked.Store(&wake)
+	return wake
+}
+
+// wake wakes up the head waiter of the limiter, if any, to compete for the allowance
+func (rl *Limiter) wake() {
+	if e := rl.optional.Load(); e != nil {
+		e.waiters.wake()
+	}
+}
+
+// wake wakes up the head waiter, if it is sleeping, so that it checks the allowance again
+func (q *queue) wake() {
+	if wake := q.parked.Swap(nil); wake != nil {
+		close(*wake)
+	}
+}
+
+// dequeue removes the waiter from the queue and hands the head over to the next one
+func (rl *Limiter) dequeue(elem *list.Element) {
+	q := &rl.extend().waiters
+	q.Lock()
+	defer q.Unlock()
+
+	if q.remove(elem) {
+		rl.prune()
+	}
+}
+
+// remove removes the waiter from the queue and hands the head over to the next one,
+// returning whether the head has changed. It must be called while holding the queue lock.
+func (q *queue) remove(elem *list.Element) bool {
+	w := elem.Value.(*waiter)
+	if !w.queued {
+		return false
+	}
+
+	w.queued = false
+	if !w.deadline.IsZero() {
+		q.timed--
+	}
+
+	head := q.head == elem
+	q.list.Remove(elem)
+	q.count[w.priority]--
+	if q.list.Len() == 0 && q.drained != nil {
+		close(q.drained)
+		q.drained = nil
+	}
+
+	if !head {
+		return false
+	}
+
+	if q.head = q.next(); q.head != nil {
+		close(q.head.Value.(*waiter).ready)
+	}
+	return true
+}
+
+// evict removes the waiter from the queue and wakes it up with an error, must be called
+// while holding the queue lock
+func (q *queue) evict(elem *list.Element, err error) {
+	w := elem.Value.(*waiter)
+	q.remove(elem)
+	w.err = err
+	close(w.evict)
+}
+
+// prune evicts the waiters which would not be served before their deadline, must be
+// called while holding the queue lock
+func (rl *Limiter) prune() {
+	q := &rl.extend().waiters
+	if q.timed == 0 {
+		return
+	}
+
+	now, i := time.Unix(0, int64(rl.now())), 0
+	for elem := q.list.Front(); elem != nil; {
+		next := elem.Next()
+		if w := elem.Value.(*waiter); !w.deadline.IsZero() && rl.estimate(i) > w.deadline.Sub(now) {
+			q.evict(elem, rl.limited(ErrDeadline, i))
+		} else {
+			i++
+		}
+		elem = next
+	}
+}
+
+// drop evicts a waiter with the error according to the drop policy and returns whether
+// one was evicted, must be called while holding the queue lock
+func (q *queue) drop(err error) bool {
+	if q.list.Len() == 0 {
+		return false
+	}
+
+	var victim *list.Element
+	switch q.policy {
+	case DropHead:
+		victim = q.list.Front()
+	case DropRandom:
+		victim = q.list.Front()
+		for i := rand.Intn(q.list.Len()); i > 0; i-- {
+			victim = victim.Next()
+		}
+	default:
+		return false
+	}
+
+	q.evict(victim, err)
+	return true
+}
+
+// full returns whether a new waiter would exceed the queue bounds, must be called
+// while holding the queue lock
+func (rl *Limiter) full() bool {
+	q := &rl.extend().waiters
+	switch {
+	case q.maxLen > 0 && q.list.Len() >= q.maxLen:
+		return true
+	case q.maxDelay > 0 && rl.estimate(q.list.Len()) > q.maxDelay:
+		return true
+	default:
+		return false
+	}
+}
+
+// estimate returns the expected time until a waiter queued behind n others is served
+func (rl *Limiter) estimate(n int) time.Duration {
+	rate, _ := rl.limits(rl.now())
+	if rate == 0 {
+		return time.Duration(math.MaxInt64)
+	}
+
+	interval := time.Duration(rl.unit / rate)
+	return rl.delay() + time.Duration(n)*interval
+}
+
+// delay returns the time until the next token becomes available, taking into account the
+// reserved rate, any penalty, the debt to repay first and the parent of a child limiter
+func (rl *Limiter) delay() time.Duration {
+	d := rl.pause()
+	if p := rl.ext().parent; p != nil {
+		if wait := p.delay(); wait > d {
+			d = wait // a child also waits for its parent
+		}
+	}
+	return d
+}
+
+// pause returns the time until the next token becomes available, ignoring the parent
+func (rl *Limiter) pause() time.Duration {
+	now := rl.now()
+	rate, _, blocked := rl.effective(now)
+	switch {
+	case blocked:
+		return time.Duration(rl.ext().penalty.until.Load() - now)
+	case rate == 0:
+		return time.Duration(rl.unit)
+	}
+
+	var elapsed uint64
+	if last := rl.lastCheck.Load(); now > last {
+		elapsed = now - last
+	}
+
+	current := rl.allowance.Load() + elapsed*rate
+	needed := rl.unit + rl.headroom() + rl.debt.Load()
+	if current >= needed {
+		return 0
+	}
+
+	return time.Duration((needed - current + rate - 1) / rate)
+}
@@ -0,0 +1,365 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Wait", func() {
+
+	It("should not block when allowance is available", func() {
+		rl := New(5, time.Minute)
+		for i := 0; i < 5; i++ {
+			Expect(rl.Wait(context.Background())).To(Succeed())
+		}
+		Expect(rl.Limit()).To(BeTrue())
+	})
+
+	It("should block until a token is available", func() {
+		rl := New(1, 20*time.Millisecond)
+		Expect(rl.Limit()).To(BeFalse())
+
+		start := time.Now()
+		Expect(rl.Wait(context.Background())).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically("~", 20*time.Millisecond, 10*time.Millisecond))
+	})
+
+	It("should serve waiters in order", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(1, time.Millisecond, WithClock(clock))
+		Expect(rl.Limit()).To(BeFalse())
+
+		var mu sync.Mutex
+		var order []int
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(rl.Wait(context.Background())).To(Succeed())
+
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+			}(i)
+
+			Eventually(func() int { return waiting(rl) }).Should(Equal(i + 1))
+		}
+
+		for i := 4; i >= 0; i-- {
+			clock.Advance(time.Millisecond)
+			Eventually(func() int { return waiting(rl) }).Should(Equal(i))
+		}
+
+		wg.Wait()
+		Expect(order).To(Equal([]int{0, 1, 2, 3, 4}))
+	})
+
+	It("should hand over the queue when cancelled", func() {
+		rl := New(1, 50*time.Millisecond)
+		Expect(rl.Limit()).To(BeFalse())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- rl.Wait(ctx)
+		}()
+
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+		time.AfterFunc(5*time.Millisecond, cancel)
+		Expect(rl.Wait(context.Background())).To(Succeed())
+		Expect(<-done).To(Equal(context.Canceled))
+		Expect(waiting(rl)).To(BeZero())
+	})
+
+	It("should reject when too many are waiting", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(1, time.Second, WithClock(clock), WithMaxWaiters(2))
+		Expect(rl.Limit()).To(BeFalse())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		for i := 0; i < 2; i++ {
+			go rl.Wait(ctx)
+			Eventually(func() int { return waiting(rl) }).Should(Equal(i + 1))
+		}
+
+		Expect(reason(rl.Wait(ctx))).To(Equal(ErrQueueFull))
+		Expect(rl.Stats().Denied).To(BeEquivalentTo(1))
+		cancel()
+		Eventually(func() int { return waiting(rl) }).Should(BeZero())
+	})
+
+	It("should not count cancelled or closed waits as denied", func() {
+		rl := New(1, time.Hour)
+		Expect(rl.Limit()).To(BeFalse())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(rl.Wait(ctx)).To(Equal(context.Canceled))
+		Expect(rl.Close()).To(Succeed())
+		Expect(rl.Wait(context.Background())).To(Equal(ErrClosed))
+		Expect(rl.Stats()).To(Equal(Stats{Allowed: 1, Waits: rl.Stats().Waits}))
+	})
+
+	It("should reject when the delay would be too long", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(10, time.Second, WithClock(clock), WithMaxDelay(250*time.Millisecond))
+		for i := 0; i < 10; i++ {
+			Expect(rl.Limit()).To(BeFalse())
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		for i := 0; i < 2; i++ {
+			go rl.Wait(ctx)
+			Eventually(func() int { return waiting(rl) }).Should(Equal(i + 1))
+		}
+
+		Expect(rl.estimate(2)).To(Equal(300 * time.Millisecond))
+		Expect(reason(rl.Wait(ctx))).To(Equal(ErrQueueFull))
+	})
+
+	It("should evict the oldest waiter with head-drop", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(1, time.Millisecond, WithClock(clock), WithMaxWaiters(2), WithDropPolicy(DropHead))
+		Expect(rl.Limit()).To(BeFalse())
+
+		errs := make([]chan error, 3)
+		for i := range errs {
+			errs[i] = make(chan error, 1)
+			go func(i int) {
+				errs[i] <- rl.Wait(context.Background())
+			}(i)
+
+			if i < 2 {
+				Eventually(func() int { return waiting(rl) }).Should(Equal(i + 1))
+			}
+		}
+
+		Eventually(errs[0]).Should(Receive(WithTransform(reason, Equal(ErrQueueFull))))
+		Expect(waiting(rl)).To(Equal(2))
+		clock.Advance(time.Millisecond)
+		Eventually(errs[1]).Should(Receive(BeNil()))
+		clock.Advance(time.Millisecond)
+		Eventually(errs[2]).Should(Receive(BeNil()))
+	})
+
+	It("should evict a random waiter with random-drop", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(1, time.Millisecond, WithClock(clock), WithMaxWaiters(3), WithDropPolicy(DropRandom))
+		Expect(rl.Limit()).To(BeFalse())
+
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			go func() {
+				errs <- rl.Wait(context.Background())
+			}()
+		}
+
+		for i := 0; i < 7; i++ {
+			Eventually(errs).Should(Receive(WithTransform(reason, Equal(ErrQueueFull))))
+		}
+
+		Expect(waiting(rl)).To(Equal(3))
+		for i := 0; i < 3; i++ {
+			clock.Advance(time.Millisecond)
+			Eventually(errs).Should(Receive(BeNil()))
+		}
+	})
+
+	It("should fail fast if the deadline would be exceeded", func() {
+		rl := New(1, time.Second)
+		Expect(rl.Limit()).To(BeFalse())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		Expect(reason(rl.Wait(ctx))).To(Equal(ErrDeadline))
+		Expect(waiting(rl)).To(BeZero())
+	})
+
+	It("should prune waiters which would miss their deadline", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(1, 10*time.Millisecond, WithClock(clock))
+		Expect(rl.Limit()).To(BeFalse())
+
+		ctx1, cancel1 := context.WithCancel(context.Background())
+		ctx2, cancel2 := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel1()
+		defer cancel2()
+
+		errs := make(chan error, 2)
+		for i, ctx := range []context.Context{ctx1, ctx2} {
+			go func(ctx context.Context) {
+				errs <- rl.Wait(ctx)
+			}(ctx)
+			Eventually(func() int { return waiting(rl) }).Should(Equal(i + 1))
+		}
+
+		rl.UpdateRate(0)
+		cancel1()
+		Eventually(errs).Should(Receive(Equal(context.Canceled)))
+		Eventually(errs).Should(Receive(WithTransform(reason, Equal(ErrDeadline))))
+		Expect(waiting(rl)).To(BeZero())
+		Expect(rl.extend().waiters.timed).To(BeZero())
+	})
+
+	It("should label blocked goroutines", func() {
+		rl := New(1, time.Minute, WithName("api"))
+		Expect(rl.Name()).To(Equal("api"))
+		Expect(rl.Limit()).To(BeFalse())
+
+		ctx, cancel := context.WithCancel(pprof.WithLabels(context.Background(), pprof.Labels("key", "alice")))
+		defer cancel()
+		go rl.Wait(ctx)
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+
+		var buffer bytes.Buffer
+		Expect(pprof.Lookup("goroutine").WriteTo(&buffer, 1)).To(Succeed())
+		Expect(buffer.String()).To(ContainSubstring(`"limiter":"api"`))
+		Expect(buffer.String()).To(ContainSubstring(`"key":"alice"`))
+	})
+
+	It("should wake up the head waiter when allowance is given back", func() {
+		rl := New(1, time.Hour)
+		Expect(rl.Limit()).To(BeFalse())
+
+		done := make(chan error, 1)
+		go func() { done <- rl.Wait(context.Background()) }()
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+
+		rl.Undo()
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	It("should wake up the head waiter when the rate is raised", func() {
+		rl := New(1, time.Hour)
+		Expect(rl.Limit()).To(BeFalse())
+
+		done := make(chan error, 1)
+		go func() { done <- rl.Wait(context.Background()) }()
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+
+		rl.UpdateRate(3600000)
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	It("should sleep until the debt is repaid", func() {
+		rl := New(1, 10*time.Millisecond)
+		rl.ReportCost(0, 3)
+		Expect(rl.Debt()).To(Equal(2))
+		Expect(rl.delay()).To(BeNumerically(">", 20*time.Millisecond))
+	})
+
+	It("should sleep until the penalty expires", func() {
+		clock := NewManualClock(time.Now())
+		rl := New(1, time.Second, WithClock(clock), WithPenalty(1, time.Minute, time.Minute, 0))
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.Limit()).To(BeTrue())
+		Expect(rl.Penalized()).To(BeTrue())
+		Expect(rl.delay()).To(Equal(time.Minute))
+	})
+
+	It("should refund the token when the context is done once granted", func() {
+		rl := New(1, time.Hour)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		Expect(rl.Wait(ctx)).To(Equal(context.Canceled))
+		Expect(rl.Remaining()).To(Equal(1))
+	})
+
+	It("should refund the token of a queued waiter cancelled once granted", func() {
+		rl := New(1, time.Hour)
+		Expect(rl.Limit()).To(BeFalse())
+
+		ctx := &lateContext{Context: context.Background()}
+		done := make(chan error, 1)
+		go func() { done <- rl.Wait(ctx) }()
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+
+		ctx.cancelled.Store(true)
+		rl.Undo()
+		Eventually(done).Should(Receive(Equal(context.Canceled)))
+		Expect(rl.Remaining()).To(Equal(1))
+	})
+
+})
+
+// lateContext is a context which is cancelled without ever closing its done channel, as
+// when the cancellation races with the grant of a token
+type lateContext struct {
+	context.Context
+	cancelled atomic.Bool
+}
+
+func (c *lateContext) Err() error {
+	if c.cancelled.Load() {
+		return context.Canceled
+	}
+	return nil
+}
+
+// waiting returns the number of goroutines queued in Wait()
+func waiting(rl *Limiter) int {
+	q := &rl.extend().waiters
+	q.Lock()
+	defer q.Unlock()
+	return q.list.Len()
+}
+
+// reason returns the reason of a rejected call
+func reason(err error) error {
+	if limited, ok := err.(*LimitedError); ok {
+		return limited.Err
+	}
+	return err
+}
+
+// --------------------------------------------------------------------
+
+func BenchmarkWait(b *testing.B) {
+	rl := New(1000000000, time.Second)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rl.Wait(ctx)
+	}
+}
+
+func BenchmarkWait10kWaiters(b *testing.B) {
+	const waiters = 10000
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rl := New(waiters, 50*time.Millisecond)
+		rl.LimitN(waiters)
+
+		var wg sync.WaitGroup
+		wg.Add(waiters)
+		for j := 0; j < waiters; j++ {
+			go func() {
+				defer wg.Done()
+				rl.Wait(ctx)
+			}()
+		}
+		wg.Wait()
+	}
+}
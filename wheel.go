@@ -0,0 +1,142 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+// TimerWheel schedules the wake-ups of waiters on a hashed timing wheel driven by a single
+// runtime ticker, instead of one runtime timer per waiter. Sharing a wheel between many
+// limiters, such as every key of a keyed limiter, reduces allocations and timer churn on
+// servers with tens of thousands of simultaneous Wait() calls, in exchange for wake-ups
+// being rounded up to the next tick. TimerWheel instances are thread-safe.
+type TimerWheel struct {
+	lock    sync.Mutex
+	tick    time.Duration // resolution of the wheel
+	slots   [][]*alarm    // alarms hashed by the tick on which they fire
+	cursor  int           // slot of the current tick
+	pending int           // number of alarms scheduled and not stopped
+	running bool          // whether the ticking goroutine is running
+}
+
+// alarm represents a single wake-up scheduled on the wheel
+type alarm struct {
+	C      chan struct{} // closed when the alarm fires
+	rounds int           // number of full turns of the wheel left before firing
+	done   bool          // whether the alarm fired or was stopped
+}
+
+// NewTimerWheel creates a new timing wheel with the specified resolution and number of
+// slots. Delays longer than a full turn of the wheel take several turns to fire.
+func NewTimerWheel(tick time.Duration, slots int) *TimerWheel {
+	if tick <= 0 {
+		tick = time.Millisecond
+	}
+	if slots < 1 {
+		slots = 1
+	}
+
+	return &TimerWheel{
+		tick:  tick,
+		slots: make([][]*alarm, slots),
+	}
+}
+
+// WithTimerWheel schedules the wake-ups of the goroutines blocked in Wait() on the timing
+// wheel, which is typically shared by many limiters.
+func WithTimerWheel(w *TimerWheel) Option {
+	return func(rl *Limiter) {
+		rl.extend().wheel = w
+	}
+}
+
+// schedule returns an alarm which fires once the delay has elapsed, rounded up to the
+// next tick. An extra tick is added since the current one is already partly elapsed.
+func (w *TimerWheel) schedule(d time.Duration) *alarm {
+	if d < 0 {
+		d = 0
+	}
+	ticks := int((d+w.tick-1)/w.tick) + 1
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	a := &alarm{
+		C:      make(chan struct{}),
+		rounds: (ticks - 1) / len(w.slots),
+	}
+
+	slot := (w.cursor + ticks) % len(w.slots)
+	w.slots[slot] = append(w.slots[slot], a)
+	if w.pending++; !w.running {
+		w.running = true
+		go w.run()
+	}
+	return a
+}
+
+// stop prevents the alarm from firing, if it has not fired yet. The alarm is removed
+// from its slot the next time the wheel reaches it.
+func (w *TimerWheel) stop(a *alarm) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !a.done {
+		a.done = true
+		w.pending--
+	}
+}
+
+// run advances the wheel at every tick until no alarm is pending
+func (w *TimerWheel) run() {
+	next := time.Now().Add(w.tick)
+	ticker := time.NewTicker(w.tick)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		w.lock.Lock()
+
+		// Catch up on the ticks which were missed if we lagged behind
+		for ; !now.Before(next); next = next.Add(w.tick) {
+			w.advance()
+		}
+
+		if w.pending == 0 {
+			w.running = false
+			w.lock.Unlock()
+			return
+		}
+		w.lock.Unlock()
+	}
+}
+
+// advance moves the wheel by a single tick and fires the alarms which are due, must be
+// called while holding the lock
+func (w *TimerWheel) advance() {
+	w.cursor = (w.cursor + 1) % len(w.slots)
+
+	alarms := w.slots[w.cursor][:0]
+	for _, a := range w.slots[w.cursor] {
+		switch {
+		case a.done:
+		case a.rounds > 0:
+			a.rounds--
+			alarms = append(alarms, a)
+		default:
+			a.done = true
+			w.pending--
+			close(a.C)
+		}
+	}
+
+	// Clear the tail so that removed alarms can be collected
+	for i := len(alarms); i < len(w.slots[w.cursor]); i++ {
+		w.slots[w.cursor][i] = nil
+	}
+	w.slots[w.cursor] = alarms
+}
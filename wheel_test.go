@@ -0,0 +1,109 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TimerWheel", func() {
+
+	It("should fire alarms once their delay elapsed", func() {
+		w := NewTimerWheel(time.Millisecond, 8)
+
+		start := time.Now()
+		a := w.schedule(5 * time.Millisecond)
+		Eventually(a.C).Should(BeClosed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 5*time.Millisecond))
+	})
+
+	It("should fire alarms longer than a turn of the wheel", func() {
+		w := NewTimerWheel(time.Millisecond, 4)
+
+		start := time.Now()
+		a := w.schedule(15 * time.Millisecond)
+		Eventually(a.C).Should(BeClosed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 15*time.Millisecond))
+	})
+
+	It("should not fire stopped alarms", func() {
+		w := NewTimerWheel(time.Millisecond, 8)
+		a := w.schedule(2 * time.Millisecond)
+		w.stop(a)
+
+		Consistently(a.C, 20*time.Millisecond).ShouldNot(BeClosed())
+		Eventually(func() bool {
+			w.lock.Lock()
+			defer w.lock.Unlock()
+			return w.running
+		}).Should(BeFalse())
+	})
+
+	It("should wake up waiters", func() {
+		rl := New(1, 20*time.Millisecond, WithTimerWheel(NewTimerWheel(time.Millisecond, 64)))
+		Expect(rl.Limit()).To(BeFalse())
+
+		start := time.Now()
+		Expect(rl.Wait(context.Background())).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically("~", 20*time.Millisecond, 10*time.Millisecond))
+	})
+
+	It("should stop the alarm of a cancelled waiter", func() {
+		w := NewTimerWheel(time.Millisecond, 64)
+		rl := New(1, time.Hour, WithTimerWheel(w))
+		Expect(rl.Limit()).To(BeFalse())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- rl.Wait(ctx) }()
+		Eventually(func() int { return waiting(rl) }).Should(Equal(1))
+
+		cancel()
+		Eventually(done).Should(Receive(Equal(context.Canceled)))
+
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		Expect(w.pending).To(BeZero())
+	})
+})
+
+// --------------------------------------------------------------------
+
+func BenchmarkWheel10kWaiters(b *testing.B) {
+	b.Run("timers", func(b *testing.B) {
+		benchmarkWaiters(b)
+	})
+	b.Run("wheel", func(b *testing.B) {
+		benchmarkWaiters(b, WithTimerWheel(NewTimerWheel(time.Millisecond, 256)))
+	})
+}
+
+// benchmarkWaiters blocks a single waiter on each of 10k limiters
+func benchmarkWaiters(b *testing.B, options ...Option) {
+	const waiters = 10000
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		wg.Add(waiters)
+		for j := 0; j < waiters; j++ {
+			rl := New(1, 20*time.Millisecond, options...)
+			rl.Limit()
+			go func() {
+				defer wg.Done()
+				rl.Wait(ctx)
+			}()
+		}
+		wg.Wait()
+	}
+}
@@ -0,0 +1,92 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy represents a rate enforced over a window, such as 300 calls per minute.
+type Policy struct {
+	Rate int           // Number of operations allowed per window
+	Per  time.Duration // Duration of the window
+}
+
+// Windowed is a limiter which enforces several windows at once, such as 10 calls per
+// second, 300 per minute and 5000 per hour. A call is only admitted if every window
+// allows it, in which case it is consumed from all of them. Windowed instances are
+// thread-safe.
+type Windowed struct {
+	lock     sync.Mutex
+	policies []Policy
+	windows  []*Limiter
+}
+
+// NewWindowed creates a new limiter enforcing all of the policies. The options are
+// applied to the limiter of every window.
+func NewWindowed(policies []Policy, options ...Option) *Windowed {
+	w := &Windowed{
+		policies: append([]Policy(nil), policies...),
+		windows:  make([]*Limiter, 0, len(policies)),
+	}
+
+	for _, p := range policies {
+		w.windows = append(w.windows, New(p.Rate, p.Per, options...))
+	}
+	return w
+}
+
+// Limit returns true if any of the windows was exceeded.
+func (w *Windowed) Limit() bool {
+	_, limited := w.Attempt(1)
+	return limited
+}
+
+// LimitN returns true if any of the windows would be exceeded by n calls at once,
+// otherwise it consumes n tokens from every window.
+func (w *Windowed) LimitN(n int) bool {
+	_, limited := w.Attempt(n)
+	return limited
+}
+
+// Attempt consumes n tokens from every window if they all allow it. Otherwise nothing is
+// consumed and the policy of the first window exceeded is returned along with true.
+func (w *Windowed) Attempt(n int) (exceeded Policy, limited bool) {
+	if n < 1 {
+		return
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for i, rl := range w.windows {
+		if rl.PeekN(n) {
+			rl.record(true, uint64(n))
+			return w.policies[i], true
+		}
+	}
+
+	for _, rl := range w.windows {
+		rl.LimitN(n)
+	}
+	return
+}
+
+// Undo reverts the last admitted call on every window.
+func (w *Windowed) Undo() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, rl := range w.windows {
+		rl.Undo()
+	}
+}
+
+// Policies returns the policies enforced by the limiter.
+func (w *Windowed) Policies() []Policy {
+	return append([]Policy(nil), w.policies...)
+}
@@ -0,0 +1,47 @@
+// Simple, thread-safe Go rate-limiter. This is a fork of https://github.com/bsm/ratelimit
+// Inspired by Antti Huima's algorithm on http://stackoverflow.com/a/668327
+// Changes Copyright (c) 2019 Misakai Limited
+// Original Copyright (c) 2017 Black Square Media
+
+package rate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Windowed", func() {
+
+	second := Policy{Rate: 2, Per: time.Second}
+	minute := Policy{Rate: 3, Per: time.Minute}
+
+	It("should enforce every window", func() {
+		clock := NewManualClock(time.Unix(0, 0))
+		rl := NewWindowed([]Policy{second, minute}, WithClock(clock))
+		Expect(rl.Policies()).To(Equal([]Policy{second, minute}))
+		Expect(rl.LimitN(2)).To(BeFalse())
+
+		exceeded, limited := rl.Attempt(1)
+		Expect(limited).To(BeTrue())
+		Expect(exceeded).To(Equal(second))
+
+		clock.Advance(time.Second)
+		Expect(rl.Limit()).To(BeFalse())
+		exceeded, limited = rl.Attempt(1)
+		Expect(limited).To(BeTrue())
+		Expect(exceeded).To(Equal(minute))
+	})
+
+	It("should not consume from any window when denied", func() {
+		rl := NewWindowed([]Policy{second, minute})
+		Expect(rl.LimitN(3)).To(BeTrue())
+		Expect(rl.LimitN(2)).To(BeFalse())
+
+		rl.Undo()
+		Expect(rl.Limit()).To(BeFalse())
+		Expect(rl.LimitN(0)).To(BeFalse())
+	})
+
+})